@@ -0,0 +1,230 @@
+package common
+
+import (
+	"dddd/ddout"
+	"dddd/structs"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"strings"
+)
+
+// HostDiscovery 按 structs.GlobalAliveProfile 依次进行 ARP、ICMP、TCP 探测，
+// 前一种方式已存活的IP不再进行后续探测，返回存活的IP。未存活的IP仍由调用方决定是否扫描
+func HostDiscovery(ips []string) []string {
+	profile := structs.GlobalAliveProfile
+	aliveMap := make(map[string]struct{})
+	var alive []string
+	addAlive := func(hosts []string) {
+		for _, ip := range hosts {
+			if _, ok := aliveMap[ip]; !ok {
+				aliveMap[ip] = struct{}{}
+				alive = append(alive, ip)
+			}
+		}
+	}
+	uncheck := func() []string {
+		var r []string
+		for _, ip := range ips {
+			if _, ok := aliveMap[ip]; !ok {
+				r = append(r, ip)
+			}
+		}
+		return r
+	}
+
+	// 直连网段 ARP 探测存活
+	if profile.ARP {
+		addAlive(CheckLiveARP(ips))
+	}
+
+	// ICMP 探测存活
+	if profile.ICMP {
+		if hosts := uncheck(); len(hosts) > 0 {
+			addAlive(CheckLive(hosts, false))
+		}
+	}
+
+	// TCP 探测存活
+	if profile.TCP != "" {
+		if hosts := uncheck(); len(hosts) > 0 {
+			gologger.Info().Msg("TCP存活探测")
+			PortScan = false
+			tcpAliveIPPort := PortScanTCP(hosts, profile.TCP,
+				structs.GlobalConfig.NoPortString,
+				structs.GlobalConfig.TCPPortScanTimeout)
+			var tcpAlive []string
+			for _, tIPPort := range tcpAliveIPPort {
+				t := strings.Split(tIPPort, ":")
+				tcpAlive = append(tcpAlive, t[0])
+			}
+			addAlive(tcpAlive)
+		}
+	}
+
+	return alive
+}
+
+// 每个C段优先探测的主机位，网关、交换机管理地址等常用地址
+var subnetSampleOctets = map[byte]bool{1: true, 254: true, 2: true, 253: true, 126: true, 129: true, 10: true, 100: true, 200: true}
+
+// 不超过该数量的C段直接完整探测
+const subnetSampleFullSize = 16
+
+type subnetStat struct {
+	CIDR    string
+	Targets []string
+	Sampled []string
+}
+
+// aliveCount 统计C段内已存活的地址数
+func (s *subnetStat) aliveCount(aliveMap map[string]struct{}) int {
+	n := 0
+	for _, host := range s.Targets {
+		if _, ok := aliveMap[host]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+// subnetStatMessages 每个C段的抽样统计，无存活的C段同样输出
+func subnetStatMessages(cidrs []string, subnets map[string]*subnetStat, aliveMap map[string]struct{}) []ddout.OutputMessage {
+	var messages []ddout.OutputMessage
+	for _, cidr := range cidrs {
+		s := subnets[cidr]
+		messages = append(messages, ddout.OutputMessage{
+			Type: "SubnetStat",
+			IP:   cidr,
+			Stats: map[string]int{
+				"targets": len(s.Targets),
+				"sampled": len(s.Sampled),
+				"alive":   s.aliveCount(aliveMap),
+			},
+		})
+	}
+	return messages
+}
+
+// subnetSampleTargets 端口扫描目标：存活C段内的所有地址(与不抽样时一致，不只是响应存活探测的地址)，
+// 以及不参与抽样的非IPv4目标，保持输入顺序
+func subnetSampleTargets(ips []string, liveCIDRs map[string]bool) []string {
+	var targets []string
+	for _, host := range ips {
+		ip := net.ParseIP(host).To4()
+		if ip == nil || liveCIDRs[fmt.Sprintf("%d.%d.%d.0/24", ip[0], ip[1], ip[2])] {
+			targets = append(targets, host)
+		}
+	}
+	return targets
+}
+
+// subnetSeenHosts 之前已经发现过的主机(ARP/被动发现/域名解析/端口扫描)，抽样时一并探测
+func subnetSeenHosts() map[string]struct{} {
+	seen := make(map[string]struct{})
+	structs.GlobalHostMACMapLock.Lock()
+	for ip := range structs.GlobalHostMACMap {
+		seen[ip] = struct{}{}
+	}
+	structs.GlobalHostMACMapLock.Unlock()
+
+	structs.GlobalIPDomainMapLock.Lock()
+	for ip := range structs.GlobalIPDomainMap {
+		seen[ip] = struct{}{}
+	}
+	structs.GlobalIPDomainMapLock.Unlock()
+
+	structs.GlobalIPPortMapLock.Lock()
+	for hostPort := range structs.GlobalIPPortMap {
+		seen[strings.Split(hostPort, ":")[0]] = struct{}{}
+	}
+	structs.GlobalIPPortMapLock.Unlock()
+
+	passiveHostsLock.Lock()
+	for ip := range passiveHosts {
+		seen[ip] = struct{}{}
+	}
+	passiveHostsLock.Unlock()
+	return seen
+}
+
+// SubnetSampleDiscovery C段抽样探活。先探测每个/24中少量可能存活的地址，
+// 只有抽样地址存在存活的C段才进行完整探测，返回这些C段内的全部目标，用于/8等大网段。
+func SubnetSampleDiscovery(ips []string) []string {
+	seen := subnetSeenHosts()
+	subnets := make(map[string]*subnetStat)
+	var cidrs []string
+	var others []string
+	for _, host := range ips {
+		ip := net.ParseIP(host).To4()
+		if ip == nil {
+			others = append(others, host)
+			continue
+		}
+		cidr := fmt.Sprintf("%d.%d.%d.0/24", ip[0], ip[1], ip[2])
+		s, ok := subnets[cidr]
+		if !ok {
+			s = &subnetStat{CIDR: cidr}
+			subnets[cidr] = s
+			cidrs = append(cidrs, cidr)
+		}
+		s.Targets = append(s.Targets, host)
+	}
+
+	var samples []string
+	for _, cidr := range cidrs {
+		s := subnets[cidr]
+		for _, host := range s.Targets {
+			_, isSeen := seen[host]
+			if len(s.Targets) <= subnetSampleFullSize || isSeen || subnetSampleOctets[net.ParseIP(host).To4()[3]] {
+				s.Sampled = append(s.Sampled, host)
+			}
+		}
+		samples = append(samples, s.Sampled...)
+	}
+	gologger.Info().Msgf("C段抽样探活，%d 个C段，抽样地址: %d", len(cidrs), len(samples))
+	// 非IPv4目标不参与抽样
+	samples = append(samples, others...)
+	alive := HostDiscovery(samples)
+
+	aliveMap := make(map[string]struct{}, len(alive))
+	for _, ip := range alive {
+		aliveMap[ip] = struct{}{}
+	}
+
+	liveCIDRs := make(map[string]bool)
+	var deadCIDRs []string
+	var sweep []string
+	for _, cidr := range cidrs {
+		s := subnets[cidr]
+		if s.aliveCount(aliveMap) == 0 {
+			deadCIDRs = append(deadCIDRs, cidr)
+			continue
+		}
+		liveCIDRs[cidr] = true
+		sampled := make(map[string]struct{}, len(s.Sampled))
+		for _, host := range s.Sampled {
+			sampled[host] = struct{}{}
+		}
+		for _, host := range s.Targets {
+			if _, ok := sampled[host]; !ok {
+				sweep = append(sweep, host)
+			}
+		}
+	}
+	gologger.Info().Msgf("C段抽样结束，存活C段: %d，跳过C段: %d，完整探测地址: %d", len(liveCIDRs), len(deadCIDRs), len(sweep))
+	gologger.AuditTimeLogger("C段抽样无存活，跳过: %s", strings.Join(deadCIDRs, ","))
+
+	// 完整探测只用于存活输出与C段统计，存活C段内的目标都会进行端口扫描
+	if len(sweep) > 0 {
+		for _, ip := range HostDiscovery(sweep) {
+			aliveMap[ip] = struct{}{}
+		}
+	}
+
+	for _, m := range subnetStatMessages(cidrs, subnets, aliveMap) {
+		ddout.FormatOutput(m)
+	}
+
+	return subnetSampleTargets(ips, liveCIDRs)
+}
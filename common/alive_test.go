@@ -0,0 +1,66 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubnetStatMessages(t *testing.T) {
+	subnets := map[string]*subnetStat{
+		"10.0.1.0/24": {CIDR: "10.0.1.0/24", Targets: []string{"10.0.1.1", "10.0.1.2", "10.0.1.3"}, Sampled: []string{"10.0.1.1"}},
+		"10.0.2.0/24": {CIDR: "10.0.2.0/24", Targets: []string{"10.0.2.1", "10.0.2.254"}, Sampled: []string{"10.0.2.1", "10.0.2.254"}},
+	}
+	tests := []struct {
+		name  string
+		alive []string
+		want  map[string]map[string]int
+	}{
+		{"all-dead", nil, map[string]map[string]int{
+			"10.0.1.0/24": {"targets": 3, "sampled": 1, "alive": 0},
+			"10.0.2.0/24": {"targets": 2, "sampled": 2, "alive": 0},
+		}},
+		{"one-live", []string{"10.0.1.1", "10.0.1.3"}, map[string]map[string]int{
+			"10.0.1.0/24": {"targets": 3, "sampled": 1, "alive": 2},
+			"10.0.2.0/24": {"targets": 2, "sampled": 2, "alive": 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aliveMap := make(map[string]struct{})
+			for _, ip := range tt.alive {
+				aliveMap[ip] = struct{}{}
+			}
+			messages := subnetStatMessages([]string{"10.0.1.0/24", "10.0.2.0/24"}, subnets, aliveMap)
+			// 无存活的C段也要输出
+			if len(messages) != len(tt.want) {
+				t.Fatalf("got %d messages, want %d", len(messages), len(tt.want))
+			}
+			for _, m := range messages {
+				if m.Type != "SubnetStat" || !reflect.DeepEqual(m.Stats, tt.want[m.IP]) {
+					t.Errorf("%s: %s %v, want %v", m.IP, m.Type, m.Stats, tt.want[m.IP])
+				}
+			}
+		})
+	}
+}
+
+func TestSubnetSampleTargets(t *testing.T) {
+	ips := []string{"10.0.1.1", "10.0.1.2", "10.0.2.1", "fe80::1", "10.0.1.3", "oa.corp.local", "10.0.3.7"}
+	tests := []struct {
+		name string
+		live map[string]bool
+		want []string
+	}{
+		{"none", nil, []string{"fe80::1", "oa.corp.local"}},
+		// 存活C段内未响应的地址同样返回
+		{"one", map[string]bool{"10.0.1.0/24": true}, []string{"10.0.1.1", "10.0.1.2", "fe80::1", "10.0.1.3", "oa.corp.local"}},
+		{"all", map[string]bool{"10.0.1.0/24": true, "10.0.2.0/24": true, "10.0.3.0/24": true}, ips},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := subnetSampleTargets(ips, tt.live); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("subnetSampleTargets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
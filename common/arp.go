@@ -0,0 +1,156 @@
+package common
+
+import (
+	"dddd/ddout"
+	"dddd/structs"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"sort"
+	"time"
+)
+
+// 直连网段的二层主机发现：IPv4 发送ARP请求，同一网卡上的IPv6邻居通过NDP获取。
+// 不依赖ICMP与开放端口，只要主机在同一广播域就会应答。
+
+// arpInterface 直连网段对应的网卡
+type arpInterface struct {
+	Iface   *net.Interface
+	SrcIP   net.IP
+	Targets []net.IP
+}
+
+// arpRoutes 按网卡分组出位于直连网段内的目标，不在任何直连网段的目标交给ICMP/TCP探测
+func arpRoutes(hostslist []string) []*arpInterface {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	type subnet struct {
+		route *arpInterface
+		ipnet *net.IPNet
+	}
+	var subnets []subnet
+	var routes []*arpInterface
+	for i := range ifaces {
+		iface := &ifaces[i]
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) != 6 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil {
+				continue
+			}
+			// 点对点或单主机掩码不存在二层邻居
+			if ones, _ := ipnet.Mask.Size(); ones >= 31 {
+				continue
+			}
+			r := &arpInterface{Iface: iface, SrcIP: ipnet.IP.To4()}
+			subnets = append(subnets, subnet{route: r, ipnet: ipnet})
+			routes = append(routes, r)
+		}
+	}
+
+	for _, host := range hostslist {
+		ip := net.ParseIP(host).To4()
+		if ip == nil {
+			continue
+		}
+		for _, s := range subnets {
+			if s.ipnet.Contains(ip) && !ip.Equal(s.route.SrcIP) {
+				s.route.Targets = append(s.route.Targets, ip)
+				break
+			}
+		}
+	}
+
+	var result []*arpInterface
+	for _, r := range routes {
+		if len(r.Targets) > 0 {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+func addHostMAC(ip string, mac net.HardwareAddr, method string) structs.HostMACInfo {
+	structs.GlobalHostMACMapLock.Lock()
+	defer structs.GlobalHostMACMapLock.Unlock()
+	info := structs.HostMACInfo{MAC: mac.String(), Method: method}
+	structs.GlobalHostMACMap[ip] = info
+	return info
+}
+
+func addHostIPv6(ip, ipv6 string) {
+	structs.GlobalHostMACMapLock.Lock()
+	defer structs.GlobalHostMACMapLock.Unlock()
+	info := structs.GlobalHostMACMap[ip]
+	if !IsContain(info.IPv6, ipv6) {
+		info.IPv6 = append(info.IPv6, ipv6)
+	}
+	structs.GlobalHostMACMap[ip] = info
+}
+
+// CheckLiveARP 对直连网段内的目标进行ARP探测，同时收集同网卡的IPv6邻居，返回存活的IPv4地址
+func CheckLiveARP(hostslist []string) []string {
+	routes := arpRoutes(hostslist)
+	if len(routes) == 0 {
+		return nil
+	}
+
+	var alive []string
+	for _, r := range routes {
+		gologger.AuditTimeLogger("ARP探测存活 %s(%s) 目标数量: %d", r.Iface.Name, r.SrcIP, len(r.Targets))
+		result, err := arpSweep(r.Iface, r.SrcIP, r.Targets)
+		if err != nil {
+			gologger.Warning().Msgf("%s ARP探测失败，转为ICMP/TCP探测: %v", r.Iface.Name, err)
+			continue
+		}
+
+		macToIP := make(map[string]string)
+		for _, ip := range r.Targets {
+			mac, ok := result[ip.String()]
+			if !ok {
+				continue
+			}
+			info := addHostMAC(ip.String(), mac, "ARP")
+			macToIP[info.MAC] = ip.String()
+			ddout.FormatOutput(ddout.OutputMessage{
+				Type:          "IPAlive",
+				IP:            ip.String(),
+				AdditionalMsg: "ARP",
+				MAC:           info.MAC,
+			})
+			alive = append(alive, ip.String())
+		}
+
+		neighbors, err := ndpNeighbors(r.Iface, time.Duration(2)*time.Second)
+		if err != nil {
+			gologger.AuditTimeLogger("NDP探测 %s: %v", r.Iface.Name, err)
+			continue
+		}
+		var ipv6s []string
+		for ipv6 := range neighbors {
+			ipv6s = append(ipv6s, ipv6)
+		}
+		sort.Strings(ipv6s)
+		for _, ipv6 := range ipv6s {
+			mac := neighbors[ipv6]
+			// 双栈主机按MAC关联到ARP发现的IPv4地址，IPv6地址只记录不扫描
+			if ip, ok := macToIP[mac.String()]; ok {
+				addHostIPv6(ip, ipv6)
+			}
+			ddout.FormatOutput(ddout.OutputMessage{
+				Type:          "IPAlive",
+				IP:            ipv6,
+				AdditionalMsg: "NDP",
+				MAC:           mac.String(),
+			})
+		}
+	}
+	return alive
+}
@@ -0,0 +1,120 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const ethPARP = 0x0806
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// arpRequest 以太网广播的 ARP who-has
+func arpRequest(srcMAC net.HardwareAddr, srcIP, dstIP net.IP) []byte {
+	frame := make([]byte, 42)
+	copy(frame[0:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	copy(frame[6:12], srcMAC)
+	binary.BigEndian.PutUint16(frame[12:14], ethPARP)
+	// htype=1 ptype=IPv4 hlen=6 plen=4 op=request
+	copy(frame[14:22], []byte{0x00, 0x01, 0x08, 0x00, 0x06, 0x04, 0x00, 0x01})
+	copy(frame[22:28], srcMAC)
+	copy(frame[28:32], srcIP.To4())
+	copy(frame[38:42], dstIP.To4())
+	return frame
+}
+
+// arpSweep 通过 AF_PACKET 原始套接字发送ARP请求，未应答的目标重发一次，返回 ip->MAC
+func arpSweep(iface *net.Interface, srcIP net.IP, targets []net.IP) (map[string]net.HardwareAddr, error) {
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(htons(ethPARP)))
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Close(fd)
+	err = syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: htons(ethPARP), Ifindex: iface.Index})
+	if err != nil {
+		return nil, err
+	}
+	tv := syscall.NsecToTimeval(int64(200 * time.Millisecond))
+	err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool)
+	for _, ip := range targets {
+		wanted[ip.String()] = true
+	}
+	result := make(map[string]net.HardwareAddr)
+	var lock sync.Mutex
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 1500)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if err != nil || n < 42 {
+				continue
+			}
+			if binary.BigEndian.Uint16(buf[12:14]) != ethPARP || binary.BigEndian.Uint16(buf[20:22]) != 2 {
+				continue
+			}
+			ip := net.IP(append([]byte{}, buf[28:32]...)).String()
+			mac := net.HardwareAddr(append([]byte{}, buf[22:28]...))
+			if !wanted[ip] || bytes.Equal(mac, iface.HardwareAddr) {
+				continue
+			}
+			lock.Lock()
+			if _, ok := result[ip]; !ok {
+				gologger.AuditTimeLogger("[ARP] %s is-at %s", ip, mac)
+				result[ip] = mac
+			}
+			lock.Unlock()
+		}
+	}()
+
+	addr := &syscall.SockaddrLinklayer{Protocol: htons(ethPARP), Ifindex: iface.Index, Halen: 6}
+	copy(addr.Addr[:], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	var sendErr error
+	for round := 0; round < 2 && sendErr == nil; round++ {
+		sent := 0
+		for _, ip := range targets {
+			lock.Lock()
+			_, ok := result[ip.String()]
+			lock.Unlock()
+			if ok {
+				continue
+			}
+			if err = syscall.Sendto(fd, arpRequest(iface.HardwareAddr, srcIP, ip), 0, addr); err != nil {
+				sendErr = err
+				break
+			}
+			// 控制广播速率，避免交换机丢包
+			sent++
+			if sent%128 == 0 {
+				time.Sleep(time.Duration(20) * time.Millisecond)
+			}
+		}
+		time.Sleep(time.Duration(1+round) * time.Second)
+	}
+	close(done)
+	wg.Wait()
+
+	if sendErr != nil && len(result) == 0 {
+		return nil, sendErr
+	}
+	return result, nil
+}
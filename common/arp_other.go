@@ -0,0 +1,13 @@
+//go:build !linux
+
+package common
+
+import (
+	"errors"
+	"net"
+)
+
+// arpSweep 原始以太网帧目前只实现了 Linux(AF_PACKET)
+func arpSweep(iface *net.Interface, srcIP net.IP, targets []net.IP) (map[string]net.HardwareAddr, error) {
+	return nil, errors.New("ARP探测仅支持Linux")
+}
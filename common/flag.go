@@ -8,6 +8,7 @@ import (
 	"embed"
 	_ "embed"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/projectdiscovery/goflags"
 	"github.com/projectdiscovery/gologger"
@@ -22,6 +23,7 @@ import (
 	"path"
 	"runtime"
 	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -59,6 +61,21 @@ dddd.version: %s
 var TargetString string
 var PortString string
 
+var ListPortsMode bool
+
+// 命令行中手动指定的参数
+var passedFlags = make(map[string]bool)
+
+// flagPassed 只记录实际使用的名称，需要同时传入长名称与短名称
+func flagPassed(names ...string) bool {
+	for _, name := range names {
+		if passedFlags[name] {
+			return true
+		}
+	}
+	return false
+}
+
 //go:embed config/dir.yaml
 var EmbedDirDBData string
 
@@ -71,6 +88,18 @@ var EmbedAPIConfigData string
 //go:embed config/pocs/*
 var EmbedNucleiPocs embed.FS
 
+//go:embed config/alive.yaml
+var EmbedAliveProfileData string
+
+//go:embed config/jarm.yaml
+var EmbedJARMData string
+
+// JARMFilePath 存在时补充内置的JARM列表
+var JARMFilePath = "config/jarm.yaml"
+
+// AliveProfileFilePath 存在时补充或覆盖内置的主机发现探针组合
+var AliveProfileFilePath = "config/alive.yaml"
+
 func fileExists(filePath string) bool {
 	_, err := os.Stat(filePath)
 	if err != nil {
@@ -141,6 +170,59 @@ func ReadDirDB() {
 
 }
 
+func ReadAliveProfileDB() {
+	// 先读取默认的，再读取文件内的进行覆盖
+	structs.AliveProfileDB = make(map[string]structs.AliveProfile)
+	err := yaml.Unmarshal([]byte(EmbedAliveProfileData), &structs.AliveProfileDB)
+	if err != nil {
+		gologger.Error().Msgf("内置主机发现探针配置解析失败: %v", err)
+	}
+
+	if !fileExists(AliveProfileFilePath) {
+		return
+	}
+	data, err := os.ReadFile(AliveProfileFilePath)
+	if err != nil {
+		return
+	}
+	profiles := make(map[string]structs.AliveProfile)
+	err = yaml.Unmarshal(data, &profiles)
+	if err != nil {
+		gologger.Error().Msgf("%s 解析失败: %v", AliveProfileFilePath, err)
+		return
+	}
+	for name, profile := range profiles {
+		structs.AliveProfileDB[name] = profile
+	}
+}
+
+func ReadJARMDB() {
+	// 先读取默认的，再读取文件内的进行补充
+	structs.JARMDB = make(map[string]string)
+	add := func(source string, data []byte) {
+		names := make(map[string][]string)
+		if err := yaml.Unmarshal(data, &names); err != nil {
+			gologger.Error().Msgf("%s 解析失败: %v", source, err)
+			return
+		}
+		for name, hashes := range names {
+			for _, hash := range hashes {
+				structs.JARMDB[strings.ToLower(hash)] = name
+			}
+		}
+	}
+	add("内置JARM列表", []byte(EmbedJARMData))
+
+	if !fileExists(JARMFilePath) {
+		return
+	}
+	data, err := os.ReadFile(JARMFilePath)
+	if err != nil {
+		return
+	}
+	add(JARMFilePath, data)
+}
+
 func IsLinux() bool {
 	os := runtime.GOOS
 	if os == "linux" {
@@ -268,6 +350,16 @@ func GetFdLimit() int {
 func prepare() {
 	var tmpTargets []string
 
+	for _, ports := range []string{PortString, structs.GlobalConfig.NoPortString, structs.GlobalConfig.TCPPingPorts} {
+		if err := CheckPortString(ports); err != nil {
+			gologger.Fatal().Msg(err.Error())
+		}
+	}
+	if ListPortsMode {
+		ListPorts(PortString)
+		os.Exit(0)
+	}
+
 	// 参数冲突校验
 	if structs.GlobalConfig.ReportName != "" {
 		suffix := path.Ext(structs.GlobalConfig.ReportName)
@@ -307,9 +399,34 @@ func prepare() {
 		}
 	}
 
-	if !structs.GlobalConfig.SkipHostDiscovery && !structs.GlobalConfig.TCPPing && structs.GlobalConfig.NoICMPPing {
-		gologger.Warning().Msg("未选择TCP或ICMP Ping，跳过存活探测")
-		structs.GlobalConfig.SkipHostDiscovery = true
+	ReadAliveProfileDB()
+	if structs.GlobalConfig.AliveProfile != "" {
+		profile, ok := structs.AliveProfileDB[structs.GlobalConfig.AliveProfile]
+		if !ok {
+			var names []string
+			for name := range structs.AliveProfileDB {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			gologger.Fatal().Msgf("-alive-profile 允许的值: %s", strings.Join(names, ","))
+		}
+		structs.GlobalAliveProfile = profile
+		if !structs.GlobalConfig.SkipHostDiscovery && !profile.ARP && !profile.ICMP && profile.TCP == "" {
+			gologger.Warning().Msgf("%s 未配置任何探针，跳过存活探测", structs.GlobalConfig.AliveProfile)
+			structs.GlobalConfig.SkipHostDiscovery = true
+		}
+	} else {
+		structs.GlobalAliveProfile = structs.AliveProfile{
+			ARP:  !structs.GlobalConfig.NoARPPing,
+			ICMP: !structs.GlobalConfig.NoICMPPing,
+		}
+		if structs.GlobalConfig.TCPPing {
+			structs.GlobalAliveProfile.TCP = structs.GlobalConfig.TCPPingPorts
+		}
+		if !structs.GlobalConfig.SkipHostDiscovery && !structs.GlobalConfig.TCPPing && structs.GlobalConfig.NoICMPPing {
+			gologger.Warning().Msg("未选择TCP或ICMP Ping，跳过存活探测")
+			structs.GlobalConfig.SkipHostDiscovery = true
+		}
 	}
 
 	if structs.GlobalConfig.HTTPProxyTest && structs.GlobalConfig.HTTPProxy != "" {
@@ -440,13 +557,34 @@ func prepare() {
 		structs.GlobalConfig.Targets = append(structs.GlobalConfig.Targets, tg)
 	}
 
-	if len(structs.GlobalConfig.Targets) == 0 && len(structs.GlobalResultMap) == 0 {
+	if len(structs.GlobalConfig.Targets) == 0 && len(structs.GlobalResultMap) == 0 && structs.GlobalConfig.PassiveListen <= 0 {
 		gologger.Fatal().Msgf("无目标输入")
 	}
 
+	// 扫描时序，手动指定的线程与超时优先
+	timingName, timing, err := ParseTimingProfile(structs.GlobalConfig.Timing)
+	if err != nil {
+		gologger.Fatal().Msg(err.Error())
+	}
+	structs.GlobalConfig.Timing = timingName
+	ScanTiming = timing
 	// 如果是Linux则调整扫描线程 gogo抄的感谢感谢
-	if IsLinux() {
+	if IsLinux() && !flagPassed("tcp-scan-threads", "tst") {
 		structs.GlobalConfig.TCPPortScanThreads = 4000
+	}
+	if timing.TCPThreads > 0 && !flagPassed("tcp-scan-threads", "tst") {
+		structs.GlobalConfig.TCPPortScanThreads = timing.TCPThreads
+	}
+	if timing.BannerThreads > 0 && !flagPassed("nmap-threads", "tc") {
+		structs.GlobalConfig.GetBannerThreads = timing.BannerThreads
+	}
+	if timing.BannerTimeout > 0 && !flagPassed("nmap-timeout", "nto") {
+		structs.GlobalConfig.GetBannerTimeout = timing.BannerTimeout
+	}
+	if timing.PortScanTimeout > 0 && !flagPassed("port-scan-timeout", "pst") {
+		structs.GlobalConfig.TCPPortScanTimeout = timing.PortScanTimeout
+	}
+	if IsLinux() {
 		if fdlimit := GetFdLimit(); structs.GlobalConfig.TCPPortScanThreads > fdlimit {
 			gologger.Warning().Msgf("System fd limit: %d , Please exec 'ulimit -n 65535'", fdlimit)
 			gologger.Warning().Msgf("Now set threads to %d", fdlimit-100)
@@ -454,12 +592,48 @@ func prepare() {
 		}
 	}
 
+	if structs.GlobalConfig.PTRResolvers != "" {
+		resolvers, err := ParsePTRResolvers(structs.GlobalConfig.PTRResolvers)
+		if err != nil {
+			gologger.Fatal().Msgf("-ptr-resolvers 设置有误: %v", err)
+		}
+		PTRResolvers = resolvers
+	}
+
+	switch structs.GlobalConfig.CredReuseScope {
+	case "all", "subnet", "host", "none":
+	default:
+		gologger.Fatal().Msgf("-cred-reuse 允许的值: all,subnet,host,none")
+	}
+
+	if structs.GlobalConfig.Spray && (structs.GlobalConfig.SprayAttempts < 1 || structs.GlobalConfig.SprayWindow < 1) {
+		gologger.Fatal().Msgf("-spray-attempts 与 -spray-window 必须大于0")
+	}
+
+	if structs.GlobalConfig.SSHKeyDir != "" {
+		if fi, err := os.Stat(structs.GlobalConfig.SSHKeyDir); err != nil || !fi.IsDir() {
+			gologger.Fatal().Msgf("-ssh-key-dir 指定的目录不存在: %s", structs.GlobalConfig.SSHKeyDir)
+		}
+	}
+
+	if structs.GlobalConfig.ShiroGadget && structs.GlobalConfig.NoInteractsh {
+		gologger.Fatal().Msg("-shiro-gadget 依赖Interactsh反连，不能与 -ni 同时使用")
+	}
+
+	if structs.GlobalConfig.ICSScan && structs.GlobalConfig.ICSRate < 1 {
+		gologger.Fatal().Msg("-ics-rate 必须大于0")
+	}
+
 	ddout.OutputType = structs.GlobalConfig.OutputType
 	ddout.OutputFileName = structs.GlobalConfig.OutputFile
 
 	if PortString == "" {
 		// 默认端口Top1000
 		structs.GlobalConfig.Ports = PortTOP1000
+		if structs.GlobalConfig.ICSScan {
+			// S7comm、Modbus/TCP 不在Top1000中，DNP3(20000)已包含
+			structs.GlobalConfig.Ports += ",102,502"
+		}
 	} else {
 		structs.GlobalConfig.Ports = PortString
 	}
@@ -483,6 +657,14 @@ func prepare() {
 	structs.GlobalBannerHMap = hm
 	structs.GlobalIPPortMap = make(map[string]string)
 	structs.GlobalIPDomainMap = make(map[string][]string)
+	structs.GlobalHostBindMap = make(map[string]string)
+	structs.GlobalTargetKeywordMap = make(map[string][]string)
+	structs.GlobalNTLMInfoMap = make(map[string]structs.NTLMInfo)
+	structs.GlobalRDPInfoMap = make(map[string]structs.RDPSecurityInfo)
+	structs.GlobalHostMACMap = make(map[string]structs.HostMACInfo)
+	structs.GlobalServiceCertMap = make(map[string]string)
+	structs.GlobalServiceInfoMap = make(map[string]structs.ServiceInfo)
+	structs.GlobalTLSInfoMap = make(map[string]structs.TLSInfo)
 	structs.GlobalURLMap = make(map[string]structs.URLEntity)
 
 	parseFingerDB()
@@ -501,6 +683,14 @@ func prepare() {
 	if len(structs.DirDB) == 0 {
 		gologger.Fatal().Msg("请检查主动指纹探测数据库是否正常。")
 	}
+
+	if !structs.GlobalConfig.NoTLSCheck {
+		ReadJARMDB()
+	}
+
+	if err := LoadNmapProbes(); err != nil {
+		gologger.Fatal().Msgf("外部协议识别探针加载失败: %v", err)
+	}
 }
 
 func parseFingerDB() {
@@ -574,25 +764,38 @@ func Flag() {
 	)
 
 	flagSet.CreateGroup("portscan", "端口扫描",
-		flagSet.StringVarP(&PortString, "port", "p", "", "端口设置。 默认扫描Top1000"),
-		flagSet.StringVarP(&structs.GlobalConfig.NoPortString, "no-port", "np", "", "禁止扫描的端口"),
+		flagSet.StringVarP(&PortString, "port", "p", "", "端口设置，可混用端口、范围、端口组与服务名，如 top100,db,8848,redis | 端口组: top100,top1000,top-N,web,db,windows,iot,ics,all | 默认扫描Top1000"),
+		flagSet.BoolVarP(&ListPortsMode, "list-ports", "lp", false, "输出 -p 展开后的端口后退出，未指定 -p 时输出所有端口组"),
+		flagSet.StringVarP(&structs.GlobalConfig.NoPortString, "no-port", "np", "", "禁止扫描的端口，支持端口组与服务名"),
 		flagSet.StringVarP(&structs.GlobalConfig.PortScanType, "scan-type", "st", "tcp", "端口扫描方式 | \"-st tcp\"设置TCP扫描 | \"-st syn\"设置SYN扫描"),
 		flagSet.IntVarP(&structs.GlobalConfig.TCPPortScanThreads, "tcp-scan-threads", "tst", 1000, "TCP扫描线程 | Windows/Mac默认1000线程 Linux默认4000"),
 		flagSet.IntVarP(&structs.GlobalConfig.SYNPortScanThreads, "syn-scan-threads", "sst", 10000, "SYN扫描线程"),
 		flagSet.StringVarP(&structs.GlobalConfig.MasscanPath, "masscan-path", "mp", "masscan", "指定masscan程序路径 | SYN扫描依赖"),
 		flagSet.IntVarP(&structs.GlobalConfig.PortsThreshold, "ports-max-count", "pmc", 300, "IP端口数量阈值 | 当一个IP的端口数量超过此值，此IP将会被抛弃"),
-		flagSet.IntVarP(&structs.GlobalConfig.TCPPortScanTimeout, "port-scan-timeout", "pst", 6, "TCP端口扫描超时(秒)"),
+		flagSet.IntVarP(&structs.GlobalConfig.TCPPortScanTimeout, "port-scan-timeout", "pst", 6, "TCP端口扫描超时(秒) | 非normal时序测得RTT后按RTT自适应缩短，此值为上限"),
+		flagSet.StringVarP(&structs.GlobalConfig.Timing, "timing", "tm", "normal", "扫描时序 | paranoid,sneaky,polite,normal,aggressive,insane 或 T0-T5 | 调整TCP扫描线程、连接超时与重试、协议识别线程与超时，手动指定的 -tst,-pst,-tc,-nto 优先"),
 	)
 
 	flagSet.CreateGroup("alive", "主机发现",
 		flagSet.BoolVar(&structs.GlobalConfig.SkipHostDiscovery, "Pn", false, "禁用主机发现功能(icmp,tcp)"),
 		flagSet.BoolVarP(&structs.GlobalConfig.NoICMPPing, "no-icmp-ping", "nip", false, "当启用主机发现功能时，禁用ICMP主机发现功能"),
 		flagSet.BoolVarP(&structs.GlobalConfig.TCPPing, "tcp-ping", "tp", false, "当启用主机发现功能时，启用TCP主机发现功能"),
+		flagSet.StringVarP(&structs.GlobalConfig.TCPPingPorts, "tcp-ping-ports", "tpp", "80,443,3389,445,22", "TCP主机发现使用的端口"),
+		flagSet.StringVarP(&structs.GlobalConfig.AliveProfile, "alive-profile", "apf", "", "主机发现探针组合，设置后忽略-nip,-tp,-nap | 内置: default,tcp,windows,linux,noicmp,web | 可在config/alive.yaml中自定义"),
+		flagSet.BoolVarP(&structs.GlobalConfig.SubnetSample, "subnet-sample", "ssp", false, "C段抽样探活，先探测每个/24的.1,.254等少量地址，有存活的C段才进行完整探测 | 适用于/8等大网段"),
+		flagSet.BoolVarP(&structs.GlobalConfig.NoARPPing, "no-arp-ping", "nap", false, "当启用主机发现功能时，禁用直连网段的ARP/NDP主机发现 | 需要root权限，ARP仅支持Linux"),
+	)
+
+	flagSet.CreateGroup("passive", "被动发现",
+		flagSet.IntVarP(&structs.GlobalConfig.PassiveListen, "passive", "pas", 0, "被动监听NBNS/LLMNR/mDNS/SSDP/DHCP广播的秒数，获取主机、主机名、服务类型与设备型号 | 不发送任何数据包"),
+		flagSet.BoolVarP(&structs.GlobalConfig.PassiveScan, "passive-scan", "pss", false, "被动发现结束后，将发现的主机加入目标进行正常扫描"),
 	)
 
 	flagSet.CreateGroup("nmap", "协议识别",
 		flagSet.IntVarP(&structs.GlobalConfig.GetBannerThreads, "nmap-threads", "tc", 500, "Nmap协议识别线程"),
 		flagSet.IntVarP(&structs.GlobalConfig.GetBannerTimeout, "nmap-timeout", "nto", 5, "Nmap协议识别超时时间(秒)"),
+		flagSet.BoolVarP(&structs.GlobalConfig.NoTLSCheck, "no-tls", "ntls", false, "关闭TLS证书链、协议版本与JARM探测"),
+		flagSet.BoolVarP(&structs.GlobalConfig.TLSCheckDB, "tls-db", "tdb", false, "分析MySQL、PostgreSQL的STARTTLS，中断的握手会计入MySQL的max_connect_errors"),
 	)
 
 	flagSet.CreateGroup("subdomain", "探索子域名",
@@ -603,6 +806,8 @@ func Flag() {
 		flagSet.BoolVarP(&structs.GlobalConfig.AllowLocalAreaDomain, "local-domain", "ld", false, "允许域名解析到局域网"),
 		flagSet.BoolVarP(&structs.GlobalConfig.AllowCDNAssets, "allow-cdn", "ac", false, "允许扫描带CDN的资产 | 默认略过"),
 		flagSet.BoolVarP(&structs.GlobalConfig.NoHostBind, "no-host-bind", "nhb", false, "禁用域名绑定资产探测"),
+		flagSet.BoolVarP(&structs.GlobalConfig.NoPTR, "no-ptr", "nptr", false, "域名绑定资产探测时不反查IP的PTR记录"),
+		flagSet.StringVarP(&structs.GlobalConfig.PTRResolvers, "ptr-resolvers", "ptrr", "", "反查PTR记录使用的DNS服务器，多个用逗号分隔，如 10.0.0.1,10.0.0.2:53 | 默认使用系统DNS"),
 	)
 
 	flagSet.CreateGroup("web", "Web探针配置",
@@ -633,6 +838,7 @@ func Flag() {
 		flagSet.StringVarP(&structs.GlobalConfig.OutputFile, "output", "o", "result.txt", "结果输出文件"),
 		flagSet.StringVarP(&structs.GlobalConfig.OutputType, "output-type", "ot", "text", "结果输出格式 text,json"),
 		flagSet.StringVarP(&structs.GlobalConfig.ReportName, "html-output", "ho", "", "html漏洞报告的名称"),
+		flagSet.StringVarP(&structs.GlobalConfig.CredOutputFile, "cred-output", "co", "", "已确认凭据导出文件 | .csv后缀导出CSV,其余导出JSON | 默认为 结果文件名_credentials.json"),
 	)
 
 	flagSet.CreateGroup("vuln-detect", "漏洞探测",
@@ -644,6 +850,12 @@ func Flag() {
 		flagSet.StringVarP(&structs.GlobalConfig.ExcludeTags, "exclude-tags", "et", "", "通过tags排除模版 | 多个tags请用,连接"),
 		flagSet.StringVarP(&structs.GlobalConfig.Severities, "severity", "s", "", "只允许指定严重程度的模板运行 | 多参数用,连接 | 允许的值: "+strings.ReplaceAll(severity.GetSupportedSeverities().String(), " ", "")),
 		flagSet.BoolVarP(&structs.GlobalConfig.NoServiceBruteForce, "no-brute", "nb", false, "禁用服务爆破 | 不包括Shiro Keys"),
+		flagSet.StringVarP(&structs.GlobalConfig.SMTPRelayLocalPart, "smtp-relay-user", "sru", "dddd", "SMTP开放中继检测使用的收件人用户名 | 收件域名固定为不存在的 .invalid 域名"),
+		flagSet.BoolVarP(&structs.GlobalConfig.DBPostAuth, "db-post-auth", "dpa", false, "数据库登录成功后收集版本、权限、库表行数等信息 | 仅执行只读查询"),
+		flagSet.StringVarP(&structs.GlobalConfig.CredReuseScope, "cred-reuse", "cr", "none", "已确认凭据复用到其它服务的范围，默认关闭 | 允许的值: all,subnet,host,none | subnet为同一C段"),
+		flagSet.BoolVarP(&structs.GlobalConfig.ShiroGadget, "shiro-gadget", "sgd", false, "Shiro Key爆破成功后通过DNS反连探测可利用的Gadget | 使用-iserver指定的Interactsh服务 | 仅加载类，不执行命令"),
+		flagSet.BoolVar(&structs.GlobalConfig.ICSScan, "ics", false, "开启工控协议识别(Modbus/S7/BACnet/DNP3) | 只发送读取设备标识的请求 | 默认端口扫描额外加入102,502"),
+		flagSet.IntVarP(&structs.GlobalConfig.ICSRate, "ics-rate", "icr", 5, "工控协议识别每秒最多发送的请求数(所有目标共享)"),
 	)
 
 	flagSet.CreateGroup("interact-sh", "反连配置",
@@ -664,6 +876,16 @@ func Flag() {
 	flagSet.CreateGroup("passwd", "爆破密码配置",
 		flagSet.StringVarP(&structs.GlobalConfig.Password, "username-password", "up", "", "设置爆破凭证，设置后将禁用内置字典 | 凭证格式 'admin : password'"),
 		flagSet.StringVarP(&structs.GlobalConfig.PasswordFile, "username-password-file", "upf", "", "设置爆破凭证文件(一行一个)，设置后将禁用内置字典 | 凭证格式 'admin : password'"),
+		flagSet.StringVarP(&structs.GlobalConfig.MutateKeys, "mutate-keys", "mk", "", "额外的口令关键字，用于替换字典中的{{key}} | 多个关键字用,连接 | 如单位简称"),
+		flagSet.StringVarP(&structs.GlobalConfig.MutateRules, "mutate-rules", "mr", "", "口令变形规则，默认不启用 | 多个规则用,连接 | 允许的值: year,season,walk,symbol,leet"),
+		flagSet.StringVarP(&structs.GlobalConfig.MutateRuleFile, "mutate-rule-file", "mrf", "", "hashcat格式的口令变形规则文件"),
+		flagSet.IntVarP(&structs.GlobalConfig.MutateMax, "mutate-max", "mm", 300, "每个服务变形口令(leet与规则口令)的数量上限，0为不变形 | 字典模板的展开不受限制"),
+		flagSet.BoolVar(&structs.GlobalConfig.Spray, "spray", false, "密码喷洒模式 | 每轮只尝试一个密码并限制每个账号的尝试频率，发现账号锁定立即停止 | 作用于SMB,RDP,WinRM,SSH,数据库,邮件服务"),
+		flagSet.IntVarP(&structs.GlobalConfig.SprayAttempts, "spray-attempts", "spa", 3, "密码喷洒时每个账号在窗口期内的最大尝试次数"),
+		flagSet.IntVarP(&structs.GlobalConfig.SprayWindow, "spray-window", "spw", 30, "密码喷洒的窗口期(分钟) | 应与域账户锁定策略的重置时间一致"),
+		flagSet.IntVarP(&structs.GlobalConfig.SprayDelay, "spray-delay", "spd", 0, "密码喷洒每轮之间的间隔(秒)"),
+		flagSet.BoolVarP(&structs.GlobalConfig.SprayDomain, "spray-domain", "spdm", false, "密码喷洒时将SMB,RDP,WinRM的账号视为同一个域的账号，所有主机共享尝试次数 | 默认按NTLM信息收集得到的域共享"),
+		flagSet.StringVarP(&structs.GlobalConfig.SSHKeyDir, "ssh-key-dir", "skd", "", "SSH私钥目录，使用目录下的私钥对所有SSH服务尝试密钥登录 | 不支持带口令的私钥"),
 	)
 
 	flagSet.CreateGroup("audit", "审计日志 | 敏感环境必备",
@@ -672,6 +894,9 @@ func Flag() {
 	)
 
 	_ = flagSet.Parse()
+	flagSet.CommandLine.Visit(func(f *flag.Flag) {
+		passedFlags[f.Name] = true
+	})
 
 	prepare()
 	flagAudit()
@@ -687,16 +912,27 @@ func flagAudit() {
 	gologger.AuditLogger("NoSubFinder: %v", structs.GlobalConfig.NoSubFinder)
 	gologger.AuditLogger("SubdomainBruteForceThreads: %v", structs.GlobalConfig.SubdomainBruteForceThreads)
 	gologger.AuditLogger("AllowLocalAreaDomain: %v", structs.GlobalConfig.AllowLocalAreaDomain)
+	gologger.AuditLogger("NoHostBind: %v", structs.GlobalConfig.NoHostBind)
+	gologger.AuditLogger("NoPTR: %v", structs.GlobalConfig.NoPTR)
+	gologger.AuditLogger("PTRResolvers: %v", structs.GlobalConfig.PTRResolvers)
 	gologger.AuditLogger("Ports: %v", structs.GlobalConfig.Ports)
 	gologger.AuditLogger("SkipHostDiscovery: %v", structs.GlobalConfig.SkipHostDiscovery)
 	gologger.AuditLogger("NoICMPPing: %v", structs.GlobalConfig.NoICMPPing)
 	gologger.AuditLogger("TCPPing: %v", structs.GlobalConfig.TCPPing)
+	gologger.AuditLogger("NoARPPing: %v", structs.GlobalConfig.NoARPPing)
+	gologger.AuditLogger("AliveProfile: %v %+v", structs.GlobalConfig.AliveProfile, structs.GlobalAliveProfile)
+	gologger.AuditLogger("SubnetSample: %v", structs.GlobalConfig.SubnetSample)
+	gologger.AuditLogger("PassiveListen: %v", structs.GlobalConfig.PassiveListen)
+	gologger.AuditLogger("PassiveScan: %v", structs.GlobalConfig.PassiveScan)
 	gologger.AuditLogger("GetBannerThreads: %v", structs.GlobalConfig.GetBannerThreads)
+	gologger.AuditLogger("NoTLSCheck: %v", structs.GlobalConfig.NoTLSCheck)
+	gologger.AuditLogger("TLSCheckDB: %v", structs.GlobalConfig.TLSCheckDB)
 	gologger.AuditLogger("PortScanType: %v", structs.GlobalConfig.PortScanType)
 	gologger.AuditLogger("TCPPortScanThreads: %v", structs.GlobalConfig.TCPPortScanThreads)
 	gologger.AuditLogger("SYNPortScanThreads: %v", structs.GlobalConfig.SYNPortScanThreads)
 	gologger.AuditLogger("PortsThreshold: %v", structs.GlobalConfig.PortsThreshold)
 	gologger.AuditLogger("TCPPortScanTimeout: %v", structs.GlobalConfig.TCPPortScanTimeout)
+	gologger.AuditLogger("Timing: %v %+v", structs.GlobalConfig.Timing, ScanTiming)
 	gologger.AuditLogger("MasscanPath: %v", structs.GlobalConfig.MasscanPath)
 	gologger.AuditLogger("WebThreads: %v", structs.GlobalConfig.WebThreads)
 	gologger.AuditLogger("WebTimeout: %v", structs.GlobalConfig.WebTimeout)
@@ -718,6 +954,8 @@ func flagAudit() {
 	gologger.AuditLogger("PocNameForSearch: %v", structs.GlobalConfig.PocNameForSearch)
 	gologger.AuditLogger("NoPoc: %v", structs.GlobalConfig.NoPoc)
 	gologger.AuditLogger("NoInteractsh: %v", structs.GlobalConfig.NoInteractsh)
+	gologger.AuditLogger("ICSScan: %v", structs.GlobalConfig.ICSScan)
+	gologger.AuditLogger("ICSRate: %v", structs.GlobalConfig.ICSRate)
 	gologger.AuditLogger("Audit: %v", gologger.Audit)
 	gologger.AuditLogger("AuditLogFileName: %v", gologger.AuditLogFileName)
 	gologger.AuditLogger("GetBannerTimeout: %v", structs.GlobalConfig.GetBannerTimeout)
@@ -4,58 +4,73 @@ import (
 	"dddd/common/http"
 	"dddd/structs"
 	"dddd/utils"
-	"fmt"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/httpx"
 	"net/url"
 	"strings"
+	"sync"
 )
 
-func HostBindCheck() {
-	gologger.Info().Msg("域名绑定资产发现")
+// 已探测过的 主机名,URL，再次调用时只探测新获取到的主机名
+var hostBindTried = make(map[string]bool)
+var hostBindTriedLock sync.Mutex
+
+// HostBindCheck 使用IP关联的主机名作为Host头访问IP上的Web服务，发现只允许域名访问的资产。
+// 主机名来自搜索引擎、CDN识别、证书、PTR反查、跳转与NTLM，返回本次探测的数量
+func HostBindCheck() int {
+	if !structs.GlobalConfig.NoPTR {
+		PTRLookup(hostNameIPs())
+	}
+
+	structs.GlobalIPDomainMapLock.Lock()
+	ipDomains := make(map[string][]string, len(structs.GlobalIPDomainMap))
+	for ip, domains := range structs.GlobalIPDomainMap {
+		ipDomains[ip] = append([]string{}, domains...)
+	}
+	structs.GlobalIPDomainMapLock.Unlock()
 
-	var urls []string
-	for rootURL, _ := range structs.GlobalURLMap {
+	var rootURLs []string
+	structs.GlobalURLMapLock.Lock()
+	for rootURL := range structs.GlobalURLMap {
+		rootURLs = append(rootURLs, rootURL)
+	}
+	structs.GlobalURLMapLock.Unlock()
+
+	// 输入为 Host头,URL，直接连接IP，主机名无需能够解析
+	var inputs []string
+	hostBindTriedLock.Lock()
+	for _, rootURL := range rootURLs {
 		URL, err := url.Parse(rootURL)
 		if err != nil {
 			continue
 		}
-
-		if strings.Contains(URL.Host, ":") {
-			tmp := strings.Split(URL.Host, ":")
-			if len(tmp) != 2 {
-				continue
-			}
-			ip, port := tmp[0], tmp[1]
-			if !utils.IsIPv4(ip) {
-				continue
-			}
-			domains, ok := structs.GlobalIPDomainMap[ip]
-			if !ok {
-				continue
-			}
-			for _, domain := range domains {
-				urls = append(urls, fmt.Sprintf("%v://%v:%v", URL.Scheme, domain, port))
-			}
-		} else {
-			ip := URL.Host
-			if !utils.IsIPv4(ip) {
-				continue
-			}
-			domains, ok := structs.GlobalIPDomainMap[ip]
-			if !ok {
-				continue
+		ip, port := URL.Hostname(), URL.Port()
+		if !utils.IsIPv4(ip) {
+			continue
+		}
+		for _, domain := range ipDomains[ip] {
+			host := domain
+			if port != "" {
+				host += ":" + port
 			}
-			for _, domain := range domains {
-				urls = append(urls, fmt.Sprintf("%v://%v", URL.Scheme, domain))
+			input := host + "," + rootURL
+			if !hostBindTried[input] {
+				hostBindTried[input] = true
+				inputs = append(inputs, input)
 			}
 		}
 	}
-	urls = utils.RemoveDuplicateElement(urls)
+	hostBindTriedLock.Unlock()
+	if len(inputs) == 0 {
+		return 0
+	}
+	gologger.Info().Msg("域名绑定资产发现")
+	gologger.AuditTimeLogger("域名绑定资产发现: %s", strings.Join(inputs, " "))
 
-	httpx.DirBrute(urls, http.HostBindHTTPxCallBack,
+	httpx.DirBrute(inputs, http.HostBindHTTPxCallBack,
 		structs.GlobalConfig.HTTPProxy,
 		structs.GlobalConfig.WebThreads,
 		structs.GlobalConfig.WebTimeout)
 	gologger.AuditTimeLogger("域名绑定资产发现结束")
+	return len(inputs)
 }
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"context"
+	"crypto/x509"
+	"dddd/common/uncover"
+	"dddd/structs"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PTRResolvers -ptr-resolvers 指定的DNS服务器，为空时使用系统DNS
+var PTRResolvers []string
+
+// PTR反查的并发数与超时
+const (
+	ptrThreads = 50
+	ptrTimeout = 3 * time.Second
+)
+
+// ParsePTRResolvers 解析逗号分隔的DNS服务器，未指定端口时使用53
+func ParsePTRResolvers(s string) ([]string, error) {
+	var resolvers []string
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(r)
+		if err != nil {
+			host, port = r, "53"
+		}
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("%s 不是IP", r)
+		}
+		resolvers = append(resolvers, net.JoinHostPort(host, port))
+	}
+	if len(resolvers) == 0 {
+		return nil, fmt.Errorf("没有DNS服务器")
+	}
+	return resolvers, nil
+}
+
+func ptrResolver() *net.Resolver {
+	if len(PTRResolvers) == 0 {
+		return net.DefaultResolver
+	}
+	var next uint32
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			// 多个DNS服务器轮流使用
+			server := PTRResolvers[int(atomic.AddUint32(&next, 1))%len(PTRResolvers)]
+			d := net.Dialer{Timeout: ptrTimeout}
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// PTRLookup 反查IP的PTR记录，结果写入 GlobalIPDomainMap，每个IP只反查一次
+func PTRLookup(ips []string) {
+	var targets []string
+	ptrLookedLock.Lock()
+	for _, ip := range ips {
+		if !ptrLooked[ip] {
+			ptrLooked[ip] = true
+			targets = append(targets, ip)
+		}
+	}
+	ptrLookedLock.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+	gologger.AuditTimeLogger("PTR反查: %s", strings.Join(targets, ","))
+
+	resolver := ptrResolver()
+	var wg sync.WaitGroup
+	ch := make(chan struct{}, ptrThreads)
+	for _, ip := range targets {
+		ch <- struct{}{}
+		wg.Add(1)
+		go func(ip string) {
+			defer func() {
+				<-ch
+				wg.Done()
+			}()
+			ctx, cancel := context.WithTimeout(context.Background(), ptrTimeout)
+			defer cancel()
+			names, err := resolver.LookupAddr(ctx, ip)
+			if err != nil {
+				gologger.AuditTimeLogger("[PTR] %s error: %v", ip, err)
+				return
+			}
+			for _, name := range names {
+				uncover.AddHostName(ip, name, "ptr")
+			}
+		}(ip)
+	}
+	wg.Wait()
+	gologger.AuditTimeLogger("PTR反查结束")
+}
+
+var ptrLooked = make(map[string]bool)
+var ptrLookedLock sync.Mutex
+
+// AddCertHostNames 证书CN与SAN中的主机名，只记录IP目标
+func AddCertHostNames(hostPort string, cert *x509.Certificate) {
+	ip, _, err := net.SplitHostPort(hostPort)
+	if err != nil || net.ParseIP(ip) == nil {
+		return
+	}
+	uncover.AddHostName(ip, cert.Subject.CommonName, "cert "+hostPort)
+	for _, name := range cert.DNSNames {
+		uncover.AddHostName(ip, name, "cert "+hostPort)
+	}
+}
+
+// hostNameIPs 需要反查PTR的IP，即存在开放端口或Web服务的IP
+func hostNameIPs() []string {
+	seen := make(map[string]bool)
+	var ips []string
+	add := func(host string) {
+		if net.ParseIP(host) != nil && !seen[host] {
+			seen[host] = true
+			ips = append(ips, host)
+		}
+	}
+	structs.GlobalIPPortMapLock.Lock()
+	for hostPort := range structs.GlobalIPPortMap {
+		host, _, _ := net.SplitHostPort(hostPort)
+		add(host)
+	}
+	structs.GlobalIPPortMapLock.Unlock()
+	structs.GlobalURLMapLock.Lock()
+	for _, urlE := range structs.GlobalURLMap {
+		add(urlE.IP)
+	}
+	structs.GlobalURLMapLock.Unlock()
+	return ips
+}
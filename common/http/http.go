@@ -1,6 +1,7 @@
 package http
 
 import (
+	"dddd/common/uncover"
 	"dddd/ddout"
 	"dddd/lib/ddfinger"
 	"dddd/structs"
@@ -8,12 +9,14 @@ import (
 	"fmt"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/httpx/runner"
+	"net"
 	"net/url"
 	"strconv"
 	"strings"
 )
 
 func UrlCallBack(resp runner.Result) {
+	harvestHostNames(resp)
 
 	finalUrl := ""
 	if resp.FinalURL != "" {
@@ -119,6 +122,27 @@ func UrlCallBack(resp runner.Result) {
 
 }
 
+// harvestHostNames 以IP访问时，记录证书与跳转中出现的主机名，用于域名绑定资产探测
+func harvestHostNames(resp runner.Result) {
+	u := URLParse(resp.URL)
+	if u == nil || net.ParseIP(u.Hostname()) == nil {
+		return
+	}
+	ip := u.Hostname()
+	if resp.TLSData != nil {
+		source := "cert " + u.Host
+		uncover.AddHostName(ip, resp.TLSData.SubjectCN, source)
+		for _, name := range resp.TLSData.SubjectAN {
+			uncover.AddHostName(ip, name, source)
+		}
+	}
+	if resp.Location != "" {
+		if location, err := u.Parse(resp.Location); err == nil {
+			uncover.AddHostName(ip, location.Hostname(), "redirect "+resp.URL)
+		}
+	}
+}
+
 func getTLSString(resp runner.Result) string {
 	result := ""
 	if resp.TLSData == nil {
@@ -194,6 +218,11 @@ func GetPocs(workflowDB map[string]structs.WorkFlowEntity) (map[string][]string,
 
 	for target, fingerprints := range structs.GlobalResultMap {
 		gologger.AuditLogger(target + ":")
+		// Nuclei通过DNS解析主机名，无法解析的域名绑定资产不进行Yaml Poc探测
+		if _, _, ok := uncover.HostBindURL(target); ok {
+			gologger.AuditLogger("主机名无法解析，跳过Yaml Poc: %s", target)
+			continue
+		}
 		for _, finger := range fingerprints {
 			workflowEntity, ok := workflowDB[finger]
 			if !ok || len(workflowEntity.PocsName) == 0 {
@@ -307,7 +336,11 @@ func DirBruteCallBack(resp runner.Result) {
 					if r {
 						success = true
 						// 给对应的urlEntry添加指纹
-						Url := URLParse(resp.URL)
+						respURL := resp.URL
+						if vhost := hostBindHost(resp); vhost != "" {
+							respURL = replaceURLHost(respURL, vhost)
+						}
+						Url := URLParse(respURL)
 						rootURL := fmt.Sprintf("%s://%s", Url.Scheme, Url.Host)
 
 						structs.GlobalURLMapLock.Lock()
@@ -349,7 +382,7 @@ func DirBruteCallBack(resp runner.Result) {
 								Finger:        []string{productName},
 								Domain:        "",
 								GoPoc:         ddout.GoPocsResultType{},
-								URI:           resp.URL,
+								URI:           respURL,
 								AdditionalMsg: "",
 							})
 							// gologger.Silent().Msgf("[Active-Finger] %s [%s]", resp.URL, productName)
@@ -362,7 +395,12 @@ func DirBruteCallBack(resp runner.Result) {
 }
 
 func HostBindHTTPxCallBack(resp runner.Result) {
+	vhost := hostBindHost(resp)
 	ips := resp.A
+	if vhost != "" {
+		ips = []string{resp.Host}
+	}
+	md5, _ := resp.Hashes["body_md5"].(string)
 	path := resp.Path
 	newWeb := false
 	for _, ip := range ips {
@@ -388,7 +426,9 @@ func HostBindHTTPxCallBack(resp runner.Result) {
 				continue
 			}
 
-			if existPath.StatusCode != resp.StatusCode || existPath.Hash != existPath.Hash {
+			// 动态页面每次的Hash都不同，同时比较长度
+			if existPath.StatusCode != resp.StatusCode || existPath.Title != resp.Title ||
+				(existPath.Hash != md5 && existPath.ContentLength != resp.ContentLength) {
 				newWeb = true
 			}
 
@@ -400,6 +440,17 @@ func HostBindHTTPxCallBack(resp runner.Result) {
 		return
 	}
 
+	uri := resp.URL
+	finalUrl := resp.URL
+	if resp.FinalURL != "" {
+		finalUrl = resp.FinalURL
+	}
+	ipURL := URLParse(finalUrl)
+	if vhost != "" {
+		uri = replaceURLHost(uri, vhost)
+		finalUrl = replaceURLHost(finalUrl, vhost)
+	}
+
 	ddout.FormatOutput(ddout.OutputMessage{
 		Type:     "Domain-Bind",
 		IP:       "",
@@ -412,7 +463,7 @@ func HostBindHTTPxCallBack(resp runner.Result) {
 		Finger:        nil,
 		Domain:        "",
 		GoPoc:         ddout.GoPocsResultType{},
-		URI:           resp.URL,
+		URI:           uri,
 		AdditionalMsg: resp.Title,
 	})
 
@@ -422,13 +473,6 @@ func HostBindHTTPxCallBack(resp runner.Result) {
 	//	gologger.Silent().Msgf("[Domain-Bind] [%v] %v", resp.StatusCode, resp.URL)
 	//}
 
-	finalUrl := ""
-	if resp.FinalURL != "" {
-		finalUrl = resp.FinalURL
-	} else {
-		finalUrl = resp.URL
-	}
-
 	urlFinal := URLParse(finalUrl)
 	rootURL := fmt.Sprintf("%s://%s", urlFinal.Scheme, urlFinal.Host)
 	structs.GlobalURLMapLock.Lock()
@@ -441,7 +485,6 @@ func HostBindHTTPxCallBack(resp runner.Result) {
 		structs.GlobalURLMapLock.Unlock()
 		if !pathOK {
 			// 没有这个path
-			md5 := resp.Hashes["body_md5"].(string)
 			headerMd5 := resp.Hashes["header_md5"].(string)
 			_ = structs.GlobalHttpBodyHMap.Set(md5, []byte(resp.Body))
 			_ = structs.GlobalHttpHeaderHMap.Set(headerMd5, []byte(resp.Header))
@@ -466,7 +509,6 @@ func HostBindHTTPxCallBack(resp runner.Result) {
 			port = 0
 		}
 
-		md5 := resp.Hashes["body_md5"].(string)
 		headerMd5 := resp.Hashes["header_md5"].(string)
 		_ = structs.GlobalHttpBodyHMap.Set(md5, []byte(resp.Body))
 		_ = structs.GlobalHttpHeaderHMap.Set(headerMd5, []byte(resp.Header))
@@ -492,9 +534,32 @@ func HostBindHTTPxCallBack(resp runner.Result) {
 		urlE.WebPaths = make(map[string]structs.UrlPathEntity)
 		urlE.WebPaths[urlFinal.Path] = webPath
 
+		// 内网主机名通常无法解析，后续的主动指纹、Nuclei与GoPoc需要直接连接IP
+		if vhost != "" && ipURL != nil && !uncover.HostResolves(urlFinal.Hostname()) {
+			uncover.AddHostBind(rootURL, fmt.Sprintf("%s://%s", ipURL.Scheme, ipURL.Host))
+		}
+
 		structs.GlobalURLMapLock.Lock()
 		structs.GlobalURLMap[rootURL] = urlE
 		structs.GlobalURLMapLock.Unlock()
 	}
 
 }
+
+// hostBindHost 输入为 Host头,URL 时直接连接IP，返回Host头，结果中的URL需要替换为Host头
+func hostBindHost(resp runner.Result) string {
+	if i := strings.Index(resp.Input, ","); i > 0 && !strings.Contains(resp.Input[:i], "://") {
+		return resp.Input[:i]
+	}
+	return ""
+}
+
+// replaceURLHost 替换URL中的 host:port
+func replaceURLHost(rawURL string, host string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Host = host
+	return u.String()
+}
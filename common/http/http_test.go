@@ -0,0 +1,66 @@
+package http
+
+import (
+	"dddd/structs"
+	"github.com/projectdiscovery/hmap/store/hybrid"
+	"github.com/projectdiscovery/httpx/runner"
+	"testing"
+)
+
+func TestHostBindHTTPxCallBack(t *testing.T) {
+	for _, m := range []**hybrid.HybridMap{&structs.GlobalHttpBodyHMap, &structs.GlobalHttpHeaderHMap} {
+		hm, err := hybrid.New(hybrid.DefaultMemoryOptions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*m = hm
+	}
+	defer func() {
+		structs.GlobalHttpBodyHMap.Close()
+		structs.GlobalHttpHeaderHMap.Close()
+		structs.GlobalHttpBodyHMap, structs.GlobalHttpHeaderHMap = nil, nil
+		structs.GlobalURLMap, structs.GlobalHostBindMap = nil, nil
+	}()
+
+	tests := []struct {
+		name    string
+		vhost   string
+		rootURL string
+		bound   bool
+	}{
+		// .invalid 保留后缀不会被解析
+		{"unresolvable", "oa.corp.invalid:8443", "https://oa.corp.invalid:8443", true},
+		{"resolvable", "localhost:8443", "https://localhost:8443", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			structs.GlobalURLMap = map[string]structs.URLEntity{
+				"https://10.0.0.5:8443": {IP: "10.0.0.5", Port: 8443, WebPaths: map[string]structs.UrlPathEntity{
+					"/": {StatusCode: 404, Title: "Not Found", Hash: "a"},
+				}},
+			}
+			structs.GlobalHostBindMap = make(map[string]string)
+
+			HostBindHTTPxCallBack(runner.Result{
+				Input:      tt.vhost + ",https://10.0.0.5:8443",
+				URL:        "https://10.0.0.5:8443/",
+				Host:       "10.0.0.5",
+				Port:       "8443",
+				Scheme:     "https",
+				Path:       "/",
+				Title:      "OA",
+				StatusCode: 200,
+				Hashes:     map[string]interface{}{"body_md5": "b", "header_md5": "c"},
+			})
+
+			urlE, ok := structs.GlobalURLMap[tt.rootURL]
+			if !ok || urlE.IP != "10.0.0.5" {
+				t.Fatalf("GlobalURLMap[%q] = %+v, %v", tt.rootURL, urlE, ok)
+			}
+			ipRootURL, bound := structs.GlobalHostBindMap[tt.rootURL]
+			if bound != tt.bound || (bound && ipRootURL != "https://10.0.0.5:8443") {
+				t.Errorf("GlobalHostBindMap[%q] = %q, %v, want bound %v", tt.rootURL, ipRootURL, bound, tt.bound)
+			}
+		})
+	}
+}
@@ -33,10 +33,17 @@ func IsContain(items []string, item string) bool {
 func CheckLive(hostslist []string, Ping bool) []string {
 	gologger.AuditTimeLogger("ICMP发包探测存活，目标IP如下")
 	gologger.AuditLogger(strings.Join(hostslist, ","))
+	// C段抽样时会多次调用
+	AliveHosts = nil
+	targets := make(map[string]struct{}, len(hostslist))
+	for _, ip := range hostslist {
+		targets[ip] = struct{}{}
+	}
 	chanHosts := make(chan string, len(hostslist))
 	go func() {
 		for ip := range chanHosts {
-			if _, ok := ExistHosts[ip]; !ok && IsContain(hostslist, ip) {
+			_, isTarget := targets[ip]
+			if _, ok := ExistHosts[ip]; !ok && isTarget {
 				ExistHosts[ip] = struct{}{}
 				// gologger.Silent().Msgf("[ICMP-Alive] %v", ip)
 				ddout.FormatOutput(ddout.OutputMessage{
@@ -84,6 +91,9 @@ func CheckLive(hostslist []string, Ping bool) []string {
 
 func RunIcmp1(hostslist []string, conn *icmp.PacketConn, chanHosts chan string) {
 	endflag := false
+	// 记录发送时间，应答的RTT用于TCP端口扫描的自适应超时
+	var sentLock sync.Mutex
+	sent := make(map[string]time.Time, len(hostslist))
 	go func() {
 		for {
 			if endflag == true {
@@ -92,6 +102,12 @@ func RunIcmp1(hostslist []string, conn *icmp.PacketConn, chanHosts chan string)
 			msg := make([]byte, 100)
 			_, sourceIP, _ := conn.ReadFrom(msg)
 			if sourceIP != nil {
+				sentLock.Lock()
+				start, ok := sent[sourceIP.String()]
+				sentLock.Unlock()
+				if ok {
+					RecordRTT(sourceIP.String(), time.Since(start))
+				}
 				livewg.Add(1)
 				chanHosts <- sourceIP.String()
 			}
@@ -101,6 +117,9 @@ func RunIcmp1(hostslist []string, conn *icmp.PacketConn, chanHosts chan string)
 	for _, host := range hostslist {
 		dst, _ := net.ResolveIPAddr("ip", host)
 		IcmpByte := makemsg(host)
+		sentLock.Lock()
+		sent[host] = time.Now()
+		sentLock.Unlock()
 		conn.WriteTo(IcmpByte, dst)
 	}
 	//根据hosts数量修改icmp监听时间
@@ -0,0 +1,128 @@
+package common
+
+import (
+	"github.com/projectdiscovery/gologger"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ndpNeighbors 向 ff02::1 发送ICMPv6 Echo 获取链路上的IPv6主机，再逐个发送邻居请求(NS)，
+// 从邻居通告(NA)的目标链路层地址选项中取得MAC，返回 ipv6->MAC
+func ndpNeighbors(iface *net.Interface, timeout time.Duration) (map[string]net.HardwareAddr, error) {
+	c, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	p := c.IPv6PacketConn()
+	_ = p.SetControlMessage(ipv6.FlagInterface, true)
+	// 邻居发现报文的跳数限制必须为255
+	cm := &ipv6.ControlMessage{HopLimit: 255, IfIndex: iface.Index}
+
+	var lock sync.Mutex
+	responders := make(map[string]bool)
+	result := make(map[string]net.HardwareAddr)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 1500)
+		for {
+			n, rcm, src, err := p.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if rcm != nil && rcm.IfIndex != iface.Index {
+				continue
+			}
+			srcAddr, ok := src.(*net.IPAddr)
+			if !ok || n < 8 {
+				continue
+			}
+			switch ipv6.ICMPType(buf[0]) {
+			case ipv6.ICMPTypeEchoReply:
+				lock.Lock()
+				responders[srcAddr.IP.String()] = true
+				lock.Unlock()
+			case ipv6.ICMPTypeNeighborAdvertisement:
+				if n < 24 {
+					continue
+				}
+				target := net.IP(append([]byte{}, buf[8:24]...))
+				// 选项 type=2 Target Link-Layer Address
+				for off := 24; off+8 <= n && buf[off+1] > 0; off += int(buf[off+1]) * 8 {
+					if buf[off] == 2 {
+						lock.Lock()
+						result[target.String()] = net.HardwareAddr(append([]byte{}, buf[off+2:off+8]...))
+						lock.Unlock()
+						break
+					}
+				}
+			}
+		}
+	}()
+
+	echo := icmp.Message{
+		Type: ipv6.ICMPTypeEchoRequest,
+		Body: &icmp.Echo{ID: os.Getpid() & 0xffff, Seq: 1, Data: []byte("dddd")},
+	}
+	b, _ := echo.Marshal(nil)
+	allNodes := &net.IPAddr{IP: net.ParseIP("ff02::1"), Zone: iface.Name}
+	if _, err = p.WriteTo(b, cm, allNodes); err != nil {
+		return nil, err
+	}
+	time.Sleep(timeout)
+
+	lock.Lock()
+	var targets []net.IP
+	for ip := range responders {
+		targets = append(targets, net.ParseIP(ip))
+	}
+	lock.Unlock()
+	for _, target := range targets {
+		if isLocalAddr(iface, target) {
+			continue
+		}
+		// 请求节点组播地址 ff02::1:ffXX:XXXX
+		dst := net.ParseIP("ff02::1:ff00:0")
+		copy(dst[13:], target[13:])
+		body := append([]byte{0, 0, 0, 0}, target...)
+		// 选项 type=1 Source Link-Layer Address
+		body = append(body, 1, 1)
+		body = append(body, iface.HardwareAddr...)
+		ns := icmp.Message{Type: ipv6.ICMPTypeNeighborSolicitation, Body: &icmp.RawBody{Data: body}}
+		b, _ = ns.Marshal(nil)
+		gologger.AuditTimeLogger("[NDP] %s who-has %s", iface.Name, target)
+		_, _ = p.WriteTo(b, cm, &net.IPAddr{IP: dst, Zone: iface.Name})
+	}
+	if len(targets) > 0 {
+		time.Sleep(timeout)
+	}
+	c.Close()
+	<-done
+
+	lock.Lock()
+	defer lock.Unlock()
+	for ip := range result {
+		if !responders[ip] || isLocalAddr(iface, net.ParseIP(ip)) {
+			delete(result, ip)
+		}
+	}
+	return result, nil
+}
+
+func isLocalAddr(iface *net.Interface, ip net.IP) bool {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
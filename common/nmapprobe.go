@@ -0,0 +1,108 @@
+package common
+
+import (
+	"fmt"
+	"github.com/lcvvvv/gonmap"
+	"github.com/projectdiscovery/gologger"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// NmapProbeDir 外部协议识别探针目录，目录存在时在内置探针的基础上加载：
+//
+//	*.probes   nmap-service-probes 格式的探针，与内置探针同名时只合并指纹与端口
+//	*.match    指纹行，格式为 <探针名> match|softmatch <指纹>
+//	ports.yaml 端口默认使用的探针及顺序，如 8443: [TCP_GetRequest, TCP_TLSSessionReq]
+var NmapProbeDir = "config/nmap"
+
+// NmapPortProbeFile 端口默认探针顺序的配置文件名
+const NmapPortProbeFile = "ports.yaml"
+
+// LoadNmapProbes 加载并校验外部探针，任一文件有误时返回错误
+func LoadNmapProbes() error {
+	if !fileExists(NmapProbeDir) {
+		return nil
+	}
+	entries, err := os.ReadDir(NmapProbeDir)
+	if err != nil {
+		return err
+	}
+	var probeFiles, matchFiles []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch filepath.Ext(entry.Name()) {
+		case ".probes":
+			probeFiles = append(probeFiles, filepath.Join(NmapProbeDir, entry.Name()))
+		case ".match":
+			matchFiles = append(matchFiles, filepath.Join(NmapProbeDir, entry.Name()))
+		}
+	}
+	sort.Strings(probeFiles)
+	sort.Strings(matchFiles)
+
+	probesCount, matchCount := gonmap.ProbesCount, gonmap.MatchCount
+	// 先加载探针，指纹行可以引用外部探针
+	for _, file := range probeFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := gonmap.LoadProbes(filepath.ToSlash(file), string(data)); err != nil {
+			return err
+		}
+	}
+	for _, file := range matchFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := gonmap.LoadMatches(filepath.ToSlash(file), string(data)); err != nil {
+			return err
+		}
+	}
+
+	portProbeFile := filepath.Join(NmapProbeDir, NmapPortProbeFile)
+	portCount := 0
+	if fileExists(portProbeFile) {
+		data, err := os.ReadFile(portProbeFile)
+		if err != nil {
+			return err
+		}
+		portProbes := make(map[string][]string)
+		if err := yaml.Unmarshal(data, &portProbes); err != nil {
+			return fmt.Errorf("%s 解析失败: %v", portProbeFile, err)
+		}
+		for ports, probes := range portProbes {
+			if err := CheckPortString(ports); err != nil {
+				return fmt.Errorf("%s: %v", portProbeFile, err)
+			}
+			portList := ParsePort(ports)
+			if len(portList) == 0 {
+				return fmt.Errorf("%s: 端口设置有误: %s", portProbeFile, ports)
+			}
+			for _, port := range portList {
+				if err := gonmap.SetPortProbes(port, probes); err != nil {
+					return fmt.Errorf("%s: %s: %v", portProbeFile, ports, err)
+				}
+			}
+			portCount += len(portList)
+		}
+	}
+
+	if len(probeFiles)+len(matchFiles) > 0 || portCount > 0 {
+		gologger.Info().Msgf("外部协议识别探针: 新增探针 %d 个，新增指纹 %d 条，指定探针顺序端口 %d 个",
+			gonmap.ProbesCount-probesCount, gonmap.MatchCount-matchCount, portCount)
+		gologger.AuditLogger("外部协议识别探针文件: %s", strings.Join(append(probeFiles, matchFiles...), ","))
+	}
+	return nil
+}
+
+// isExternalMatch 指纹来自外部探针目录
+func isExternalMatch(source string) bool {
+	return strings.HasPrefix(source, filepath.ToSlash(NmapProbeDir)+"/")
+}
@@ -0,0 +1,383 @@
+package common
+
+import (
+	"bufio"
+	"dddd/common/uncover"
+	"dddd/ddout"
+	"dddd/structs"
+	"dddd/utils"
+	"encoding/binary"
+	"github.com/projectdiscovery/gologger"
+	"golang.org/x/net/dns/dnsmessage"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 被动发现：只监听局域网内的广播/组播报文，不发送任何数据包。
+// NBNS(137)、DHCP(67) 绑定知名端口，需要root权限且端口未被占用；LLMNR、mDNS、SSDP 加入组播组监听。
+
+type PassiveHost struct {
+	IP       string
+	Names    []string
+	Services []string
+	Model    string
+	MAC      string
+	Sources  []string
+}
+
+var passiveHosts = make(map[string]*PassiveHost)
+var passiveHostsLock sync.Mutex
+
+// 本机地址不记录
+var passiveLocalIPs = make(map[string]bool)
+
+func appendUnique(items []string, item string) []string {
+	item = strings.TrimSpace(item)
+	if item == "" || IsContain(items, item) {
+		return items
+	}
+	return append(items, item)
+}
+
+func passiveRecord(ip, source string, f func(h *PassiveHost)) {
+	parsed := net.ParseIP(ip).To4()
+	if parsed == nil || parsed.IsUnspecified() || parsed.Equal(net.IPv4bcast) || passiveLocalIPs[ip] {
+		return
+	}
+	passiveHostsLock.Lock()
+	defer passiveHostsLock.Unlock()
+	h, ok := passiveHosts[ip]
+	if !ok {
+		h = &PassiveHost{IP: ip}
+		passiveHosts[ip] = h
+		gologger.Info().Msgf("[Passive] 发现主机 %s (%s)", ip, source)
+	}
+	h.Sources = appendUnique(h.Sources, source)
+	if f != nil {
+		f(h)
+	}
+}
+
+// nbnsDecodeName NetBIOS 一级编码，返回名称与后缀
+func nbnsDecodeName(encoded []byte) (string, byte) {
+	if len(encoded) != 32 {
+		return "", 0
+	}
+	name := make([]byte, 16)
+	for i := 0; i < 16; i++ {
+		name[i] = (encoded[2*i]-'A')<<4 | (encoded[2*i+1] - 'A')
+	}
+	return strings.TrimSpace(string(name[:15])), name[15]
+}
+
+// passiveNBNS 只处理名称注册/刷新，查询报文中的名称不属于发送方
+func passiveNBNS(src string, data []byte) {
+	if len(data) < 12+34 {
+		return
+	}
+	opcode := (binary.BigEndian.Uint16(data[2:4]) >> 11) & 0x0f
+	passiveRecord(src, "NBNS", nil)
+	if opcode != 5 && opcode != 8 && opcode != 9 {
+		return
+	}
+	if data[12] != 0x20 {
+		return
+	}
+	name, suffix := nbnsDecodeName(data[13:45])
+	if name == "" {
+		return
+	}
+	ip := src
+	group := false
+	// 附加记录: 名称指针(2) type(2) class(2) ttl(4) rdlength(2) NB_FLAGS(2) 地址(4)
+	offset := 12 + 34 + 4
+	if len(data) >= offset+18 && data[offset]&0xc0 == 0xc0 {
+		rdata := data[offset+12 : offset+18]
+		group = rdata[0]&0x80 != 0
+		ip = net.IP(rdata[2:6]).String()
+	}
+	passiveRecord(ip, "NBNS", func(h *PassiveHost) {
+		if group {
+			h.Services = appendUnique(h.Services, "NetBIOS-Group:"+name)
+		} else if suffix == 0x00 || suffix == 0x20 {
+			h.Names = appendUnique(h.Names, name)
+		}
+	})
+}
+
+// passiveLLMNR LLMNR 响应为单播，只能看到查询方与其查询的名称
+func passiveLLMNR(src string, data []byte) {
+	var p dnsmessage.Parser
+	header, err := p.Start(data)
+	if err != nil || header.Response {
+		return
+	}
+	questions, _ := p.AllQuestions()
+	passiveRecord(src, "LLMNR", func(h *PassiveHost) {
+		for _, q := range questions {
+			h.Services = appendUnique(h.Services, "LLMNR-Query:"+strings.TrimSuffix(q.Name.String(), "."))
+		}
+	})
+}
+
+// mdnsModelKeys TXT记录中表示设备型号的字段
+var mdnsModelKeys = []string{"md", "model", "am", "ty", "usb_MDL", "product"}
+
+func passiveMDNS(src string, data []byte) {
+	var p dnsmessage.Parser
+	if _, err := p.Start(data); err != nil {
+		return
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return
+	}
+	passiveRecord(src, "mDNS", nil)
+	for {
+		r, err := p.Answer()
+		if err == dnsmessage.ErrSectionDone {
+			// 附加记录中也包含A记录与TXT
+			if err = p.SkipAllAuthorities(); err != nil {
+				return
+			}
+			for {
+				r, err = p.Additional()
+				if err != nil {
+					return
+				}
+				passiveMDNSRecord(src, r)
+			}
+		}
+		if err != nil {
+			return
+		}
+		passiveMDNSRecord(src, r)
+	}
+}
+
+func passiveMDNSRecord(src string, r dnsmessage.Resource) {
+	name := strings.TrimSuffix(r.Header.Name.String(), ".")
+	switch body := r.Body.(type) {
+	case *dnsmessage.AResource:
+		passiveRecord(net.IP(body.A[:]).String(), "mDNS", func(h *PassiveHost) {
+			h.Names = appendUnique(h.Names, name)
+		})
+	case *dnsmessage.PTRResource:
+		// _http._tcp.local -> xxx._http._tcp.local
+		if strings.HasPrefix(name, "_") && !strings.HasPrefix(name, "_services.") {
+			passiveRecord(src, "mDNS", func(h *PassiveHost) {
+				h.Services = appendUnique(h.Services, strings.TrimSuffix(name, ".local"))
+			})
+		}
+	case *dnsmessage.SRVResource:
+		passiveRecord(src, "mDNS", func(h *PassiveHost) {
+			h.Names = appendUnique(h.Names, strings.TrimSuffix(body.Target.String(), "."))
+		})
+	case *dnsmessage.TXTResource:
+		for _, txt := range body.TXT {
+			k, v, ok := strings.Cut(txt, "=")
+			if !ok || v == "" {
+				continue
+			}
+			for _, key := range mdnsModelKeys {
+				if strings.EqualFold(k, key) {
+					passiveRecord(src, "mDNS", func(h *PassiveHost) {
+						if h.Model == "" {
+							h.Model = v
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+// ssdpDeviceType urn:schemas-upnp-org:device:MediaRenderer:1 -> MediaRenderer
+func ssdpDeviceType(nt string) string {
+	fields := strings.Split(nt, ":")
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "device" || fields[i] == "service" {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
+
+func passiveSSDP(src string, data []byte) {
+	line, rest, _ := strings.Cut(string(data), "\r\n")
+	if !strings.HasPrefix(line, "NOTIFY") && !strings.HasPrefix(line, "M-SEARCH") {
+		return
+	}
+	// 复用HTTP头解析
+	reader := bufio.NewReader(strings.NewReader("HTTP/1.1 200 OK\r\n" + rest))
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		passiveRecord(src, "SSDP", nil)
+		return
+	}
+	resp.Body.Close()
+	passiveRecord(src, "SSDP", func(h *PassiveHost) {
+		if server := resp.Header.Get("Server"); server != "" && h.Model == "" {
+			h.Model = server
+		}
+		if deviceType := ssdpDeviceType(resp.Header.Get("NT")); deviceType != "" {
+			h.Services = appendUnique(h.Services, "upnp:"+deviceType)
+		}
+		if location := resp.Header.Get("Location"); location != "" {
+			h.Services = appendUnique(h.Services, "upnp-location:"+location)
+		}
+	})
+}
+
+func passiveDHCP(data []byte) {
+	// BOOTREQUEST，magic cookie 99.130.83.99
+	if len(data) < 240 || data[0] != 1 || binary.BigEndian.Uint32(data[236:240]) != 0x63825363 {
+		return
+	}
+	mac := net.HardwareAddr(append([]byte{}, data[28:34]...))
+	ip := net.IP(data[12:16]).String()
+	var hostname, vendorClass string
+	for offset := 240; offset+2 <= len(data); {
+		code := data[offset]
+		if code == 0xff {
+			break
+		}
+		if code == 0 {
+			offset++
+			continue
+		}
+		n := int(data[offset+1])
+		if offset+2+n > len(data) {
+			break
+		}
+		value := data[offset+2 : offset+2+n]
+		switch code {
+		case 12:
+			hostname = string(value)
+		case 50:
+			if n == 4 && ip == "0.0.0.0" {
+				ip = net.IP(value).String()
+			}
+		case 60:
+			vendorClass = string(value)
+		case 81:
+			// Client FQDN: flags rcode1 rcode2 name
+			if n > 3 && hostname == "" {
+				hostname = string(value[3:])
+			}
+		}
+		offset += 2 + n
+	}
+	passiveRecord(ip, "DHCP", func(h *PassiveHost) {
+		h.MAC = mac.String()
+		h.Names = appendUnique(h.Names, hostname)
+		if vendorClass != "" {
+			h.Services = appendUnique(h.Services, "dhcp-vendor:"+vendorClass)
+		}
+	})
+}
+
+type passiveListener struct {
+	Name    string
+	Group   string
+	Port    int
+	Handler func(src string, data []byte)
+}
+
+var passiveListeners = []passiveListener{
+	{"NBNS", "", 137, passiveNBNS},
+	{"LLMNR", "224.0.0.252", 5355, passiveLLMNR},
+	{"mDNS", "224.0.0.251", 5353, passiveMDNS},
+	{"SSDP", "239.255.255.250", 1900, passiveSSDP},
+	{"DHCP", "", 67, func(src string, data []byte) { passiveDHCP(data) }},
+}
+
+func passiveListen(l passiveListener, deadline time.Time, wg *sync.WaitGroup) {
+	defer wg.Done()
+	var conn net.PacketConn
+	var err error
+	if l.Group != "" {
+		conn, err = net.ListenMulticastUDP("udp4", nil, &net.UDPAddr{IP: net.ParseIP(l.Group), Port: l.Port})
+	} else {
+		conn, err = net.ListenPacket("udp4", net.JoinHostPort("0.0.0.0", strconv.Itoa(l.Port)))
+	}
+	if err != nil {
+		gologger.Warning().Msgf("[Passive] %s 监听失败: %v", l.Name, err)
+		return
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(deadline)
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		gologger.AuditTimeLogger("[Passive] [%s] %d bytes from %s", l.Name, n, udpAddr)
+		l.Handler(udpAddr.IP.String(), append([]byte{}, buf[:n]...))
+	}
+}
+
+// PassiveListen 监听 -passive 指定的秒数，发现的主机加入目标列表，主机名写入 GlobalIPDomainMap
+func PassiveListen() []string {
+	duration := time.Duration(structs.GlobalConfig.PassiveListen) * time.Second
+	gologger.Info().Msgf("被动发现: 监听NBNS/LLMNR/mDNS/SSDP/DHCP广播 %v", duration)
+
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				passiveLocalIPs[ipnet.IP.String()] = true
+			}
+		}
+	}
+
+	deadline := time.Now().Add(duration)
+	var wg sync.WaitGroup
+	for _, l := range passiveListeners {
+		wg.Add(1)
+		go passiveListen(l, deadline, &wg)
+	}
+	wg.Wait()
+
+	passiveHostsLock.Lock()
+	defer passiveHostsLock.Unlock()
+	var ips []string
+	for ip := range passiveHosts {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	for _, ip := range ips {
+		h := passiveHosts[ip]
+		for _, name := range h.Names {
+			uncover.AddHostName(ip, name, "passive")
+		}
+		// 主机名同时作为爆破字典关键字
+		uncover.AddTargetKeyword(ip, h.Names...)
+		if h.MAC != "" {
+			if mac, err := net.ParseMAC(h.MAC); err == nil {
+				addHostMAC(ip, mac, "DHCP")
+			}
+		}
+		ddout.FormatOutput(ddout.OutputMessage{
+			Type:          "Passive",
+			IP:            ip,
+			Names:         h.Names,
+			Services:      h.Services,
+			Model:         h.Model,
+			MAC:           h.MAC,
+			AdditionalMsg: strings.Join(h.Sources, ","),
+		})
+		structs.GlobalConfig.Targets = append(structs.GlobalConfig.Targets, ip)
+	}
+	structs.GlobalConfig.Targets = utils.RemoveDuplicateElement(structs.GlobalConfig.Targets)
+	gologger.Info().Msgf("被动发现结束，共发现 %d 个主机", len(ips))
+	return ips
+}
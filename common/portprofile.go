@@ -0,0 +1,132 @@
+package common
+
+import (
+	"dddd/utils"
+	"fmt"
+	"github.com/lcvvvv/gonmap"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// 端口组，-p、-np、-tpp 中可与端口、端口范围、服务名混用，如 top100,db,8848,redis
+var PortProfiles = map[string]string{
+	"web":     "80,81,82,83,84,85,88,443,591,888,2082,2083,2086,2087,3000,3001,4443,5000,5001,7001,7002,7080,7443,8000,8001,8002,8008,8009,8010,8042,8060,8069,8080,8081,8082,8083,8084,8085,8086,8088,8089,8090,8091,8161,8180,8181,8443,8761,8800,8848,8880,8888,8899,9000,9001,9043,9060,9080,9090,9091,9200,9443,9999,10000,10080,10443,18080,18443,50070",
+	"db":      "1433,1521,1522,1583,2638,3050,3306,3307,3351,5236,5432,5984,6379,7474,8086,8123,9042,9200,9300,11211,26257,27017,27018,28017,50000,54321",
+	"windows": "53,88,135,137,139,389,445,464,593,636,1433,3268,3269,3389,5985,5986,9389,47001",
+	"iot":     "23,80,81,443,554,1883,2323,5000,7547,8000,8080,8291,8443,8554,8728,8883,8899,9527,34567,37215,37777,49152,52869",
+	"ics":     "102,502,789,1217,1911,1962,2404,2455,4911,5007,9600,18245,18246,20000,20547,44818",
+}
+
+// PortProfileNames -list-ports 未指定 -p 时输出的端口组
+var PortProfileNames = []string{"top100", "top1000", "web", "db", "windows", "iot", "ics", "all"}
+
+var portRanking struct {
+	once  sync.Once
+	ports []int
+}
+
+// rankedPorts top-N 使用的端口排序：nmap开放频率前100，其后为默认Top1000，再其后为 nmap-services 中有服务名的端口
+func rankedPorts() []int {
+	portRanking.once.Do(func() {
+		ports := gonmap.TopPorts(100)
+		ports = append(ports, ParsePort(PortTOP1000)...)
+		named := gonmap.NamedPorts()
+		sort.Ints(named)
+		ports = append(ports, named...)
+		for i := 1; i <= 65535; i++ {
+			ports = append(ports, i)
+		}
+		portRanking.ports = utils.RemoveDuplicateElementInt(ports)
+	})
+	return portRanking.ports
+}
+
+// topPorts 排序后的前N个端口，topN 总是 topM(M>N) 的子集
+func topPorts(n int) []int {
+	ports := rankedPorts()
+	if n > len(ports) {
+		n = len(ports)
+	}
+	return append([]int{}, ports[:n]...)
+}
+
+func isPortName(s string) bool {
+	for _, c := range s {
+		if unicode.IsLetter(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandPortName 解析端口组(top100,top-N,web,db...)或服务名(redis,mysql...)
+func expandPortName(name string) ([]int, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "all" {
+		return topPorts(65535), true
+	}
+	if ports, ok := PortProfiles[name]; ok {
+		return ParsePort(ports), true
+	}
+	if strings.HasPrefix(name, "top") {
+		n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(name, "top"), "-"))
+		if err == nil && n > 0 {
+			return topPorts(n), true
+		}
+		return nil, false
+	}
+	if ports := gonmap.ServicePorts(name); len(ports) > 0 {
+		return ports, true
+	}
+	return nil, false
+}
+
+// CheckPortString 校验端口设置中的端口组与服务名
+func CheckPortString(ports string) error {
+	for _, port := range strings.Split(ports, ",") {
+		port = strings.TrimSpace(port)
+		if !isPortName(port) {
+			continue
+		}
+		if _, ok := expandPortName(port); !ok {
+			return fmt.Errorf("未知的端口组或服务名: %s", port)
+		}
+	}
+	return nil
+}
+
+// compactPorts 将端口排序并合并连续端口为范围，结果可直接用于 -p
+func compactPorts(ports []int) string {
+	ports = append([]int{}, ports...)
+	sort.Ints(ports)
+	var parts []string
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", ports[i], ports[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(ports[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// ListPorts 输出端口设置展开后的端口，未指定时输出所有端口组
+func ListPorts(ports string) {
+	if ports == "" {
+		for _, name := range PortProfileNames {
+			p, _ := expandPortName(name)
+			fmt.Printf("%s (%d): %s\n", name, len(p), compactPorts(p))
+		}
+		return
+	}
+	p := ParsePort(ports)
+	fmt.Printf("%s (%d): %s\n", ports, len(p), compactPorts(p))
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"github.com/lcvvvv/gonmap"
+	"reflect"
+	"testing"
+)
+
+func TestCompactPorts(t *testing.T) {
+	tests := []struct {
+		ports []int
+		want  string
+	}{
+		{nil, ""},
+		{[]int{80}, "80"},
+		{[]int{443, 80, 81, 82}, "80-82,443"},
+		{[]int{1, 2, 3, 5, 7, 8}, "1-3,5,7-8"},
+		{[]int{65535, 65534, 22}, "22,65534-65535"},
+	}
+	for _, tt := range tests {
+		if got := compactPorts(tt.ports); got != tt.want {
+			t.Errorf("compactPorts(%v) = %q, want %q", tt.ports, got, tt.want)
+		}
+	}
+}
+
+func TestTopPorts(t *testing.T) {
+	all := topPorts(65535)
+	if len(all) != 65535 {
+		t.Fatalf("len(topPorts(65535)) = %d", len(all))
+	}
+	if got := topPorts(100); !reflect.DeepEqual(got, gonmap.TopPorts(100)) {
+		t.Errorf("topPorts(100) = %v, want nmap top100", got)
+	}
+	tests := []int{1, 10, 100, 101, 500, 1000, 1005, 3000, 70000}
+	for _, n := range tests {
+		got := topPorts(n)
+		want := n
+		if want > len(all) {
+			want = len(all)
+		}
+		// 每个topN都是同一排序的前N个
+		if len(got) != want || !reflect.DeepEqual(got, all[:want]) {
+			t.Errorf("topPorts(%d) is not the first %d ranked ports (len %d)", n, want, len(got))
+		}
+	}
+	// 返回副本，修改不影响排序
+	top := topPorts(10)
+	top[0] = -1
+	if topPorts(10)[0] == -1 {
+		t.Error("topPorts() shares the ranked slice")
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"dddd/lib/masscan"
 	"dddd/structs"
 	"dddd/utils"
-	"fmt"
 	"github.com/projectdiscovery/gologger"
 	"os"
 	"os/exec"
@@ -26,6 +25,13 @@ func ParsePort(ports string) (scanPorts []int) {
 		if port == "" {
 			continue
 		}
+		// 端口组与服务名
+		if isPortName(port) {
+			if ports, ok := expandPortName(port); ok {
+				scanPorts = append(scanPorts, ports...)
+			}
+			continue
+		}
 		upper := port
 		if strings.Contains(port, "-") {
 			ranges := strings.Split(port, "-")
@@ -57,6 +63,21 @@ var BackList map[string]struct{}
 var BackListLock sync.Mutex
 
 func PortScanTCP(IPs []string, Ports string, NoPorts string, timeout int) []string {
+	return PortScanTCPStream(IPs, Ports, NoPorts, timeout, nil)
+}
+
+// PortScanResult 流式端口扫描结果，Port为空表示该IP的所有端口已经探测完成
+type PortScanResult struct {
+	IP   string
+	Port string
+}
+
+// 流式扫描时按块探测，块内依次对每个端口探测所有IP，块内IP的端口全部探测完成后即可判断是否超出阈值
+const portScanBlockSize = 256
+
+// PortScanTCPStream 开放端口在发现时即写入out，IP的所有端口探测完成后写入只有IP的结果，
+// out已满时扫描阻塞等待下游处理
+func PortScanTCPStream(IPs []string, Ports string, NoPorts string, timeout int, out chan<- PortScanResult) []string {
 	var AliveAddress []string
 	gologger.AuditTimeLogger("开始TCP端口扫描，端口设置: %s\nTCP端口扫描目标:%s", Ports, strings.Join(IPs, ","))
 	ports := ParsePort(Ports)
@@ -84,16 +105,41 @@ func PortScanTCP(IPs []string, Ports string, NoPorts string, timeout int) []stri
 		workers = len(IPs) * len(probePorts)
 	}
 	Addrs := make(chan Addr, structs.GlobalConfig.TCPPortScanThreads)
-	results := make(chan string, structs.GlobalConfig.TCPPortScanThreads)
+	results := make(chan PortScanResult, structs.GlobalConfig.TCPPortScanThreads)
 	var wg sync.WaitGroup
 
+	// 每个IP尚未探测的端口数
+	remaining := make(map[string]int)
+	var remainingLock sync.Mutex
+	for _, host := range IPs {
+		remaining[host] += len(probePorts)
+	}
+	finish := func(ip string) {
+		remainingLock.Lock()
+		remaining[ip]--
+		done := remaining[ip] == 0
+		remainingLock.Unlock()
+		// 该IP的开放端口已经先于此写入results
+		if done {
+			wg.Add(1)
+			results <- PortScanResult{IP: ip}
+		}
+	}
+
 	//接收结果
 	go func() {
 		for found := range results {
-			AliveAddress = append(AliveAddress, found)
+			if found.Port == "" {
+				out <- found
+				wg.Done()
+				continue
+			}
+			AliveAddress = append(AliveAddress, found.IP+":"+found.Port)
+			if out != nil {
+				out <- found
+			}
 
-			t := strings.Split(found, ":")
-			ip := t[0]
+			ip := found.IP
 
 			count, ok := IPPortCount[ip]
 			if ok {
@@ -123,22 +169,33 @@ func PortScanTCP(IPs []string, Ports string, NoPorts string, timeout int) []stri
 		go func() {
 			for addr := range Addrs {
 				PortConnect(addr, results, timeout, &wg)
+				if out != nil {
+					finish(addr.ip)
+				}
 				wg.Done()
 			}
 		}()
 	}
 
 	//添加扫描目标
-	for _, port := range probePorts {
-		for _, host := range IPs {
-			wg.Add(1)
-			Addrs <- Addr{host, port}
+	block := len(IPs)
+	if out != nil && block > portScanBlockSize {
+		block = portScanBlockSize
+	}
+	for i := 0; i < len(IPs); i += block {
+		blockIPs := IPs[i:min(i+block, len(IPs))]
+		for _, port := range probePorts {
+			for _, host := range blockIPs {
+				wg.Add(1)
+				Addrs <- Addr{host, port}
+			}
 		}
 	}
 	wg.Wait()
 	close(Addrs)
 	close(results)
 	gologger.AuditTimeLogger("TCP端口扫描结束")
+	AuditRTT()
 
 	return AliveAddress
 }
@@ -150,7 +207,7 @@ type Addr struct {
 
 var PortScan bool
 
-func PortConnect(addr Addr, respondingHosts chan<- string, adjustedTimeout int, wg *sync.WaitGroup) {
+func PortConnect(addr Addr, respondingHosts chan<- PortScanResult, adjustedTimeout int, wg *sync.WaitGroup) {
 	inblack := false
 	BackListLock.Lock()
 	_, inblack = BackList[addr.ip]
@@ -160,14 +217,13 @@ func PortConnect(addr Addr, respondingHosts chan<- string, adjustedTimeout int,
 	}
 
 	host, port := addr.ip, addr.port
-	conn, err := WrapperTcpWithTimeout("tcp4", fmt.Sprintf("%s:%v", host, port), time.Duration(adjustedTimeout)*time.Second)
+	conn, err := DialAdaptive("tcp4", host, port, time.Duration(adjustedTimeout)*time.Second)
 	defer func() {
 		if conn != nil {
 			conn.Close()
 		}
 	}()
 	if err == nil {
-		address := host + ":" + strconv.Itoa(port)
 		if PortScan {
 			// gologger.Silent().Msgf("[PortScan] %v", address)
 			ddout.FormatOutput(ddout.OutputMessage{
@@ -185,7 +241,7 @@ func PortConnect(addr Addr, respondingHosts chan<- string, adjustedTimeout int,
 			})
 		}
 		wg.Add(1)
-		respondingHosts <- address
+		respondingHosts <- PortScanResult{IP: host, Port: strconv.Itoa(port)}
 	}
 }
 
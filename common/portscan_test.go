@@ -0,0 +1,75 @@
+package common
+
+import (
+	"dddd/structs"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestPortScanTCPStream(t *testing.T) {
+	structs.GlobalConfig.TCPPortScanThreads = 4
+	structs.GlobalConfig.PortsThreshold = 100
+	var open []string
+	for i := 0; i < 2; i++ {
+		ln, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		_, port, _ := net.SplitHostPort(ln.Addr().String())
+		open = append(open, port)
+	}
+	// 关闭的端口
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, closed, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+
+	tests := []struct {
+		name  string
+		ips   []string
+		ports string
+		open  int
+	}{
+		{"open", []string{"127.0.0.1"}, open[0] + "," + open[1], 2},
+		{"closed", []string{"127.0.0.1"}, closed, 0},
+		{"mixed", []string{"127.0.0.1"}, open[0] + "," + closed + "," + open[1], 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := make(chan PortScanResult, 10)
+			var results []PortScanResult
+			done := make(chan struct{})
+			go func() {
+				for r := range out {
+					results = append(results, r)
+				}
+				close(done)
+			}()
+			alive := PortScanTCPStream(tt.ips, tt.ports, "", 2, out)
+			close(out)
+			<-done
+			if len(alive) != tt.open {
+				t.Errorf("alive = %v, want %d ports", alive, tt.open)
+			}
+			// 每个开放端口一个结果，最后是该IP的完成标记
+			if len(results) != tt.open+1 {
+				t.Fatalf("results = %v", results)
+			}
+			for _, r := range results[:tt.open] {
+				if r.IP != "127.0.0.1" || r.Port == "" {
+					t.Errorf("result = %v", r)
+				}
+				if _, err := strconv.Atoi(r.Port); err != nil {
+					t.Errorf("port = %q", r.Port)
+				}
+			}
+			if last := results[tt.open]; last != (PortScanResult{IP: "127.0.0.1"}) {
+				t.Errorf("last result = %v, want IP finished", last)
+			}
+		})
+	}
+}
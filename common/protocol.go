@@ -3,7 +3,6 @@ package common
 import (
 	"dddd/ddout"
 	"dddd/structs"
-	"dddd/utils"
 	"fmt"
 	"github.com/lcvvvv/gonmap"
 	"github.com/projectdiscovery/gologger"
@@ -13,77 +12,20 @@ import (
 	"time"
 )
 
-func GetProtocol(hostPorts []string, threads int, timeout int) {
-	if len(hostPorts) == 0 {
-		return
-	}
-
-	hostPorts = utils.RemoveDuplicateElement(hostPorts)
-	if len(hostPorts) < threads {
-		threads = len(hostPorts)
-	}
-
-	gologger.AuditTimeLogger("TCP指纹识别，识别目标: %s", strings.Join(hostPorts, ","))
-
-	workers := threads
-	Addrs := make(chan string, len(hostPorts))
-	defer close(Addrs)
-	results := make(chan structs.ProtocolResult, len(hostPorts))
-	defer close(results)
-	var wg sync.WaitGroup
-
-	//接收结果
-	go func() {
-		for found := range results {
-			if found.Status == int(gonmap.Closed) {
-				wg.Done()
-				continue
-			}
-			if found.Status == gonmap.Open || found.Response == nil {
-				ddout.FormatOutput(ddout.OutputMessage{
-					Type:     "Nmap",
-					IP:       found.IP,
-					Port:     strconv.Itoa(found.Port),
-					Protocol: "tcp",
-				})
-				wg.Done()
-				continue
-			}
-
-			if found.Port == 23 && found.Response.FingerPrint.Service == "" {
-				found.Response.FingerPrint.Service = "telnet"
-			}
-			hostPort := fmt.Sprintf("%s:%v", found.IP, found.Port)
-			structs.GlobalIPPortMapLock.Lock()
-			_, ok := structs.GlobalIPPortMap[hostPort]
-			structs.GlobalIPPortMapLock.Unlock()
-			if !ok {
-				structs.GlobalBannerHMap.Set(hostPort, []byte(found.Response.Raw))
-				structs.GlobalIPPortMapLock.Lock()
-				structs.GlobalIPPortMap[hostPort] = found.Response.FingerPrint.Service
-				structs.GlobalIPPortMapLock.Unlock()
-			}
-			proto := found.Response.FingerPrint.Service
-			if proto == "" {
-				proto = "tcp"
-			}
-			ddout.FormatOutput(ddout.OutputMessage{
-				Type:     "Nmap",
-				IP:       found.IP,
-				Port:     strconv.Itoa(found.Port),
-				Protocol: proto,
-			})
-
-			wg.Done()
-		}
-	}()
+// GetProtocolStream 从in读取 host:port 进行协议识别，in关闭且全部识别完成后返回。
+// 新识别出的服务调用callBack，callBack阻塞时识别随之等待
+func GetProtocolStream(in <-chan string, threads int, timeout int, callBack func(hostPort string, service string)) {
+	results := make(chan structs.ProtocolResult, threads)
+	var workers sync.WaitGroup
 
 	//多线程扫描
-	for i := 0; i < workers; i++ {
+	for i := 0; i < threads; i++ {
+		workers.Add(1)
 		go func() {
+			defer workers.Done()
 			scanner := gonmap.New()
 			scanner.SetTimeout(time.Duration(timeout) * time.Second)
-			for addr := range Addrs {
+			for addr := range in {
 				t := strings.Split(addr, ":")
 				if len(t) < 2 {
 					continue
@@ -103,12 +45,87 @@ func GetProtocol(hostPorts []string, threads int, timeout int) {
 			}
 		}()
 	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	//接收结果
+	for found := range results {
+		if found.Status == int(gonmap.Closed) {
+			continue
+		}
+		if found.Status == gonmap.Open || found.Response == nil {
+			ddout.FormatOutput(ddout.OutputMessage{
+				Type:     "Nmap",
+				IP:       found.IP,
+				Port:     strconv.Itoa(found.Port),
+				Protocol: "tcp",
+			})
+			continue
+		}
 
-	//添加扫描目标
-	for _, hostPort := range hostPorts {
-		wg.Add(1)
-		Addrs <- hostPort
+		if found.Port == 23 && found.Response.FingerPrint.Service == "" {
+			found.Response.FingerPrint.Service = "telnet"
+		}
+		hostPort := fmt.Sprintf("%s:%v", found.IP, found.Port)
+		structs.GlobalIPPortMapLock.Lock()
+		_, ok := structs.GlobalIPPortMap[hostPort]
+		structs.GlobalIPPortMapLock.Unlock()
+		if !ok {
+			structs.GlobalBannerHMap.Set(hostPort, []byte(found.Response.Raw))
+			structs.GlobalIPPortMapLock.Lock()
+			structs.GlobalIPPortMap[hostPort] = found.Response.FingerPrint.Service
+			structs.GlobalIPPortMapLock.Unlock()
+			if info, has := serviceInfo(found.Response.FingerPrint); has {
+				structs.GlobalServiceInfoMapLock.Lock()
+				structs.GlobalServiceInfoMap[hostPort] = info
+				structs.GlobalServiceInfoMapLock.Unlock()
+			}
+		}
+		proto := found.Response.FingerPrint.Service
+		if proto == "" {
+			proto = "tcp"
+		}
+		// 外部探针识别出的服务在结果中标记探针与指纹行
+		var am string
+		if isExternalMatch(found.Response.FingerPrint.MatchSource) {
+			am = found.Response.FingerPrint.ProbeName + " " + found.Response.FingerPrint.MatchSource
+		}
+		fp := found.Response.FingerPrint
+		ddout.FormatOutput(ddout.OutputMessage{
+			Type:          "Nmap",
+			IP:            found.IP,
+			Port:          strconv.Itoa(found.Port),
+			Protocol:      proto,
+			Product:       fp.ProductName,
+			Version:       fp.Version,
+			Info:          fp.Info,
+			OS:            fp.OperatingSystem,
+			Device:        fp.DeviceType,
+			CPE:           fp.CPE,
+			Probe:         fp.ProbeName,
+			Match:         fp.MatchSource,
+			AdditionalMsg: am,
+		})
+		if !ok && callBack != nil {
+			callBack(hostPort, found.Response.FingerPrint.Service)
+		}
 	}
-	wg.Wait()
 	gologger.AuditTimeLogger("TCP指纹识别结束")
 }
+
+// serviceInfo 提取指纹中的版本信息，没有任何版本信息时返回false
+func serviceInfo(fp *gonmap.FingerPrint) (structs.ServiceInfo, bool) {
+	info := structs.ServiceInfo{
+		Product: fp.ProductName,
+		Version: fp.Version,
+		Info:    fp.Info,
+		OS:      fp.OperatingSystem,
+		Device:  fp.DeviceType,
+		CPE:     fp.CPE,
+	}
+	has := info.Product != "" || info.Version != "" || info.Info != "" ||
+		info.OS != "" || info.Device != "" || len(info.CPE) > 0
+	return info, has
+}
@@ -0,0 +1,225 @@
+package common
+
+import (
+	"crypto/tls"
+	"dddd/common/uncover"
+	"dddd/structs"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"strings"
+	"time"
+)
+
+// RDP_NEG_REQ 中的 requestedProtocols
+const (
+	RDPProtocolRDP      = 0x00
+	RDPProtocolSSL      = 0x01
+	RDPProtocolHybrid   = 0x02
+	RDPProtocolHybridEx = 0x08
+)
+
+var rdpNegFailure = errors.New("rdp negotiation failure")
+
+// 逐个请求的安全协议，CredSSP以TLS为基础，请求时同时带上SSL
+var rdpSecurityProtocols = []struct {
+	Flag    uint32
+	Request uint32
+	Name    string
+}{
+	{RDPProtocolRDP, RDPProtocolRDP, "RDP"},
+	{RDPProtocolSSL, RDPProtocolSSL, "SSL"},
+	{RDPProtocolHybrid, RDPProtocolSSL | RDPProtocolHybrid, "HYBRID"},
+	{RDPProtocolHybridEx, RDPProtocolSSL | RDPProtocolHybrid | RDPProtocolHybridEx, "HYBRID_EX"},
+}
+
+var rdpFailureCodes = map[uint32]string{
+	1: "SSL_REQUIRED_BY_SERVER",
+	2: "SSL_NOT_ALLOWED_BY_SERVER",
+	3: "SSL_CERT_NOT_ON_SERVER",
+	4: "INCONSISTENT_FLAGS",
+	5: "HYBRID_REQUIRED_BY_SERVER",
+	6: "SSL_WITH_USER_AUTH_REQUIRED_BY_SERVER",
+}
+
+// RDPX224Request X.224 Connection Request，携带 RDP_NEG_REQ
+func RDPX224Request(protocols uint32) []byte {
+	neg := []byte{0x01, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00}
+	binary.LittleEndian.PutUint32(neg[4:8], protocols)
+	x224 := append([]byte{byte(6 + len(neg)), 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00}, neg...)
+	tpkt := []byte{0x03, 0x00, 0x00, 0x00}
+	binary.BigEndian.PutUint16(tpkt[2:4], uint16(4+len(x224)))
+	return append(tpkt, x224...)
+}
+
+// RDPNegotiate 发送X.224连接请求，返回服务端选择的安全协议，被拒绝时 failure 为 RDP_NEG_FAILURE 中的错误码
+func RDPNegotiate(conn net.Conn, protocols uint32) (selected uint32, failure uint32, err error) {
+	request := RDPX224Request(protocols)
+	_, err = conn.Write(request)
+	gologger.AuditTimeLogger("[RDP] Dumped TCP request for %s\n\n%s\n", conn.RemoteAddr(), hex.Dump(request))
+	if err != nil {
+		return
+	}
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return
+	}
+	reply := buf[:n]
+	gologger.AuditTimeLogger("[RDP] Dumped TCP response for %s\n\n%s\n", conn.RemoteAddr(), hex.Dump(reply))
+
+	// TPKT(4) + X.224 CC(7) + RDP_NEG_RSP/RDP_NEG_FAILURE(8)
+	if len(reply) < 11 || reply[0] != 0x03 || reply[5] != 0xd0 {
+		err = errors.New("rdp x224 connection confirm not found")
+		return
+	}
+	// 不支持协商的老版本服务端只有标准RDP安全层
+	if len(reply) < 19 {
+		return RDPProtocolRDP, 0, nil
+	}
+	switch reply[11] {
+	case 0x02:
+		selected = binary.LittleEndian.Uint32(reply[15:19])
+	case 0x03:
+		failure = binary.LittleEndian.Uint32(reply[15:19])
+		err = rdpNegFailure
+	}
+	return
+}
+
+func rdpDial(hostPort string, timeout time.Duration) (net.Conn, error) {
+	conn, err := WrapperTcpWithTimeout("tcp", hostPort, timeout)
+	if err != nil {
+		return nil, err
+	}
+	err = conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// rdpSecurityAnalyze 逐个安全协议发起协商，不进行认证，返回协商结果与TLS证书
+func rdpSecurityAnalyze(hostPort string, timeout time.Duration) (*structs.RDPSecurityInfo, string, error) {
+	result := &structs.RDPSecurityInfo{}
+	var reachable, ssl bool
+	var tlsRequest uint32
+	for _, p := range rdpSecurityProtocols {
+		conn, err := rdpDial(hostPort, timeout)
+		if err != nil {
+			return nil, "", err
+		}
+		selected, failure, err := RDPNegotiate(conn, p.Request)
+		conn.Close()
+		if failure != 0 {
+			reachable = true
+			gologger.AuditTimeLogger("[RDP] %s request %s: %s", hostPort, p.Name, rdpFailureCodes[failure])
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		reachable = true
+		if selected != p.Flag {
+			continue
+		}
+		result.Protocols = append(result.Protocols, p.Name)
+		if p.Flag == RDPProtocolRDP {
+			result.LegacyRDP = true
+		} else if p.Flag == RDPProtocolSSL {
+			ssl = true
+		}
+		if p.Flag != RDPProtocolRDP && tlsRequest == 0 {
+			tlsRequest = p.Request
+		}
+	}
+	if !reachable {
+		return nil, "", rdpNegFailure
+	}
+	result.NLARequired = len(result.Protocols) > 0 && !result.LegacyRDP && !ssl
+
+	if tlsRequest == 0 {
+		return result, "", nil
+	}
+	conn, err := rdpDial(hostPort, timeout)
+	if err != nil {
+		return result, "", nil
+	}
+	defer conn.Close()
+	if _, _, err = RDPNegotiate(conn, tlsRequest); err != nil {
+		return result, "", nil
+	}
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+	if err = tlsConn.Handshake(); err != nil {
+		return result, "", nil
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return result, "", nil
+	}
+	cert := certs[0]
+	result.CertCN = cert.Subject.CommonName
+	result.CertSAN = cert.DNSNames
+	result.CertIssuer = cert.Issuer.CommonName
+	result.CertExpire = cert.NotAfter.Format("2006-01-02")
+
+	return result, CertString(cert), nil
+}
+
+// RDPBannerFields 追加到Banner中的字段，指纹规则可以通过 banner="RDP-NLA: not-required" 匹配
+func RDPBannerFields(info *structs.RDPSecurityInfo) string {
+	nla := "not-required"
+	if info.NLARequired {
+		nla = "required"
+	}
+	legacy := "disabled"
+	if info.LegacyRDP {
+		legacy = "enabled"
+	}
+	fields := fmt.Sprintf("\nRDP-Protocols: %s\nRDP-NLA: %s\nRDP-Legacy-Security: %s\n",
+		strings.Join(info.Protocols, ","), nla, legacy)
+	if info.CertCN != "" {
+		fields += "RDP-Cert-CN: " + info.CertCN + "\n"
+	}
+	return fields
+}
+
+// IsRDPTarget 需要进行RDP安全层分析的端口
+func IsRDPTarget(hostPort string, protocol string) bool {
+	return protocol == "rdp" || strings.HasSuffix(hostPort, ":3389")
+}
+
+// RDPSecurityCheckTarget 分析单个RDP服务
+func RDPSecurityCheckTarget(hostPort string) {
+	timeout := time.Duration(structs.GlobalConfig.GetBannerTimeout) * time.Second
+	info, cert, err := rdpSecurityAnalyze(hostPort, timeout)
+	if err != nil {
+		gologger.AuditTimeLogger("[RDP] %s error: %v", hostPort, err)
+		return
+	}
+
+	structs.GlobalRDPInfoMapLock.Lock()
+	structs.GlobalRDPInfoMap[hostPort] = *info
+	structs.GlobalRDPInfoMapLock.Unlock()
+	if cert != "" {
+		structs.GlobalServiceCertMapLock.Lock()
+		structs.GlobalServiceCertMap[hostPort] = cert
+		structs.GlobalServiceCertMapLock.Unlock()
+	}
+	banner, _ := structs.GlobalBannerHMap.Get(hostPort)
+	_ = structs.GlobalBannerHMap.Set(hostPort, append(banner, []byte(RDPBannerFields(info))...))
+
+	// 证书CN一般为真实主机名
+	ip := strings.Split(hostPort, ":")[0]
+	if info.CertCN != "" {
+		uncover.AddTargetKeyword(ip, info.CertCN)
+		uncover.AddHostName(ip, info.CertCN, "cert "+hostPort)
+	}
+	for _, name := range info.CertSAN {
+		uncover.AddHostName(ip, name, "cert "+hostPort)
+	}
+}
@@ -8,8 +8,10 @@ import (
 	"github.com/projectdiscovery/nuclei/v3/pkg/model/types/severity"
 	"github.com/projectdiscovery/nuclei/v3/pkg/output"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -46,6 +48,9 @@ func writeFile(result string, filename string) {
 
 var ReportIndex = 1
 
+// Nuclei与GoPoc同时运行，写入报告与递增序号需要加锁
+var reportLock sync.Mutex
+
 func GenerateHTMLReportHeader() {
 	if structs.GlobalConfig.ReportName == "" {
 		structs.GlobalConfig.ReportName = strconv.Itoa(int(time.Now().Unix())) + ".html"
@@ -71,6 +76,8 @@ func AddResultByResultEvent(result output.ResultEvent) {
 		})
 	}
 
+	reportLock.Lock()
+	defer reportLock.Unlock()
 	severityString := getSeverity(result.Info.SeverityHolder.Severity)
 
 	title := fmt.Sprintf(`<table>
@@ -148,6 +155,8 @@ func AddResultByResultEvent(result output.ResultEvent) {
 }
 
 func AddResultByGoPocResult(result structs.GoPocsResultType) {
+	reportLock.Lock()
+	defer reportLock.Unlock()
 	severityString := result.Security
 
 	title := fmt.Sprintf(`<table>
@@ -192,3 +201,58 @@ func AddResultByGoPocResult(result structs.GoPocsResultType) {
 
 	ReportIndex += 1
 }
+
+// AddServiceInventory 报告末尾追加协议识别得到的服务版本清单
+func AddServiceInventory() {
+	if structs.GlobalConfig.ReportName == "" {
+		return
+	}
+	structs.GlobalServiceInfoMapLock.Lock()
+	var hostPorts []string
+	for hostPort := range structs.GlobalServiceInfoMap {
+		hostPorts = append(hostPorts, hostPort)
+	}
+	sort.Strings(hostPorts)
+
+	rows := ""
+	for _, hostPort := range hostPorts {
+		info := structs.GlobalServiceInfoMap[hostPort]
+		structs.GlobalIPPortMapLock.Lock()
+		service := structs.GlobalIPPortMap[hostPort]
+		structs.GlobalIPPortMapLock.Unlock()
+
+		var version []string
+		for _, v := range []string{info.Product, info.Version} {
+			if v != "" {
+				version = append(version, v)
+			}
+		}
+		if info.Info != "" {
+			version = append(version, "("+info.Info+")")
+		}
+		if info.OS != "" {
+			version = append(version, "["+info.OS+"]")
+		}
+		show := xssfilter(strings.Join(version, " "))
+		if len(info.CPE) > 0 {
+			show += "<br/>" + xssfilter(strings.Join(info.CPE, " "))
+		}
+		rows += fmt.Sprintf(`<tr>
+		<td>%s</td>
+		<td>%s</td>
+		<td>%s</td>
+	</tr>`, xssfilter(hostPort), xssfilter(service), show)
+	}
+	structs.GlobalServiceInfoMapLock.Unlock()
+	if rows == "" {
+		return
+	}
+
+	d := fmt.Sprintf(`<table>
+	<thead onclick="$(this).next('tbody').toggle()" style="background:#000000">
+		<td class="vuln">服务版本</td>
+		<td class="security info">%d</td>
+		<td class="url"></td>
+	</thead><tbody>`, len(hostPorts)) + rows + "</tbody></table>"
+	writeFile(d, structs.GlobalConfig.ReportName)
+}
@@ -0,0 +1,52 @@
+package report
+
+import (
+	"dddd/structs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddServiceInventory(t *testing.T) {
+	structs.GlobalIPPortMap = map[string]string{"10.0.0.1:22": "ssh", "10.0.0.1:3306": "mysql"}
+	defer func() {
+		structs.GlobalServiceInfoMap = nil
+		ReportIndex = 1
+	}()
+	tests := []struct {
+		name     string
+		services map[string]structs.ServiceInfo
+		index    int
+		want     []string
+	}{
+		// 没有漏洞结果时同样写入
+		{"no-results", map[string]structs.ServiceInfo{
+			"10.0.0.1:22": {Product: "OpenSSH", Version: "8.9p1", OS: "Linux", CPE: []string{"cpe:/a:openbsd:openssh:8.9p1"}},
+		}, 1, []string{"服务版本", "10.0.0.1:22", "ssh", "OpenSSH 8.9p1 [Linux]", "cpe:/a:openbsd:openssh:8.9p1"}},
+		{"with-results", map[string]structs.ServiceInfo{
+			"10.0.0.1:3306": {Product: "MySQL", Version: "5.7.44", Info: "log"},
+		}, 3, []string{"服务版本", "10.0.0.1:3306", "mysql", "MySQL 5.7.44 (log)"}},
+		{"xss", map[string]structs.ServiceInfo{
+			"10.0.0.1:22": {Product: "<script>"},
+		}, 1, []string{"%3Cscript%3E"}},
+		{"empty", map[string]structs.ServiceInfo{}, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			structs.GlobalConfig.ReportName = filepath.Join(t.TempDir(), "report.html")
+			structs.GlobalServiceInfoMap = tt.services
+			ReportIndex = tt.index
+			AddServiceInventory()
+			b, _ := os.ReadFile(structs.GlobalConfig.ReportName)
+			if tt.want == nil && len(b) > 0 {
+				t.Errorf("report = %q, want empty", b)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(b), want) {
+					t.Errorf("report missing %q: %s", want, b)
+				}
+			}
+		})
+	}
+}
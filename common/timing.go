@@ -0,0 +1,195 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 扫描时序，参考nmap的 -T0~-T5。normal 保持固定的 -pst 超时，其他时序的TCP连接超时根据已测得的RTT自适应调整：
+// 先使用主机自身的RTT，没有时使用同C段的RTT，都没有时使用 -pst 指定的超时。
+
+type TimingProfile struct {
+	TCPThreads      int           // TCP端口扫描线程，0为不调整
+	BannerThreads   int           // 协议识别线程，0为不调整
+	BannerTimeout   int           // 协议识别超时(秒)，0为不调整
+	PortScanTimeout int           // TCP连接超时上限(秒)，0为不调整
+	Adaptive        bool          // 按RTT自适应超时，否则固定使用 -pst
+	MinTimeout      time.Duration // 自适应超时下限
+	MaxRetries      int           // 连接超时后的重试次数
+}
+
+var TimingProfiles = map[string]TimingProfile{
+	"paranoid": {TCPThreads: 10, BannerThreads: 10, BannerTimeout: 10, PortScanTimeout: 10, Adaptive: true, MinTimeout: time.Second, MaxRetries: 3},
+	"sneaky":   {TCPThreads: 100, BannerThreads: 50, BannerTimeout: 8, PortScanTimeout: 10, Adaptive: true, MinTimeout: 500 * time.Millisecond, MaxRetries: 2},
+	"polite":   {TCPThreads: 300, BannerThreads: 100, BannerTimeout: 6, PortScanTimeout: 8, Adaptive: true, MinTimeout: 200 * time.Millisecond, MaxRetries: 2},
+	// 默认时序与未引入时序前一致
+	"normal":     {},
+	"aggressive": {TCPThreads: 4000, BannerThreads: 800, BannerTimeout: 3, PortScanTimeout: 3, Adaptive: true, MinTimeout: 100 * time.Millisecond, MaxRetries: 1},
+	"insane":     {TCPThreads: 6000, BannerThreads: 1000, BannerTimeout: 2, PortScanTimeout: 1, Adaptive: true, MinTimeout: 50 * time.Millisecond, MaxRetries: 0},
+}
+
+// TimingProfileNames 按 T0~T5 排列
+var TimingProfileNames = []string{"paranoid", "sneaky", "polite", "normal", "aggressive", "insane"}
+
+// ScanTiming 本次运行使用的时序
+var ScanTiming = TimingProfiles["normal"]
+
+// ParseTimingProfile 支持名称或 T0~T5
+func ParseTimingProfile(name string) (string, TimingProfile, error) {
+	name = strings.ToLower(name)
+	if len(name) == 2 && name[0] == 't' && name[1] >= '0' && name[1] <= '5' {
+		name = TimingProfileNames[name[1]-'0']
+	}
+	if p, ok := TimingProfiles[name]; ok {
+		return name, p, nil
+	}
+	return "", TimingProfile{}, fmt.Errorf("-timing 允许的值: %s 或 T0-T5", strings.Join(TimingProfileNames, ","))
+}
+
+// rttStat 平滑RTT，计算方式与TCP重传超时一致(RFC 6298)
+type rttStat struct {
+	srtt    time.Duration
+	rttvar  time.Duration
+	samples int
+}
+
+func (s *rttStat) update(rtt time.Duration) {
+	if s.samples == 0 {
+		s.srtt = rtt
+		s.rttvar = rtt / 2
+	} else {
+		delta := rtt - s.srtt
+		s.srtt += delta / 8
+		if delta < 0 {
+			delta = -delta
+		}
+		s.rttvar += (delta - s.rttvar) / 4
+	}
+	s.samples++
+}
+
+func (s *rttStat) timeout() time.Duration {
+	return s.srtt + 4*s.rttvar
+}
+
+var rttStats = struct {
+	sync.Mutex
+	hosts   map[string]*rttStat
+	subnets map[string]*rttStat
+}{
+	hosts:   make(map[string]*rttStat),
+	subnets: make(map[string]*rttStat),
+}
+
+func rttSubnet(host string) string {
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return host
+	}
+	return fmt.Sprintf("%d.%d.%d.0/24", ip[0], ip[1], ip[2])
+}
+
+func rttUpdate(m map[string]*rttStat, key string, rtt time.Duration) {
+	s, ok := m[key]
+	if !ok {
+		s = &rttStat{}
+		m[key] = s
+	}
+	s.update(rtt)
+}
+
+// RecordRTT 记录一次往返时间，来源为TCP连接建立/拒绝与ICMP应答
+func RecordRTT(host string, rtt time.Duration) {
+	rttStats.Lock()
+	defer rttStats.Unlock()
+	rttUpdate(rttStats.hosts, host, rtt)
+	rttUpdate(rttStats.subnets, rttSubnet(host), rtt)
+}
+
+// HostTimeout 返回主机的TCP连接超时，maxTimeout 为上限及没有RTT数据时的超时
+func HostTimeout(host string, maxTimeout time.Duration) time.Duration {
+	timeout, _ := hostTimeout(host, maxTimeout)
+	return timeout
+}
+
+func hostTimeout(host string, maxTimeout time.Duration) (time.Duration, bool) {
+	if !ScanTiming.Adaptive {
+		return maxTimeout, false
+	}
+	rttStats.Lock()
+	s, ok := rttStats.hosts[host]
+	if !ok {
+		s, ok = rttStats.subnets[rttSubnet(host)]
+	}
+	var timeout time.Duration
+	if ok {
+		timeout = s.timeout()
+	}
+	rttStats.Unlock()
+	if !ok {
+		return maxTimeout, false
+	}
+	if timeout > maxTimeout {
+		return maxTimeout, true
+	}
+	if timeout < ScanTiming.MinTimeout {
+		return ScanTiming.MinTimeout, true
+	}
+	return timeout, true
+}
+
+// isConnRefused 收到RST同样可以得到RTT
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// DialAdaptive 按自适应超时建立TCP连接。已有RTT数据时超时可能是丢包，
+// 按 ScanTiming.MaxRetries 加倍超时重试；没有RTT数据或未开启自适应时直接使用上限且不重试
+func DialAdaptive(network, host string, port int, maxTimeout time.Duration) (net.Conn, error) {
+	address := net.JoinHostPort(host, fmt.Sprint(port))
+	timeout, known := hostTimeout(host, maxTimeout)
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		conn, err := WrapperTcpWithTimeout(network, address, timeout)
+		if err == nil || isConnRefused(err) {
+			RecordRTT(host, time.Since(start))
+			return conn, err
+		}
+		if !known || !isTimeout(err) || attempt >= ScanTiming.MaxRetries {
+			return nil, err
+		}
+		timeout *= 2
+		if timeout > maxTimeout {
+			timeout = maxTimeout
+		}
+	}
+}
+
+// AuditRTT 审计日志中记录各C段的RTT与超时
+func AuditRTT() {
+	rttStats.Lock()
+	defer rttStats.Unlock()
+	var subnets []string
+	for subnet := range rttStats.subnets {
+		subnets = append(subnets, subnet)
+	}
+	sort.Strings(subnets)
+	var lines []string
+	for _, subnet := range subnets {
+		s := rttStats.subnets[subnet]
+		lines = append(lines, fmt.Sprintf("%s srtt: %v rttvar: %v samples: %d", subnet, s.srtt, s.rttvar, s.samples))
+	}
+	gologger.AuditTimeLogger("RTT统计\n%s", strings.Join(lines, "\n"))
+}
@@ -0,0 +1,36 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHostTimeout(t *testing.T) {
+	defer func() { ScanTiming = TimingProfiles["normal"] }()
+	RecordRTT("10.9.0.1", 20*time.Millisecond)
+	RecordRTT("10.9.1.1", 2*time.Millisecond)
+
+	tests := []struct {
+		timing string
+		host   string
+		want   time.Duration
+	}{
+		// normal 固定使用 -pst
+		{"normal", "10.9.0.1", 6 * time.Second},
+		{"normal", "10.9.9.9", 6 * time.Second},
+		{"insane", "10.9.0.1", 60 * time.Millisecond},
+		// 同C段的RTT
+		{"insane", "10.9.0.2", 60 * time.Millisecond},
+		{"insane", "10.9.1.1", 50 * time.Millisecond},
+		{"insane", "10.9.9.9", 6 * time.Second},
+		// 不低于超时下限
+		{"aggressive", "10.9.0.1", 100 * time.Millisecond},
+		{"paranoid", "10.9.0.1", time.Second},
+	}
+	for _, tt := range tests {
+		_, ScanTiming, _ = ParseTimingProfile(tt.timing)
+		if got := HostTimeout(tt.host, 6*time.Second); got != tt.want {
+			t.Errorf("%s HostTimeout(%q) = %v, want %v", tt.timing, tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,505 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"dddd/ddout"
+	"dddd/structs"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"github.com/hdm/jarm-go"
+	"github.com/projectdiscovery/gologger"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 直接使用TLS的服务
+var tlsDirectServices = map[string]bool{
+	"https": true, "ssl": true, "smtps": true, "imaps": true, "pop3s": true, "ldaps": true, "ftps": true,
+}
+
+// 通过STARTTLS升级的服务，值为升级方式
+var tlsStartServices = map[string]string{
+	"smtp": "smtp", "submission": "smtp", "imap": "imap", "pop3": "pop3", "ftp": "ftp",
+	"ldap": "ldap", "postgresql": "postgresql", "mysql": "mysql", "rdp": "rdp",
+}
+
+// 协议未识别时按端口判断
+var tlsDirectPorts = map[string]bool{
+	"443": true, "465": true, "636": true, "853": true, "990": true, "993": true, "995": true,
+	"3269": true, "5986": true, "8443": true,
+}
+
+var tlsStartPorts = map[string]string{
+	"21": "ftp", "25": "smtp", "110": "pop3", "143": "imap", "389": "ldap", "587": "smtp",
+	"3306": "mysql", "3389": "rdp", "5432": "postgresql",
+}
+
+// 逐个探测的协议版本，从高到低
+var tlsVersions = []struct {
+	Version uint16
+	Name    string
+}{
+	{tls.VersionTLS13, "TLS1.3"},
+	{tls.VersionTLS12, "TLS1.2"},
+	{tls.VersionTLS11, "TLS1.1"},
+	{tls.VersionTLS10, "TLS1.0"},
+}
+
+// 数据库的STARTTLS默认不分析，每个端口需要十余次连接，中断的握手会计入MySQL的max_connect_errors，
+// 导致后续爆破阶段被服务端封禁
+var tlsStartDatabases = map[string]bool{"mysql": true, "postgresql": true}
+
+var errStartTLS = errors.New("starttls not supported")
+
+// TLSMode 判断服务是否使用TLS，mode为空表示直接TLS，否则为STARTTLS的升级方式
+func TLSMode(hostPort string, service string) (mode string, ok bool) {
+	mode, ok = tlsMode(hostPort, service)
+	if ok && tlsStartDatabases[mode] && !structs.GlobalConfig.TLSCheckDB {
+		return "", false
+	}
+	return mode, ok
+}
+
+func tlsMode(hostPort string, service string) (string, bool) {
+	if tlsDirectServices[service] || strings.HasSuffix(service, "-ssl") {
+		return "", true
+	}
+	if mode, ok := tlsStartServices[service]; ok {
+		return mode, true
+	}
+	if service != "" && service != "tcp" {
+		return "", false
+	}
+	port := hostPort[strings.LastIndex(hostPort, ":")+1:]
+	if tlsDirectPorts[port] {
+		return "", true
+	}
+	mode, ok := tlsStartPorts[port]
+	return mode, ok
+}
+
+// tlsDial 建立连接，STARTTLS服务完成升级前的明文交互
+func tlsDial(hostPort string, mode string, timeout time.Duration) (net.Conn, error) {
+	conn, err := WrapperTcpWithTimeout("tcp", hostPort, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if mode != "" {
+		if err = startTLS(conn, mode); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func startTLS(conn net.Conn, mode string) error {
+	r := bufio.NewReader(conn)
+	switch mode {
+	case "smtp":
+		if code, err := readCodeReply(r); err != nil || code != "220" {
+			return errStartTLS
+		}
+		if _, err := conn.Write([]byte("EHLO dddd\r\n")); err != nil {
+			return err
+		}
+		if code, err := readCodeReply(r); err != nil || code != "250" {
+			return errStartTLS
+		}
+		return writeExpect(conn, r, "STARTTLS\r\n", "220")
+	case "ftp":
+		if code, err := readCodeReply(r); err != nil || code != "220" {
+			return errStartTLS
+		}
+		return writeExpect(conn, r, "AUTH TLS\r\n", "234")
+	case "imap":
+		if line, err := r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "* OK") {
+			return errStartTLS
+		}
+		if _, err := conn.Write([]byte("a001 STARTTLS\r\n")); err != nil {
+			return err
+		}
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(line, "a001 ") {
+				if strings.HasPrefix(line, "a001 OK") {
+					return nil
+				}
+				return errStartTLS
+			}
+		}
+	case "pop3":
+		if line, err := r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "+OK") {
+			return errStartTLS
+		}
+		return writeExpect(conn, r, "STLS\r\n", "+OK")
+	case "ldap":
+		return ldapStartTLS(conn)
+	case "postgresql":
+		// SSLRequest
+		if _, err := conn.Write([]byte{0x00, 0x00, 0x00, 0x08, 0x04, 0xd2, 0x16, 0x2f}); err != nil {
+			return err
+		}
+		b, err := r.ReadByte()
+		if err != nil || b != 'S' {
+			return errStartTLS
+		}
+		return nil
+	case "mysql":
+		return mysqlStartTLS(conn)
+	case "rdp":
+		selected, _, err := RDPNegotiate(conn, RDPProtocolSSL|RDPProtocolHybrid)
+		if err != nil || selected == RDPProtocolRDP {
+			return errStartTLS
+		}
+		return nil
+	}
+	return errStartTLS
+}
+
+// readCodeReply 读取SMTP/FTP的多行应答，返回最后一行的状态码
+func readCodeReply(r *bufio.Reader) (string, error) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if len(line) < 4 {
+			return "", errStartTLS
+		}
+		if line[3] == ' ' || line[3] == '\r' || line[3] == '\n' {
+			return line[:3], nil
+		}
+	}
+}
+
+func writeExpect(conn net.Conn, r *bufio.Reader, request string, prefix string) error {
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, prefix) {
+		return errStartTLS
+	}
+	return nil
+}
+
+// ldapStartTLS 发送 StartTLS ExtendedRequest(1.3.6.1.4.1.1466.20037)，resultCode为0时成功
+func ldapStartTLS(conn net.Conn) error {
+	oid := []byte("1.3.6.1.4.1.1466.20037")
+	extended := append([]byte{0x80, byte(len(oid))}, oid...)
+	op := append([]byte{0x77, byte(len(extended))}, extended...)
+	msg := append([]byte{0x02, 0x01, 0x01}, op...)
+	request := append([]byte{0x30, byte(len(msg))}, msg...)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return err
+	}
+	reply := buf[:n]
+	// LDAPMessage ::= SEQUENCE { messageID INTEGER, ExtendedResponse [APPLICATION 24] { resultCode ENUMERATED ... } }
+	i := bytes.IndexByte(reply, 0x78)
+	if i < 0 || i+1 >= len(reply) {
+		return errStartTLS
+	}
+	i++
+	if reply[i]&0x80 != 0 {
+		i += int(reply[i] & 0x7f)
+	}
+	i++
+	if i+2 >= len(reply) || reply[i] != 0x0a || reply[i+1] != 0x01 || reply[i+2] != 0x00 {
+		return errStartTLS
+	}
+	return nil
+}
+
+// mysqlStartTLS 读取握手包，服务端支持 CLIENT_SSL 时发送 SSLRequest
+func mysqlStartTLS(conn net.Conn) error {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	if length < 1 || length > 1024 {
+		return errStartTLS
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return err
+	}
+	// protocol version(1) server version(NUL) connection id(4) auth-plugin-data-part-1(8) filler(1) capability flags(2)
+	if payload[0] != 0x0a {
+		return errStartTLS
+	}
+	end := bytes.IndexByte(payload[1:], 0x00)
+	if end < 0 {
+		return errStartTLS
+	}
+	offset := 1 + end + 1 + 4 + 8 + 1
+	if offset+2 > len(payload) {
+		return errStartTLS
+	}
+	if binary.LittleEndian.Uint16(payload[offset:offset+2])&0x0800 == 0 {
+		return errStartTLS
+	}
+	// CLIENT_LONG_PASSWORD | CLIENT_PROTOCOL_41 | CLIENT_SSL | CLIENT_SECURE_CONNECTION
+	request := make([]byte, 4+32)
+	request[0] = 32
+	request[3] = header[3] + 1
+	binary.LittleEndian.PutUint32(request[4:8], 0x8a01)
+	binary.LittleEndian.PutUint32(request[8:12], 0x01000000)
+	request[12] = 0x21
+	_, err := conn.Write(request)
+	return err
+}
+
+// tlsHandshake 使用指定版本握手，返回服务端证书链
+func tlsHandshake(hostPort string, mode string, version uint16, timeout time.Duration) ([]*x509.Certificate, error) {
+	conn, err := tlsDial(hostPort, mode, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	host, _, _ := net.SplitHostPort(hostPort)
+	config := &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         version,
+		MaxVersion:         version,
+	}
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		config.CipherSuites = append(config.CipherSuites, suite.ID)
+	}
+	if net.ParseIP(host) == nil {
+		config.ServerName = host
+	}
+	tlsConn := tls.Client(conn, config)
+	if err = tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	return tlsConn.ConnectionState().PeerCertificates, nil
+}
+
+// tlsJARM 计算JARM，服务端对所有探针均无响应时返回空
+func tlsJARM(hostPort string, mode string, timeout time.Duration) string {
+	host, portString, _ := net.SplitHostPort(hostPort)
+	port, _ := strconv.Atoi(portString)
+	var results []string
+	for _, probe := range jarm.GetProbes(host, port) {
+		ans := "|||"
+		conn, err := tlsDial(hostPort, mode, timeout)
+		if err == nil {
+			if _, err = conn.Write(jarm.BuildProbe(probe)); err == nil {
+				buf := make([]byte, 1484)
+				n, _ := conn.Read(buf)
+				if r, err := jarm.ParseServerHello(buf[:n], probe); err == nil {
+					ans = r
+				}
+			}
+			conn.Close()
+		}
+		results = append(results, ans)
+	}
+	hash := jarm.RawHashToFuzzyHash(strings.Join(results, ","))
+	if strings.Trim(hash, "0") == "" {
+		return ""
+	}
+	return hash
+}
+
+// certKey 公钥类型与长度
+func certKey(cert *x509.Certificate) (string, int) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	case *dsa.PublicKey:
+		return "DSA", key.P.BitLen()
+	}
+	return cert.PublicKeyAlgorithm.String(), 0
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// CertString 与Web证书一致的证书文本，供指纹识别的cert规则使用
+func CertString(cert *x509.Certificate) string {
+	result := "SubjectCN: " + cert.Subject.CommonName + "\n"
+	result += "SubjectDN: " + cert.Subject.String() + "\n"
+	result += "IssuerCN: " + cert.Issuer.CommonName + "\n"
+	result += "IssuerDN: " + cert.Issuer.String() + "\n"
+	result += "IssuerOrg: \n"
+	for _, v := range cert.Issuer.Organization {
+		result += "    - " + v + "\n"
+	}
+	return result
+}
+
+// tlsAnalyze 探测协议版本、证书链与JARM
+func tlsAnalyze(hostPort string, mode string, timeout time.Duration) (*structs.TLSInfo, []*x509.Certificate, error) {
+	info := &structs.TLSInfo{StartTLS: mode}
+	var chain []*x509.Certificate
+	var lastErr error
+	for _, v := range tlsVersions {
+		certs, err := tlsHandshake(hostPort, mode, v.Version, timeout)
+		if err != nil {
+			lastErr = err
+			// STARTTLS不支持时不必继续
+			if errors.Is(err, errStartTLS) {
+				return nil, nil, err
+			}
+			continue
+		}
+		info.Versions = append(info.Versions, v.Name)
+		if chain == nil {
+			chain = certs
+		}
+	}
+	if len(info.Versions) == 0 {
+		return nil, nil, lastErr
+	}
+	info.JARM = tlsJARM(hostPort, mode, timeout)
+	if info.JARM != "" {
+		info.JARMMatch = structs.JARMDB[info.JARM]
+	}
+
+	now := time.Now()
+	issues := make(map[string]bool)
+	for i, cert := range chain {
+		keyType, keyBits := certKey(cert)
+		sum := sha256.Sum256(cert.Raw)
+		info.Chain = append(info.Chain, structs.TLSCertInfo{
+			Subject:            cert.Subject.String(),
+			Issuer:             cert.Issuer.String(),
+			SAN:                certSAN(cert),
+			NotBefore:          cert.NotBefore.Format("2006-01-02"),
+			NotAfter:           cert.NotAfter.Format("2006-01-02"),
+			KeyType:            keyType,
+			KeyBits:            keyBits,
+			SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+			SHA256:             hex.EncodeToString(sum[:]),
+		})
+		if now.After(cert.NotAfter) {
+			issues["expired"] = true
+		}
+		if now.Before(cert.NotBefore) {
+			issues["not-yet-valid"] = true
+		}
+		if (keyType == "RSA" && keyBits < 2048) || keyType == "DSA" || (keyType == "ECDSA" && keyBits < 256) {
+			issues["weak-key"] = true
+		}
+		// 自签名根证书的签名算法不参与校验
+		selfSigned := isSelfSigned(cert)
+		if i == 0 && selfSigned {
+			issues["self-signed"] = true
+		}
+		if !selfSigned {
+			switch cert.SignatureAlgorithm {
+			case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+				issues["weak-signature"] = true
+			}
+		}
+	}
+	for _, v := range info.Versions {
+		if v == "TLS1.0" || v == "TLS1.1" {
+			issues["weak-protocol"] = true
+		}
+	}
+	for _, issue := range []string{"expired", "not-yet-valid", "self-signed", "weak-key", "weak-signature", "weak-protocol"} {
+		if issues[issue] {
+			info.Issues = append(info.Issues, issue)
+		}
+	}
+	// JARM 与同TLS实现的正常服务无法区分(如Java服务与Cobalt Strike)，命中只作为参考信息输出
+	return info, chain, nil
+}
+
+func certSAN(cert *x509.Certificate) []string {
+	san := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		san = append(san, ip.String())
+	}
+	san = append(san, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		san = append(san, u.String())
+	}
+	return san
+}
+
+// TLSCheckTarget 分析单个TLS服务，证书写入 GlobalServiceCertMap 供指纹识别使用
+func TLSCheckTarget(hostPort string, service string, mode string) {
+	timeout := time.Duration(structs.GlobalConfig.GetBannerTimeout) * time.Second
+	info, chain, err := tlsAnalyze(hostPort, mode, timeout)
+	if err != nil {
+		gologger.AuditTimeLogger("[TLS] %s error: %v", hostPort, err)
+		return
+	}
+
+	structs.GlobalTLSInfoMapLock.Lock()
+	structs.GlobalTLSInfoMap[hostPort] = *info
+	structs.GlobalTLSInfoMapLock.Unlock()
+	if len(chain) > 0 {
+		structs.GlobalServiceCertMapLock.Lock()
+		if _, ok := structs.GlobalServiceCertMap[hostPort]; !ok {
+			structs.GlobalServiceCertMap[hostPort] = CertString(chain[0])
+		}
+		structs.GlobalServiceCertMapLock.Unlock()
+		AddCertHostNames(hostPort, chain[0])
+	}
+
+	// 协议未识别时按端口判断出的服务
+	if service == "" || service == "tcp" {
+		service = mode
+		if service == "" {
+			service = "tls"
+		}
+	}
+	ip, port, _ := net.SplitHostPort(hostPort)
+	var certs []ddout.CertInfo
+	for _, c := range info.Chain {
+		certs = append(certs, ddout.CertInfo(c))
+	}
+	ddout.FormatOutput(ddout.OutputMessage{
+		Type:        "TLS",
+		IP:          ip,
+		Port:        port,
+		Protocol:    service,
+		StartTLS:    mode,
+		TLSVersions: info.Versions,
+		JARM:        info.JARM,
+		JARMMatch:   info.JARMMatch,
+		Issues:      info.Issues,
+		Certs:       certs,
+	})
+	if info.JARMMatch != "" {
+		gologger.AuditTimeLogger("[TLS] %s JARM %s 与 %s 默认配置一致", hostPort, info.JARM, info.JARMMatch)
+	}
+}
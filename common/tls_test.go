@@ -0,0 +1,146 @@
+package common
+
+import (
+	"bytes"
+	"dddd/structs"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTLSMode(t *testing.T) {
+	tests := []struct {
+		hostPort string
+		service  string
+		db       bool
+		mode     string
+		ok       bool
+	}{
+		{"10.0.0.1:443", "https", false, "", true},
+		{"10.0.0.1:8443", "", false, "", true},
+		{"10.0.0.1:10443", "http-ssl", false, "", true},
+		{"10.0.0.1:25", "smtp", false, "smtp", true},
+		{"10.0.0.1:389", "tcp", false, "ldap", true},
+		{"10.0.0.1:80", "http", false, "", false},
+		{"10.0.0.1:3306", "mysql", false, "", false},
+		{"10.0.0.1:5432", "", false, "", false},
+		{"10.0.0.1:3306", "mysql", true, "mysql", true},
+		{"10.0.0.1:5432", "", true, "postgresql", true},
+	}
+	defer func() { structs.GlobalConfig.TLSCheckDB = false }()
+	for _, tt := range tests {
+		structs.GlobalConfig.TLSCheckDB = tt.db
+		mode, ok := TLSMode(tt.hostPort, tt.service)
+		if mode != tt.mode || ok != tt.ok {
+			t.Errorf("TLSMode(%q, %q) db=%v = (%q, %v), want (%q, %v)", tt.hostPort, tt.service, tt.db, mode, ok, tt.mode, tt.ok)
+		}
+	}
+}
+
+// pipeStub 服务端读取 requestLen 字节的请求后写入 reply，先写入 greeting
+func pipeStub(t *testing.T, greeting []byte, requestLen int, reply []byte) (net.Conn, <-chan []byte) {
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	_ = client.SetDeadline(time.Now().Add(2 * time.Second))
+	_ = server.SetDeadline(time.Now().Add(2 * time.Second))
+	requests := make(chan []byte, 1)
+	go func() {
+		defer close(requests)
+		if len(greeting) > 0 {
+			if _, err := server.Write(greeting); err != nil {
+				return
+			}
+		}
+		request := make([]byte, requestLen)
+		if _, err := io.ReadFull(server, request); err != nil {
+			return
+		}
+		requests <- request
+		if len(reply) > 0 {
+			_, _ = server.Write(reply)
+		}
+	}()
+	return client, requests
+}
+
+func TestLDAPStartTLS(t *testing.T) {
+	request := []byte{0x30, 0x1d, 0x02, 0x01, 0x01, 0x77, 0x18, 0x80, 0x16}
+	request = append(request, "1.3.6.1.4.1.1466.20037"...)
+
+	tests := []struct {
+		name  string
+		reply []byte
+		want  error
+	}{
+		{"success", []byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00}, nil},
+		{"long length", []byte{0x30, 0x0d, 0x02, 0x01, 0x01, 0x78, 0x81, 0x07, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00}, nil},
+		{"protocol error", []byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, 0x02, 0x04, 0x00, 0x04, 0x00}, errStartTLS},
+		{"unavailable", []byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, 0x34, 0x04, 0x00, 0x04, 0x00}, errStartTLS},
+		{"not extended response", []byte{0x30, 0x07, 0x02, 0x01, 0x01, 0x61, 0x02, 0x0a, 0x01}, errStartTLS},
+		{"truncated", []byte{0x30, 0x05, 0x02, 0x01, 0x01, 0x78, 0x07}, errStartTLS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, requests := pipeStub(t, nil, len(request), tt.reply)
+			if err := ldapStartTLS(conn); !errors.Is(err, tt.want) {
+				t.Errorf("ldapStartTLS() error = %v, want %v", err, tt.want)
+			}
+			if got := <-requests; !bytes.Equal(got, request) {
+				t.Errorf("request = % x, want % x", got, request)
+			}
+		})
+	}
+}
+
+// mysqlHandshake 构造 HandshakeV10 握手包
+func mysqlHandshake(protocol byte, capability uint16) []byte {
+	payload := []byte{protocol}
+	payload = append(payload, "8.0.36\x00"...)
+	payload = append(payload, 0x01, 0x00, 0x00, 0x00)
+	payload = append(payload, "abcdefgh"...)
+	payload = append(payload, 0x00)
+	payload = binary.LittleEndian.AppendUint16(payload, capability)
+	payload = append(payload, 0x21, 0x02, 0x00)
+	header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), 0x00}
+	return append(header, payload...)
+}
+
+func TestMySQLStartTLS(t *testing.T) {
+	tests := []struct {
+		name      string
+		handshake []byte
+		want      error
+		request   bool
+	}{
+		{"ssl", mysqlHandshake(0x0a, 0xffff), nil, true},
+		{"no ssl", mysqlHandshake(0x0a, 0xf7ff), errStartTLS, false},
+		{"protocol v9", mysqlHandshake(0x09, 0xffff), errStartTLS, false},
+		{"error packet", []byte{0x05, 0x00, 0x00, 0x00, 0xff, 0x6a, 0x04, 'H', 'o'}, errStartTLS, false},
+		{"empty", []byte{0x00, 0x00, 0x00, 0x00}, errStartTLS, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, requests := pipeStub(t, tt.handshake, 36, nil)
+			if err := mysqlStartTLS(conn); !errors.Is(err, tt.want) {
+				t.Fatalf("mysqlStartTLS() error = %v, want %v", err, tt.want)
+			}
+			if !tt.request {
+				return
+			}
+			got := <-requests
+			// 长度32，序号紧跟握手包
+			if !bytes.Equal(got[:4], []byte{32, 0, 0, 1}) {
+				t.Errorf("header = % x", got[:4])
+			}
+			if flags := binary.LittleEndian.Uint32(got[4:8]); flags&0x0800 == 0 {
+				t.Errorf("capability flags = %#x without CLIENT_SSL", flags)
+			}
+		})
+	}
+}
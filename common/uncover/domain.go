@@ -1,6 +1,16 @@
 package uncover
 
-import "dddd/structs"
+import (
+	"context"
+	"dddd/ddout"
+	"dddd/structs"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
 
 func AddIPDomainMap(ip string, domain string) {
 	structs.GlobalIPDomainMapLock.Lock()
@@ -30,3 +40,96 @@ func AddIPDomainMap(ip string, domain string) {
 		structs.GlobalIPDomainMapLock.Unlock()
 	}
 }
+
+// AddTargetKeyword 记录与ip相关的关键字，用于爆破字典中 {{key}} 的生成
+func AddTargetKeyword(ip string, keywords ...string) {
+	structs.GlobalTargetKeywordMapLock.Lock()
+	defer structs.GlobalTargetKeywordMapLock.Unlock()
+	if structs.GlobalTargetKeywordMap == nil {
+		structs.GlobalTargetKeywordMap = make(map[string][]string)
+	}
+	for _, keyword := range keywords {
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" {
+			continue
+		}
+		exists := false
+		for _, k := range structs.GlobalTargetKeywordMap[ip] {
+			if k == keyword {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			structs.GlobalTargetKeywordMap[ip] = append(structs.GlobalTargetKeywordMap[ip], keyword)
+		}
+	}
+}
+
+var hostNameRegexp = regexp.MustCompile(`^[a-z0-9_]([a-z0-9_-]*[a-z0-9])?(\.[a-z0-9_]([a-z0-9_-]*[a-z0-9])?)+$`)
+
+// AddHostName 记录从证书、PTR、跳转、NTLM等途径获取到的IP主机名，用于域名绑定资产探测。
+// 通配符、IP、localhost 与不含点的主机名不记录，新增时返回true
+func AddHostName(ip string, name string, source string) bool {
+	if net.ParseIP(ip) == nil {
+		return false
+	}
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if len(name) > 253 || net.ParseIP(name) != nil || !hostNameRegexp.MatchString(name) ||
+		name == "localhost" || strings.HasPrefix(name, "localhost.") {
+		return false
+	}
+
+	structs.GlobalIPDomainMapLock.Lock()
+	for _, dm := range structs.GlobalIPDomainMap[ip] {
+		if dm == name {
+			structs.GlobalIPDomainMapLock.Unlock()
+			return false
+		}
+	}
+	structs.GlobalIPDomainMap[ip] = append(structs.GlobalIPDomainMap[ip], name)
+	structs.GlobalIPDomainMapLock.Unlock()
+
+	gologger.AuditTimeLogger("[Hostname] %s => %s (%s)", ip, name, source)
+	ddout.FormatOutput(ddout.OutputMessage{
+		Type:          "Hostname",
+		IP:            ip,
+		Domain:        name,
+		AdditionalMsg: source,
+	})
+	return true
+}
+
+// HostResolves 主机名能否通过DNS解析
+func HostResolves(name string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, name)
+	return err == nil && len(addrs) > 0
+}
+
+// AddHostBind 记录主机名无法解析的域名绑定资产，rootURL 为 scheme://主机名:端口，ipRootURL 为实际连接的 scheme://IP:端口
+func AddHostBind(rootURL string, ipRootURL string) {
+	structs.GlobalHostBindMapLock.Lock()
+	structs.GlobalHostBindMap[rootURL] = ipRootURL
+	structs.GlobalHostBindMapLock.Unlock()
+}
+
+// HostBindURL 属于无法解析的域名绑定资产时，返回直接连接IP的URL与Host头
+func HostBindURL(rawURL string) (ipURL string, host string, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", "", false
+	}
+	rootURL := u.Scheme + "://" + u.Host
+	if !strings.HasPrefix(rawURL, rootURL) {
+		return "", "", false
+	}
+	structs.GlobalHostBindMapLock.Lock()
+	ipRootURL, ok := structs.GlobalHostBindMap[rootURL]
+	structs.GlobalHostBindMapLock.Unlock()
+	if !ok {
+		return "", "", false
+	}
+	return ipRootURL + rawURL[len(rootURL):], u.Host, true
+}
@@ -0,0 +1,73 @@
+package uncover
+
+import (
+	"dddd/structs"
+	"reflect"
+	"testing"
+)
+
+func TestAddHostName(t *testing.T) {
+	structs.GlobalIPDomainMap = map[string][]string{"10.0.0.1": {"exist.corp.local"}}
+	defer func() { structs.GlobalIPDomainMap = nil }()
+
+	tests := []struct {
+		ip   string
+		name string
+		want bool
+	}{
+		{"10.0.0.1", "oa.corp.local", true},
+		{"10.0.0.1", " OA.Corp.Local. ", false},
+		{"10.0.0.1", "exist.corp.local", false},
+		{"10.0.0.1", "dc01.corp.local.", true},
+		{"10.0.0.1", "*.corp.local", false},
+		{"10.0.0.1", "DC01", false},
+		{"10.0.0.1", "10.0.0.2", false},
+		{"10.0.0.1", "localhost", false},
+		{"10.0.0.1", "localhost.localdomain", false},
+		{"10.0.0.1", "", false},
+		{"10.0.0.1", "bad_host-.corp.local", false},
+		{"10.0.0.2", "_ldap.corp.local", true},
+		{"fe80::1", "printer.corp.local", true},
+		{"oa.corp.local", "oa.corp.local", false},
+	}
+	for _, tt := range tests {
+		if got := AddHostName(tt.ip, tt.name, "test"); got != tt.want {
+			t.Errorf("AddHostName(%q, %q) = %v, want %v", tt.ip, tt.name, got, tt.want)
+		}
+	}
+
+	want := map[string][]string{
+		"10.0.0.1": {"exist.corp.local", "oa.corp.local", "dc01.corp.local"},
+		"10.0.0.2": {"_ldap.corp.local"},
+		"fe80::1":  {"printer.corp.local"},
+	}
+	if !reflect.DeepEqual(structs.GlobalIPDomainMap, want) {
+		t.Errorf("GlobalIPDomainMap = %v, want %v", structs.GlobalIPDomainMap, want)
+	}
+}
+
+func TestHostBindURL(t *testing.T) {
+	structs.GlobalHostBindMap = make(map[string]string)
+	defer func() { structs.GlobalHostBindMap = nil }()
+	AddHostBind("https://oa.corp.local:8443", "https://10.0.0.5:8443")
+
+	tests := []struct {
+		rawURL string
+		ipURL  string
+		host   string
+		ok     bool
+	}{
+		{"https://oa.corp.local:8443", "https://10.0.0.5:8443", "oa.corp.local:8443", true},
+		{"https://oa.corp.local:8443/login?next=/", "https://10.0.0.5:8443/login?next=/", "oa.corp.local:8443", true},
+		{"http://oa.corp.local:8443/", "", "", false},
+		{"https://www.corp.local:8443/", "", "", false},
+		{"https://10.0.0.5:8443/", "", "", false},
+		{"10.0.0.5:445", "", "", false},
+	}
+	for _, tt := range tests {
+		ipURL, host, ok := HostBindURL(tt.rawURL)
+		if ipURL != tt.ipURL || host != tt.host || ok != tt.ok {
+			t.Errorf("HostBindURL(%q) = (%q, %q, %v), want (%q, %q, %v)", tt.rawURL, ipURL, host, ok, tt.ipURL, tt.host, tt.ok)
+		}
+	}
+}
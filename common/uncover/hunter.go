@@ -182,6 +182,7 @@ func SearchHunterCore(keyword string, pageSize int, maxQueryPage int) ([]string,
 			}
 			if !isCDN {
 				AddIPDomainMap(v.IP, v.Domain)
+				AddTargetKeyword(v.IP, v.Company)
 			}
 			if v.IsWeb == "是" {
 				if structs.GlobalConfig.LowPerceptionMode {
@@ -29,6 +29,19 @@ type GoPocsResultType struct {
 	ShowMsg     string `json:"show_msg,omitempty"`
 }
 
+// CertInfo 与 structs.TLSCertInfo 一致
+type CertInfo struct {
+	Subject            string   `json:"subject"`
+	Issuer             string   `json:"issuer"`
+	SAN                []string `json:"san,omitempty"`
+	NotBefore          string   `json:"not_before"`
+	NotAfter           string   `json:"not_after"`
+	KeyType            string   `json:"key_type"`
+	KeyBits            int      `json:"key_bits"`
+	SignatureAlgorithm string   `json:"signature_algorithm"`
+	SHA256             string   `json:"sha256"`
+}
+
 type OutputMessage struct {
 	Type          string           `json:"type,omitempty"`
 	IP            string           `json:"ip,omitempty"`
@@ -42,6 +55,25 @@ type OutputMessage struct {
 	URI           string           `json:"uri,omitempty"`
 	City          string           `json:"city,omitempty"`
 	AdditionalMsg string           `json:"am,omitempty"`
+	MAC           string           `json:"mac,omitempty"`
+	Names         []string         `json:"names,omitempty"`
+	Services      []string         `json:"services,omitempty"`
+	Model         string           `json:"model,omitempty"`
+	Stats         map[string]int   `json:"stats,omitempty"`
+	Product       string           `json:"product,omitempty"`
+	Version       string           `json:"version,omitempty"`
+	Info          string           `json:"info,omitempty"`
+	OS            string           `json:"os,omitempty"`
+	Device        string           `json:"device,omitempty"`
+	CPE           []string         `json:"cpe,omitempty"`
+	Probe         string           `json:"probe,omitempty"`
+	Match         string           `json:"match,omitempty"`
+	StartTLS      string           `json:"starttls,omitempty"`
+	TLSVersions   []string         `json:"tls_versions,omitempty"`
+	JARM          string           `json:"jarm,omitempty"`
+	JARMMatch     string           `json:"jarm_match,omitempty"`
+	Issues        []string         `json:"issues,omitempty"`
+	Certs         []CertInfo       `json:"certs,omitempty"`
 	Show          string           `json:"-"`
 	Nuclei        string           `json:"nuclei,omitempty"`
 }
@@ -53,10 +85,58 @@ func (o *OutputMessage) ToString() (string, error) {
 	// IP存活验证
 	if o.Type == "IPAlive" {
 		r = "[Alive] " + o.IP
+		if o.MAC != "" {
+			r += " [" + o.MAC + "]"
+		}
+	} else if o.Type == "Passive" {
+		r = "[Passive] " + o.IP
+		if len(o.Names) > 0 {
+			r += " [" + strings.Join(o.Names, ",") + "]"
+		}
+		if o.Model != "" {
+			r += " [" + o.Model + "]"
+		}
+		if len(o.Services) > 0 {
+			r += " [" + strings.Join(o.Services, ",") + "]"
+		}
+		if o.MAC != "" {
+			r += " [" + o.MAC + "]"
+		}
+	} else if o.Type == "SubnetStat" {
+		r = fmt.Sprintf("[Subnet] %s [alive %d/%d sampled %d]", o.IP, o.Stats["alive"], o.Stats["targets"], o.Stats["sampled"])
 	} else if o.Type == "PortScan" {
 		r = "[PortScan] " + o.IP + ":" + o.Port
 	} else if o.Type == "Nmap" {
 		r = fmt.Sprintf("[Nmap] %s://%s:%s", o.Protocol, o.IP, o.Port)
+		if v := o.ServiceVersion(); v != "" {
+			r += " [" + v + "]"
+		}
+		if o.OS != "" {
+			r += " [" + o.OS + "]"
+		}
+	} else if o.Type == "TLS" {
+		r = "[TLS] " + o.IP + ":" + o.Port
+		if o.Protocol != "" {
+			r += " [" + o.Protocol + "]"
+		}
+		if o.StartTLS != "" {
+			r += " [STARTTLS " + o.StartTLS + "]"
+		}
+		r += " [" + strings.Join(o.TLSVersions, ",") + "]"
+		if len(o.Certs) > 0 {
+			c := o.Certs[0]
+			r += fmt.Sprintf(" [%s %s-%d %s]", c.Subject, c.KeyType, c.KeyBits, c.NotAfter)
+		}
+		if o.JARM != "" {
+			r += " [" + o.JARM
+			if o.JARMMatch != "" {
+				r += " ~" + o.JARMMatch
+			}
+			r += "]"
+		}
+		if len(o.Issues) > 0 {
+			r += " [" + strings.Join(o.Issues, ",") + "]"
+		}
 	} else if o.Type == "Web" {
 		r = fmt.Sprintf("[Web] [%v] %s", o.Web.Status, o.URI)
 		if o.Web.Title != "" {
@@ -66,6 +146,8 @@ func (o *OutputMessage) ToString() (string, error) {
 		r = "[Brute] " + o.Domain
 	} else if o.Type == "DNS-SubFinder" {
 		r = "[SubFinder] " + o.Domain
+	} else if o.Type == "Hostname" {
+		r = "[Hostname] " + o.IP + " => " + o.Domain
 	} else if o.Type == "CDN-Domain" {
 		r = "[CDN-Domain] " + o.Domain
 	} else if o.Type == "RealIP" {
@@ -132,6 +214,21 @@ func (o *OutputMessage) ToString() (string, error) {
 	return r, err
 }
 
+// ServiceVersion 与nmap -sV的VERSION列一致，如 OpenSSH 8.9p1 (protocol 2.0)
+func (o *OutputMessage) ServiceVersion() string {
+	var parts []string
+	if o.Product != "" {
+		parts = append(parts, o.Product)
+	}
+	if o.Version != "" {
+		parts = append(parts, o.Version)
+	}
+	if o.Info != "" {
+		parts = append(parts, "("+o.Info+")")
+	}
+	return strings.Join(parts, " ")
+}
+
 func (o *OutputMessage) ToJson() (string, error) {
 	b, err := json.Marshal(o)
 	return string(b), err
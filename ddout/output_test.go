@@ -0,0 +1,32 @@
+package ddout
+
+import "testing"
+
+func TestTLSToString(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  OutputMessage
+		want string
+	}{
+		{"jarm", OutputMessage{Type: "TLS", IP: "10.0.0.5", Port: "636", Protocol: "ldaps", TLSVersions: []string{"TLS1.2", "TLS1.0"},
+			JARM: "29d29d00029d29d00042d43d00041d", Issues: []string{"weak-protocol"}},
+			"[TLS] 10.0.0.5:636 [ldaps] [TLS1.2,TLS1.0] [29d29d00029d29d00042d43d00041d] [weak-protocol]"},
+		// JARM命中只作为参考，不出现在问题标记中
+		{"jarm match", OutputMessage{Type: "TLS", IP: "10.0.0.9", Port: "8443", Protocol: "https", TLSVersions: []string{"TLS1.2"},
+			JARM: "07d14d16d21d21d07c42d41d00041d", JARMMatch: "Cobalt Strike"},
+			"[TLS] 10.0.0.9:8443 [https] [TLS1.2] [07d14d16d21d21d07c42d41d00041d ~Cobalt Strike]"},
+		{"no jarm", OutputMessage{Type: "TLS", IP: "10.0.0.8", Port: "25", Protocol: "smtp", StartTLS: "smtp", TLSVersions: []string{"TLS1.3"}},
+			"[TLS] 10.0.0.8:25 [smtp] [STARTTLS smtp] [TLS1.3]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.msg.ToString()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ToString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,13 +3,16 @@ module dddd
 go 1.21
 
 require (
+	github.com/Azure/go-ntlmssp v0.0.0-20221128193559-754e69321358
 	github.com/denisenkom/go-mssqldb v0.12.3
 	github.com/go-sql-driver/mysql v1.6.0
+	github.com/hdm/jarm-go v0.0.7
 	github.com/hirochachacha/go-smb2 v1.1.0
 	github.com/jlaffaye/ftp v0.2.0
 	github.com/lib/pq v1.10.9
 	github.com/projectdiscovery/dnsx v1.1.6
 	github.com/projectdiscovery/gologger v1.1.12
+	github.com/projectdiscovery/ratelimit v0.0.26
 	github.com/satori/go.uuid v1.2.0
 	github.com/sijms/go-ora/v2 v2.7.9
 	github.com/tomatome/grdp v0.1.0
@@ -21,7 +24,6 @@ require (
 	github.com/Azure/azure-sdk-for-go/sdk/azidentity v1.3.0 // indirect
 	github.com/Azure/azure-sdk-for-go/sdk/internal v1.3.0 // indirect
 	github.com/Azure/azure-sdk-for-go/sdk/storage/azblob v1.1.0 // indirect
-	github.com/Azure/go-ntlmssp v0.0.0-20221128193559-754e69321358 // indirect
 	github.com/AzureAD/microsoft-authentication-library-for-go v1.0.0 // indirect
 	github.com/Knetic/govaluate v3.0.1-0.20171022003610-9aa49832a739+incompatible // indirect
 	github.com/Microsoft/go-winio v0.6.1 // indirect
@@ -119,7 +121,6 @@ require (
 	github.com/hashicorp/go-version v1.6.0 // indirect
 	github.com/hashicorp/golang-lru/v2 v2.0.6 // indirect
 	github.com/hbakhtiyor/strsim v0.0.0-20190107154042-4d2bbb273edf // indirect
-	github.com/hdm/jarm-go v0.0.7 // indirect
 	github.com/huin/asn1ber v0.0.0-20120622192748-af09f62e6358 // indirect
 	github.com/iancoleman/orderedmap v0.0.0-20190318233801-ac98e3ecb4b0 // indirect
 	github.com/icodeface/tls v0.0.0-20190904083142-17aec93c60e5 // indirect
@@ -271,7 +272,6 @@ require (
 	github.com/projectdiscovery/hmap v0.0.36
 	github.com/projectdiscovery/httpx v1.3.5
 	github.com/projectdiscovery/mapcidr v1.1.16 // indirect
-	github.com/projectdiscovery/ratelimit v0.0.26 // indirect
 	github.com/projectdiscovery/retryabledns v1.0.53 // indirect
 	github.com/projectdiscovery/retryablehttp-go v1.0.44
 	github.com/projectdiscovery/subfinder/v2 v2.6.5
@@ -313,7 +313,7 @@ require (
 
 // nuclei
 require (
-	github.com/projectdiscovery/interactsh v1.1.8 // indirect
+	github.com/projectdiscovery/interactsh v1.1.8
 	github.com/projectdiscovery/nuclei/v3 v3.0.2
 )
 
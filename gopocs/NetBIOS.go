@@ -1,10 +1,11 @@
 package gopocs
 
 import (
-	"bytes"
 	"dddd/common"
+	"dddd/common/uncover"
 	"dddd/ddout"
 	"dddd/structs"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -22,6 +23,8 @@ func NetBIOS(info *structs.HostInfo) error {
 	netbios, _ := NetBIOS1(info)
 	output := netbios.String()
 	if len(output) > 0 {
+		uncover.AddTargetKeyword(info.Host, netbios.NetComputerName, netbios.ComputerName, netbios.ServerService,
+			netbios.WorkstationService, netbios.NetDomainName, netbios.DomainName, netbios.GroupName)
 		realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
 		result := fmt.Sprintf("NetBios: %s %s ", info.Host, output)
 
@@ -122,11 +125,55 @@ func NetBIOS1(info *structs.HostInfo) (netbios NetBiosInfo, err error) {
 		return
 	}
 	gologger.AuditTimeLogger("[Go] [netbios] [NetBIOS1] [3/] Dumped TCP response for %s\n\n%s\n", realhost, hex.Dump(ret))
-	netbios2, err := ParseNTLM(ret)
+	netbios2, err := parseSMBv1SessionSetup(ret)
+	if err != nil {
+		return
+	}
 	JoinNetBios(&netbios, &netbios2)
 	return
 }
 
+// parseSMBv1SessionSetup 从 SMBv1 Session Setup AndX 响应中解析 NTLM Challenge 及 NativeOS
+func parseSMBv1SessionSetup(ret []byte) (netbios NetBiosInfo, err error) {
+	ntlm, err := parseNTLMChallenge(ret)
+	if err != nil {
+		return
+	}
+	netbios = NetBiosInfo{
+		ComputerName:    ntlm.DNSComputer,
+		DomainName:      ntlm.DNSDomain,
+		NetComputerName: ntlm.NetBIOSComputer,
+		NetDomainName:   ntlm.NetBIOSDomain,
+		OsVersion:       smbv1NativeOS(ret),
+	}
+	if netbios.OsVersion == "" {
+		netbios.OsVersion = ntlm.OSVersion
+	}
+	return
+}
+
+// smbv1NativeOS SMBv1 Session Setup AndX 响应中安全数据之后的 NativeOS，如 "Windows Server 2008 R2 Standard 7601 Service Pack 1"
+func smbv1NativeOS(ret []byte) string {
+	// NetBIOS(4) SMB Header(32) WordCount(1) AndX(4) Action(2) SecurityBlobLength(2) ByteCount(2)
+	if len(ret) < 47 {
+		return ""
+	}
+	start := 47 + int(binary.LittleEndian.Uint16(ret[43:45]))
+	// Unicode字符串相对SMB头按2字节对齐
+	if (start-4)%2 == 1 {
+		start++
+	}
+	if start >= len(ret) {
+		return ""
+	}
+	data := ret[start:]
+	end := 0
+	for end+1 < len(data) && (data[end] != 0 || data[end+1] != 0) {
+		end += 2
+	}
+	return utf16String(data[:end])
+}
+
 func GetNbnsname(info *structs.HostInfo) (netbios NetBiosInfo, err error) {
 	senddata1 := []byte{102, 102, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 32, 67, 75, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 65, 0, 0, 33, 0, 1}
 	//senddata1 := []byte("ff\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00 CKAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\x00\x00!\x00\x01")
@@ -197,14 +244,6 @@ var (
 		"\x1E": "Browser Service Elections",
 	}
 
-	NetBIOS_ITEM_TYPE = map[string]string{
-		"\x01\x00": "NetBiosComputerName",
-		"\x02\x00": "NetBiosDomainName",
-		"\x03\x00": "ComputerName",
-		"\x04\x00": "DomainName",
-		"\x05\x00": "DNS tree name",
-		"\x07\x00": "Time stamp",
-	}
 	NegotiateSMBv1Data1 = []byte{
 		0x00, 0x00, 0x00, 0x85, 0xFF, 0x53, 0x4D, 0x42, 0x72, 0x00, 0x00, 0x00, 0x00, 0x18, 0x53, 0xC8,
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFE,
@@ -332,78 +371,6 @@ func ParseNetBios(input []byte) (netbios NetBiosInfo, err error) {
 	return
 }
 
-func ParseNTLM(ret []byte) (netbios NetBiosInfo, err error) {
-	if len(ret) < 47 {
-		err = netbioserr
-		return
-	}
-	var num1, num2 int
-	num1, err = bytetoint(ret[43:44][0])
-	if err != nil {
-		return
-	}
-	num2, err = bytetoint(ret[44:45][0])
-	if err != nil {
-		return
-	}
-	length := num1 + num2*256
-	if len(ret) < 48+length {
-		return
-	}
-	os_version := ret[47+length:]
-	tmp1 := bytes.ReplaceAll(os_version, []byte{0x00, 0x00}, []byte{124})
-	tmp1 = bytes.ReplaceAll(tmp1, []byte{0x00}, []byte{})
-	ostext := string(tmp1[:len(tmp1)-1])
-	ss := strings.Split(ostext, "|")
-	netbios.OsVersion = ss[0]
-	start := bytes.Index(ret, []byte("NTLMSSP"))
-	if len(ret) < start+45 {
-		return
-	}
-	num1, err = bytetoint(ret[start+40 : start+41][0])
-	if err != nil {
-		return
-	}
-	num2, err = bytetoint(ret[start+41 : start+42][0])
-	if err != nil {
-		return
-	}
-	length = num1 + num2*256
-	num1, err = bytetoint(ret[start+44 : start+45][0])
-	if err != nil {
-		return
-	}
-	offset, err := bytetoint(ret[start+44 : start+45][0])
-	if err != nil || len(ret) < start+offset+length {
-		return
-	}
-	var msg string
-	index := start + offset
-	for index < start+offset+length {
-		item_type := ret[index : index+2]
-		num1, err = bytetoint(ret[index+2 : index+3][0])
-		if err != nil {
-			continue
-		}
-		num2, err = bytetoint(ret[index+3 : index+4][0])
-		if err != nil {
-			continue
-		}
-		item_length := num1 + num2*256
-		item_content := bytes.ReplaceAll(ret[index+4:index+4+item_length], []byte{0x00}, []byte{})
-		index += 4 + item_length
-		if string(item_type) == "\x07\x00" {
-			//Time stamp, 不需要输出
-		} else if NetBIOS_ITEM_TYPE[string(item_type)] != "" {
-			msg += fmt.Sprintf("%s: %s\n", NetBIOS_ITEM_TYPE[string(item_type)], string(item_content))
-		} else if string(item_type) == "\x00\x00" {
-			break
-		}
-	}
-	err = yaml.Unmarshal([]byte(msg), &netbios)
-	return
-}
-
 func JoinNetBios(netbios1, netbios2 *NetBiosInfo) *NetBiosInfo {
 	netbios1.ComputerName = netbios2.ComputerName
 	netbios1.NetDomainName = netbios2.NetDomainName
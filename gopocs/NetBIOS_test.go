@@ -0,0 +1,94 @@
+package gopocs
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+func utf16Bytes(s string) []byte {
+	var b []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, r)
+	}
+	return b
+}
+
+// ntlmChallengeMessage 构造带 Version 与 TargetInfo 的 NTLM Challenge 消息
+func ntlmChallengeMessage(items map[uint16]string) []byte {
+	var targetInfo []byte
+	for id := uint16(1); id <= 5; id++ {
+		if v, ok := items[id]; ok {
+			targetInfo = binary.LittleEndian.AppendUint16(targetInfo, id)
+			targetInfo = binary.LittleEndian.AppendUint16(targetInfo, uint16(len(utf16Bytes(v))))
+			targetInfo = append(targetInfo, utf16Bytes(v)...)
+		}
+	}
+	targetInfo = append(targetInfo, 0, 0, 0, 0)
+
+	msg := append([]byte("NTLMSSP\x00"), 0x02, 0x00, 0x00, 0x00)
+	msg = append(msg, make([]byte, 8)...)
+	msg = binary.LittleEndian.AppendUint32(msg, 0x02808215)
+	msg = append(msg, make([]byte, 16)...)
+	msg = binary.LittleEndian.AppendUint16(msg, uint16(len(targetInfo)))
+	msg = binary.LittleEndian.AppendUint16(msg, uint16(len(targetInfo)))
+	msg = binary.LittleEndian.AppendUint32(msg, 56)
+	msg = append(msg, 6, 1, 0xb1, 0x1d, 0, 0, 0, 0x0f)
+	return append(msg, targetInfo...)
+}
+
+// smbv1SessionSetup 构造 Session Setup AndX 响应，securityBlob 后接 NativeOS 与 NativeLanMan
+func smbv1SessionSetup(blob []byte, nativeOS string) []byte {
+	ret := make([]byte, 43)
+	copy(ret[4:], "\xffSMB\x73")
+	ret[36] = 4
+	ret = binary.LittleEndian.AppendUint16(ret, uint16(len(blob)))
+	ret = append(ret, 0, 0)
+	ret = append(ret, blob...)
+	if len(ret)%2 == 1 {
+		ret = append(ret, 0)
+	}
+	if nativeOS != "" {
+		ret = append(ret, utf16Bytes(nativeOS)...)
+		ret = append(ret, 0, 0)
+		ret = append(ret, utf16Bytes("Windows 7 Ultimate 6.1")...)
+		ret = append(ret, 0, 0)
+	}
+	return ret
+}
+
+func TestParseSMBv1SessionSetup(t *testing.T) {
+	items := map[uint16]string{1: "DC01", 2: "CORP", 3: "dc01.corp.local", 4: "corp.local", 5: "corp.local"}
+	tests := []struct {
+		name    string
+		ret     []byte
+		want    NetBiosInfo
+		wantErr bool
+	}{
+		// 安全数据为偶数长度时 NativeOS 前有1字节对齐填充
+		{"padded", smbv1SessionSetup(ntlmChallengeMessage(items), "Windows 7 Ultimate 7601 Service Pack 1"), NetBiosInfo{
+			ComputerName: "dc01.corp.local", DomainName: "corp.local", NetComputerName: "DC01", NetDomainName: "CORP",
+			OsVersion: "Windows 7 Ultimate 7601 Service Pack 1",
+		}, false},
+		{"unpadded", smbv1SessionSetup(append(ntlmChallengeMessage(items), 0), "Windows Server 2008 R2 Standard 7601 Service Pack 1"), NetBiosInfo{
+			ComputerName: "dc01.corp.local", DomainName: "corp.local", NetComputerName: "DC01", NetDomainName: "CORP",
+			OsVersion: "Windows Server 2008 R2 Standard 7601 Service Pack 1",
+		}, false},
+		{"ntlm version", smbv1SessionSetup(ntlmChallengeMessage(map[uint16]string{1: "WS01", 2: "WORKGROUP"}), ""), NetBiosInfo{
+			NetComputerName: "WS01", NetDomainName: "WORKGROUP", OsVersion: "Windows 6.1 Build 7601",
+		}, false},
+		{"no ntlm", smbv1SessionSetup([]byte{0xa1, 0x07}, "Windows 5.1"), NetBiosInfo{}, true},
+		{"truncated", []byte{0x00, 0x00, 0x00, 0x10, 0xff, 'S', 'M', 'B'}, NetBiosInfo{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSMBv1SessionSetup(tt.ret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSMBv1SessionSetup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSMBv1SessionSetup() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
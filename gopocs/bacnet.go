@@ -0,0 +1,226 @@
+package gopocs
+
+import (
+	"dddd/structs"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"strings"
+	"time"
+)
+
+// BACnet/IP，对 Device 对象发送 ReadProperty(只读)
+
+const (
+	bacnetPropApplicationVersion = 12
+	bacnetPropDescription        = 28
+	bacnetPropFirmwareRevision   = 44
+	bacnetPropLocation           = 58
+	bacnetPropModelName          = 70
+	bacnetPropObjectIdentifier   = 75
+	bacnetPropObjectName         = 77
+	bacnetPropVendorIdentifier   = 120
+	bacnetPropVendorName         = 121
+)
+
+// 实例号未知时使用通配实例 4194303
+const bacnetWildcardInstance = 0x3fffff
+
+var bacnetNoResponseErr = errors.New("bacnet no response")
+
+// bacnetReadPropertyRequest BVLC Original-Unicast-NPDU + Confirmed ReadProperty，以属性ID作为 invoke ID
+func bacnetReadPropertyRequest(instance uint32, property byte) []byte {
+	objectID := make([]byte, 4)
+	binary.BigEndian.PutUint32(objectID, 8<<22|instance&0x3fffff)
+	apdu := append([]byte{0x00, 0x05, property, 0x0c, 0x0c}, objectID...)
+	apdu = append(apdu, 0x19, property)
+	npdu := append([]byte{0x01, 0x04}, apdu...)
+	req := []byte{0x81, 0x0a, 0x00, 0x00}
+	binary.BigEndian.PutUint16(req[2:4], uint16(4+len(npdu)))
+	return append(req, npdu...)
+}
+
+// bacnetAPDU 跳过 BVLC 与 NPDU 的路由字段
+func bacnetAPDU(resp []byte) ([]byte, error) {
+	if len(resp) < 6 || resp[0] != 0x81 || resp[4] != 0x01 {
+		return nil, errors.New("not bacnet protocol")
+	}
+	control := resp[5]
+	offset := 6
+	if control&0x80 != 0 {
+		return nil, errors.New("bacnet network layer message")
+	}
+	if control&0x20 != 0 {
+		if offset+3 > len(resp) {
+			return nil, errors.New("bacnet npdu too short")
+		}
+		offset += 3 + int(resp[offset+2])
+	}
+	if control&0x08 != 0 {
+		if offset+3 > len(resp) {
+			return nil, errors.New("bacnet npdu too short")
+		}
+		offset += 3 + int(resp[offset+2])
+	}
+	if control&0x20 != 0 {
+		offset++
+	}
+	if offset >= len(resp) {
+		return nil, errors.New("bacnet npdu too short")
+	}
+	return resp[offset:], nil
+}
+
+// bacnetParseValue 解析 ComplexACK 中 ReadProperty 的第一个应用层值
+func bacnetParseValue(apdu []byte) (string, error) {
+	if len(apdu) < 3 {
+		return "", errors.New("bacnet apdu too short")
+	}
+	switch apdu[0] >> 4 {
+	case 0x3:
+	case 0x5, 0x6, 0x7:
+		return "", errors.New("bacnet error/reject/abort")
+	default:
+		return "", errors.New("unexpected bacnet apdu")
+	}
+	if apdu[0]&0x08 != 0 {
+		return "", errors.New("bacnet segmented response")
+	}
+	// objectIdentifier(0x0c) + propertyIdentifier(0x19/0x1a) + 可选的 arrayIndex(0x29-0x2c)，0x3e 为 property-value 的开始标签
+	offset := 3
+	if offset+5 > len(apdu) || apdu[offset] != 0x0c {
+		return "", errors.New("bacnet value not found")
+	}
+	offset += 5
+	for _, tagNumber := range []byte{1, 2} {
+		if offset < len(apdu) && apdu[offset]>>4 == tagNumber && apdu[offset]&0x08 != 0 {
+			offset += 1 + int(apdu[offset]&0x07)
+		}
+	}
+	if offset+1 >= len(apdu) || apdu[offset] != 0x3e {
+		return "", errors.New("bacnet value not found")
+	}
+	start := offset + 1
+	tag := apdu[start]
+	tagNumber := tag >> 4
+	length := int(tag & 0x07)
+	offset = start + 1
+	if length == 5 {
+		if offset >= len(apdu) {
+			return "", errors.New("bacnet value too short")
+		}
+		length = int(apdu[offset])
+		offset++
+		if length == 254 {
+			if offset+2 > len(apdu) {
+				return "", errors.New("bacnet value too short")
+			}
+			length = int(binary.BigEndian.Uint16(apdu[offset:]))
+			offset += 2
+		}
+	}
+	if offset+length > len(apdu) {
+		return "", errors.New("bacnet value too short")
+	}
+	value := apdu[offset : offset+length]
+	switch tagNumber {
+	case 2:
+		var n uint64
+		for _, b := range value {
+			n = n<<8 | uint64(b)
+		}
+		return fmt.Sprint(n), nil
+	case 7:
+		// 第一个字节为字符集，0为UTF-8
+		if len(value) == 0 {
+			return "", nil
+		}
+		return strings.TrimSpace(string(value[1:])), nil
+	case 12:
+		if len(value) != 4 {
+			return "", errors.New("bacnet object identifier error")
+		}
+		return fmt.Sprint(binary.BigEndian.Uint32(value) & 0x3fffff), nil
+	}
+	return hex.EncodeToString(value), nil
+}
+
+func bacnetReadProperty(conn net.Conn, realhost string, instance uint32, property byte) (string, error) {
+	req := bacnetReadPropertyRequest(instance, property)
+	icsTake()
+	gologger.AuditTimeLogger("[Go] [BACnet] Dumped UDP request for %s\n\n%s\n", realhost, hex.Dump(req))
+	if _, err := conn.Write(req); err != nil {
+		return "", err
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(time.Duration(3) * time.Second)); err != nil {
+		return "", err
+	}
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return "", bacnetNoResponseErr
+		}
+		return "", err
+	}
+	gologger.AuditTimeLogger("[Go] [BACnet] Dumped UDP response for %s\n\n%s\n", realhost, hex.Dump(buf[:n]))
+	apdu, err := bacnetAPDU(buf[:n])
+	if err != nil {
+		return "", err
+	}
+	if len(apdu) > 1 && apdu[1] != property {
+		return "", errors.New("bacnet invoke id mismatch")
+	}
+	return bacnetParseValue(apdu)
+}
+
+func BACnetScan(info *structs.HostInfo) error {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	conn, err := net.DialTimeout("udp", realhost, time.Duration(6)*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// 先用通配实例获取设备真实实例号，部分设备不接受通配实例
+	instance := uint32(bacnetWildcardInstance)
+	id, err := bacnetReadProperty(conn, realhost, instance, bacnetPropObjectIdentifier)
+	if err == bacnetNoResponseErr {
+		return err
+	}
+	if err == nil {
+		var n uint32
+		if _, scanErr := fmt.Sscan(id, &n); scanErr == nil {
+			instance = n
+		}
+	}
+
+	d := &ICSDevice{Protocol: "BACnet/IP"}
+	d.Vendor, _ = bacnetReadProperty(conn, realhost, instance, bacnetPropVendorName)
+	d.Model, _ = bacnetReadProperty(conn, realhost, instance, bacnetPropModelName)
+	d.Firmware, _ = bacnetReadProperty(conn, realhost, instance, bacnetPropFirmwareRevision)
+	if err == nil {
+		d.addExtra("Instance", id)
+	}
+	for _, p := range []struct {
+		Name     string
+		Property byte
+	}{
+		{"VendorID", bacnetPropVendorIdentifier},
+		{"ApplicationVersion", bacnetPropApplicationVersion},
+		{"ObjectName", bacnetPropObjectName},
+		{"Description", bacnetPropDescription},
+		{"Location", bacnetPropLocation},
+	} {
+		v, _ := bacnetReadProperty(conn, realhost, instance, p.Property)
+		d.addExtra(p.Name, v)
+	}
+	if d.Vendor == "" && d.Model == "" && d.Firmware == "" && len(d.Extra) == 0 {
+		return errors.New("bacnet read property failed")
+	}
+	icsReport("BACnet-Info", "bacnet", realhost, d, "BACnet设备信息(ReadProperty)")
+	return nil
+}
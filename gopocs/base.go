@@ -5,25 +5,35 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"dddd/common/report"
+	"dddd/ddout"
 	"dddd/structs"
 	"dddd/utils"
 	"encoding/base64"
+	"github.com/projectdiscovery/retryablehttp-go"
 	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"sync"
+	"time"
 )
 
 var PluginList = map[string]interface{}{
 	"NetBios-GetHostInfo": NetBIOS,
 	"RPC-GetHostInfo":     Findnet,
+	"NTLM-GetHostInfo":    NTLMInfoScan,
 	"SSH-Crack":           SshScan,
+	"SSH-Audit":           SSHAuditScan,
+	"SSH-Key-Crack":       SshKeyScan,
 	"FTP-Crack":           FtpScan,
 	"Mysql-Crack":         MysqlScan,
 	"Mssql-Crack":         MssqlScan,
 	"Oracle-Crack":        OracleScan,
+	"Oracle-TNS":          OracleTNSScan,
 	"MongoDB-Crack":       MongodbScan,
 	"RDP-Crack":           RdpScan,
+	"RDP-Security":        RDPSecurityScan,
 	"Redis-Crack":         RedisScan,
 	"SMB-MS17-010":        MS17010,
 	"PostgreSQL-Crack":    PostgresScan,
@@ -33,6 +43,20 @@ var PluginList = map[string]interface{}{
 	"JDWP-Scan":           JDWPScan,
 	"Shiro-Key-Crack":     ShiroKeyCheck,
 	"ADB-Scan":            ADBScan,
+	"SMB-Negotiate":       SMBNegotiateCheck,
+	"SMB-SMBGhost":        SMBGhostCheck,
+	"VNC-Crack":           VncScan,
+	"WinRM-Crack":         WinRMScan,
+	"SMTP-Scan":           SmtpScan,
+	"SMTP-Crack":          SmtpAuthScan,
+	"POP3-Crack":          Pop3Scan,
+	"IMAP-Crack":          ImapScan,
+	"Rsync-Scan":          RsyncScan,
+	"NFS-Scan":            NFSScan,
+	"Modbus-DeviceID":     ModbusScan,
+	"S7-Info":             S7Scan,
+	"BACnet-Info":         BACnetScan,
+	"DNP3-LinkStatus":     DNP3Scan,
 }
 
 var WriteResultLock sync.Mutex
@@ -111,14 +135,6 @@ func PKCS7UnPadding(origData []byte) []byte {
 	return origData[:(length - unpadding)]
 }
 
-func generateKeys(key string) []string {
-	var results []string
-	results = append(results, strings.ToLower(key))
-	results = append(results, strings.ToUpper(key))
-	results = append(results, strings.ToUpper(strings.ToUpper(key[:1])+key[1:]))
-	return results
-}
-
 func splitUserPass(userPasswd string) (user string, oriPass string) {
 	sp := strings.Split(userPasswd, " : ")
 	user = ""
@@ -136,9 +152,10 @@ func splitUserPass(userPasswd string) (user string, oriPass string) {
 }
 
 func sortUserPassword(info *structs.HostInfo, UserPasswdDict string, DefaultKeys []string) []structs.UserPasswd {
-	var userPasswdList []structs.UserPasswd
 	var upList []string
-	if structs.GlobalConfig.Password != "" {
+	if info.CredReuse {
+		upList = info.UserPass
+	} else if structs.GlobalConfig.Password != "" {
 		upList = append(upList, structs.GlobalConfig.Password)
 	} else if structs.GlobalConfig.PasswordFile != "" {
 		b, err := os.ReadFile(structs.GlobalConfig.PasswordFile)
@@ -159,34 +176,67 @@ func sortUserPassword(info *structs.HostInfo, UserPasswdDict string, DefaultKeys
 			upList = append(upList, v)
 		}
 	}
+	// 已确认的凭据优先尝试，只有密码的凭据(Redis、VNC)不参与
+	if !info.CredReuse {
+		var reuse []string
+		for _, v := range reuseCandidates(info, time.Time{}) {
+			if !strings.HasPrefix(v, " : ") {
+				reuse = append(reuse, v)
+			}
+		}
+		upList = append(reuse, upList...)
+	}
 	upList = utils.RemoveDuplicateElement(upList)
 
 	// 统计变形后的字典
-	for _, userPasswd := range upList {
-		user, oriPass := splitUserPass(userPasswd)
+	return expandUserPass(info, upList, DefaultKeys)
+}
 
-		if strings.Contains(oriPass, "{{key}}") {
-			for _, sKey := range info.InfoStr {
-				newKeys := generateKeys(sKey)
-				for _, nKey := range newKeys {
-					newPass := strings.Replace(oriPass, "{{key}}", nKey, -1)
-					userPasswdList = append(userPasswdList, structs.UserPasswd{UserName: user, Password: newPass})
-				}
+// sortPassword 用于只需要密码的服务(Redis、VNC)，字典中的用户名会被忽略
+func sortPassword(info *structs.HostInfo, PasswdDict string, DefaultKeys []string) []string {
+	var upList []string
 
-			}
-			for _, dk := range DefaultKeys {
-				newKeys := generateKeys(dk)
-				for _, nKey := range newKeys {
-					newPass := strings.Replace(oriPass, "{{key}}", nKey, -1)
-					userPasswdList = append(userPasswdList, structs.UserPasswd{UserName: user, Password: newPass})
+	if info.CredReuse {
+		for _, v := range info.UserPass {
+			_, p := splitUserPass(v)
+			upList = append(upList, p)
+		}
+	} else if structs.GlobalConfig.Password != "" {
+		upList = append(upList, structs.GlobalConfig.Password)
+	} else if structs.GlobalConfig.PasswordFile != "" {
+		b, err := os.ReadFile(structs.GlobalConfig.PasswordFile)
+		if err == nil {
+			t := strings.ReplaceAll(string(b), "\r\n", "\n")
+			for _, v := range strings.Split(t, "\n") {
+				if !strings.Contains(v, " : ") {
+					continue
 				}
+				upList = append(upList, v)
 			}
-
-		} else {
-			userPasswdList = append(userPasswdList, structs.UserPasswd{UserName: user, Password: oriPass})
+		}
+	} else {
+		for _, v := range info.UserPass {
+			_, p := splitUserPass(v)
+			upList = append(upList, p)
+		}
+		for _, v := range strings.Split(strings.ReplaceAll(PasswdDict, "\r\n", "\n"), "\n") {
+			upList = append(upList, v)
 		}
 	}
-	return RemoveDuplicateUserPass(userPasswdList)
+	// 已确认的凭据优先尝试
+	if !info.CredReuse {
+		var reuse []string
+		for _, v := range reuseCandidates(info, time.Time{}) {
+			_, p := splitUserPass(v)
+			reuse = append(reuse, p)
+		}
+		upList = append(reuse, upList...)
+	}
+
+	upList = utils.RemoveDuplicateElement(upList)
+
+	// 统计变形后的字典
+	return expandPassword(info, upList, DefaultKeys)
 }
 
 func RemoveDuplicateUserPass(input []structs.UserPasswd) []structs.UserPasswd {
@@ -205,6 +255,9 @@ func CheckErrs(err error) bool {
 	if err == nil {
 		return false
 	}
+	if isLockoutErr(err) {
+		return true
+	}
 	errs := []string{
 		"closed by the remote host", "too many connections",
 		"i/o timeout", "EOF", "A connection attempt failed",
@@ -223,12 +276,45 @@ func CheckErrs(err error) bool {
 	return false
 }
 
+// newHTTPClient Go Poc 发送HTTP请求使用的客户端，与Web探测一致使用 -proxy 与 -wto
+func newHTTPClient() *retryablehttp.Client {
+	transport := retryablehttp.DefaultHostSprayingTransport()
+	if structs.GlobalConfig.HTTPProxy != "" {
+		if proxyURL, err := url.Parse(structs.GlobalConfig.HTTPProxy); err == nil {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	opts := retryablehttp.DefaultOptionsSpraying
+	opts.RetryMax = 2
+	if structs.GlobalConfig.WebTimeout > 0 {
+		opts.Timeout = time.Duration(structs.GlobalConfig.WebTimeout) * time.Second
+	}
+	opts.HttpClient = &http.Client{Transport: transport, Timeout: opts.Timeout}
+	return retryablehttp.NewClient(opts)
+}
+
 func GoPocWriteResult(result structs.GoPocsResultType) {
 	WriteResultLock.Lock()
 	report.AddResultByGoPocResult(result)
 	WriteResultLock.Unlock()
 }
 
+// GoPocOutput 同时输出到结果文件与HTML报告
+func GoPocOutput(result structs.GoPocsResultType, showMsg string) {
+	ddout.FormatOutput(ddout.OutputMessage{
+		Type: "GoPoc",
+		GoPoc: ddout.GoPocsResultType{PocName: result.PocName,
+			Security:    result.Security,
+			Target:      result.Target,
+			InfoLeft:    result.InfoLeft,
+			InfoRight:   result.InfoRight,
+			Description: result.Description,
+			ShowMsg:     showMsg},
+	})
+
+	GoPocWriteResult(result)
+}
+
 func readDict(name string) string {
 	bt, err := os.ReadFile(name)
 	if err != nil {
@@ -263,6 +349,9 @@ func initDic() {
 	if fileExists(basePath + "") {
 		oracleUserPasswdDict = readDict(basePath + "oracle.txt")
 	}
+	if fileExists(basePath + "oracle_sid.txt") {
+		oracleSIDDict = readDict(basePath + "oracle_sid.txt")
+	}
 	if fileExists(basePath + "") {
 		postgreSQLUserPasswdDict = readDict(basePath + "postgresql.txt")
 	}
@@ -272,6 +361,9 @@ func initDic() {
 	if fileExists(basePath + "") {
 		redisUserPasswdDict = readDict(basePath + "redis.txt")
 	}
+	if fileExists(basePath + "redis_acl.txt") {
+		redisACLUserPasswdDict = readDict(basePath + "redis_acl.txt")
+	}
 	if fileExists(basePath + "") {
 		smbUserPasswdDict = readDict(basePath + "smb.txt")
 	}
@@ -281,6 +373,12 @@ func initDic() {
 	if fileExists(basePath + "") {
 		telnetUserPasswdDict = readDict(basePath + "telnet.txt")
 	}
+	if fileExists(basePath + "vnc.txt") {
+		vncPasswdDict = readDict(basePath + "vnc.txt")
+	}
+	if fileExists(basePath + "mail.txt") {
+		mailUserPasswdDict = readDict(basePath + "mail.txt")
+	}
 	if fileExists(basePath + "") {
 		ShiroKeys = readDict(basePath + "shirokeys.txt")
 	}
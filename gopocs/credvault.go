@@ -0,0 +1,225 @@
+package gopocs
+
+import (
+	"dddd/structs"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 凭据库：收集所有已确认的凭据，导出为单独的文件，并在第二轮中复用到其它服务
+
+type Credential struct {
+	Service string    `json:"service"`
+	Host    string    `json:"host"`
+	Port    string    `json:"port"`
+	User    string    `json:"user"`
+	Secret  string    `json:"secret"`
+	Source  string    `json:"source"`
+	Time    time.Time `json:"time"`
+}
+
+func (c Credential) Target() string {
+	return fmt.Sprintf("%s://%s:%s", c.Service, c.Host, c.Port)
+}
+
+var credentialVault []Credential
+var credentialVaultLock sync.Mutex
+
+// 参与凭据复用的爆破插件，插件名去掉 -Crack 后即为服务名
+var credReusePlugins = map[string]bool{
+	"SSH-Crack":        true,
+	"FTP-Crack":        true,
+	"Mysql-Crack":      true,
+	"Mssql-Crack":      true,
+	"Oracle-Crack":     true,
+	"RDP-Crack":        true,
+	"Redis-Crack":      true,
+	"PostgreSQL-Crack": true,
+	"SMB-Crack":        true,
+	"Telnet-Crack":     true,
+	"VNC-Crack":        true,
+	"WinRM-Crack":      true,
+	"SMTP-Crack":       true,
+	"POP3-Crack":       true,
+	"IMAP-Crack":       true,
+}
+
+type credReuseTask struct {
+	Name  string
+	Host  string
+	Port  string
+	Start time.Time
+}
+
+// 第一轮中派发过的爆破任务，AddScan 为单线程调用
+var credReuseTasks []*credReuseTask
+
+// AddCredential 记录一条已确认的凭据，reuse 表示来自第二轮的凭据复用
+func AddCredential(service, host, port, user, secret string, reuse bool) {
+	credentialVaultLock.Lock()
+	defer credentialVaultLock.Unlock()
+
+	source := "bruteforce"
+	if reuse {
+		source = "reuse"
+		for _, c := range credentialVault {
+			if c.User == user && c.Secret == secret {
+				source = "reuse from " + c.Target()
+				break
+			}
+		}
+	} else if structs.GlobalConfig.Password != "" || structs.GlobalConfig.PasswordFile != "" {
+		source = "user-supplied"
+	}
+
+	for _, c := range credentialVault {
+		if c.Service == service && c.Host == host && c.Port == port && c.User == user && c.Secret == secret {
+			return
+		}
+	}
+	credentialVault = append(credentialVault, Credential{
+		Service: service,
+		Host:    host,
+		Port:    port,
+		User:    user,
+		Secret:  secret,
+		Source:  source,
+		Time:    time.Now(),
+	})
+}
+
+// credReuseEnabled -cred-reuse none 时关闭复用，但仍然收集与导出
+func credReuseEnabled() bool {
+	return structs.GlobalConfig.CredReuseScope != "none" && !structs.GlobalConfig.NoServiceBruteForce
+}
+
+// credInScope 判断凭据来源主机是否在复用范围内
+func credInScope(credHost, host string) bool {
+	switch structs.GlobalConfig.CredReuseScope {
+	case "host":
+		return credHost == host
+	case "subnet":
+		if credHost == host {
+			return true
+		}
+		a, b := net.ParseIP(credHost), net.ParseIP(host)
+		if a == nil || b == nil {
+			return false
+		}
+		mask := net.CIDRMask(64, 128)
+		if a.To4() != nil && b.To4() != nil {
+			a, b = a.To4(), b.To4()
+			mask = net.CIDRMask(24, 32)
+		}
+		return a.Mask(mask).Equal(b.Mask(mask))
+	}
+	return true
+}
+
+// reuseCandidates 返回可用于当前目标的已确认凭据，格式与字典一致 "user : pass"
+// after 非零时只返回该时间之后确认的凭据
+func reuseCandidates(info *structs.HostInfo, after time.Time) []string {
+	if !credReuseEnabled() {
+		return nil
+	}
+	credentialVaultLock.Lock()
+	defer credentialVaultLock.Unlock()
+
+	var result []string
+	for _, c := range credentialVault {
+		if c.Host == info.Host && c.Port == info.Ports {
+			continue
+		}
+		if !c.Time.After(after) || !credInScope(c.Host, info.Host) {
+			continue
+		}
+		result = append(result, c.User+" : "+c.Secret)
+	}
+	return result
+}
+
+// hasCredential 目标服务是否已经拿到凭据
+func hasCredential(service, host, port string) bool {
+	credentialVaultLock.Lock()
+	defer credentialVaultLock.Unlock()
+	for _, c := range credentialVault {
+		if c.Service == service && c.Host == host && c.Port == port {
+			return true
+		}
+	}
+	return false
+}
+
+// credentialReusePass 第二轮：将第一轮爆破期间新确认的凭据喂给其它服务
+func credentialReusePass(ch *chan struct{}, wg *sync.WaitGroup) {
+	if !credReuseEnabled() || len(credentialVault) == 0 {
+		return
+	}
+	var count int
+	for _, task := range credReuseTasks {
+		service := strings.TrimSuffix(task.Name, "-Crack")
+		if hasCredential(service, task.Host, task.Port) {
+			continue
+		}
+		info := structs.HostInfo{Host: task.Host, Ports: task.Port, CredReuse: true}
+		// 任务开始前确认的凭据在第一轮已优先尝试过
+		info.UserPass = reuseCandidates(&info, task.Start)
+		if len(info.UserPass) == 0 {
+			continue
+		}
+		if count == 0 {
+			gologger.Info().Msgf("凭据复用: 已确认凭据 %d 条，开始第二轮尝试", len(credentialVault))
+		}
+		count++
+		AddScan(task.Name, info, ch, wg)
+	}
+}
+
+// ExportCredentials 按文件后缀导出凭据库，.csv 为CSV，其余为JSON
+func ExportCredentials() {
+	credentialVaultLock.Lock()
+	defer credentialVaultLock.Unlock()
+	if len(credentialVault) == 0 {
+		return
+	}
+
+	filename := structs.GlobalConfig.CredOutputFile
+	if filename == "" {
+		output := structs.GlobalConfig.OutputFile
+		filename = strings.TrimSuffix(output, filepath.Ext(output)) + "_credentials.json"
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		gologger.Error().Msgf("凭据导出失败: %v", err)
+		return
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(filename), ".csv") {
+		w := csv.NewWriter(f)
+		_ = w.Write([]string{"service", "host", "port", "user", "secret", "source", "time"})
+		for _, c := range credentialVault {
+			_ = w.Write([]string{c.Service, c.Host, c.Port, c.User, c.Secret, c.Source, c.Time.Format("2006-01-02 15:04:05")})
+		}
+		w.Flush()
+		err = w.Error()
+	} else {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(credentialVault)
+	}
+	if err != nil {
+		gologger.Error().Msgf("凭据导出失败: %v", err)
+		return
+	}
+	gologger.Info().Msgf("已确认凭据 %d 条，导出至 %s", len(credentialVault), filename)
+}
@@ -0,0 +1,312 @@
+package gopocs
+
+import (
+	"context"
+	"database/sql"
+	"dddd/structs"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 数据库登录成功后的信息收集，所有语句均为只读查询
+
+// 每个实例最多统计的库/集合数量，避免大实例耗时过长
+const dbPostAuthMaxDatabases = 20
+
+type DBEntry struct {
+	Name   string
+	Tables int64
+	Rows   int64
+	Size   string
+}
+
+type DBPostAuthInfo struct {
+	Service    string
+	Target     string
+	Version    string
+	User       string
+	Privileges []string
+	// Risks 高危权限或配置，如 FILE、sysadmin、xp_cmdshell
+	Risks     []string
+	Databases []DBEntry
+	Settings  [][2]string
+	// Rating 指定输出等级，为空时按是否存在 Risks 判断
+	Rating string
+}
+
+func (d *DBPostAuthInfo) addSetting(k, v string) {
+	d.Settings = append(d.Settings, [2]string{k, v})
+}
+
+func (d *DBPostAuthInfo) String() string {
+	msg := fmt.Sprintf("Service: %v\nTarget: %v\nVersion: %v\nCurrentUser: %v\n", d.Service, d.Target, d.Version, d.User)
+	if len(d.Risks) > 0 {
+		msg += fmt.Sprintf("Risks: %v\n", strings.Join(d.Risks, ", "))
+	}
+	if len(d.Privileges) > 0 {
+		msg += "\nPrivileges:\n"
+		for _, p := range d.Privileges {
+			msg += "     " + p + "\n"
+		}
+	}
+	if len(d.Settings) > 0 {
+		msg += "\nSettings:\n"
+		for _, s := range d.Settings {
+			msg += fmt.Sprintf("     %v = %v\n", s[0], s[1])
+		}
+	}
+	if len(d.Databases) > 0 {
+		msg += "\nDatabases:\n"
+		msg += fmt.Sprintf("     %-30s %8s %12s %s\n", "NAME", "TABLES", "ROWS", "SIZE")
+		for _, e := range d.Databases {
+			msg += fmt.Sprintf("     %-30s %8d %12d %s\n", e.Name, e.Tables, e.Rows, e.Size)
+		}
+	}
+	return msg
+}
+
+// reportDBPostAuth 输出收集结果，存在高危权限时提升等级
+func reportDBPostAuth(d *DBPostAuthInfo) {
+	security := "INFO"
+	if len(d.Risks) > 0 {
+		security = "HIGH"
+	}
+	if d.Rating != "" {
+		security = d.Rating
+	}
+	show := fmt.Sprintf("%s-PostAuth %s [%s] [%s]", d.Service, d.Target, d.User, d.Version)
+	if len(d.Risks) > 0 {
+		show += " [" + strings.Join(d.Risks, ",") + "]"
+	}
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     d.Service + "-PostAuth",
+		Security:    security,
+		Target:      d.Target,
+		InfoLeft:    fmt.Sprintf("Host: %v\nUser: %v\nVersion: %v\n", d.Target, d.User, d.Version),
+		InfoRight:   d.String(),
+		Description: d.Service + "登录后信息收集",
+	}, show)
+}
+
+// dbQuery 执行只读查询并把结果统一转为字符串
+func dbQuery(db *sql.DB, query string) ([][]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(6)*time.Second)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result [][]string
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		for i := range values {
+			values[i] = new(interface{})
+		}
+		if err = rows.Scan(values...); err != nil {
+			continue
+		}
+		var row []string
+		for _, v := range values {
+			switch t := (*(v.(*interface{}))).(type) {
+			case nil:
+				row = append(row, "NULL")
+			case []byte:
+				row = append(row, string(t))
+			default:
+				row = append(row, fmt.Sprintf("%v", t))
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// dbQueryOne 取第一行第一列
+func dbQueryOne(db *sql.DB, query string) string {
+	rows, err := dbQuery(db, query)
+	if err != nil || len(rows) == 0 || len(rows[0]) == 0 {
+		return ""
+	}
+	return rows[0][0]
+}
+
+func toInt64(s string) int64 {
+	var i int64
+	_, _ = fmt.Sscanf(s, "%d", &i)
+	return i
+}
+
+func mysqlPostAuth(db *sql.DB, target string) *DBPostAuthInfo {
+	d := &DBPostAuthInfo{Service: "Mysql", Target: target}
+	d.Version = dbQueryOne(db, "SELECT @@version")
+	d.User = dbQueryOne(db, "SELECT CURRENT_USER()")
+
+	rows, _ := dbQuery(db, "SHOW GRANTS")
+	for _, r := range rows {
+		grant := r[0]
+		d.Privileges = append(d.Privileges, grant)
+		upper := strings.ToUpper(grant)
+		if strings.Contains(upper, "ALL PRIVILEGES ON *.*") {
+			d.Risks = append(d.Risks, "ALL PRIVILEGES")
+		}
+		if strings.Contains(upper, " FILE") && strings.Contains(upper, "ON *.*") {
+			d.Risks = append(d.Risks, "FILE")
+		}
+		if strings.Contains(upper, " SUPER") && strings.Contains(upper, "ON *.*") {
+			d.Risks = append(d.Risks, "SUPER")
+		}
+	}
+
+	for _, name := range []string{"secure_file_priv", "plugin_dir", "datadir", "general_log", "general_log_file", "version_compile_os"} {
+		rows, err := dbQuery(db, "SELECT @@"+name)
+		if err != nil || len(rows) == 0 || len(rows[0]) == 0 {
+			d.addSetting(name, "")
+			continue
+		}
+		d.addSetting(name, rows[0][0])
+		// 空字符串表示不限制导入导出目录，NULL表示禁止
+		if name == "secure_file_priv" && rows[0][0] == "" {
+			d.Risks = append(d.Risks, "secure_file_priv=''")
+		}
+	}
+
+	rows, _ = dbQuery(db, fmt.Sprintf("SELECT TABLE_SCHEMA, COUNT(*), IFNULL(SUM(TABLE_ROWS),0), IFNULL(SUM(DATA_LENGTH+INDEX_LENGTH),0) FROM information_schema.TABLES GROUP BY TABLE_SCHEMA LIMIT %d", dbPostAuthMaxDatabases))
+	for _, r := range rows {
+		d.Databases = append(d.Databases, DBEntry{Name: r[0], Tables: toInt64(r[1]), Rows: toInt64(r[2]), Size: r[3] + " bytes"})
+	}
+	d.Risks = removeDuplicateString(d.Risks)
+	return d
+}
+
+func mssqlPostAuth(db *sql.DB, target string) *DBPostAuthInfo {
+	d := &DBPostAuthInfo{Service: "Mssql", Target: target}
+	d.Version = strings.Split(dbQueryOne(db, "SELECT @@VERSION"), "\n")[0]
+	d.User = dbQueryOne(db, "SELECT SYSTEM_USER")
+
+	for _, role := range []string{"sysadmin", "serveradmin", "securityadmin", "dbcreator"} {
+		if dbQueryOne(db, fmt.Sprintf("SELECT IS_SRVROLEMEMBER('%s')", role)) == "1" {
+			d.Privileges = append(d.Privileges, role)
+			if role == "sysadmin" {
+				d.Risks = append(d.Risks, "sysadmin")
+			}
+		}
+	}
+
+	rows, _ := dbQuery(db, "SELECT name, CAST(value_in_use AS INT) FROM sys.configurations WHERE name IN ('xp_cmdshell','Ole Automation Procedures','clr enabled','Ad Hoc Distributed Queries')")
+	for _, r := range rows {
+		d.addSetting(r[0], r[1])
+		if r[1] == "1" {
+			d.Risks = append(d.Risks, r[0]+" enabled")
+		}
+	}
+
+	rows, _ = dbQuery(db, fmt.Sprintf("SELECT TOP %d name FROM sys.databases ORDER BY name", dbPostAuthMaxDatabases))
+	for _, r := range rows {
+		name := strings.ReplaceAll(r[0], "]", "]]")
+		e := DBEntry{Name: r[0]}
+		stat, err := dbQuery(db, fmt.Sprintf("SELECT COUNT(DISTINCT t.object_id), ISNULL(SUM(p.rows),0) FROM [%s].sys.tables t JOIN [%s].sys.partitions p ON t.object_id = p.object_id AND p.index_id IN (0,1)", name, name))
+		if err == nil && len(stat) > 0 {
+			e.Tables = toInt64(stat[0][0])
+			e.Rows = toInt64(stat[0][1])
+		}
+		d.Databases = append(d.Databases, e)
+	}
+	return d
+}
+
+func postgresPostAuth(db *sql.DB, target string) *DBPostAuthInfo {
+	d := &DBPostAuthInfo{Service: "PostgreSQL", Target: target}
+	d.Version = dbQueryOne(db, "SELECT version()")
+	d.User = dbQueryOne(db, "SELECT current_user")
+
+	rows, _ := dbQuery(db, "SELECT rolsuper, rolcreaterole, rolcreatedb FROM pg_roles WHERE rolname = current_user")
+	if len(rows) > 0 {
+		names := []string{"superuser", "createrole", "createdb"}
+		for i, v := range rows[0] {
+			if v == "true" {
+				d.Privileges = append(d.Privileges, names[i])
+			}
+		}
+		if rows[0][0] == "true" {
+			d.Risks = append(d.Risks, "superuser")
+		}
+	}
+	for _, role := range []string{"pg_read_server_files", "pg_write_server_files", "pg_execute_server_program"} {
+		if dbQueryOne(db, fmt.Sprintf("SELECT pg_has_role(current_user, '%s', 'member')", role)) == "true" {
+			d.Privileges = append(d.Privileges, role)
+			d.Risks = append(d.Risks, role)
+		}
+	}
+
+	for _, name := range []string{"data_directory", "config_file", "hba_file", "listen_addresses", "log_directory"} {
+		d.addSetting(name, dbQueryOne(db, "SHOW "+name))
+	}
+
+	rows, _ = dbQuery(db, fmt.Sprintf("SELECT datname, pg_size_pretty(pg_database_size(datname)) FROM pg_database WHERE datistemplate = false ORDER BY datname LIMIT %d", dbPostAuthMaxDatabases))
+	current := dbQueryOne(db, "SELECT current_database()")
+	for _, r := range rows {
+		e := DBEntry{Name: r[0], Size: r[1]}
+		// 行数统计只能在当前连接的库内进行
+		if r[0] == current {
+			stat, err := dbQuery(db, "SELECT COUNT(*), COALESCE(SUM(n_live_tup),0) FROM pg_stat_user_tables")
+			if err == nil && len(stat) > 0 {
+				e.Tables = toInt64(stat[0][0])
+				e.Rows = toInt64(stat[0][1])
+			}
+		}
+		d.Databases = append(d.Databases, e)
+	}
+	return d
+}
+
+func oraclePostAuth(db *sql.DB, target string) *DBPostAuthInfo {
+	d := &DBPostAuthInfo{Service: "Oracle", Target: target}
+	d.Version = dbQueryOne(db, "SELECT banner FROM v$version WHERE ROWNUM = 1")
+	d.User = dbQueryOne(db, "SELECT USER FROM dual")
+
+	rows, _ := dbQuery(db, "SELECT granted_role FROM user_role_privs")
+	for _, r := range rows {
+		d.Privileges = append(d.Privileges, "ROLE "+r[0])
+		if r[0] == "DBA" {
+			d.Risks = append(d.Risks, "DBA")
+		}
+	}
+	rows, _ = dbQuery(db, "SELECT privilege FROM session_privs")
+	for _, r := range rows {
+		d.Privileges = append(d.Privileges, r[0])
+		if r[0] == "CREATE ANY PROCEDURE" || r[0] == "CREATE EXTERNAL JOB" || r[0] == "SELECT ANY DICTIONARY" {
+			d.Risks = append(d.Risks, r[0])
+		}
+	}
+
+	d.addSetting("instance_name", dbQueryOne(db, "SELECT SYS_CONTEXT('USERENV','INSTANCE_NAME') FROM dual"))
+	d.addSetting("db_name", dbQueryOne(db, "SELECT SYS_CONTEXT('USERENV','DB_NAME') FROM dual"))
+	d.addSetting("server_host", dbQueryOne(db, "SELECT SYS_CONTEXT('USERENV','SERVER_HOST') FROM dual"))
+
+	rows, _ = dbQuery(db, fmt.Sprintf("SELECT * FROM (SELECT owner, COUNT(*), NVL(SUM(num_rows),0) FROM all_tables GROUP BY owner ORDER BY 3 DESC) WHERE ROWNUM <= %d", dbPostAuthMaxDatabases))
+	for _, r := range rows {
+		d.Databases = append(d.Databases, DBEntry{Name: r[0], Tables: toInt64(r[1]), Rows: toInt64(r[2])})
+	}
+	d.Risks = removeDuplicateString(d.Risks)
+	return d
+}
+
+func removeDuplicateString(input []string) []string {
+	m := make(map[string]struct{})
+	var result []string
+	for _, v := range input {
+		if _, ok := m[v]; !ok {
+			m[v] = struct{}{}
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,146 @@
+package gopocs
+
+import (
+	"dddd/common"
+	"dddd/structs"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"io"
+	"net"
+	"time"
+)
+
+// DNP3 数据链路层 Request Link Status(功能码9)，只探测链路，不发送应用层请求
+
+// 主站地址
+const dnp3MasterAddress = 0xfffc
+
+// 从站地址未知时探测的范围
+const dnp3MaxAddress = 100
+
+func dnp3CRC(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa6bc
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return ^crc
+}
+
+// dnp3LinkStatusRequest DIR=1 PRM=1 FC=9
+func dnp3LinkStatusRequest(destination uint16) []byte {
+	req := []byte{0x05, 0x64, 0x05, 0xc9, 0x00, 0x00, 0x00, 0x00}
+	binary.LittleEndian.PutUint16(req[4:6], destination)
+	binary.LittleEndian.PutUint16(req[6:8], dnp3MasterAddress)
+	crc := make([]byte, 2)
+	binary.LittleEndian.PutUint16(crc, dnp3CRC(req))
+	return append(req, crc...)
+}
+
+// dnp3ReadLinkStatus 读取响应头，返回从站地址与功能码
+func dnp3ReadLinkStatus(conn net.Conn, realhost string) (uint16, byte, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, 0, err
+	}
+	gologger.AuditTimeLogger("[Go] [DNP3] Dumped TCP response for %s\n\n%s\n", realhost, hex.Dump(header))
+	if header[0] != 0x05 || header[1] != 0x64 {
+		return 0, 0, errors.New("not dnp3 protocol")
+	}
+	if binary.LittleEndian.Uint16(header[8:10]) != dnp3CRC(header[:8]) {
+		return 0, 0, errors.New("dnp3 crc error")
+	}
+	// 用户数据块(每16字节带2字节CRC)，链路状态响应没有用户数据
+	if userData := int(header[2]) - 5; userData > 0 {
+		blocks := userData + (userData+15)/16*2
+		if _, err := io.ReadFull(conn, make([]byte, blocks)); err != nil {
+			return 0, 0, err
+		}
+	}
+	return binary.LittleEndian.Uint16(header[6:8]), header[3], nil
+}
+
+type dnp3LinkStatus struct {
+	source  uint16
+	control byte
+	err     error
+}
+
+// dnp3SweepTimeout 所有地址共用的超时：按限速发送完所有请求后再等待2秒
+func dnp3SweepTimeout() time.Duration {
+	rate := structs.GlobalConfig.ICSRate
+	if rate <= 0 {
+		rate = 1
+	}
+	return time.Duration(dnp3MaxAddress+1)*time.Second/time.Duration(rate) + 2*time.Second
+}
+
+func DNP3Scan(info *structs.HostInfo) error {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(dnp3SweepTimeout())); err != nil {
+		return err
+	}
+
+	// 地址不匹配的从站不响应，请求连续发送，收到第一个从站响应后停止
+	found := make(chan dnp3LinkStatus, 1)
+	go func() {
+		for {
+			source, control, err := dnp3ReadLinkStatus(conn, realhost)
+			// DIR=1 为主站方向的报文，继续等待从站响应
+			if err == nil && control&0x80 != 0 {
+				continue
+			}
+			found <- dnp3LinkStatus{source, control, err}
+			return
+		}
+	}()
+
+	var status dnp3LinkStatus
+	received := false
+sweep:
+	for address := uint16(0); address <= dnp3MaxAddress; address++ {
+		select {
+		case status = <-found:
+			received = true
+			break sweep
+		default:
+		}
+		req := dnp3LinkStatusRequest(address)
+		icsTake()
+		gologger.AuditTimeLogger("[Go] [DNP3] Dumped TCP request for %s\n\n%s\n", realhost, hex.Dump(req))
+		if _, err = conn.Write(req); err != nil {
+			break
+		}
+	}
+	if !received {
+		status = <-found
+	}
+	if status.err != nil {
+		if ne, ok := status.err.(net.Error); ok && ne.Timeout() {
+			return errors.New("dnp3 no outstation response")
+		}
+		return status.err
+	}
+
+	// 功能码11为 Link Status
+	d := &ICSDevice{Protocol: "DNP3"}
+	d.addExtra("OutstationAddress", fmt.Sprint(status.source))
+	d.addExtra("MasterAddress", fmt.Sprint(dnp3MasterAddress))
+	d.addExtra("LinkFunction", fmt.Sprintf("%d", status.control&0x0f))
+	icsReport("DNP3-LinkStatus", "dnp3", realhost, d, "DNP3从站链路状态(Request Link Status)")
+	return nil
+}
@@ -3,6 +3,7 @@ package gopocs
 import (
 	"bytes"
 	"dddd/common"
+	"dddd/common/uncover"
 	"dddd/ddout"
 	"dddd/structs"
 	"encoding/hex"
@@ -136,6 +137,9 @@ func read(text []byte, host string) error {
 			ipInfo = append(ipInfo, string(hostStr))
 		}
 	}
+	if name != "GetNameError" {
+		uncover.AddTargetKeyword(host, name)
+	}
 	result := host + " " + name
 	for _, v := range ipInfo {
 		result += " => " + v
@@ -19,15 +19,17 @@ func FtpScan(info *structs.HostInfo) (tmperr error) {
 	}
 	starttime := time.Now().Unix()
 
-	// 先检测匿名访问
-	gologger.AuditTimeLogger("[Go] [FTP-Unauth] Try %s:%v", info.Host, info.Ports)
-	flag, err := FtpConn(info, "anonymous", "")
-	if flag == true && err == nil {
-		return err
-	} else {
-		tmperr = err
-		if CheckErrs(err) {
+	// 先检测匿名访问，凭据复用轮次已经检测过
+	if !info.CredReuse {
+		gologger.AuditTimeLogger("[Go] [FTP-Unauth] Try %s:%v", info.Host, info.Ports)
+		flag, err := FtpConn(info, "anonymous", "")
+		if flag == true && err == nil {
 			return err
+		} else {
+			tmperr = err
+			if CheckErrs(err) {
+				return err
+			}
 		}
 	}
 
@@ -46,7 +48,7 @@ func FtpScan(info *structs.HostInfo) (tmperr error) {
 			}
 			if time.Now().Unix()-starttime > (int64(len(userPasswdList)) * 6) {
 				gologger.AuditTimeLogger("[Go] [FTP-Brute] Timeout,break! %s:%v", info.Host, info.Ports)
-				return err
+				return ftpErr
 			}
 		}
 	}
@@ -59,13 +61,14 @@ func FtpConn(info *structs.HostInfo, user string, pass string) (flag bool, err e
 	Host, Port, Username, Password := info.Host, info.Ports, user, pass
 	conn, err := ftp.DialTimeout(fmt.Sprintf("%v:%v", Host, Port), time.Duration(6)*time.Second)
 	if err == nil {
+		defer conn.Quit()
 		err = conn.Login(Username, Password)
 		if err == nil {
 			flag = true
 
 			result := fmt.Sprintf("FTP://%v:%v:%v %v", Host, Port, Username, Password)
-			dirs, err := conn.List("")
-			//defer conn.Logout()
+			listing := "ftp> ls /\n"
+			dirs, err := conn.List("/")
 			if err == nil {
 				if len(dirs) > 0 {
 					for i := 0; i < len(dirs); i++ {
@@ -79,7 +82,18 @@ func FtpConn(info *structs.HostInfo, user string, pass string) (flag bool, err e
 						}
 					}
 				}
+				listing += ftpListingExcerpt(dirs)
+			}
+
+			pocName := "FTP-Login"
+			description := "FTP弱口令"
+			if Username == "anonymous" {
+				pocName = "FTP-Anonymous"
+				description = "FTP匿名访问"
+			} else {
+				AddCredential("FTP", Host, Port, Username, Password, info.CredReuse)
 			}
+			showData := fmt.Sprintf("Host: %v:%v\nUsername: %v\nPassword: %v\n", Host, Port, Username, Password)
 
 			ddout.FormatOutput(ddout.OutputMessage{
 				Type:     "GoPoc",
@@ -90,24 +104,45 @@ func FtpConn(info *structs.HostInfo, user string, pass string) (flag bool, err e
 				Web:      ddout.WebInfo{},
 				Finger:   nil,
 				Domain:   "",
-				GoPoc: ddout.GoPocsResultType{PocName: "FTP-Login",
+				GoPoc: ddout.GoPocsResultType{PocName: pocName,
 					Security:    "HIGH",
 					Target:      fmt.Sprintf("%v:%v", Host, Port),
-					InfoLeft:    result,
-					Description: "FTP未授权访问或弱口令",
+					InfoLeft:    showData,
+					InfoRight:   listing,
+					Description: description,
 					ShowMsg:     result},
 				AdditionalMsg: "",
 			})
 
 			GoPocWriteResult(structs.GoPocsResultType{
-				PocName:     "FTP-Login",
+				PocName:     pocName,
 				Security:    "HIGH",
 				Target:      fmt.Sprintf("%v:%v", Host, Port),
-				InfoLeft:    result,
-				Description: "FTP未授权访问或弱口令",
+				InfoLeft:    showData,
+				InfoRight:   listing,
+				Description: description,
 			})
 
 		}
 	}
 	return flag, err
 }
+
+// ftpListingExcerpt 根目录列表摘要，最多展示50条
+func ftpListingExcerpt(entries []*ftp.Entry) string {
+	var listing string
+	for i, entry := range entries {
+		if i == 50 {
+			listing += fmt.Sprintf("... (%d more)\n", len(entries)-50)
+			break
+		}
+		t := "file"
+		if entry.Type == ftp.EntryTypeFolder {
+			t = "dir"
+		} else if entry.Type == ftp.EntryTypeLink {
+			t = "link"
+		}
+		listing += fmt.Sprintf("%-4s %12d %s %s\n", t, entry.Size, entry.Time.Format("2006-01-02 15:04"), entry.Name)
+	}
+	return listing
+}
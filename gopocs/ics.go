@@ -0,0 +1,152 @@
+package gopocs
+
+import (
+	"context"
+	"dddd/structs"
+	"fmt"
+	"github.com/projectdiscovery/ratelimit"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 工控协议识别，只发送设备标识、SZL读取、ReadProperty、链路状态等只读请求，
+// 不写寄存器、不下发控制命令。所有请求共用一个限速器，避免对PLC造成压力。
+
+var icsLimiter struct {
+	once    sync.Once
+	limiter *ratelimit.Limiter
+}
+
+// icsTake 每发送一个工控协议请求前调用
+func icsTake() {
+	icsLimiter.once.Do(func() {
+		rate := structs.GlobalConfig.ICSRate
+		if rate <= 0 {
+			rate = 1
+		}
+		icsLimiter.limiter = ratelimit.New(context.Background(), uint(rate), time.Second)
+	})
+	icsLimiter.limiter.Take()
+}
+
+// ICSDevice 工控设备标识，字段名在各协议中保持一致，便于按 Vendor/Model/Firmware 检索
+type ICSDevice struct {
+	Protocol string
+	Vendor   string
+	Model    string
+	Firmware string
+	Extra    [][2]string
+}
+
+func (d *ICSDevice) addExtra(name, value string) {
+	value = strings.TrimSpace(value)
+	if value != "" {
+		d.Extra = append(d.Extra, [2]string{name, value})
+	}
+}
+
+func (d *ICSDevice) fields() string {
+	s := fmt.Sprintf("Protocol: %s\n", d.Protocol)
+	for _, kv := range [][2]string{{"Vendor", d.Vendor}, {"Model", d.Model}, {"Firmware", d.Firmware}} {
+		if kv[1] != "" {
+			s += fmt.Sprintf("%s: %s\n", kv[0], kv[1])
+		}
+	}
+	for _, kv := range d.Extra {
+		s += fmt.Sprintf("%s: %s\n", kv[0], kv[1])
+	}
+	return s
+}
+
+func (d *ICSDevice) summary() string {
+	var parts []string
+	for _, v := range []string{d.Vendor, d.Model, d.Firmware} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	if len(parts) == 0 && len(d.Extra) > 0 {
+		parts = append(parts, d.Extra[0][0]+"="+d.Extra[0][1])
+	}
+	return strings.Join(parts, "/")
+}
+
+// BannerFields 追加到Banner中的字段，指纹规则可以通过 banner="ICS-Vendor: Siemens" 匹配
+func (d *ICSDevice) BannerFields() string {
+	fields := "\nICS-Protocol: " + d.Protocol + "\n"
+	for _, kv := range [][2]string{{"ICS-Vendor", d.Vendor}, {"ICS-Model", d.Model}, {"ICS-Firmware", d.Firmware}} {
+		if kv[1] != "" {
+			fields += kv[0] + ": " + kv[1] + "\n"
+		}
+	}
+	return fields
+}
+
+// icsReport 输出识别结果，设备标识同时写入Banner
+func icsReport(pocName, scheme, realhost string, d *ICSDevice, description string) {
+	if structs.GlobalBannerHMap != nil {
+		banner, _ := structs.GlobalBannerHMap.Get(realhost)
+		_ = structs.GlobalBannerHMap.Set(realhost, append(banner, []byte(d.BannerFields())...))
+	}
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     pocName,
+		Security:    "INFO",
+		Target:      realhost,
+		InfoLeft:    fmt.Sprintf("Host: %v\n", realhost),
+		InfoRight:   d.fields(),
+		Description: description,
+	}, fmt.Sprintf("%s %s://%s [%s]", pocName, scheme, realhost, d.summary()))
+}
+
+// ICSTarget -ics 开启时根据协议与端口判断使用的工控协议识别插件，BACnet为UDP协议不在此处
+func ICSTarget(hostPort, service string) (string, bool) {
+	if !structs.GlobalConfig.ICSScan {
+		return "", false
+	}
+	port := hostPort[strings.LastIndex(hostPort, ":")+1:]
+	switch {
+	case service == "modbus" || service == "mbap" || port == "502":
+		return "Modbus-DeviceID", true
+	case service == "iso-tsap" || port == "102":
+		return "S7-Info", true
+	case service == "dnp" || service == "dnp3" || port == "20000":
+		return "DNP3-LinkStatus", true
+	}
+	return "", false
+}
+
+// ICSCheckTarget 在指纹识别之前进行工控协议识别，设备标识参与指纹识别
+func ICSCheckTarget(hostPort, name string) {
+	i := strings.LastIndex(hostPort, ":")
+	info := structs.HostInfo{Host: hostPort[:i], Ports: hostPort[i+1:]}
+	ScanFunc(&name, &info)
+}
+
+// icsHosts 端口扫描结果中的主机
+func icsHosts() []string {
+	var hosts []string
+	for hostPort := range structs.GlobalIPPortMap {
+		hosts = append(hosts, strings.Split(hostPort, ":")[0])
+	}
+	return removeDuplicateKeepOrder(hosts)
+}
+
+// icsCString 读取以0结尾、空格填充的定长字符串
+func icsCString(b []byte, offset, max int) string {
+	if offset >= len(b) {
+		return ""
+	}
+	end := offset + max
+	if end > len(b) {
+		end = len(b)
+	}
+	s := b[offset:end]
+	for i, c := range s {
+		if c == 0 {
+			s = s[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(string(s))
+}
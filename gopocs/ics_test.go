@@ -0,0 +1,136 @@
+package gopocs
+
+import (
+	"dddd/structs"
+	"encoding/binary"
+	"github.com/projectdiscovery/hmap/store/hybrid"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestICSTarget(t *testing.T) {
+	tests := []struct {
+		hostPort string
+		service  string
+		ics      bool
+		want     string
+		ok       bool
+	}{
+		{"10.0.0.1:502", "", true, "Modbus-DeviceID", true},
+		{"10.0.0.1:5020", "modbus", true, "Modbus-DeviceID", true},
+		{"10.0.0.1:102", "iso-tsap", true, "S7-Info", true},
+		{"10.0.0.1:20000", "", true, "DNP3-LinkStatus", true},
+		{"10.0.0.1:20001", "dnp3", true, "DNP3-LinkStatus", true},
+		{"10.0.0.1:80", "http", true, "", false},
+		{"10.0.0.1:502", "", false, "", false},
+	}
+	defer func() { structs.GlobalConfig.ICSScan = false }()
+	for _, tt := range tests {
+		structs.GlobalConfig.ICSScan = tt.ics
+		got, ok := ICSTarget(tt.hostPort, tt.service)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ICSTarget(%q, %q) = (%q, %v), want (%q, %v)", tt.hostPort, tt.service, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestICSBannerFields(t *testing.T) {
+	tests := []struct {
+		device ICSDevice
+		want   string
+	}{
+		{ICSDevice{Protocol: "S7comm", Vendor: "Siemens", Model: "6ES7 315-2EH14-0AB0", Firmware: "V3.2.6"},
+			"\nICS-Protocol: S7comm\nICS-Vendor: Siemens\nICS-Model: 6ES7 315-2EH14-0AB0\nICS-Firmware: V3.2.6\n"},
+		{ICSDevice{Protocol: "DNP3", Extra: [][2]string{{"OutstationAddress", "7"}}}, "\nICS-Protocol: DNP3\n"},
+	}
+	for _, tt := range tests {
+		if got := tt.device.BannerFields(); got != tt.want {
+			t.Errorf("BannerFields() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// dnp3Stub 只有 outstation 地址的从站响应，返回收到的请求数
+func dnp3Stub(t *testing.T, outstation int) (string, *atomic.Int32) {
+	requests := &atomic.Int32{}
+	addr := stubServer(t, func(conn net.Conn) {
+		for {
+			req := make([]byte, 10)
+			if _, err := io.ReadFull(conn, req); err != nil {
+				return
+			}
+			requests.Add(1)
+			destination := binary.LittleEndian.Uint16(req[4:6])
+			if int(destination) != outstation {
+				continue
+			}
+			// DIR=0 PRM=0 FC=11 Link Status
+			resp := []byte{0x05, 0x64, 0x05, 0x0b, 0x00, 0x00, 0x00, 0x00}
+			binary.LittleEndian.PutUint16(resp[4:6], dnp3MasterAddress)
+			binary.LittleEndian.PutUint16(resp[6:8], destination)
+			crc := make([]byte, 2)
+			binary.LittleEndian.PutUint16(crc, dnp3CRC(resp))
+			_, _ = conn.Write(append(resp, crc...))
+		}
+	})
+	return addr, requests
+}
+
+func TestDNP3Scan(t *testing.T) {
+	// 限速器每秒放行 ICSRate 个请求，响应到达后不再发送下一批
+	structs.GlobalConfig.ICSRate = 50
+	structs.GlobalConfig.ReportName = filepath.Join(t.TempDir(), "report.html")
+	hm, err := hybrid.New(hybrid.DefaultMemoryOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	structs.GlobalBannerHMap = hm
+	defer func() {
+		hm.Close()
+		structs.GlobalBannerHMap = nil
+	}()
+
+	tests := []struct {
+		name       string
+		outstation int
+		wantErr    bool
+	}{
+		{"first", 0, false},
+		{"middle", 7, false},
+		{"none", -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, requests := dnp3Stub(t, tt.outstation)
+			host, port, _ := net.SplitHostPort(addr)
+			start := time.Now()
+			err := DNP3Scan(&structs.HostInfo{Host: host, Ports: port})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DNP3Scan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// 所有地址共用一个超时
+			if elapsed := time.Since(start); elapsed > dnp3SweepTimeout()+time.Second {
+				t.Errorf("DNP3Scan() took %v", elapsed)
+			}
+			if tt.wantErr {
+				if n := requests.Load(); n != dnp3MaxAddress+1 {
+					t.Errorf("requests = %d, want %d", n, dnp3MaxAddress+1)
+				}
+				return
+			}
+			// 收到响应后停止发送
+			if n := requests.Load(); n >= dnp3MaxAddress+1 {
+				t.Errorf("requests = %d after outstation %d responded", n, tt.outstation)
+			}
+			banner, _ := hm.Get(addr)
+			if !strings.Contains(string(banner), "ICS-Protocol: DNP3") {
+				t.Errorf("banner = %q", banner)
+			}
+		})
+	}
+}
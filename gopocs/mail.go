@@ -0,0 +1,543 @@
+package gopocs
+
+import (
+	"bufio"
+	"crypto/tls"
+	"dddd/common"
+	"dddd/structs"
+	"dddd/utils"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+//go:embed dict/mail.txt
+var mailUserPasswdDict string
+
+var mailAuthFailed = errors.New("mail authentication failed")
+
+// 开放中继检测使用的收件域名，.invalid 为保留顶级域，不会产生真实投递
+const smtpRelayDomain = "dddd-relay-check.invalid"
+
+var smtpEnumUsers = []string{
+	"root", "admin", "administrator", "postmaster", "test", "info",
+	"webmaster", "support", "mail", "sales", "service", "hr",
+}
+
+// mailImplicitTLS 465/993/995 以及协议识别为 xxx-ssl/xxxs 的端口直接使用TLS
+func mailImplicitTLS(info *structs.HostInfo) bool {
+	if info.Ports == "465" || info.Ports == "993" || info.Ports == "995" {
+		return true
+	}
+	structs.GlobalIPPortMapLock.Lock()
+	protocol := structs.GlobalIPPortMap[info.Host+":"+info.Ports]
+	structs.GlobalIPPortMapLock.Unlock()
+	return strings.HasSuffix(protocol, "-ssl") || protocol == "smtps" || protocol == "pop3s" || protocol == "imaps"
+}
+
+// mailSession 基于行的邮件协议会话，SMTP/POP3/IMAP 共用
+type mailSession struct {
+	realhost string
+	host     string
+	conn     net.Conn
+	reader   *bufio.Reader
+	tls      bool
+}
+
+func newMailSession(info *structs.HostInfo, implicitTLS bool) (*mailSession, error) {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	s := &mailSession{realhost: realhost, host: info.Host, conn: conn}
+	if implicitTLS {
+		s.conn = tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+		s.tls = true
+	}
+	err = s.conn.SetDeadline(time.Now().Add(time.Duration(10) * time.Second))
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.reader = bufio.NewReader(s.conn)
+	return s, nil
+}
+
+func (s *mailSession) Close() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+func (s *mailSession) startTLS() error {
+	conn := tls.Client(s.conn, &tls.Config{InsecureSkipVerify: true})
+	if err := conn.Handshake(); err != nil {
+		return err
+	}
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+	s.tls = true
+	return nil
+}
+
+func (s *mailSession) writeLine(line string) error {
+	gologger.AuditTimeLogger("[Go] [Mail] Dumped TCP request for %s\n\n%s\n", s.realhost, line)
+	_, err := s.conn.Write([]byte(line + "\r\n"))
+	return err
+}
+
+func (s *mailSession) readLine() (string, error) {
+	line, err := s.reader.ReadString('\n')
+	gologger.AuditTimeLogger("[Go] [Mail] Dumped TCP response for %s\n\n%s\n", s.realhost, line)
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// readSMTPReply 读取SMTP多行响应 "250-xxx ... 250 xxx"
+func (s *mailSession) readSMTPReply() (code int, lines []string, err error) {
+	for {
+		var line string
+		line, err = s.readLine()
+		if err != nil {
+			return
+		}
+		if len(line) < 3 {
+			err = fmt.Errorf("smtp short response: %q", line)
+			return
+		}
+		_, err = fmt.Sscanf(line[:3], "%d", &code)
+		if err != nil {
+			return
+		}
+		if len(line) > 4 {
+			lines = append(lines, line[4:])
+		}
+		if len(line) == 3 || line[3] != '-' {
+			return
+		}
+	}
+}
+
+func (s *mailSession) smtpCmd(line string) (int, []string, error) {
+	if err := s.writeLine(line); err != nil {
+		return 0, nil, err
+	}
+	return s.readSMTPReply()
+}
+
+// smtpHello 读取欢迎信息并发送EHLO，支持时升级STARTTLS，返回欢迎信息与扩展列表
+func (s *mailSession) smtpHello() (banner string, ext map[string]string, err error) {
+	code, lines, err := s.readSMTPReply()
+	if err != nil {
+		return
+	}
+	if code != 220 {
+		err = fmt.Errorf("smtp greeting %v", code)
+		return
+	}
+	banner = strings.Join(lines, " ")
+
+	ext, err = s.smtpEhlo()
+	if err != nil {
+		return
+	}
+	if _, ok := ext["STARTTLS"]; ok && !s.tls {
+		code, _, err = s.smtpCmd("STARTTLS")
+		if err != nil {
+			return
+		}
+		if code == 220 {
+			if err = s.startTLS(); err != nil {
+				return
+			}
+			ext, err = s.smtpEhlo()
+		}
+	}
+	return
+}
+
+func (s *mailSession) smtpEhlo() (map[string]string, error) {
+	code, lines, err := s.smtpCmd("EHLO dddd.local")
+	if err != nil {
+		return nil, err
+	}
+	ext := make(map[string]string)
+	if code != 250 {
+		code, _, err = s.smtpCmd("HELO dddd.local")
+		if err != nil {
+			return nil, err
+		}
+		if code != 250 {
+			return nil, fmt.Errorf("smtp helo %v", code)
+		}
+		return ext, nil
+	}
+	if len(lines) < 2 {
+		return ext, nil
+	}
+	for _, line := range lines[1:] {
+		k, v, _ := strings.Cut(line, " ")
+		ext[strings.ToUpper(k)] = v
+	}
+	return ext, nil
+}
+
+func (s *mailSession) smtpAuth(ext map[string]string, user, pass string) error {
+	mechanisms := strings.ToUpper(ext["AUTH"])
+	var code int
+	var err error
+	if strings.Contains(mechanisms, "PLAIN") {
+		resp := base64.StdEncoding.EncodeToString([]byte("\x00" + user + "\x00" + pass))
+		code, _, err = s.smtpCmd("AUTH PLAIN " + resp)
+	} else if strings.Contains(mechanisms, "LOGIN") {
+		code, _, err = s.smtpCmd("AUTH LOGIN")
+		if err == nil && code == 334 {
+			code, _, err = s.smtpCmd(base64.StdEncoding.EncodeToString([]byte(user)))
+		}
+		if err == nil && code == 334 {
+			code, _, err = s.smtpCmd(base64.StdEncoding.EncodeToString([]byte(pass)))
+		}
+	} else {
+		return errors.New("smtp auth mechanism not supported")
+	}
+	if err != nil {
+		return err
+	}
+	if code == 235 {
+		return nil
+	}
+	if code == 535 || code == 334 || code == 501 {
+		return mailAuthFailed
+	}
+	return fmt.Errorf("smtp auth %v", code)
+}
+
+// smtpDomain 从欢迎信息中的主机名推测邮件域
+func smtpDomain(banner string, host string) string {
+	name, _, _ := strings.Cut(banner, " ")
+	labels := strings.Split(name, ".")
+	if len(labels) > 2 {
+		return strings.Join(labels[1:], ".")
+	}
+	if len(labels) == 2 {
+		return name
+	}
+	return host
+}
+
+func randomLocalPart() string {
+	return fmt.Sprintf("dddd%d", rand.New(rand.NewSource(time.Now().UnixNano())).Intn(100000000))
+}
+
+// smtpEnumerate 依次尝试VRFY/EXPN/RCPT TO枚举用户，先用随机用户校验方法是否可信
+func smtpEnumerate(s *mailSession, domain string, users []string) (method string, found []string) {
+	probe := func(method, user string) (bool, error) {
+		var code int
+		var err error
+		switch method {
+		case "VRFY":
+			code, _, err = s.smtpCmd("VRFY " + user)
+		case "EXPN":
+			code, _, err = s.smtpCmd("EXPN " + user)
+		case "RCPT":
+			code, _, err = s.smtpCmd(fmt.Sprintf("RCPT TO:<%s@%s>", user, domain))
+		}
+		return code == 250 || code == 251, err
+	}
+
+	for _, m := range []string{"VRFY", "EXPN", "RCPT"} {
+		if m == "RCPT" {
+			code, _, err := s.smtpCmd("MAIL FROM:<>")
+			if err != nil || code != 250 {
+				return
+			}
+		}
+		ok, err := probe(m, randomLocalPart())
+		if err != nil {
+			return
+		}
+		if ok {
+			// 任意用户都返回成功，方法不可信
+			continue
+		}
+		for _, user := range users {
+			ok, err = probe(m, user)
+			if err != nil {
+				break
+			}
+			if ok {
+				found = append(found, user)
+			}
+		}
+		if len(found) > 0 {
+			method = m
+			break
+		}
+	}
+	_, _, _ = s.smtpCmd("RSET")
+	return
+}
+
+// smtpOpenRelay 未认证情况下尝试向不存在的外部域名投递，只发送到RCPT TO，不发送DATA
+func smtpOpenRelay(s *mailSession, domain string) (bool, string, error) {
+	// 发件人使用服务器自身域名，域名未知时使用空发件人，避免发件域名校验在 MAIL FROM 阶段拒绝
+	from := ""
+	if net.ParseIP(domain) == nil && strings.Contains(domain, ".") {
+		from = "postmaster@" + domain
+	}
+	code, _, err := s.smtpCmd(fmt.Sprintf("MAIL FROM:<%s>", from))
+	if err != nil || code != 250 {
+		return false, "", err
+	}
+	addr := fmt.Sprintf("%s@%s", structs.GlobalConfig.SMTPRelayLocalPart, smtpRelayDomain)
+	code, _, err = s.smtpCmd(fmt.Sprintf("RCPT TO:<%s>", addr))
+	_, _, _ = s.smtpCmd("RSET")
+	return code == 250 || code == 251, addr, err
+}
+
+// SmtpScan 用户枚举与开放中继检测
+func SmtpScan(info *structs.HostInfo) error {
+	s, err := newMailSession(info, mailImplicitTLS(info))
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	banner, _, err := s.smtpHello()
+	if err != nil {
+		return err
+	}
+
+	domain := smtpDomain(banner, info.Host)
+	relay, addr, err := smtpOpenRelay(s, domain)
+	if err == nil && relay {
+		showData := fmt.Sprintf("Host: %v\nBanner: %v\nRCPT TO: %v\n", s.realhost, banner, addr)
+		GoPocOutput(structs.GoPocsResultType{
+			PocName:     "SMTP-Open-Relay",
+			Security:    "HIGH",
+			Target:      s.realhost,
+			InfoLeft:    showData,
+			Description: "SMTP开放中继，可未认证向外部域名发送邮件",
+		}, fmt.Sprintf("SMTP-Open-Relay %s", s.realhost))
+	}
+
+	if structs.GlobalConfig.NoServiceBruteForce {
+		return nil
+	}
+
+	var users []string
+	users = append(users, smtpEnumUsers...)
+	for _, up := range sortUserPassword(info, mailUserPasswdDict, []string{"mail"}) {
+		users = append(users, up.UserName)
+	}
+	users = utils.RemoveDuplicateElement(users)
+
+	method, found := smtpEnumerate(s, domain, users)
+	if len(found) > 0 {
+		showData := fmt.Sprintf("Host: %v\nBanner: %v\nMethod: %v\nDomain: %v\n", s.realhost, banner, method, domain)
+		GoPocOutput(structs.GoPocsResultType{
+			PocName:     "SMTP-User-Enum",
+			Security:    "MEDIUM",
+			Target:      s.realhost,
+			InfoLeft:    showData,
+			InfoRight:   strings.Join(found, "\n"),
+			Description: "SMTP用户枚举",
+		}, fmt.Sprintf("SMTP-User-Enum %s [%s] %s", s.realhost, method, strings.Join(found, ",")))
+	}
+	return nil
+}
+
+// mailBrute 邮件协议通用的爆破流程
+func mailBrute(info *structs.HostInfo, name string, conn func(*structs.HostInfo, string, string) (bool, error)) (tmperr error) {
+	if structs.GlobalConfig.NoServiceBruteForce {
+		return
+	}
+	starttime := time.Now().Unix()
+	gologger.AuditTimeLogger("[Go] [%s-Brute] start try %s:%v", name, info.Host, info.Ports)
+	defer gologger.AuditTimeLogger("[Go] [%s-Brute] return %s:%v", name, info.Host, info.Ports)
+
+	userPasswdList := sortUserPassword(info, mailUserPasswdDict, []string{"mail"})
+	for _, userPass := range userPasswdList {
+		gologger.AuditTimeLogger("[Go] [%s-Brute] start try %s:%v %v %v", name, info.Host, info.Ports, userPass.UserName, userPass.Password)
+		flag, err := conn(info, userPass.UserName, userPass.Password)
+		if flag == true && err == nil {
+			return err
+		} else {
+			tmperr = err
+			if err != mailAuthFailed {
+				return err
+			}
+			if time.Now().Unix()-starttime > (int64(len(userPasswdList)) * 6) {
+				return err
+			}
+		}
+	}
+	return tmperr
+}
+
+func mailLoginResult(name string, s *mailSession, user, pass string) {
+	showData := fmt.Sprintf("Host: %v\nTLS: %v\nUsername: %v\nPassword: %v\n", s.realhost, s.tls, user, pass)
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     name + "-Login",
+		Security:    "CRITICAL",
+		Target:      s.realhost,
+		InfoLeft:    showData,
+		Description: name + "弱口令",
+	}, fmt.Sprintf("%s://%s:%v %v", name, s.realhost, user, pass))
+}
+
+func SmtpAuthScan(info *structs.HostInfo) error {
+	return mailBrute(info, "SMTP", SmtpConn)
+}
+
+func SmtpConn(info *structs.HostInfo, user string, pass string) (bool, error) {
+	s, err := newMailSession(info, mailImplicitTLS(info))
+	if err != nil {
+		return false, err
+	}
+	defer s.Close()
+
+	_, ext, err := s.smtpHello()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := ext["AUTH"]; !ok {
+		return false, errors.New("smtp auth not supported")
+	}
+	err = s.smtpAuth(ext, user, pass)
+	if err != nil {
+		return false, err
+	}
+	AddCredential("SMTP", info.Host, info.Ports, user, pass, info.CredReuse)
+	mailLoginResult("SMTP", s, user, pass)
+	return true, nil
+}
+
+func Pop3Scan(info *structs.HostInfo) error {
+	return mailBrute(info, "POP3", Pop3Conn)
+}
+
+func Pop3Conn(info *structs.HostInfo, user string, pass string) (bool, error) {
+	s, err := newMailSession(info, mailImplicitTLS(info))
+	if err != nil {
+		return false, err
+	}
+	defer s.Close()
+
+	cmd := func(line string) (bool, error) {
+		if line != "" {
+			if err := s.writeLine(line); err != nil {
+				return false, err
+			}
+		}
+		reply, err := s.readLine()
+		if err != nil {
+			return false, err
+		}
+		return strings.HasPrefix(reply, "+OK"), nil
+	}
+
+	ok, err := cmd("")
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, errors.New("pop3 greeting error")
+	}
+	if !s.tls {
+		ok, err = cmd("STLS")
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			if err = s.startTLS(); err != nil {
+				return false, err
+			}
+		}
+	}
+	ok, err = cmd("USER " + user)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		ok, err = cmd("PASS " + pass)
+		if err != nil {
+			return false, err
+		}
+	}
+	if !ok {
+		return false, mailAuthFailed
+	}
+	AddCredential("POP3", info.Host, info.Ports, user, pass, info.CredReuse)
+	mailLoginResult("POP3", s, user, pass)
+	return true, nil
+}
+
+func ImapScan(info *structs.HostInfo) error {
+	return mailBrute(info, "IMAP", ImapConn)
+}
+
+func imapQuote(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	return "\"" + strings.ReplaceAll(s, "\"", "\\\"") + "\""
+}
+
+func ImapConn(info *structs.HostInfo, user string, pass string) (bool, error) {
+	s, err := newMailSession(info, mailImplicitTLS(info))
+	if err != nil {
+		return false, err
+	}
+	defer s.Close()
+
+	// 读取到带标签的最终响应
+	cmd := func(tag, line string) (string, error) {
+		if err := s.writeLine(tag + " " + line); err != nil {
+			return "", err
+		}
+		for {
+			reply, err := s.readLine()
+			if err != nil {
+				return "", err
+			}
+			if strings.HasPrefix(reply, tag+" ") {
+				return strings.TrimPrefix(reply, tag+" "), nil
+			}
+		}
+	}
+
+	greeting, err := s.readLine()
+	if err != nil {
+		return false, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return false, errors.New("imap greeting error")
+	}
+	if !s.tls {
+		reply, err := cmd("a1", "STARTTLS")
+		if err != nil {
+			return false, err
+		}
+		if strings.HasPrefix(reply, "OK") {
+			if err = s.startTLS(); err != nil {
+				return false, err
+			}
+		}
+	}
+	reply, err := cmd("a2", "LOGIN "+imapQuote(user)+" "+imapQuote(pass))
+	if err != nil {
+		return false, err
+	}
+	if !strings.HasPrefix(reply, "OK") {
+		return false, mailAuthFailed
+	}
+	_, _ = cmd("a3", "LOGOUT")
+	AddCredential("IMAP", info.Host, info.Ports, user, pass, info.CredReuse)
+	mailLoginResult("IMAP", s, user, pass)
+	return true, nil
+}
@@ -0,0 +1,295 @@
+package gopocs
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"dddd/structs"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	mailStubUser = "admin"
+	mailStubPass = "P@ss w0rd"
+)
+
+// mailStubCert 测试用自签名证书
+func mailStubCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mail.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+type mailStubConn struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	cert     tls.Certificate
+	tls      bool
+	upgraded *atomic.Bool
+}
+
+func (c *mailStubConn) send(lines ...string) {
+	for _, line := range lines {
+		_, _ = c.conn.Write([]byte(line + "\r\n"))
+	}
+}
+
+func (c *mailStubConn) recv() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func (c *mailStubConn) startTLS() error {
+	conn := tls.Server(c.conn, &tls.Config{Certificates: []tls.Certificate{c.cert}})
+	if err := conn.Handshake(); err != nil {
+		return err
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+	c.tls = true
+	c.upgraded.Store(true)
+	return nil
+}
+
+// mailStub 启动邮件服务桩，返回监听端口与是否发生过STARTTLS升级
+func mailStub(t *testing.T, serve func(c *mailStubConn)) (string, *atomic.Bool) {
+	cert := mailStubCert(t)
+	upgraded := &atomic.Bool{}
+	addr := stubServer(t, func(conn net.Conn) {
+		serve(&mailStubConn{conn: conn, reader: bufio.NewReader(conn), cert: cert, upgraded: upgraded})
+	})
+	_, port, _ := net.SplitHostPort(addr)
+	return port, upgraded
+}
+
+func smtpStub(starttls bool) func(c *mailStubConn) {
+	return func(c *mailStubConn) {
+		c.send("220 mail.test ESMTP")
+		for {
+			line, err := c.recv()
+			if err != nil {
+				return
+			}
+			cmd, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(cmd) {
+			case "EHLO":
+				c.send("250-mail.test")
+				if starttls && !c.tls {
+					c.send("250-STARTTLS")
+				}
+				c.send("250 AUTH PLAIN LOGIN")
+			case "STARTTLS":
+				c.send("220 ready")
+				if c.startTLS() != nil {
+					return
+				}
+			case "AUTH":
+				mech, resp, _ := strings.Cut(arg, " ")
+				decoded, _ := base64.StdEncoding.DecodeString(resp)
+				if strings.ToUpper(mech) == "PLAIN" && string(decoded) == "\x00"+mailStubUser+"\x00"+mailStubPass {
+					c.send("235 2.7.0 Authentication successful")
+				} else {
+					c.send("535 5.7.8 Authentication failed")
+				}
+			case "QUIT":
+				c.send("221 bye")
+				return
+			default:
+				c.send("502 unknown command")
+			}
+		}
+	}
+}
+
+func pop3Stub(starttls bool) func(c *mailStubConn) {
+	return func(c *mailStubConn) {
+		c.send("+OK POP3 ready")
+		var user string
+		for {
+			line, err := c.recv()
+			if err != nil {
+				return
+			}
+			cmd, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(cmd) {
+			case "STLS":
+				if !starttls || c.tls {
+					c.send("-ERR not supported")
+					continue
+				}
+				c.send("+OK begin TLS")
+				if c.startTLS() != nil {
+					return
+				}
+			case "USER":
+				user = arg
+				c.send("+OK")
+			case "PASS":
+				if user == mailStubUser && arg == mailStubPass {
+					c.send("+OK logged in")
+				} else {
+					c.send("-ERR authentication failed")
+				}
+			default:
+				c.send("-ERR unknown command")
+			}
+		}
+	}
+}
+
+func imapStub(starttls bool) func(c *mailStubConn) {
+	return func(c *mailStubConn) {
+		c.send("* OK IMAP4rev1 ready")
+		for {
+			line, err := c.recv()
+			if err != nil {
+				return
+			}
+			tag, rest, _ := strings.Cut(line, " ")
+			cmd, arg, _ := strings.Cut(rest, " ")
+			switch strings.ToUpper(cmd) {
+			case "STARTTLS":
+				if !starttls || c.tls {
+					c.send(tag + " BAD not supported")
+					continue
+				}
+				c.send(tag + " OK begin TLS")
+				if c.startTLS() != nil {
+					return
+				}
+			case "LOGIN":
+				if arg == imapQuote(mailStubUser)+" "+imapQuote(mailStubPass) {
+					c.send(tag + " OK LOGIN completed")
+				} else {
+					c.send(tag + " NO LOGIN failed")
+				}
+			case "LOGOUT":
+				c.send("* BYE", tag+" OK LOGOUT completed")
+				return
+			default:
+				c.send(tag + " BAD unknown command")
+			}
+		}
+	}
+}
+
+func TestMailConn(t *testing.T) {
+	structs.GlobalIPPortMap = make(map[string]string)
+	structs.GlobalConfig.ReportName = filepath.Join(t.TempDir(), "report.html")
+
+	type connFunc func(*structs.HostInfo, string, string) (bool, error)
+	tests := []struct {
+		name     string
+		stub     func(bool) func(*mailStubConn)
+		conn     connFunc
+		starttls bool
+		pass     string
+		wantOK   bool
+		wantErr  error
+	}{
+		{"smtp-ok", smtpStub, SmtpConn, false, mailStubPass, true, nil},
+		{"smtp-fail", smtpStub, SmtpConn, false, "wrong", false, mailAuthFailed},
+		{"smtp-starttls", smtpStub, SmtpConn, true, mailStubPass, true, nil},
+		{"pop3-ok", pop3Stub, Pop3Conn, false, mailStubPass, true, nil},
+		{"pop3-fail", pop3Stub, Pop3Conn, false, "wrong", false, mailAuthFailed},
+		{"pop3-starttls", pop3Stub, Pop3Conn, true, mailStubPass, true, nil},
+		{"imap-ok", imapStub, ImapConn, false, mailStubPass, true, nil},
+		{"imap-fail", imapStub, ImapConn, false, "wrong", false, mailAuthFailed},
+		{"imap-starttls", imapStub, ImapConn, true, mailStubPass, true, nil},
+		{"imap-starttls-fail", imapStub, ImapConn, true, "wrong", false, mailAuthFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port, upgraded := mailStub(t, tt.stub(tt.starttls))
+			info := &structs.HostInfo{Host: "127.0.0.1", Ports: port}
+			ok, err := tt.conn(info, mailStubUser, tt.pass)
+			if ok != tt.wantOK || err != tt.wantErr {
+				t.Fatalf("got (%v, %v), want (%v, %v)", ok, err, tt.wantOK, tt.wantErr)
+			}
+			if upgraded.Load() != tt.starttls {
+				t.Errorf("STARTTLS upgraded = %v, want %v", upgraded.Load(), tt.starttls)
+			}
+		})
+	}
+}
+
+func TestSMTPOpenRelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		domain   string
+		relay    bool
+		wantFrom string
+		want     bool
+	}{
+		{"relay", "corp.local", true, "<postmaster@corp.local>", true},
+		{"null-sender", "10.0.0.1", true, "<>", true},
+		{"rejected", "corp.local", false, "<postmaster@corp.local>", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var from string
+			conn := stubPipe(t, func(conn net.Conn) {
+				c := &mailStubConn{conn: conn, reader: bufio.NewReader(conn)}
+				for {
+					line, err := c.recv()
+					if err != nil {
+						return
+					}
+					cmd, arg, _ := strings.Cut(line, ":")
+					switch strings.ToUpper(cmd) {
+					case "MAIL FROM":
+						from = arg
+						// 校验发件人域名，.invalid 无法解析
+						if strings.Contains(arg, ".invalid") {
+							c.send("550 5.1.8 sender domain does not exist")
+						} else {
+							c.send("250 2.1.0 Ok")
+						}
+					case "RCPT TO":
+						if tt.relay {
+							c.send("250 2.1.5 Ok")
+						} else {
+							c.send("554 5.7.1 Relay access denied")
+						}
+					default:
+						c.send("250 2.0.0 Ok")
+					}
+				}
+			})
+			s := &mailSession{realhost: "10.0.0.1:25", host: "10.0.0.1", conn: conn, reader: bufio.NewReader(conn)}
+			got, addr, err := smtpOpenRelay(s, tt.domain)
+			if err != nil || got != tt.want {
+				t.Fatalf("smtpOpenRelay() = (%v, %q, %v), want %v", got, addr, err, tt.want)
+			}
+			if from != tt.wantFrom {
+				t.Errorf("MAIL FROM = %q, want %q", from, tt.wantFrom)
+			}
+			if !strings.HasSuffix(addr, "@"+smtpRelayDomain) {
+				t.Errorf("RCPT TO = %q", addr)
+			}
+		})
+	}
+}
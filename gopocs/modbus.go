@@ -0,0 +1,153 @@
+package gopocs
+
+import (
+	"dddd/common"
+	"dddd/structs"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"io"
+	"net"
+	"time"
+)
+
+// Modbus/TCP 功能码43/14(Read Device Identification)，只读取设备标识对象
+
+var modbusObjectNames = map[byte]string{
+	0x00: "VendorName",
+	0x01: "ProductCode",
+	0x02: "MajorMinorRevision",
+	0x03: "VendorUrl",
+	0x04: "ProductName",
+	0x05: "ModelName",
+	0x06: "UserApplicationName",
+}
+
+// 网关后的从站地址未知，依次尝试常见的 Unit ID
+var modbusUnitIDs = []byte{0x00, 0xff, 0x01}
+
+var modbusExceptionErr = errors.New("modbus exception")
+
+// modbusReadDeviceID readCode 1=basic 2=regular，返回对象与下一个对象ID(0表示读取完毕)
+func modbusReadDeviceID(conn net.Conn, realhost string, unit, readCode, objectID byte, objects map[byte]string) (byte, error) {
+	req := []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x05, unit, 0x2b, 0x0e, readCode, objectID}
+	icsTake()
+	gologger.AuditTimeLogger("[Go] [Modbus] Dumped TCP request for %s\n\n%s\n", realhost, hex.Dump(req))
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, err
+	}
+	length := int(binary.BigEndian.Uint16(header[4:6]))
+	if binary.BigEndian.Uint16(header[2:4]) != 0 || length < 2 || length > 260 {
+		return 0, errors.New("not modbus protocol")
+	}
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(conn, pdu); err != nil {
+		return 0, err
+	}
+	gologger.AuditTimeLogger("[Go] [Modbus] Dumped TCP response for %s\n\n%s\n", realhost, hex.Dump(append(header, pdu...)))
+
+	if pdu[0] == 0xab {
+		return 0, modbusExceptionErr
+	}
+	if pdu[0] != 0x2b || len(pdu) < 7 || pdu[1] != 0x0e {
+		return 0, errors.New("not modbus protocol")
+	}
+	moreFollows := pdu[4]
+	nextObject := pdu[5]
+	count := int(pdu[6])
+	offset := 7
+	for i := 0; i < count && offset+2 <= len(pdu); i++ {
+		id := pdu[offset]
+		n := int(pdu[offset+1])
+		offset += 2
+		if offset+n > len(pdu) {
+			break
+		}
+		objects[id] = string(pdu[offset : offset+n])
+		offset += n
+	}
+	if moreFollows == 0xff && nextObject != 0 {
+		return nextObject, nil
+	}
+	return 0, nil
+}
+
+// modbusDeviceID 读取一个从站的 basic 与 regular 标识对象
+func modbusDeviceID(realhost string, unit byte) (map[byte]string, error) {
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.SetDeadline(time.Now().Add(time.Duration(15) * time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[byte]string)
+	for _, readCode := range []byte{0x01, 0x02} {
+		objectID := byte(0x00)
+		if readCode == 0x02 {
+			objectID = 0x03
+		}
+		// 单次响应放不下时分多次读取
+		for i := 0; i < 8; i++ {
+			next, err := modbusReadDeviceID(conn, realhost, unit, readCode, objectID, objects)
+			if err != nil {
+				if readCode == 0x02 && len(objects) > 0 {
+					// 只支持 basic 的设备对 regular 返回异常
+					return objects, nil
+				}
+				return nil, err
+			}
+			if next == 0 {
+				break
+			}
+			objectID = next
+		}
+	}
+	return objects, nil
+}
+
+func ModbusScan(info *structs.HostInfo) error {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	var objects map[byte]string
+	var unit byte
+	var err error
+	for _, unit = range modbusUnitIDs {
+		objects, err = modbusDeviceID(realhost, unit)
+		if err == nil {
+			break
+		}
+		// 从站不存在时网关返回异常或不响应
+		if ne, ok := err.(net.Error); err != modbusExceptionErr && !(ok && ne.Timeout()) {
+			return err
+		}
+	}
+	if len(objects) == 0 {
+		return err
+	}
+
+	d := &ICSDevice{Protocol: "Modbus/TCP", Vendor: objects[0x00], Firmware: objects[0x02]}
+	for _, id := range []byte{0x05, 0x04, 0x01} {
+		if objects[id] != "" {
+			d.Model = objects[id]
+			break
+		}
+	}
+	d.addExtra("UnitID", fmt.Sprint(unit))
+	for id := byte(0x01); id <= 0x06; id++ {
+		if id != 0x02 {
+			d.addExtra(modbusObjectNames[id], objects[id])
+		}
+	}
+	icsReport("Modbus-DeviceID", "modbus", realhost, d, "Modbus设备标识(功能码43/14)")
+	return nil
+}
@@ -1,13 +1,20 @@
 package gopocs
 
 import (
+	"bytes"
 	"dddd/common"
 	"dddd/ddout"
 	"dddd/structs"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	_ "github.com/denisenkom/go-mssqldb"
 	"github.com/projectdiscovery/gologger"
+	"io"
+	"math"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -112,6 +119,327 @@ func MongodbUnauth(info *structs.HostInfo) (flag bool, err error) {
 			InfoLeft:    reply,
 			Description: "Mongodb未授权访问",
 		})
+
+		if structs.GlobalConfig.DBPostAuth {
+			if d, postErr := mongoPostAuth(realhost); postErr == nil {
+				reportDBPostAuth(d)
+			}
+		}
 	}
 	return flag, err
 }
+
+// 以下为登录后信息收集使用的最小BSON实现，仅支持命令与响应中用到的类型
+
+type bsonElem struct {
+	Key   string
+	Value interface{}
+}
+
+type bsonDoc []bsonElem
+
+var mongoBsonError = errors.New("mongodb bson error")
+
+func (d bsonDoc) Get(key string) interface{} {
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return nil
+}
+
+func bsonEncode(doc bsonDoc) []byte {
+	var body bytes.Buffer
+	for _, e := range doc {
+		switch v := e.Value.(type) {
+		case string:
+			body.WriteByte(0x02)
+			body.WriteString(e.Key + "\x00")
+			_ = binary.Write(&body, binary.LittleEndian, int32(len(v)+1))
+			body.WriteString(v + "\x00")
+		case int32:
+			body.WriteByte(0x10)
+			body.WriteString(e.Key + "\x00")
+			_ = binary.Write(&body, binary.LittleEndian, v)
+		case bool:
+			body.WriteByte(0x08)
+			body.WriteString(e.Key + "\x00")
+			if v {
+				body.WriteByte(1)
+			} else {
+				body.WriteByte(0)
+			}
+		case bsonDoc:
+			body.WriteByte(0x03)
+			body.WriteString(e.Key + "\x00")
+			body.Write(bsonEncode(v))
+		case []interface{}:
+			arr := make(bsonDoc, 0, len(v))
+			for i, item := range v {
+				arr = append(arr, bsonElem{Key: strconv.Itoa(i), Value: item})
+			}
+			body.WriteByte(0x04)
+			body.WriteString(e.Key + "\x00")
+			body.Write(bsonEncode(arr))
+		}
+	}
+	out := make([]byte, 4, body.Len()+5)
+	binary.LittleEndian.PutUint32(out, uint32(body.Len()+5))
+	out = append(out, body.Bytes()...)
+	return append(out, 0x00)
+}
+
+func bsonDecode(data []byte) (bsonDoc, error) {
+	if len(data) < 5 {
+		return nil, mongoBsonError
+	}
+	size := int(binary.LittleEndian.Uint32(data[0:4]))
+	if size > len(data) || size < 5 {
+		return nil, mongoBsonError
+	}
+	data = data[4 : size-1]
+	var doc bsonDoc
+	for len(data) > 0 {
+		t := data[0]
+		end := bytes.IndexByte(data[1:], 0x00)
+		if end < 0 {
+			return nil, mongoBsonError
+		}
+		key := string(data[1 : 1+end])
+		data = data[2+end:]
+		var value interface{}
+		n := 0
+		switch t {
+		case 0x01: // double
+			n = 8
+			if len(data) >= n {
+				value = math.Float64frombits(binary.LittleEndian.Uint64(data))
+			}
+		case 0x02, 0x0D, 0x0E: // string, javascript, symbol
+			if len(data) < 4 {
+				return nil, mongoBsonError
+			}
+			l := int(binary.LittleEndian.Uint32(data))
+			n = 4 + l
+			if len(data) >= n && l > 0 {
+				value = string(data[4 : n-1])
+			}
+		case 0x03, 0x04: // document, array
+			if len(data) < 4 {
+				return nil, mongoBsonError
+			}
+			n = int(binary.LittleEndian.Uint32(data))
+			if len(data) >= n {
+				sub, err := bsonDecode(data[:n])
+				if err != nil {
+					return nil, err
+				}
+				if t == 0x04 {
+					var arr []interface{}
+					for _, e := range sub {
+						arr = append(arr, e.Value)
+					}
+					value = arr
+				} else {
+					value = sub
+				}
+			}
+		case 0x05: // binary
+			if len(data) < 4 {
+				return nil, mongoBsonError
+			}
+			n = 5 + int(binary.LittleEndian.Uint32(data))
+		case 0x07: // ObjectId
+			n = 12
+		case 0x08: // bool
+			n = 1
+			if len(data) >= n {
+				value = data[0] == 1
+			}
+		case 0x09, 0x11: // datetime, timestamp
+			n = 8
+		case 0x0A, 0x06, 0xFF, 0x7F: // null, undefined, minkey, maxkey
+			n = 0
+		case 0x0B: // regex
+			for i := 0; i < 2; i++ {
+				e := bytes.IndexByte(data[n:], 0x00)
+				if e < 0 {
+					return nil, mongoBsonError
+				}
+				n += e + 1
+			}
+		case 0x10: // int32
+			n = 4
+			if len(data) >= n {
+				value = int32(binary.LittleEndian.Uint32(data))
+			}
+		case 0x12: // int64
+			n = 8
+			if len(data) >= n {
+				value = int64(binary.LittleEndian.Uint64(data))
+			}
+		case 0x13: // decimal128
+			n = 16
+		default:
+			return nil, mongoBsonError
+		}
+		if n > len(data) {
+			return nil, mongoBsonError
+		}
+		data = data[n:]
+		doc = append(doc, bsonElem{Key: key, Value: value})
+	}
+	return doc, nil
+}
+
+func bsonInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
+
+// mongoCommand 通过OP_MSG发送一条命令，要求MongoDB 3.6及以上
+func mongoCommand(conn net.Conn, realhost string, db string, cmd bsonDoc) (bsonDoc, error) {
+	cmd = append(cmd, bsonElem{Key: "$db", Value: db})
+	body := bsonEncode(cmd)
+	packet := make([]byte, 21, 21+len(body))
+	binary.LittleEndian.PutUint32(packet[0:4], uint32(21+len(body)))
+	binary.LittleEndian.PutUint32(packet[4:8], uint32(time.Now().UnixNano()&0x7fffffff))
+	binary.LittleEndian.PutUint32(packet[12:16], 2013) // OP_MSG
+	packet = append(packet, body...)
+	_, err := conn.Write(packet)
+	gologger.AuditTimeLogger("[Go] [Mongodb] Dumped TCP request for %s\n\n%s\n", realhost, hex.Dump(packet))
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 4)
+	if _, err = io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	size := int(binary.LittleEndian.Uint32(header))
+	if size < 26 || size > 16*1024*1024 {
+		return nil, mongoBsonError
+	}
+	reply := make([]byte, size-4)
+	if _, err = io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	gologger.AuditTimeLogger("[Go] [Mongodb] Dumped TCP response for %s\n\n%s\n", realhost, hex.Dump(reply))
+	// requestID, responseTo, opCode, flagBits, section kind
+	if binary.LittleEndian.Uint32(reply[8:12]) != 2013 || reply[16] != 0 {
+		return nil, mongoBsonError
+	}
+	doc, err := bsonDecode(reply[17:])
+	if err != nil {
+		return nil, err
+	}
+	if ok := bsonInt64(doc.Get("ok")); ok != 1 {
+		if msg, isStr := doc.Get("errmsg").(string); isStr {
+			return doc, errors.New(msg)
+		}
+		return doc, mongoBsonError
+	}
+	return doc, nil
+}
+
+// mongoPostAuth 未授权访问成功后收集版本、库与集合信息，均为只读命令
+func mongoPostAuth(realhost string) (*DBPostAuthInfo, error) {
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+	err = conn.SetDeadline(time.Now().Add(time.Duration(30) * time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DBPostAuthInfo{Service: "Mongodb", Target: realhost, User: "(anonymous)"}
+	buildInfo, err := mongoCommand(conn, realhost, "admin", bsonDoc{{"buildInfo", int32(1)}})
+	if err != nil {
+		return nil, err
+	}
+	d.Version, _ = buildInfo.Get("version").(string)
+
+	if status, err := mongoCommand(conn, realhost, "admin", bsonDoc{{"connectionStatus", int32(1)}, {"showPrivileges", true}}); err == nil {
+		if authInfo, ok := status.Get("authInfo").(bsonDoc); ok {
+			roles, _ := authInfo.Get("authenticatedUserRoles").([]interface{})
+			for _, r := range roles {
+				if role, ok := r.(bsonDoc); ok {
+					d.Privileges = append(d.Privileges, fmt.Sprintf("%v@%v", role.Get("role"), role.Get("db")))
+				}
+			}
+		}
+	}
+
+	if cmdLine, err := mongoCommand(conn, realhost, "admin", bsonDoc{{"getCmdLineOpts", int32(1)}}); err == nil {
+		if parsed, ok := cmdLine.Get("parsed").(bsonDoc); ok {
+			for _, section := range []string{"net", "security", "storage"} {
+				if sub, ok := parsed.Get(section).(bsonDoc); ok {
+					for _, e := range sub {
+						if _, isDoc := e.Value.(bsonDoc); !isDoc {
+							d.addSetting(section+"."+e.Key, fmt.Sprintf("%v", e.Value))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	dbs, err := mongoCommand(conn, realhost, "admin", bsonDoc{{"listDatabases", int32(1)}, {"authorizedDatabases", true}})
+	if err != nil {
+		return d, nil
+	}
+	list, _ := dbs.Get("databases").([]interface{})
+	for i, item := range list {
+		if i >= dbPostAuthMaxDatabases {
+			break
+		}
+		db, ok := item.(bsonDoc)
+		if !ok {
+			continue
+		}
+		name, _ := db.Get("name").(string)
+		e := DBEntry{Name: name, Size: fmt.Sprintf("%d bytes", bsonInt64(db.Get("sizeOnDisk")))}
+		mongoCountCollections(conn, realhost, &e)
+		d.Databases = append(d.Databases, e)
+	}
+	return d, nil
+}
+
+// mongoCountCollections 对单个库执行listCollections，集合全部计数，文档数最多统计 dbPostAuthMaxDatabases 个集合
+func mongoCountCollections(conn net.Conn, realhost string, e *DBEntry) {
+	collections, err := mongoCommand(conn, realhost, e.Name, bsonDoc{{"listCollections", int32(1)}, {"nameOnly", true}})
+	if err != nil {
+		return
+	}
+	cursor, _ := collections.Get("cursor").(bsonDoc)
+	batch, _ := cursor.Get("firstBatch").([]interface{})
+	for _, c := range batch {
+		coll, ok := c.(bsonDoc)
+		if !ok {
+			continue
+		}
+		e.Tables++
+		if e.Tables > dbPostAuthMaxDatabases {
+			continue
+		}
+		collName, _ := coll.Get("name").(string)
+		// count 使用集合元数据，不会扫描文档
+		if count, err := mongoCommand(conn, realhost, e.Name, bsonDoc{{"count", collName}}); err == nil {
+			e.Rows += bsonInt64(count.Get("n"))
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package gopocs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestBsonRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  bsonDoc
+	}{
+		{"scalar", bsonDoc{{"s", "v"}, {"i", int32(7)}, {"b", true}}},
+		{"nested", bsonDoc{{"cursor", bsonDoc{{"id", int32(0)}, {"ns", "db.$cmd"}}}}},
+		{"array", bsonDoc{{"firstBatch", []interface{}{bsonDoc{{"name", "users"}}, "x", int32(1)}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bsonDecode(bsonEncode(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.doc) {
+				t.Errorf("bsonDecode(bsonEncode()) = %v, want %v", got, tt.doc)
+			}
+		})
+	}
+}
+
+// mongoStub 模拟 databases 个库，每个库 collections 个集合，记录每个库的count次数
+func mongoStub(t *testing.T, databases, collections int) (string, map[string]int, *sync.Mutex) {
+	counts := make(map[string]int)
+	var lock sync.Mutex
+	addr := stubServer(t, func(conn net.Conn) {
+		for {
+			header := make([]byte, 4)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			msg := make([]byte, binary.LittleEndian.Uint32(header)-4)
+			if _, err := io.ReadFull(conn, msg); err != nil {
+				return
+			}
+			cmd, err := bsonDecode(msg[17:])
+			if err != nil || len(cmd) == 0 {
+				return
+			}
+			db, _ := cmd.Get("$db").(string)
+			reply := bsonDoc{{"ok", int32(0)}, {"errmsg", "no such command"}}
+			switch cmd[0].Key {
+			case "buildInfo":
+				reply = bsonDoc{{"version", "6.0.0"}, {"ok", int32(1)}}
+			case "listDatabases":
+				var list []interface{}
+				for i := 0; i < databases; i++ {
+					list = append(list, bsonDoc{{"name", fmt.Sprintf("db%d", i)}, {"sizeOnDisk", int32(4096)}})
+				}
+				reply = bsonDoc{{"databases", list}, {"ok", int32(1)}}
+			case "listCollections":
+				var batch []interface{}
+				for i := 0; i < collections; i++ {
+					batch = append(batch, bsonDoc{{"name", fmt.Sprintf("c%d", i)}, {"type", "collection"}})
+				}
+				reply = bsonDoc{{"cursor", bsonDoc{{"firstBatch", batch}}}, {"ok", int32(1)}}
+			case "count":
+				lock.Lock()
+				counts[db]++
+				lock.Unlock()
+				reply = bsonDoc{{"n", int32(5)}, {"ok", int32(1)}}
+			}
+			body := bsonEncode(reply)
+			packet := make([]byte, 21, 21+len(body))
+			binary.LittleEndian.PutUint32(packet[0:4], uint32(21+len(body)))
+			binary.LittleEndian.PutUint32(packet[8:12], binary.LittleEndian.Uint32(msg[0:4]))
+			binary.LittleEndian.PutUint32(packet[12:16], 2013)
+			_, _ = conn.Write(append(packet, body...))
+		}
+	})
+	return addr, counts, &lock
+}
+
+func TestMongoPostAuth(t *testing.T) {
+	tests := []struct {
+		name        string
+		databases   int
+		collections int
+		wantDBs     int
+		wantCounts  int
+	}{
+		{"small", 3, 4, 3, 4},
+		{"capped", dbPostAuthMaxDatabases + 5, dbPostAuthMaxDatabases + 10, dbPostAuthMaxDatabases, dbPostAuthMaxDatabases},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, counts, lock := mongoStub(t, tt.databases, tt.collections)
+			d, err := mongoPostAuth(addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Version != "6.0.0" {
+				t.Errorf("Version = %q", d.Version)
+			}
+			if len(d.Databases) != tt.wantDBs {
+				t.Fatalf("len(Databases) = %d, want %d", len(d.Databases), tt.wantDBs)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			for _, e := range d.Databases {
+				if e.Tables != int64(tt.collections) {
+					t.Errorf("%s: Tables = %d, want %d", e.Name, e.Tables, tt.collections)
+				}
+				if counts[e.Name] != tt.wantCounts || e.Rows != int64(5*tt.wantCounts) {
+					t.Errorf("%s: count calls = %d, Rows = %d, want %d calls", e.Name, counts[e.Name], e.Rows, tt.wantCounts)
+				}
+			}
+		})
+	}
+}
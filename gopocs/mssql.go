@@ -116,6 +116,7 @@ func MssqlConn(info *structs.HostInfo, user string, pass string) (flag bool, err
 		defer db.Close()
 		err = db.Ping()
 		if err == nil {
+			AddCredential("Mssql", Host, Port, Username, Password, info.CredReuse)
 			result := fmt.Sprintf("Mssql://%v:%v:%v %v", Host, Port, Username, Password)
 			// gologger.Silent().Msg(result)
 
@@ -151,6 +152,10 @@ func MssqlConn(info *structs.HostInfo, user string, pass string) (flag bool, err
 				Description: "Mssql弱口令",
 			})
 
+			if structs.GlobalConfig.DBPostAuth {
+				reportDBPostAuth(mssqlPostAuth(db, Host+":"+Port))
+			}
+
 			flag = true
 		}
 	}
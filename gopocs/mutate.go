@@ -0,0 +1,585 @@
+package gopocs
+
+import (
+	"bufio"
+	"dddd/structs"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 口令变形引擎：根据目标上下文(域名、备案单位、NetBIOS主机名/域名、网站标题)生成 {{key}}，
+// 再按规则(年份、季节、键盘序列、符号后缀、leet、hashcat规则文件)变形
+
+// 不适合作为口令关键字的常见词
+var keywordStopWords = map[string]struct{}{
+	"www": {}, "com": {}, "net": {}, "org": {}, "gov": {}, "edu": {}, "mil": {}, "int": {},
+	"info": {}, "biz": {}, "xyz": {}, "top": {}, "site": {}, "online": {}, "local": {}, "localdomain": {},
+	"lan": {}, "mail": {}, "smtp": {}, "imap": {}, "pop": {}, "vpn": {}, "api": {}, "dev": {},
+	"test": {}, "web": {}, "home": {}, "index": {}, "login": {}, "welcome": {}, "page": {}, "default": {},
+	"system": {}, "admin": {}, "the": {}, "and": {}, "for": {}, "not": {}, "found": {}, "error": {},
+	"forbidden": {}, "server": {}, "http": {}, "https": {}, "html": {}, "nginx": {}, "apache": {}, "iis": {},
+	"workgroup": {}, "msbrowse": {}, "ltd": {}, "inc": {}, "corp": {},
+	"win": {}, "desktop": {}, "laptop": {},
+}
+
+var mutationSeasons = []string{"Spring", "Summer", "Autumn", "Winter"}
+var mutationWalkSuffixes = []string{"!@#", "123!@#", "qwe", "123qwe", "1qaz", "@1qaz", "qaz"}
+var mutationWalks = []string{"1qaz@WSX", "1qaz2wsx", "1qaz!QAZ", "qwe123!@#", "Qwer1234", "qwer1234!", "1q2w3e4r", "zaq1@WSX"}
+var mutationSymbolSuffixes = []string{"@", "#", "!", "@123", "#123", "!@#", "@123456", "123", "123456", "@@"}
+var mutationLeet = strings.NewReplacer("a", "@", "A", "@", "e", "3", "E", "3", "i", "1", "I", "1", "o", "0", "O", "0", "s", "$", "S", "$")
+
+// hashcat规则文件只读取一次
+var mutationHashcatRules [][]string
+var mutationHashcatOnce sync.Once
+
+// 中文关键字常见的后缀与无意义词，如单位名称中的"有限公司"、网站标题中的"管理系统"
+var keywordCJKSuffixes = []string{"股份有限公司", "有限责任公司", "有限公司", "公司", "集团", "管理系统", "管理平台", "系统", "平台"}
+var keywordCJKStopWords = map[string]struct{}{
+	"欢迎": {}, "登录": {}, "首页": {}, "后台": {}, "管理": {}, "用户登录": {}, "欢迎登录": {}, "统一身份认证": {},
+}
+
+// normalizeKeyword 从原始文本中提取可作为关键字的字母数字片段，中文片段单独保留
+func normalizeKeyword(s string) []string {
+	var result []string
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	}) {
+		// 中英文混排时按字符集拆分，如 "acme综合管理系统" => "acme", "综合管理系统"
+		for _, token := range splitKeywordScript(field) {
+			if token[0] > unicode.MaxASCII {
+				if token = trimCJKKeyword(token); token != "" {
+					result = append(result, token)
+				}
+				continue
+			}
+			token = strings.ToLower(token)
+			if len(token) < 3 || len(token) > 20 {
+				continue
+			}
+			if _, err := strconv.Atoi(token); err == nil {
+				continue
+			}
+			if _, ok := keywordStopWords[token]; ok {
+				continue
+			}
+			result = append(result, token)
+		}
+	}
+	return result
+}
+
+// splitKeywordScript 在ASCII与非ASCII字符的边界处拆分
+func splitKeywordScript(s string) []string {
+	var tokens []string
+	start := 0
+	for i, r := range s {
+		if i > 0 && (r > unicode.MaxASCII) != (s[start] > unicode.MaxASCII) {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+	}
+	return append(tokens, s[start:])
+}
+
+// trimCJKKeyword 去掉中文关键字的常见后缀，保留2到8个字
+func trimCJKKeyword(token string) string {
+	for _, suffix := range keywordCJKSuffixes {
+		if trimmed := strings.TrimSuffix(token, suffix); trimmed != token {
+			token = trimmed
+			break
+		}
+	}
+	if _, ok := keywordCJKStopWords[token]; ok {
+		return ""
+	}
+	if n := utf8.RuneCountInString(token); n < 2 || n > 8 {
+		return ""
+	}
+	return token
+}
+
+// targetKeywords 汇总与目标相关的关键字
+func targetKeywords(host string) []string {
+	var raw []string
+
+	structs.GlobalTargetKeywordMapLock.Lock()
+	raw = append(raw, structs.GlobalTargetKeywordMap[host]...)
+	structs.GlobalTargetKeywordMapLock.Unlock()
+
+	var domains []string
+	if net.ParseIP(host) == nil {
+		domains = append(domains, host)
+	}
+	structs.GlobalIPDomainMapLock.Lock()
+	domains = append(domains, structs.GlobalIPDomainMap[host]...)
+	structs.GlobalIPDomainMapLock.Unlock()
+	for _, domain := range domains {
+		labels := strings.Split(domain, ".")
+		// 去掉顶级域名，剩下的每一级都可以作为关键字
+		if len(labels) > 1 {
+			labels = labels[:len(labels)-1]
+		}
+		raw = append(raw, labels...)
+	}
+
+	structs.GlobalURLMapLock.Lock()
+	for _, entity := range structs.GlobalURLMap {
+		if entity.IP != host {
+			continue
+		}
+		for _, path := range entity.WebPaths {
+			raw = append(raw, path.Title)
+		}
+	}
+	structs.GlobalURLMapLock.Unlock()
+
+	var keys []string
+	for _, r := range raw {
+		keys = append(keys, normalizeKeyword(r)...)
+	}
+	return removeDuplicateKeepOrder(keys)
+}
+
+func removeDuplicateKeepOrder(input []string) []string {
+	m := make(map[string]struct{})
+	var result []string
+	for _, v := range input {
+		if _, ok := m[v]; !ok {
+			m[v] = struct{}{}
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// mutationKeys 按优先级排列的 {{key}}：指定关键字、目标上下文(InfoStr)、服务默认关键字
+func mutationKeys(info *structs.HostInfo, DefaultKeys []string) []string {
+	var keys []string
+	for _, k := range strings.Split(structs.GlobalConfig.MutateKeys, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	keys = append(keys, info.InfoStr...)
+	keys = append(keys, DefaultKeys...)
+	return removeDuplicateKeepOrder(keys)
+}
+
+func mutationRuleEnabled(name string) bool {
+	for _, r := range strings.Split(structs.GlobalConfig.MutateRules, ",") {
+		if strings.TrimSpace(r) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mutateKey 关键字本身的大小写形式：小写、大写、首字母大写，与字典模板一起直接展开
+func mutateKey(key string) []string {
+	if key == "" {
+		return nil
+	}
+	lower := strings.ToLower(key)
+	first, size := utf8.DecodeRuneInString(lower)
+	return removeDuplicateKeepOrder([]string{lower, strings.ToUpper(key), string(unicode.ToUpper(first)) + lower[size:]})
+}
+
+// leetKeys 关键字的leet变形，未启用leet规则或没有可替换的字符时为空
+func leetKeys(key string) []string {
+	if key == "" || !mutationRuleEnabled("leet") {
+		return nil
+	}
+	var results []string
+	for _, k := range mutateKey(key) {
+		if leet := mutationLeet.Replace(k); leet != k && k != strings.ToUpper(key) {
+			results = append(results, leet)
+		}
+	}
+	return removeDuplicateKeepOrder(results)
+}
+
+// ruleCandidates 基于关键字按规则生成的口令，不依赖字典模板，全大写的关键字较少见故不参与
+func ruleCandidates(key string) []string {
+	var results []string
+	year := time.Now().Year()
+	for _, k := range append(mutateKey(key), leetKeys(key)...) {
+		if k == strings.ToUpper(key) && k != strings.ToLower(key) {
+			continue
+		}
+		if mutationRuleEnabled("year") {
+			for y := year; y >= year-2; y-- {
+				for _, sep := range []string{"", "@", "#", "_"} {
+					results = append(results, fmt.Sprintf("%s%s%d", k, sep, y))
+				}
+				results = append(results, fmt.Sprintf("%s@%d!", k, y))
+			}
+		}
+		if mutationRuleEnabled("symbol") {
+			for _, s := range mutationSymbolSuffixes {
+				results = append(results, k+s)
+			}
+		}
+		if mutationRuleEnabled("walk") {
+			for _, s := range mutationWalkSuffixes {
+				results = append(results, k+s)
+			}
+		}
+		for _, rule := range loadHashcatRules() {
+			if p, ok := applyHashcatRule(k, rule); ok {
+				results = append(results, p)
+			}
+		}
+	}
+	return removeDuplicateKeepOrder(results)
+}
+
+// staticRuleCandidates 与关键字无关的规则口令(季节+年份、键盘序列)
+func staticRuleCandidates() []string {
+	var results []string
+	year := time.Now().Year()
+	if mutationRuleEnabled("season") {
+		for y := year; y >= year-1; y-- {
+			for _, s := range mutationSeasons {
+				results = append(results, fmt.Sprintf("%s%d", s, y), fmt.Sprintf("%s%d!", s, y), fmt.Sprintf("%s@%d", s, y))
+			}
+		}
+	}
+	if mutationRuleEnabled("walk") {
+		results = append(results, mutationWalks...)
+	}
+	return results
+}
+
+// mutationBudget 单个服务变形口令(leet与规则口令)的数量上限，为0时不变形，字典模板的展开不计入
+type mutationBudget struct {
+	left int
+}
+
+func newMutationBudget() *mutationBudget {
+	return &mutationBudget{left: structs.GlobalConfig.MutateMax}
+}
+
+func (b *mutationBudget) take() bool {
+	if b.left <= 0 {
+		return false
+	}
+	b.left--
+	return true
+}
+
+// expandUserPass 展开 "user : pass" 列表中的 {{key}}，并为含模板的用户追加规则口令
+// 关键字按优先级逐个展开，数量达到上限后靠后的关键字不再参与
+func expandUserPass(info *structs.HostInfo, upList []string, DefaultKeys []string) []structs.UserPasswd {
+	var userPasswdList []structs.UserPasswd
+	var templates []structs.UserPasswd
+	var templateUsers []string
+
+	for _, userPasswd := range upList {
+		user, oriPass := splitUserPass(userPasswd)
+		if strings.Contains(oriPass, "{{key}}") {
+			templates = append(templates, structs.UserPasswd{UserName: user, Password: oriPass})
+			templateUsers = append(templateUsers, user)
+		} else {
+			userPasswdList = append(userPasswdList, structs.UserPasswd{UserName: user, Password: oriPass})
+		}
+	}
+	if len(templates) == 0 {
+		return RemoveDuplicateUserPass(userPasswdList)
+	}
+	templateUsers = removeDuplicateKeepOrder(templateUsers)
+
+	// 先展开字典模板，再追加变形口令
+	keys := mutationKeys(info, DefaultKeys)
+	for _, k := range keys {
+		for _, nKey := range mutateKey(k) {
+			for _, t := range templates {
+				userPasswdList = append(userPasswdList, structs.UserPasswd{UserName: t.UserName, Password: strings.Replace(t.Password, "{{key}}", nKey, -1)})
+			}
+		}
+	}
+
+	budget := newMutationBudget()
+	add := func(user, pass string) bool {
+		if !budget.take() {
+			return false
+		}
+		userPasswdList = append(userPasswdList, structs.UserPasswd{UserName: user, Password: pass})
+		return true
+	}
+leet:
+	for _, k := range keys {
+		for _, nKey := range leetKeys(k) {
+			for _, t := range templates {
+				if !add(t.UserName, strings.Replace(t.Password, "{{key}}", nKey, -1)) {
+					break leet
+				}
+			}
+		}
+	}
+rule:
+	for _, k := range keys {
+		for _, p := range ruleCandidates(k) {
+			for _, user := range templateUsers {
+				if !add(user, p) {
+					break rule
+				}
+			}
+		}
+	}
+static:
+	for _, p := range staticRuleCandidates() {
+		for _, user := range templateUsers {
+			if !add(user, p) {
+				break static
+			}
+		}
+	}
+	return RemoveDuplicateUserPass(userPasswdList)
+}
+
+// expandPassword 只需要密码的服务使用
+func expandPassword(info *structs.HostInfo, passList []string, DefaultKeys []string) []string {
+	var result []string
+	var templates []string
+
+	for _, oriPass := range passList {
+		oriPass = strings.TrimSuffix(oriPass, "\r")
+		if strings.Contains(oriPass, "{{key}}") {
+			templates = append(templates, oriPass)
+		} else {
+			result = append(result, oriPass)
+		}
+	}
+	if len(templates) == 0 {
+		return removeDuplicateKeepOrder(result)
+	}
+
+	// 先展开字典模板，再追加变形口令
+	keys := mutationKeys(info, DefaultKeys)
+	for _, k := range keys {
+		for _, nKey := range mutateKey(k) {
+			for _, t := range templates {
+				result = append(result, strings.Replace(t, "{{key}}", nKey, -1))
+			}
+		}
+	}
+
+	budget := newMutationBudget()
+	add := func(pass string) bool {
+		if !budget.take() {
+			return false
+		}
+		result = append(result, pass)
+		return true
+	}
+leet:
+	for _, k := range keys {
+		for _, nKey := range leetKeys(k) {
+			for _, t := range templates {
+				if !add(strings.Replace(t, "{{key}}", nKey, -1)) {
+					break leet
+				}
+			}
+		}
+	}
+rule:
+	for _, k := range keys {
+		for _, p := range ruleCandidates(k) {
+			if !add(p) {
+				break rule
+			}
+		}
+	}
+	for _, p := range staticRuleCandidates() {
+		if !add(p) {
+			break
+		}
+	}
+	return removeDuplicateKeepOrder(result)
+}
+
+// loadHashcatRules 读取hashcat规则文件，每行一条规则，不支持的规则会被忽略
+func loadHashcatRules() [][]string {
+	mutationHashcatOnce.Do(func() {
+		if structs.GlobalConfig.MutateRuleFile == "" {
+			return
+		}
+		f, err := os.Open(structs.GlobalConfig.MutateRuleFile)
+		if err != nil {
+			gologger.Error().Msgf("读取规则文件失败: %v", err)
+			return
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if rule, ok := parseHashcatRule(line); ok {
+				mutationHashcatRules = append(mutationHashcatRules, rule)
+			}
+		}
+		gologger.Info().Msgf("口令变形规则: %d 条", len(mutationHashcatRules))
+	})
+	return mutationHashcatRules
+}
+
+// hashcat规则函数及其参数个数
+var hashcatRuleArgs = map[rune]int{
+	':': 0, 'l': 0, 'u': 0, 'c': 0, 'C': 0, 't': 0, 'r': 0, 'd': 0, 'f': 0, '{': 0, '}': 0, '[': 0, ']': 0,
+	'$': 1, '^': 1, '@': 1, 'T': 1, 'D': 1, '\'': 1, 'z': 1, 'Z': 1, 'p': 1,
+	's': 2, 'i': 2, 'o': 2,
+}
+
+// parseHashcatRule 将一行规则拆分为函数列表，例如 "c $2 $0" => ["c", "$2", "$0"]，参数可以是中文字符
+func parseHashcatRule(line string) ([]string, bool) {
+	var rule []string
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		if runes[i] == ' ' || runes[i] == '\t' {
+			i++
+			continue
+		}
+		n, ok := hashcatRuleArgs[runes[i]]
+		if !ok || i+n >= len(runes) {
+			return nil, false
+		}
+		rule = append(rule, string(runes[i:i+1+n]))
+		i += 1 + n
+	}
+	return rule, len(rule) > 0
+}
+
+func hashcatPos(r rune) int {
+	if r >= '0' && r <= '9' {
+		return int(r - '0')
+	}
+	if r >= 'A' && r <= 'Z' {
+		return int(r-'A') + 10
+	}
+	return -1
+}
+
+// toggleASCII 切换ASCII字母的大小写，k/s 等字母的 unicode.SimpleFold 不是对应的大小写，其它字符不变
+func toggleASCII(r rune) rune {
+	if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+		return r ^ 0x20
+	}
+	return r
+}
+
+// applyHashcatRule 实现常用的hashcat规则函数，按字符(rune)处理，中文关键字不会被截断
+func applyHashcatRule(word string, rule []string) (string, bool) {
+	w := []rune(word)
+	for _, f := range rule {
+		fn := []rune(f)
+		switch fn[0] {
+		case ':':
+		case 'l':
+			w = []rune(strings.ToLower(string(w)))
+		case 'u':
+			w = []rune(strings.ToUpper(string(w)))
+		case 'c':
+			w = []rune(strings.ToLower(string(w)))
+			if len(w) > 0 && w[0] >= 'a' && w[0] <= 'z' {
+				w[0] = toggleASCII(w[0])
+			}
+		case 'C':
+			w = []rune(strings.ToUpper(string(w)))
+			if len(w) > 0 && w[0] >= 'A' && w[0] <= 'Z' {
+				w[0] = toggleASCII(w[0])
+			}
+		case 't':
+			for i := range w {
+				w[i] = toggleASCII(w[i])
+			}
+		case 'r':
+			for i, j := 0, len(w)-1; i < j; i, j = i+1, j-1 {
+				w[i], w[j] = w[j], w[i]
+			}
+		case 'd':
+			w = append(w, w...)
+		case 'f':
+			r := make([]rune, len(w))
+			for i := range w {
+				r[len(w)-1-i] = w[i]
+			}
+			w = append(w, r...)
+		case '{':
+			if len(w) > 0 {
+				w = append(w[1:], w[0])
+			}
+		case '}':
+			if len(w) > 0 {
+				w = append([]rune{w[len(w)-1]}, w[:len(w)-1]...)
+			}
+		case '[':
+			if len(w) > 0 {
+				w = w[1:]
+			}
+		case ']':
+			if len(w) > 0 {
+				w = w[:len(w)-1]
+			}
+		case '$':
+			w = append(w, fn[1])
+		case '^':
+			w = append([]rune{fn[1]}, w...)
+		case '@':
+			w = []rune(strings.ReplaceAll(string(w), string(fn[1]), ""))
+		case 's':
+			w = []rune(strings.ReplaceAll(string(w), string(fn[1]), string(fn[2])))
+		case 'T', 'D', '\'', 'z', 'Z', 'p':
+			n := hashcatPos(fn[1])
+			if n < 0 {
+				return "", false
+			}
+			switch fn[0] {
+			case 'T':
+				if n < len(w) {
+					w[n] = toggleASCII(w[n])
+				}
+			case 'D':
+				if n < len(w) {
+					w = append(w[:n:n], w[n+1:]...)
+				}
+			case '\'':
+				if n < len(w) {
+					w = w[:n]
+				}
+			case 'z':
+				if len(w) > 0 {
+					w = append([]rune(strings.Repeat(string(w[0]), n)), w...)
+				}
+			case 'Z':
+				if len(w) > 0 {
+					w = append(w, []rune(strings.Repeat(string(w[len(w)-1]), n))...)
+				}
+			case 'p':
+				w = []rune(strings.Repeat(string(w), n+1))
+			}
+		case 'i', 'o':
+			n := hashcatPos(fn[1])
+			if n < 0 {
+				return "", false
+			}
+			if fn[0] == 'i' && n <= len(w) {
+				w = append(w[:n:n], append([]rune{fn[2]}, w[n:]...)...)
+			} else if fn[0] == 'o' && n < len(w) {
+				w[n] = fn[2]
+			}
+		default:
+			return "", false
+		}
+	}
+	return string(w), len(w) > 0
+}
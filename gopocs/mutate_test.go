@@ -0,0 +1,193 @@
+package gopocs
+
+import (
+	"dddd/structs"
+	"dddd/utils"
+	"reflect"
+	"testing"
+)
+
+func TestApplyHashcatRule(t *testing.T) {
+	tests := []struct {
+		word string
+		rule string
+		want string
+		ok   bool
+	}{
+		{"password", ":", "password", true},
+		{"password", "c $1 $2 $3", "Password123", true},
+		{"PassWord", "C", "pASSWORD", true},
+		{"PassWord", "t", "pASSwORD", true},
+		{"password", "T0", "Password", true},
+		{"Password", "T0", "password", true},
+		{"password", "T0 T1", "PAssword", true},
+		// k/s 的 SimpleFold 为开尔文符号与长s，必须只切换ASCII大小写
+		{"kelvin", "T0", "Kelvin", true},
+		{"secret", "T0", "Secret", true},
+		{"p@ss", "T1", "p@ss", true},
+		{"abc", "T9", "abc", true},
+		{"acme", "^1 $!", "1acme!", true},
+		{"password", "sa@ so0", "p@ssw0rd", true},
+		{"acme", "d", "acmeacme", true},
+		{"acme", "r", "emca", true},
+		{"acme", "D0", "cme", true},
+		{"acme", "'2", "ac", true},
+		{"acme", "z2", "aaacme", true},
+		{"acme", "i1X", "aXcme", true},
+		{"acme", "o0X", "Xcme", true},
+		{"测试", "c", "测试", true},
+		{"测试", "t", "测试", true},
+		// 中文关键字按字符处理，结果必须是合法的UTF-8
+		{"某某科技", "r", "技科某某", true},
+		{"某某", "f", "某某某某", true},
+		{"acme某", "{", "cme某a", true},
+		{"acme某", "}", "某acme", true},
+		{"某acme", "[", "acme", true},
+		{"acme某", "]", "acme", true},
+		{"某某科技", "'2", "某某", true},
+		{"某某科技", "D0", "某科技", true},
+		{"某某", "T0 $1", "某某1", true},
+		{"acme", "$某", "acme某", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.word+" "+tt.rule, func(t *testing.T) {
+			rule, ok := parseHashcatRule(tt.rule)
+			if !ok {
+				t.Fatalf("parseHashcatRule(%q) failed", tt.rule)
+			}
+			got, ok := applyHashcatRule(tt.word, rule)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("applyHashcatRule(%q, %q) = (%q, %v), want (%q, %v)", tt.word, tt.rule, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseHashcatRule(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+		ok   bool
+	}{
+		{"c $2 $0", []string{"c", "$2", "$0"}, true},
+		{"sa@T3", []string{"sa@", "T3"}, true},
+		{"^某 $!", []string{"^某", "$!"}, true},
+		{"$", nil, false},
+		{"X", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseHashcatRule(tt.line)
+		if ok != tt.ok || (ok && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("parseHashcatRule(%q) = (%v, %v), want (%v, %v)", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalizeKeyword(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"www.acme.com", []string{"acme"}},
+		{"Acme Corp Login", []string{"acme"}},
+		{"2024", nil},
+		{"北京某某科技有限公司", []string{"北京某某科技"}},
+		{"某某集团", []string{"某某"}},
+		{"acme综合管理系统", []string{"acme", "综合"}},
+		{"欢迎登录 - 用户登录", nil},
+		{"统一身份认证平台", nil},
+		{"某某大学教务系统", []string{"某某大学教务"}},
+		{"测", nil},
+	}
+	for _, tt := range tests {
+		if got := normalizeKeyword(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("normalizeKeyword(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMutateKey(t *testing.T) {
+	structs.GlobalConfig.MutateRules = ""
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"acme", []string{"acme", "ACME", "Acme"}},
+		{"ACME", []string{"acme", "ACME", "Acme"}},
+		{"某某", []string{"某某"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := mutateKey(tt.key); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mutateKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestExpandPasswordRules(t *testing.T) {
+	structs.GlobalConfig.MutateKeys = "acme"
+	structs.GlobalConfig.MutateMax = 300
+	defer func() {
+		structs.GlobalConfig.MutateKeys = ""
+		structs.GlobalConfig.MutateRules = ""
+	}()
+	tests := []struct {
+		rules   string
+		want    string
+		present bool
+	}{
+		// 默认不启用规则，只展开字典模板
+		{"", "acme@123", true},
+		{"", "acme#", false},
+		{"symbol", "acme#", true},
+		{"season", "acme#", false},
+	}
+	for _, tt := range tests {
+		structs.GlobalConfig.MutateRules = tt.rules
+		got := expandPassword(&structs.HostInfo{Host: "10.0.0.1"}, []string{"{{key}}@123"}, nil)
+		found := false
+		for _, p := range got {
+			if p == tt.want {
+				found = true
+			}
+		}
+		if found != tt.present {
+			t.Errorf("rules %q: %q present = %v, want %v (%v)", tt.rules, tt.want, found, tt.present, got)
+		}
+	}
+}
+
+func TestExpandPasswordBudget(t *testing.T) {
+	structs.GlobalConfig.MutateKeys = "acme,example"
+	structs.GlobalConfig.MutateRules = "leet,symbol"
+	defer func() {
+		structs.GlobalConfig.MutateKeys = ""
+		structs.GlobalConfig.MutateRules = ""
+		structs.GlobalConfig.MutateMax = 300
+	}()
+	templates := []string{"{{key}}@123", "{{key}}#2024", "admin"}
+	tests := []struct {
+		name    string
+		max     int
+		wantLen int
+	}{
+		// 2个关键字 x 3种大小写 x 2个模板，加上不含模板的口令
+		{"no-mutation", 0, 2*3*2 + 1},
+		{"capped", 5, 2*3*2 + 1 + 5},
+		{"one", 1, 2*3*2 + 1 + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			structs.GlobalConfig.MutateMax = tt.max
+			got := expandPassword(&structs.HostInfo{Host: "10.0.0.1"}, templates, nil)
+			if len(got) != tt.wantLen {
+				t.Errorf("len = %d, want %d: %v", len(got), tt.wantLen, got)
+			}
+			for _, want := range []string{"admin", "acme@123", "ACME@123", "Acme#2024", "example#2024", "EXAMPLE@123"} {
+				if utils.GetItemInArray(got, want) < 0 {
+					t.Errorf("%q missing: %v", want, got)
+				}
+			}
+		})
+	}
+}
@@ -54,6 +54,7 @@ func MysqlConn(info *structs.HostInfo, user string, pass string) (flag bool, err
 		defer db.Close()
 		err = db.Ping()
 		if err == nil {
+			AddCredential("Mysql", Host, Port, Username, Password, info.CredReuse)
 			result := fmt.Sprintf("Mysql://%v:%v:%v %v", Host, Port, Username, Password)
 			// gologger.Silent().Msg("[GoPoc] " + result)
 
@@ -124,6 +125,10 @@ func MysqlConn(info *structs.HostInfo, user string, pass string) (flag bool, err
 				Description: "Mysql弱口令",
 			})
 
+			if structs.GlobalConfig.DBPostAuth {
+				reportDBPostAuth(mysqlPostAuth(db, Host+":"+Port))
+			}
+
 			flag = true
 		}
 	}
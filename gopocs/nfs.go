@@ -0,0 +1,283 @@
+package gopocs
+
+import (
+	"bytes"
+	"dddd/common"
+	"dddd/structs"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rpcProgPortmap = 100000
+	rpcProgMount   = 100005
+
+	pmapProcGetPort = 3
+	mountProcExport = 5
+)
+
+var nfsRPCError = errors.New("nfs rpc error")
+
+// 111与2049端口都会触发NFS检测，同一主机只检测一次
+var nfsChecked = make(map[string]struct{})
+var nfsCheckedLock sync.Mutex
+
+type nfsExport struct {
+	Path   string
+	Groups []string
+}
+
+// WorldMountable 未限制客户端或允许任意客户端挂载
+func (e nfsExport) WorldMountable() bool {
+	if len(e.Groups) == 0 {
+		return true
+	}
+	for _, g := range e.Groups {
+		if g == "*" || g == "0.0.0.0/0" || g == "(everyone)" {
+			return true
+		}
+	}
+	return false
+}
+
+// Sensitive 根目录或home目录
+func (e nfsExport) Sensitive() bool {
+	p := strings.TrimRight(e.Path, "/")
+	return p == "" || p == "/home" || strings.HasPrefix(p, "/home/") || p == "/root"
+}
+
+// rpcCall 通过TCP发送一次ONC RPC调用(AUTH_NULL)，返回结果部分
+func rpcCall(realhost string, prog, vers, proc uint32, args []byte) ([]byte, error) {
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+	err = conn.SetDeadline(time.Now().Add(time.Duration(6) * time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	xid := rand.New(rand.NewSource(time.Now().UnixNano())).Uint32()
+	var call bytes.Buffer
+	// xid, CALL, rpcvers=2, prog, vers, proc, cred(AUTH_NULL), verf(AUTH_NULL)
+	_ = binary.Write(&call, binary.BigEndian, []uint32{xid, 0, 2, prog, vers, proc, 0, 0, 0, 0})
+	call.Write(args)
+
+	packet := make([]byte, 4)
+	binary.BigEndian.PutUint32(packet, 0x80000000|uint32(call.Len()))
+	packet = append(packet, call.Bytes()...)
+	_, err = conn.Write(packet)
+	gologger.AuditTimeLogger("[Go] [NFS] Dumped TCP request for %s\n\n%s\n", realhost, hex.Dump(packet))
+	if err != nil {
+		return nil, err
+	}
+
+	// 读取所有记录分片
+	var reply []byte
+	for {
+		marker := make([]byte, 4)
+		if _, err = io.ReadFull(conn, marker); err != nil {
+			return nil, err
+		}
+		m := binary.BigEndian.Uint32(marker)
+		size := m & 0x7fffffff
+		if size > 4*1024*1024 {
+			return nil, nfsRPCError
+		}
+		fragment := make([]byte, size)
+		if _, err = io.ReadFull(conn, fragment); err != nil {
+			return nil, err
+		}
+		reply = append(reply, fragment...)
+		if m&0x80000000 != 0 {
+			break
+		}
+	}
+	gologger.AuditTimeLogger("[Go] [NFS] Dumped TCP response for %s\n\n%s\n", realhost, hex.Dump(reply))
+
+	// xid, REPLY, MSG_ACCEPTED, verf(flavor, len, body), SUCCESS
+	if len(reply) < 24 || binary.BigEndian.Uint32(reply[0:4]) != xid ||
+		binary.BigEndian.Uint32(reply[4:8]) != 1 || binary.BigEndian.Uint32(reply[8:12]) != 0 {
+		return nil, nfsRPCError
+	}
+	verfLen := int(binary.BigEndian.Uint32(reply[16:20]))
+	offset := 20 + (verfLen+3)/4*4
+	if len(reply) < offset+4 || binary.BigEndian.Uint32(reply[offset:offset+4]) != 0 {
+		return nil, nfsRPCError
+	}
+	return reply[offset+4:], nil
+}
+
+type xdrReader struct {
+	data []byte
+	err  error
+}
+
+func (r *xdrReader) uint32() uint32 {
+	if r.err != nil || len(r.data) < 4 {
+		r.err = nfsRPCError
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data[:4])
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *xdrReader) string() string {
+	n := int(r.uint32())
+	padded := (n + 3) / 4 * 4
+	if r.err != nil || n > len(r.data) || padded > len(r.data) {
+		r.err = nfsRPCError
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[padded:]
+	return s
+}
+
+// nfsPortmapAddrs portmapper候选地址，依次为当前端口或协议识别为rpcbind的端口、111。
+// 流水线中协议识别仍在进行，111可能尚未识别，因此总是尝试111
+func nfsPortmapAddrs(info *structs.HostInfo) []string {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	var addrs []string
+	structs.GlobalIPPortMapLock.Lock()
+	if structs.GlobalIPPortMap[realhost] == "rpcbind" {
+		addrs = append(addrs, realhost)
+	}
+	var rpcbind []string
+	for hostPort, protocol := range structs.GlobalIPPortMap {
+		if protocol == "rpcbind" && hostPort != realhost && strings.HasPrefix(hostPort, info.Host+":") {
+			rpcbind = append(rpcbind, hostPort)
+		}
+	}
+	structs.GlobalIPPortMapLock.Unlock()
+	sort.Strings(rpcbind)
+	addrs = append(addrs, rpcbind...)
+	for _, addr := range addrs {
+		if addr == info.Host+":111" {
+			return addrs
+		}
+	}
+	return append(addrs, info.Host+":111")
+}
+
+// nfsGetMountPort 通过portmapper查询mountd的TCP端口
+func nfsGetMountPort(realhost string) (uint32, error) {
+	var args bytes.Buffer
+	// prog, vers, prot(TCP=6), port
+	_ = binary.Write(&args, binary.BigEndian, []uint32{rpcProgMount, 3, 6, 0})
+	res, err := rpcCall(realhost, rpcProgPortmap, 2, pmapProcGetPort, args.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	r := &xdrReader{data: res}
+	port := r.uint32()
+	if r.err != nil || port == 0 {
+		return 0, nfsRPCError
+	}
+	return port, nil
+}
+
+// nfsGetExports MOUNTPROC_EXPORT，等同于 showmount -e
+func nfsGetExports(host string, port uint32) ([]nfsExport, error) {
+	res, err := rpcCall(fmt.Sprintf("%s:%d", host, port), rpcProgMount, 3, mountProcExport, nil)
+	if err != nil {
+		return nil, err
+	}
+	var exports []nfsExport
+	r := &xdrReader{data: res}
+	for r.uint32() == 1 && r.err == nil {
+		export := nfsExport{Path: r.string()}
+		for r.uint32() == 1 && r.err == nil {
+			export.Groups = append(export.Groups, r.string())
+		}
+		exports = append(exports, export)
+	}
+	return exports, r.err
+}
+
+func NFSScan(info *structs.HostInfo) error {
+	nfsCheckedLock.Lock()
+	_, ok := nfsChecked[info.Host]
+	nfsChecked[info.Host] = struct{}{}
+	nfsCheckedLock.Unlock()
+	if ok {
+		return nil
+	}
+
+	var port uint32
+	var err error
+	for _, addr := range nfsPortmapAddrs(info) {
+		if port, err = nfsGetMountPort(addr); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	exports, err := nfsGetExports(info.Host, port)
+	if err != nil || len(exports) == 0 {
+		return err
+	}
+
+	target := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	var listing string
+	var worldMountable []string
+	security := "MEDIUM"
+	for _, e := range exports {
+		acl := "(everyone)"
+		if len(e.Groups) > 0 {
+			acl = strings.Join(e.Groups, ",")
+		}
+		listing += fmt.Sprintf("%-30s %s\n", e.Path, acl)
+		if e.WorldMountable() {
+			worldMountable = append(worldMountable, e.Path)
+			if e.Sensitive() {
+				security = "HIGH"
+			}
+		}
+	}
+
+	var paths []string
+	for _, e := range exports {
+		paths = append(paths, e.Path)
+	}
+
+	pocName := "NFS-Exports"
+	description := "NFS共享目录泄露"
+	if len(worldMountable) == 0 {
+		security = "INFO"
+	} else {
+		paths = worldMountable
+		pocName = "NFS-World-Mountable"
+		description = "NFS共享目录允许任意主机挂载"
+		if security == "HIGH" {
+			description = "NFS根目录或home目录允许任意主机挂载"
+		}
+	}
+
+	showData := fmt.Sprintf("Host: %v\nMountd: %v\nExports: %v\nWorldMountable: %v\n", info.Host, port, len(exports), strings.Join(worldMountable, ","))
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     pocName,
+		Security:    security,
+		Target:      target,
+		InfoLeft:    showData,
+		InfoRight:   "showmount -e " + info.Host + "\n" + listing,
+		Description: description,
+	}, fmt.Sprintf("%s nfs://%s [%s]", pocName, info.Host, strings.Join(paths, ",")))
+	return nil
+}
@@ -0,0 +1,71 @@
+package gopocs
+
+import (
+	"dddd/structs"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestNFSPortmapAddrs(t *testing.T) {
+	tests := []struct {
+		name    string
+		portMap map[string]string
+		info    structs.HostInfo
+		want    []string
+	}{
+		{"default", map[string]string{"10.0.0.1:2049": "nfs"}, structs.HostInfo{Host: "10.0.0.1", Ports: "2049"}, []string{"10.0.0.1:111"}},
+		{"current-port", map[string]string{"10.0.0.1:1111": "rpcbind"}, structs.HostInfo{Host: "10.0.0.1", Ports: "1111"}, []string{"10.0.0.1:1111", "10.0.0.1:111"}},
+		{"other-port", map[string]string{"10.0.0.1:2049": "nfs", "10.0.0.1:1111": "rpcbind"}, structs.HostInfo{Host: "10.0.0.1", Ports: "2049"}, []string{"10.0.0.1:1111", "10.0.0.1:111"}},
+		{"identified-111", map[string]string{"10.0.0.1:111": "rpcbind"}, structs.HostInfo{Host: "10.0.0.1", Ports: "2049"}, []string{"10.0.0.1:111"}},
+		{"other-host", map[string]string{"10.0.0.10:1111": "rpcbind"}, structs.HostInfo{Host: "10.0.0.1", Ports: "2049"}, []string{"10.0.0.1:111"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			structs.GlobalIPPortMap = tt.portMap
+			if got := nfsPortmapAddrs(&tt.info); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("nfsPortmapAddrs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// portmapStub 对GETPORT请求回复固定的mountd端口
+func portmapStub(t *testing.T, mountPort uint32) string {
+	return stubServer(t, func(conn net.Conn) {
+		marker := make([]byte, 4)
+		if _, err := io.ReadFull(conn, marker); err != nil {
+			return
+		}
+		call := make([]byte, binary.BigEndian.Uint32(marker)&0x7fffffff)
+		if _, err := io.ReadFull(conn, call); err != nil {
+			return
+		}
+		if binary.BigEndian.Uint32(call[12:16]) != rpcProgPortmap || binary.BigEndian.Uint32(call[20:24]) != pmapProcGetPort {
+			return
+		}
+		// xid, REPLY, MSG_ACCEPTED, verf(AUTH_NULL), SUCCESS, port
+		reply := make([]byte, 28)
+		copy(reply[0:4], call[0:4])
+		binary.BigEndian.PutUint32(reply[4:8], 1)
+		binary.BigEndian.PutUint32(reply[24:28], mountPort)
+		binary.BigEndian.PutUint32(marker, 0x80000000|uint32(len(reply)))
+		_, _ = conn.Write(append(marker, reply...))
+	})
+}
+
+func TestNFSGetMountPort(t *testing.T) {
+	addr := portmapStub(t, 20048)
+	host, port, _ := net.SplitHostPort(addr)
+	structs.GlobalIPPortMap = map[string]string{addr: "rpcbind", host + ":2049": "nfs"}
+
+	got, err := nfsGetMountPort(nfsPortmapAddrs(&structs.HostInfo{Host: host, Ports: "2049"})[0])
+	if err != nil {
+		t.Fatalf("nfsGetMountPort() via %s error: %v", port, err)
+	}
+	if got != 20048 {
+		t.Errorf("nfsGetMountPort() = %d, want 20048", got)
+	}
+}
@@ -0,0 +1,556 @@
+package gopocs
+
+import (
+	"bytes"
+	"crypto/tls"
+	"dddd/common"
+	"dddd/common/uncover"
+	"dddd/structs"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+)
+
+// NTLM信息收集：向SMB/RDP/MSSQL/SMTP/WinRM/HTTP发起匿名NTLM协商，
+// 从服务端Challenge中读取主机名、域名与系统版本，每个主机只需成功一次
+
+var ntlmErr = errors.New("ntlm challenge not found")
+
+// 匿名NTLM协商请求，携带 NEGOTIATE_VERSION 以获取服务端系统版本
+var ntlmNegotiateMessage = []byte{
+	'N', 'T', 'L', 'M', 'S', 'S', 'P', 0x00,
+	0x01, 0x00, 0x00, 0x00,
+	0x97, 0x82, 0x08, 0xe2,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x06, 0x01, 0xb1, 0x1d, 0x00, 0x00, 0x00, 0x0f,
+}
+
+var spnegoOID = []byte{0x2b, 0x06, 0x01, 0x05, 0x05, 0x02}
+var ntlmsspOID = []byte{0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x02, 0x02, 0x0a}
+
+type ntlmEndpoint struct {
+	Service string
+	Port    string
+	URL     string
+}
+
+func (e ntlmEndpoint) Target(host string) string {
+	if e.URL != "" {
+		return e.URL
+	}
+	return fmt.Sprintf("%s://%s:%s", e.Service, host, e.Port)
+}
+
+// 主机 => 可进行NTLM协商的服务，流水线中与 collectNTLMEndpoints 中写入
+var ntlmEndpoints = make(map[string][]ntlmEndpoint)
+var ntlmEndpointsLock sync.Mutex
+
+func addNTLMEndpoint(host string, e ntlmEndpoint) {
+	ntlmEndpointsLock.Lock()
+	defer ntlmEndpointsLock.Unlock()
+	for _, exist := range ntlmEndpoints[host] {
+		if exist == e {
+			return
+		}
+	}
+	ntlmEndpoints[host] = append(ntlmEndpoints[host], e)
+}
+
+func ntlmHostEndpoints(host string) []ntlmEndpoint {
+	ntlmEndpointsLock.Lock()
+	defer ntlmEndpointsLock.Unlock()
+	return append([]ntlmEndpoint{}, ntlmEndpoints[host]...)
+}
+
+func ntlmEndpointHosts() []string {
+	ntlmEndpointsLock.Lock()
+	defer ntlmEndpointsLock.Unlock()
+	var hosts []string
+	for host := range ntlmEndpoints {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// httpNTLMAdvertised 响应头中是否包含 WWW-Authenticate: NTLM/Negotiate
+func httpNTLMAdvertised(headerHash string) bool {
+	if structs.GlobalHttpHeaderHMap == nil || headerHash == "" {
+		return false
+	}
+	header, ok := structs.GlobalHttpHeaderHMap.Get(headerHash)
+	if !ok {
+		return false
+	}
+	for _, line := range strings.Split(strings.ToLower(string(header)), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "www-authenticate:") {
+			continue
+		}
+		if strings.Contains(line, "ntlm") || strings.Contains(line, "negotiate") {
+			return true
+		}
+	}
+	return false
+}
+
+// ntlmServiceEndpoint 非Web服务中可进行NTLM协商的服务
+func ntlmServiceEndpoint(host, port, protocol string) (ntlmEndpoint, bool) {
+	if protocol == "smb" || port == "445" {
+		return ntlmEndpoint{Service: "SMB", Port: port}, true
+	}
+	if protocol == "rdp" || port == "3389" {
+		return ntlmEndpoint{Service: "RDP", Port: port}, true
+	}
+	if protocol == "mssql" || port == "1433" {
+		return ntlmEndpoint{Service: "MSSQL", Port: port}, true
+	}
+	if protocol == "wsman" || port == "5985" || port == "5986" {
+		return ntlmEndpoint{Service: "WinRM", Port: port,
+			URL: winrmURL(&structs.HostInfo{Host: host, Ports: port})}, true
+	}
+	if protocol == "smtp" || protocol == "smtp-ssl" || protocol == "smtps" ||
+		port == "25" || port == "465" || port == "587" {
+		return ntlmEndpoint{Service: "SMTP", Port: port}, true
+	}
+	return ntlmEndpoint{}, false
+}
+
+// collectNTLMEndpoints 从端口与Web资产中整理每个主机可用于NTLM协商的服务，按优先级排列
+func collectNTLMEndpoints() {
+	structs.GlobalIPPortMapLock.Lock()
+	for hostPort, protocol := range structs.GlobalIPPortMap {
+		t := strings.Split(hostPort, ":")
+		if e, ok := ntlmServiceEndpoint(t[0], t[1], protocol); ok {
+			addNTLMEndpoint(t[0], e)
+		}
+	}
+	structs.GlobalIPPortMapLock.Unlock()
+
+	structs.GlobalURLMapLock.Lock()
+	for rootURL, urlE := range structs.GlobalURLMap {
+		if urlE.IP == "" {
+			continue
+		}
+		for pth, webPath := range urlE.WebPaths {
+			if httpNTLMAdvertised(webPath.HeaderHashString) {
+				u := rootURL + pth
+				// 主机名无法解析的域名绑定资产直接连接IP
+				if ipURL, _, ok := uncover.HostBindURL(u); ok {
+					u = ipURL
+				}
+				addNTLMEndpoint(urlE.IP, ntlmEndpoint{Service: "HTTP", Port: fmt.Sprint(urlE.Port), URL: u})
+			}
+		}
+	}
+	structs.GlobalURLMapLock.Unlock()
+}
+
+// NTLMInfoScan 依次尝试主机上的服务，取第一个返回Challenge的结果
+func NTLMInfoScan(info *structs.HostInfo) error {
+	for _, e := range ntlmHostEndpoints(info.Host) {
+		gologger.AuditTimeLogger("[Go] [NTLM-Info] try %s", e.Target(info.Host))
+		data, err := ntlmChallenge(info.Host, e)
+		if err != nil {
+			gologger.AuditTimeLogger("[Go] [NTLM-Info] %s error: %v", e.Target(info.Host), err)
+			continue
+		}
+		result, err := parseNTLMChallenge(data)
+		if err != nil {
+			continue
+		}
+		result.Source = e.Target(info.Host)
+		ntlmReport(info.Host, result)
+		return nil
+	}
+	return ntlmErr
+}
+
+func ntlmChallenge(host string, e ntlmEndpoint) ([]byte, error) {
+	realhost := fmt.Sprintf("%s:%v", host, e.Port)
+	switch e.Service {
+	case "SMB":
+		data, err := smbNTLMChallenge(realhost)
+		if err != nil {
+			return smbv1NTLMChallenge(realhost)
+		}
+		return data, nil
+	case "RDP":
+		return rdpNTLMChallenge(realhost)
+	case "MSSQL":
+		return mssqlNTLMChallenge(realhost)
+	case "SMTP":
+		return smtpNTLMChallenge(&structs.HostInfo{Host: host, Ports: e.Port})
+	case "WinRM":
+		return httpNTLMChallenge(e.URL, "POST", winrmIdentifyBody)
+	case "HTTP":
+		return httpNTLMChallenge(e.URL, "GET", "")
+	}
+	return nil, ntlmErr
+}
+
+func ntlmReport(host string, result *structs.NTLMInfo) {
+	structs.GlobalNTLMInfoMapLock.Lock()
+	structs.GlobalNTLMInfoMap[host] = *result
+	structs.GlobalNTLMInfoMapLock.Unlock()
+
+	uncover.AddTargetKeyword(host, result.NetBIOSComputer, result.NetBIOSDomain, result.DNSComputer, result.DNSDomain)
+	uncover.AddHostName(host, result.DNSComputer, "ntlm "+result.Source)
+
+	name := result.NetBIOSComputer
+	if result.NetBIOSDomain != "" {
+		name = result.NetBIOSDomain + "\\" + name
+	}
+	if result.DNSComputer != "" {
+		name += " (" + result.DNSComputer + ")"
+	}
+	showMsg := fmt.Sprintf("NTLM-Info %s %s %s", result.Source, name, result.OSVersion)
+
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     "NTLM-Info",
+		Security:    "INFO",
+		Target:      result.Source,
+		InfoLeft:    "Host: " + host + "\n" + ntlmInfoString(result),
+		Description: "NTLM认证协商泄露了主机名、域名与系统版本",
+	}, strings.TrimSpace(showMsg))
+}
+
+func ntlmInfoString(result *structs.NTLMInfo) string {
+	var msg string
+	for _, item := range [][2]string{
+		{"NetBIOS Computer", result.NetBIOSComputer},
+		{"NetBIOS Domain", result.NetBIOSDomain},
+		{"DNS Computer", result.DNSComputer},
+		{"DNS Domain", result.DNSDomain},
+		{"DNS Tree", result.DNSTree},
+		{"OS Version", result.OSVersion},
+		{"System Time", result.SystemTime},
+	} {
+		if item[1] != "" {
+			msg += fmt.Sprintf("%s: %s\n", item[0], item[1])
+		}
+	}
+	return msg
+}
+
+// parseNTLMChallenge 在任意协议的响应中查找NTLM CHALLENGE_MESSAGE并解析 TargetInfo
+func parseNTLMChallenge(data []byte) (*structs.NTLMInfo, error) {
+	start := bytes.Index(data, []byte("NTLMSSP\x00\x02\x00\x00\x00"))
+	if start < 0 {
+		return nil, ntlmErr
+	}
+	msg := data[start:]
+	if len(msg) < 48 {
+		return nil, ntlmErr
+	}
+	result := &structs.NTLMInfo{}
+	flags := binary.LittleEndian.Uint32(msg[20:24])
+	// NTLMSSP_NEGOTIATE_VERSION
+	if flags&0x02000000 != 0 && len(msg) >= 56 {
+		result.OSVersion = fmt.Sprintf("Windows %d.%d Build %d", msg[48], msg[49], binary.LittleEndian.Uint16(msg[50:52]))
+	}
+
+	length := int(binary.LittleEndian.Uint16(msg[40:42]))
+	offset := int(binary.LittleEndian.Uint32(msg[44:48]))
+	if offset > 0 && offset+length <= len(msg) {
+		targetInfo := msg[offset : offset+length]
+		for len(targetInfo) >= 4 {
+			id := binary.LittleEndian.Uint16(targetInfo[0:2])
+			l := int(binary.LittleEndian.Uint16(targetInfo[2:4]))
+			if id == 0 || 4+l > len(targetInfo) {
+				break
+			}
+			value := targetInfo[4 : 4+l]
+			targetInfo = targetInfo[4+l:]
+			switch id {
+			case 1:
+				result.NetBIOSComputer = utf16String(value)
+			case 2:
+				result.NetBIOSDomain = utf16String(value)
+			case 3:
+				result.DNSComputer = utf16String(value)
+			case 4:
+				result.DNSDomain = utf16String(value)
+			case 5:
+				result.DNSTree = utf16String(value)
+			case 7:
+				if l == 8 {
+					// FILETIME，1601-01-01起的100纳秒数
+					ft := int64(binary.LittleEndian.Uint64(value))
+					if ft > 116444736000000000 {
+						result.SystemTime = time.Unix(0, (ft-116444736000000000)*100).UTC().Format("2006-01-02 15:04:05 UTC")
+					}
+				}
+			}
+		}
+	}
+
+	if result.NetBIOSComputer == "" && result.DNSComputer == "" && result.OSVersion == "" {
+		return nil, ntlmErr
+	}
+	return result, nil
+}
+
+func utf16String(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// derWrap 生成 ASN.1 DER 的 TLV
+func derWrap(tag byte, contents ...[]byte) []byte {
+	body := bytes.Join(contents, nil)
+	l := len(body)
+	var result []byte
+	switch {
+	case l < 0x80:
+		result = []byte{tag, byte(l)}
+	case l < 0x100:
+		result = []byte{tag, 0x81, byte(l)}
+	default:
+		result = []byte{tag, 0x82, byte(l >> 8), byte(l)}
+	}
+	return append(result, body...)
+}
+
+// spnegoNTLMNegotiate SPNEGO NegTokenInit，内含NTLM协商请求
+func spnegoNTLMNegotiate() []byte {
+	mechTypes := derWrap(0xa0, derWrap(0x30, derWrap(0x06, ntlmsspOID)))
+	mechToken := derWrap(0xa2, derWrap(0x04, ntlmNegotiateMessage))
+	return derWrap(0x60, derWrap(0x06, spnegoOID), derWrap(0xa0, derWrap(0x30, mechTypes, mechToken)))
+}
+
+func ntlmExchange(realhost, name string, conn net.Conn, requests ...[]byte) (reply []byte, err error) {
+	for i, request := range requests {
+		_, err = conn.Write(request)
+		gologger.AuditTimeLogger("[Go] [NTLM-Info] [%s] [%d/%d] Dumped TCP request for %s\n\n%s\n", name, i+1, len(requests), realhost, hex.Dump(request))
+		if err != nil {
+			return
+		}
+		reply, err = ReadBytes(conn)
+		if err != nil {
+			return
+		}
+		gologger.AuditTimeLogger("[Go] [NTLM-Info] [%s] [%d/%d] Dumped TCP response for %s\n\n%s\n", name, i+1, len(requests), realhost, hex.Dump(reply))
+	}
+	return
+}
+
+func ntlmDial(realhost string) (net.Conn, error) {
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	err = conn.SetDeadline(time.Now().Add(time.Duration(6) * time.Second))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func smb2SessionSetupRequest(token []byte) []byte {
+	header := make([]byte, 64)
+	copy(header[0:4], "\xfeSMB")
+	binary.LittleEndian.PutUint16(header[4:6], 64)
+	binary.LittleEndian.PutUint16(header[12:14], 1)
+	binary.LittleEndian.PutUint16(header[14:16], 1)
+	binary.LittleEndian.PutUint64(header[24:32], 1)
+
+	setup := make([]byte, 24)
+	binary.LittleEndian.PutUint16(setup[0:2], 25)
+	setup[3] = 0x01
+	binary.LittleEndian.PutUint16(setup[12:14], 64+24)
+	binary.LittleEndian.PutUint16(setup[14:16], uint16(len(token)))
+
+	packet := append(append(header, setup...), token...)
+	netbios := make([]byte, 4)
+	binary.BigEndian.PutUint32(netbios, uint32(len(packet)))
+	return append(netbios, packet...)
+}
+
+func smbNTLMChallenge(realhost string) ([]byte, error) {
+	conn, err := ntlmDial(realhost)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	reply, err := ntlmExchange(realhost, "SMB2", conn, smb2NegotiateRequest(smb2Dialects))
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) < 4+64 || !bytes.Equal(reply[4:8], []byte("\xfeSMB")) {
+		return nil, netbioserr
+	}
+	return ntlmExchange(realhost, "SMB2", conn, smb2SessionSetupRequest(spnegoNTLMNegotiate()))
+}
+
+// smbv1NTLMChallenge 不支持SMB2的老系统，与 NetBIOS1 相同的SMBv1协商
+func smbv1NTLMChallenge(realhost string) ([]byte, error) {
+	conn, err := ntlmDial(realhost)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return ntlmExchange(realhost, "SMBv1", conn, NegotiateSMBv1Data1, NegotiateSMBv1Data2)
+}
+
+func rdpNTLMChallenge(realhost string) ([]byte, error) {
+	conn, err := ntlmDial(realhost)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	selected, _, err := common.RDPNegotiate(conn, common.RDPProtocolSSL|common.RDPProtocolHybrid)
+	if err != nil {
+		return nil, err
+	}
+	if selected&(common.RDPProtocolHybrid|common.RDPProtocolHybridEx) == 0 {
+		return nil, errors.New("rdp nla not supported")
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+	if err = tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	// CredSSP TSRequest，version 2，negoTokens 中为NTLM协商请求
+	tsRequest := derWrap(0x30,
+		derWrap(0xa0, derWrap(0x02, []byte{0x02})),
+		derWrap(0xa1, derWrap(0x30, derWrap(0x30, derWrap(0xa0, derWrap(0x04, ntlmNegotiateMessage))))))
+	return ntlmExchange(realhost, "RDP", tlsConn, tsRequest)
+}
+
+func tdsPacket(packetType byte, payload []byte) []byte {
+	header := []byte{packetType, 0x01, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00}
+	binary.BigEndian.PutUint16(header[2:4], uint16(8+len(payload)))
+	return append(header, payload...)
+}
+
+func mssqlNTLMChallenge(realhost string) ([]byte, error) {
+	conn, err := ntlmDial(realhost)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// PRELOGIN: VERSION + ENCRYPTION(ENCRYPT_NOT_SUP)
+	prelogin := []byte{
+		0x00, 0x00, 0x0b, 0x00, 0x06,
+		0x01, 0x00, 0x11, 0x00, 0x01,
+		0xff,
+		0x09, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x02,
+	}
+	reply, err := ntlmExchange(realhost, "MSSQL", conn, tdsPacket(0x12, prelogin))
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) < 8 || reply[0] != 0x04 {
+		return nil, errors.New("mssql prelogin failed")
+	}
+	data := reply[8:]
+	for i := 0; i+5 <= len(data) && data[i] != 0xff; i += 5 {
+		offset := int(binary.BigEndian.Uint16(data[i+1 : i+3]))
+		if data[i] == 0x01 && offset < len(data) {
+			// ENCRYPT_ON/ENCRYPT_REQ 需要在TLS中登录
+			if data[offset] == 0x01 || data[offset] == 0x03 {
+				return nil, errors.New("mssql encryption required")
+			}
+		}
+	}
+
+	// LOGIN7，OptionFlags2 设置 fIntSecurity，SSPI 中为NTLM协商请求
+	login := make([]byte, 94)
+	binary.LittleEndian.PutUint32(login[0:4], uint32(94+len(ntlmNegotiateMessage)))
+	binary.LittleEndian.PutUint32(login[4:8], 0x71000001)
+	binary.LittleEndian.PutUint32(login[8:12], 4096)
+	login[24] = 0xe0
+	login[25] = 0x83
+	binary.LittleEndian.PutUint32(login[32:36], 0x0409)
+	for i := 36; i < 72; i += 4 {
+		binary.LittleEndian.PutUint16(login[i:i+2], 94)
+	}
+	binary.LittleEndian.PutUint16(login[78:80], 94)
+	binary.LittleEndian.PutUint16(login[80:82], uint16(len(ntlmNegotiateMessage)))
+	binary.LittleEndian.PutUint16(login[82:84], 94)
+	binary.LittleEndian.PutUint16(login[86:88], 94)
+	login = append(login, ntlmNegotiateMessage...)
+	return ntlmExchange(realhost, "MSSQL", conn, tdsPacket(0x10, login))
+}
+
+func smtpNTLMChallenge(info *structs.HostInfo) ([]byte, error) {
+	s, err := newMailSession(info, mailImplicitTLS(info))
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	_, _, err = s.smtpHello()
+	if err != nil {
+		return nil, err
+	}
+	code, lines, err := s.smtpCmd("AUTH NTLM " + base64.StdEncoding.EncodeToString(ntlmNegotiateMessage))
+	if err != nil {
+		return nil, err
+	}
+	if code != 334 || len(lines) == 0 {
+		return nil, fmt.Errorf("smtp auth ntlm %v", code)
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(lines[0]))
+}
+
+// httpNTLMChallenge 依次以 NTLM、Negotiate 认证头发送协商请求，从 WWW-Authenticate 中取回Challenge
+func httpNTLMChallenge(u, method, body string) ([]byte, error) {
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(6) * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	token := base64.StdEncoding.EncodeToString(ntlmNegotiateMessage)
+	for _, scheme := range []string{"NTLM", "Negotiate"} {
+		req, err := http.NewRequest(method, u, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if method == "POST" {
+			req.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+		}
+		req.Header.Set("Authorization", scheme+" "+token)
+		gologger.AuditTimeLogger("[Go] [NTLM-Info] [HTTP] %s %s Authorization: %s %s", method, u, scheme, token)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		for _, value := range resp.Header.Values("WWW-Authenticate") {
+			gologger.AuditTimeLogger("[Go] [NTLM-Info] [HTTP] %s WWW-Authenticate: %s", u, value)
+			if !strings.HasPrefix(strings.ToLower(value), strings.ToLower(scheme)+" ") {
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[len(scheme)+1:]))
+			if err == nil && bytes.Contains(data, []byte("NTLMSSP")) {
+				return data, nil
+			}
+		}
+	}
+	return nil, ntlmErr
+}
@@ -7,7 +7,10 @@ import (
 	_ "embed"
 	"fmt"
 	"github.com/projectdiscovery/gologger"
-	_ "github.com/sijms/go-ora/v2"
+	go_ora "github.com/sijms/go-ora/v2"
+	"regexp"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -21,6 +24,8 @@ func OracleScan(info *structs.HostInfo) (tmperr error) {
 	starttime := time.Now().Unix()
 
 	userPasswdList := sortUserPassword(info, oracleUserPasswdDict, []string{"oracle"})
+	// 只有确定连接目标前的口令会尝试多个目标
+	targets := len(oracleConnectTargets(info.Host, info.Ports))
 
 	for _, userPass := range userPasswdList {
 		flag, err := OracleConn(info, userPass.UserName, userPass.Password)
@@ -31,7 +36,7 @@ func OracleScan(info *structs.HostInfo) (tmperr error) {
 			if CheckErrs(err) {
 				return err
 			}
-			if time.Now().Unix()-starttime > (int64(len(userPasswdList)) * 6) {
+			if time.Now().Unix()-starttime > (int64(len(userPasswdList)+targets) * 6) {
 				gologger.AuditTimeLogger("[Go] [Oracle] Timeout,break! %s:%v", info.Host, info.Ports)
 				return err
 			}
@@ -42,9 +47,81 @@ func OracleScan(info *structs.HostInfo) (tmperr error) {
 }
 
 func OracleConn(info *structs.HostInfo, user string, pass string) (flag bool, err error) {
+	return oracleLogin(info.Host+":"+info.Ports, oracleConnectTargets(info.Host, info.Ports), func(target oracleTarget) (bool, error) {
+		return oracleConnTarget(info, target, user, pass)
+	})
+}
+
+// ORA-01017 口令错误、ORA-28000 账号锁定等认证阶段的错误，说明 Listener 已接受该服务名/SID
+var oracleAuthErrs = map[string]bool{
+	"01017": true, "01005": true, "01045": true, "28000": true, "28001": true, "28009": true,
+}
+
+var oraErrRe = regexp.MustCompile(`ORA-(\d{5})`)
+
+func oracleAuthReached(err error) bool {
+	if err == nil {
+		return false
+	}
+	m := oraErrRe.FindStringSubmatch(err.Error())
+	return m != nil && oracleAuthErrs[m[1]]
+}
+
+type oracleLoginEntry struct {
+	sync.Mutex
+	resolved bool
+	target   oracleTarget
+}
+
+// 每个 Listener 只确定一次登录使用的连接目标，避免每组口令在所有目标上各消耗一次 FAILED_LOGIN_ATTEMPTS
+var oracleLoginMap = make(map[string]*oracleLoginEntry)
+var oracleLoginLock sync.Mutex
+
+// oracleLogin 依次尝试连接目标，首个到达认证阶段的目标缓存后，之后的口令只尝试该目标
+func oracleLogin(realhost string, targets []oracleTarget, try func(oracleTarget) (bool, error)) (flag bool, err error) {
+	oracleLoginLock.Lock()
+	e, ok := oracleLoginMap[realhost]
+	if !ok {
+		e = &oracleLoginEntry{}
+		oracleLoginMap[realhost] = e
+	}
+	oracleLoginLock.Unlock()
+
+	e.Lock()
+	if e.resolved {
+		e.Unlock()
+		return try(e.target)
+	}
+	defer e.Unlock()
+	for _, target := range targets {
+		flag, err = try(target)
+		if flag || oracleAuthReached(err) {
+			gologger.AuditTimeLogger("[Go] [Oracle] %s use target %v", realhost, target)
+			e.target, e.resolved = target, true
+			return flag, err
+		}
+		if CheckErrs(err) {
+			// 网络错误时不缓存，下次重新确定
+			return flag, err
+		}
+	}
+	// 均未到达认证阶段，之后只尝试第一个目标
+	if len(targets) > 0 {
+		e.target, e.resolved = targets[0], true
+	}
+	return flag, err
+}
+
+func oracleConnTarget(info *structs.HostInfo, target oracleTarget, user string, pass string) (flag bool, err error) {
 	flag = false
 	Host, Port, Username, Password := info.Host, info.Ports, user, pass
-	dataSourceName := fmt.Sprintf("oracle://%s:%s@%s:%s/orcl", Username, Password, Host, Port)
+	portNum, _ := strconv.Atoi(Port)
+	var dataSourceName string
+	if target.Kind == "SID" {
+		dataSourceName = go_ora.BuildUrl(Host, portNum, "", Username, Password, map[string]string{"SID": target.Name})
+	} else {
+		dataSourceName = go_ora.BuildUrl(Host, portNum, target.Name, Username, Password, nil)
+	}
 	gologger.AuditTimeLogger("[Go] [Oracle-Brute] start try %s", dataSourceName)
 	db, err := sql.Open("oracle", dataSourceName)
 	if err == nil {
@@ -54,10 +131,11 @@ func OracleConn(info *structs.HostInfo, user string, pass string) (flag bool, er
 		defer db.Close()
 		err = db.Ping()
 		if err == nil {
-			result := fmt.Sprintf("Oracle://%v:%v:%v %v", Host, Port, Username, Password)
+			AddCredential("Oracle", Host, Port, Username, Password, info.CredReuse)
+			result := fmt.Sprintf("Oracle://%v:%v:%v %v [%v]", Host, Port, Username, Password, target)
 			// gologger.Silent().Msg("[GoPoc] " + result)
 
-			showData := fmt.Sprintf("Host: %v:%v\nUsername: %v\nPassword: %v\n", Host, Port, Username, Password)
+			showData := fmt.Sprintf("Host: %v:%v\nUsername: %v\nPassword: %v\nTarget: %v\n", Host, Port, Username, Password, target)
 
 			ddout.FormatOutput(ddout.OutputMessage{
 				Type:     "GoPoc",
@@ -85,6 +163,10 @@ func OracleConn(info *structs.HostInfo, user string, pass string) (flag bool, er
 				Description: "Oracle弱口令",
 			})
 
+			if structs.GlobalConfig.DBPostAuth {
+				reportDBPostAuth(oraclePostAuth(db, Host+":"+Port))
+			}
+
 			flag = true
 		}
 	}
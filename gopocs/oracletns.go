@@ -0,0 +1,345 @@
+package gopocs
+
+import (
+	"dddd/common"
+	"dddd/structs"
+	_ "embed"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Oracle TNS Listener 探测：版本、STATUS/SERVICES 命令泄露的服务名，以及SID/服务名字典枚举，
+// 结果作为 Oracle 爆破的连接目标
+
+//go:embed dict/oracle_sid.txt
+var oracleSIDDict string
+
+// TNS 包类型
+const (
+	tnsConnect  = 0x01
+	tnsAccept   = 0x02
+	tnsRefuse   = 0x04
+	tnsRedirect = 0x05
+	tnsData     = 0x06
+	tnsResend   = 0x0b
+)
+
+var tnsPacketErr = errors.New("tns packet error")
+
+var (
+	tnsErrRe      = regexp.MustCompile(`\(ERR=(\d+)\)`)
+	tnsVsnRe      = regexp.MustCompile(`\(VSNNUM=(\d+)\)`)
+	tnsVersionRe  = regexp.MustCompile(`(?i)TNSLSNR for ([^:]+): Version ([0-9.]+)`)
+	tnsServiceRe  = regexp.MustCompile(`(?i)\(SERVICE_NAME=([^)]+)\)`)
+	tnsInstanceRe = regexp.MustCompile(`(?i)\(INSTANCE_NAME=([^)]+)\)`)
+)
+
+// 服务名/SID存在但暂时无法连接时返回的错误码，如 ORA-12516 无可用handler、ORA-12528 实例被限制
+var tnsValidTargetErrs = map[string]bool{
+	"12500": true, "12511": true, "12516": true, "12518": true, "12519": true,
+	"12520": true, "12521": true, "12526": true, "12527": true, "12528": true,
+}
+
+type oracleTarget struct {
+	Kind string // SID 或 SERVICE_NAME
+	Name string
+}
+
+func (t oracleTarget) String() string {
+	return t.Kind + "=" + t.Name
+}
+
+type OracleListenerInfo struct {
+	Version  string
+	OS       string
+	Status   string // STATUS 命令结果：leaked / ERR=1189 等
+	Services []string
+	SIDs     []string
+	// Targets 可用于登录的连接目标，泄露的服务名优先，其次为字典枚举结果
+	Targets []oracleTarget
+	Bruted  bool
+}
+
+type oracleListenerEntry struct {
+	once sync.Once
+	info *OracleListenerInfo
+}
+
+// 每个 Listener 只探测一次，Oracle-TNS 与 Oracle-Crack 共用结果
+var oracleListenerMap = make(map[string]*oracleListenerEntry)
+var oracleListenerLock sync.Mutex
+
+func oracleListener(host, port string) *OracleListenerInfo {
+	realhost := fmt.Sprintf("%s:%v", host, port)
+	oracleListenerLock.Lock()
+	e, ok := oracleListenerMap[realhost]
+	if !ok {
+		e = &oracleListenerEntry{}
+		oracleListenerMap[realhost] = e
+	}
+	oracleListenerLock.Unlock()
+	e.once.Do(func() {
+		e.info = oracleProbe(host, port)
+	})
+	return e.info
+}
+
+// tnsConnectPacket TNS CONNECT 包，连接数据直接跟在固定头之后
+func tnsConnectPacket(connectData string) []byte {
+	const offset = 58
+	p := make([]byte, offset, offset+len(connectData))
+	binary.BigEndian.PutUint16(p[0:2], uint16(offset+len(connectData)))
+	p[4] = tnsConnect
+	binary.BigEndian.PutUint16(p[8:10], 0x0136)  // version 310
+	binary.BigEndian.PutUint16(p[10:12], 0x012c) // compatible 300
+	binary.BigEndian.PutUint16(p[14:16], 0x0800) // SDU
+	binary.BigEndian.PutUint16(p[16:18], 0x7fff) // TDU
+	binary.BigEndian.PutUint16(p[18:20], 0x7f08) // NT protocol characteristics
+	binary.BigEndian.PutUint16(p[22:24], 0x0001) // value of 1 in hardware
+	binary.BigEndian.PutUint16(p[24:26], uint16(len(connectData)))
+	binary.BigEndian.PutUint16(p[26:28], offset)
+	return append(p, connectData...)
+}
+
+func tnsReadPacket(conn net.Conn) (byte, []byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[0:2]))
+	if length < 8 {
+		return 0, nil, tnsPacketErr
+	}
+	body := make([]byte, length-8)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return 0, nil, err
+	}
+	return header[4], body, nil
+}
+
+// tnsRequest 发送 CONNECT 包，返回第一个响应包的类型与文本，more 为 true 时继续读取后续的 DATA 包
+func tnsRequest(realhost, connectData string, more bool) (byte, string, error) {
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+	if err != nil {
+		return 0, "", err
+	}
+	defer conn.Close()
+	err = conn.SetDeadline(time.Now().Add(time.Duration(6) * time.Second))
+	if err != nil {
+		return 0, "", err
+	}
+
+	packet := tnsConnectPacket(connectData)
+	gologger.AuditTimeLogger("[Go] [Oracle-TNS] Dumped TCP request for %s\n\n%s\n", realhost, hex.Dump(packet))
+	if _, err = conn.Write(packet); err != nil {
+		return 0, "", err
+	}
+	ptype, body, err := tnsReadPacket(conn)
+	// 要求客户端重发 CONNECT
+	if err == nil && ptype == tnsResend {
+		if _, err = conn.Write(packet); err != nil {
+			return 0, "", err
+		}
+		ptype, body, err = tnsReadPacket(conn)
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	data := string(body)
+	// STATUS/SERVICES 的内容可能分多个 DATA 包返回
+	for i := 0; more && i < 16 && (ptype == tnsAccept || ptype == tnsData); i++ {
+		t, b, err := tnsReadPacket(conn)
+		if err != nil {
+			break
+		}
+		data += string(b)
+		if t != tnsData {
+			break
+		}
+	}
+	gologger.AuditTimeLogger("[Go] [Oracle-TNS] Dumped TCP response for %s\n\n%s\n", realhost, hex.Dump([]byte(data)))
+	return ptype, data, nil
+}
+
+func tnsErr(data string) string {
+	if m := tnsErrRe.FindStringSubmatch(data); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// oracleVersion VSNNUM 为十进制的版本号，如 186647552 = 0x0B200200 = 11.2.0.2.0
+func oracleVersion(vsnnum string) string {
+	v, err := strconv.ParseUint(vsnnum, 10, 32)
+	if err != nil || v == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d.%d.%d", v>>24, (v>>20)&0xf, (v>>12)&0xff, (v>>8)&0xf, v&0xff)
+}
+
+func tnsCommand(command string) string {
+	return fmt.Sprintf("(CONNECT_DATA=(COMMAND=%s)(ARGUMENTS=64)(SERVICE=LISTENER)(VERSION=169869568))", command)
+}
+
+// tnsTargetExists 以 SID 或 SERVICE_NAME 发起连接，根据 Listener 的响应判断是否存在
+func tnsTargetExists(realhost string, t oracleTarget) (bool, error) {
+	host, port, _ := net.SplitHostPort(realhost)
+	connectData := fmt.Sprintf("(DESCRIPTION=(CONNECT_DATA=(%s)(CID=(PROGRAM=dddd)(HOST=dddd)(USER=dddd)))(ADDRESS=(PROTOCOL=TCP)(HOST=%s)(PORT=%s)))",
+		t.String(), host, port)
+	ptype, data, err := tnsRequest(realhost, connectData, false)
+	if err != nil {
+		return false, err
+	}
+	switch ptype {
+	case tnsAccept, tnsRedirect:
+		return true, nil
+	case tnsRefuse:
+		return tnsValidTargetErrs[tnsErr(data)], nil
+	}
+	return false, nil
+}
+
+func oracleProbe(host, port string) *OracleListenerInfo {
+	realhost := fmt.Sprintf("%s:%v", host, port)
+	info := &OracleListenerInfo{}
+
+	ptype, data, err := tnsRequest(realhost, tnsCommand("version"), true)
+	if err != nil {
+		gologger.AuditTimeLogger("[Go] [Oracle-TNS] %s error: %v", realhost, err)
+		return info
+	}
+	if m := tnsVersionRe.FindStringSubmatch(data); m != nil {
+		info.OS, info.Version = strings.TrimSpace(m[1]), m[2]
+	} else if m = tnsVsnRe.FindStringSubmatch(data); m != nil {
+		info.Version = oracleVersion(m[1])
+	}
+	gologger.AuditTimeLogger("[Go] [Oracle-TNS] %s version packet type %d: %s", realhost, ptype, info.Version)
+
+	// 10g 之后默认禁止远程执行 STATUS/SERVICES，返回 ERR=1189 或 12618
+	for _, command := range []string{"status", "services"} {
+		_, data, err = tnsRequest(realhost, tnsCommand(command), true)
+		if err != nil {
+			continue
+		}
+		services := tnsServiceRe.FindAllStringSubmatch(data, -1)
+		instances := tnsInstanceRe.FindAllStringSubmatch(data, -1)
+		if len(services) == 0 && len(instances) == 0 {
+			if code := tnsErr(data); code != "" && code != "0" && info.Status == "" {
+				info.Status = "ERR=" + code
+			}
+			continue
+		}
+		info.Status = "leaked"
+		for _, m := range services {
+			info.Services = append(info.Services, m[1])
+		}
+		for _, m := range instances {
+			info.SIDs = append(info.SIDs, m[1])
+		}
+	}
+	info.Services = removeDuplicateString(info.Services)
+	info.SIDs = removeDuplicateString(info.SIDs)
+
+	for _, name := range info.Services {
+		// 扩展程序与XDB服务不能用于登录
+		upper := strings.ToUpper(name)
+		if strings.HasPrefix(upper, "PLSEXTPROC") || strings.HasSuffix(upper, "XDB") {
+			continue
+		}
+		info.Targets = append(info.Targets, oracleTarget{Kind: "SERVICE_NAME", Name: name})
+	}
+	for _, name := range info.SIDs {
+		if !strings.HasPrefix(strings.ToUpper(name), "PLSEXTPROC") {
+			info.Targets = append(info.Targets, oracleTarget{Kind: "SID", Name: name})
+		}
+	}
+	if len(info.Targets) > 0 {
+		return info
+	}
+
+	// 未泄露时使用字典枚举
+	info.Bruted = true
+	for _, name := range strings.Split(strings.ReplaceAll(oracleSIDDict, "\r\n", "\n"), "\n") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		for _, kind := range []string{"SID", "SERVICE_NAME"} {
+			t := oracleTarget{Kind: kind, Name: name}
+			ok, err := tnsTargetExists(realhost, t)
+			if err != nil {
+				gologger.AuditTimeLogger("[Go] [Oracle-TNS] %s %v error: %v", realhost, t, err)
+				if CheckErrs(err) {
+					return info
+				}
+				continue
+			}
+			if ok {
+				gologger.AuditTimeLogger("[Go] [Oracle-TNS] %s found %v", realhost, t)
+				info.Targets = append(info.Targets, t)
+			}
+		}
+	}
+	return info
+}
+
+// oracleConnectTargets Oracle 爆破使用的候选连接目标，由 oracleLogin 确定实际使用的目标，
+// 未探测到时使用默认的 orcl 服务名
+func oracleConnectTargets(host, port string) []oracleTarget {
+	if info := oracleListener(host, port); len(info.Targets) > 0 {
+		return info.Targets
+	}
+	return []oracleTarget{{Kind: "SERVICE_NAME", Name: "orcl"}}
+}
+
+func OracleTNSScan(info *structs.HostInfo) error {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	l := oracleListener(info.Host, info.Ports)
+	if l.Version == "" && l.Status == "" && len(l.Targets) == 0 {
+		return nil
+	}
+
+	var targets []string
+	for _, t := range l.Targets {
+		targets = append(targets, t.String())
+	}
+	showData := fmt.Sprintf("Host: %v\nVersion: %v\nOS: %v\nStatus: %v\n", realhost, l.Version, l.OS, l.Status)
+	if len(l.Services) > 0 {
+		showData += fmt.Sprintf("Services: %v\n", strings.Join(l.Services, ","))
+	}
+	if len(l.SIDs) > 0 {
+		showData += fmt.Sprintf("Instances: %v\n", strings.Join(l.SIDs, ","))
+	}
+	if len(targets) > 0 {
+		source := "listener"
+		if l.Bruted {
+			source = "dictionary"
+		}
+		showData += fmt.Sprintf("Targets (%v): %v\n", source, strings.Join(targets, ","))
+	}
+
+	security := "INFO"
+	description := "Oracle TNS Listener 信息"
+	if l.Status == "leaked" {
+		security = "MEDIUM"
+		description = "Oracle TNS Listener 未授权执行 STATUS/SERVICES 命令，泄露服务名与实例信息"
+	}
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     "Oracle-TNS",
+		Security:    security,
+		Target:      realhost,
+		InfoLeft:    showData,
+		Description: description,
+	}, fmt.Sprintf("Oracle-TNS %v [%v] [%v]", realhost, l.Version, strings.Join(targets, ",")))
+	return nil
+}
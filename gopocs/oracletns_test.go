@@ -0,0 +1,113 @@
+package gopocs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOracleConnectTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []oracleTarget
+		want    []oracleTarget
+	}{
+		{"default", nil, []oracleTarget{{"SERVICE_NAME", "orcl"}}},
+		{"single", []oracleTarget{{"SID", "XE"}}, []oracleTarget{{"SID", "XE"}}},
+		{"all", []oracleTarget{{"SERVICE_NAME", "PROD"}, {"SERVICE_NAME", "prod.corp"}, {"SID", "TEST"}},
+			[]oracleTarget{{"SERVICE_NAME", "PROD"}, {"SERVICE_NAME", "prod.corp"}, {"SID", "TEST"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 预置探测结果，不发起连接
+			e := &oracleListenerEntry{info: &OracleListenerInfo{Targets: tt.targets}}
+			e.once.Do(func() {})
+			oracleListenerLock.Lock()
+			oracleListenerMap["10.0.0.1:"+tt.name] = e
+			oracleListenerLock.Unlock()
+
+			if got := oracleConnectTargets("10.0.0.1", tt.name); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("oracleConnectTargets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTnsConnectPacket(t *testing.T) {
+	for _, data := range []string{"", "(CONNECT_DATA=(COMMAND=version))", "(DESCRIPTION=(CONNECT_DATA=(SID=XE)))"} {
+		p := tnsConnectPacket(data)
+		if int(p[0])<<8|int(p[1]) != len(p) || p[4] != tnsConnect || string(p[58:]) != data {
+			t.Errorf("tnsConnectPacket(%q) = % x", data, p[:10])
+		}
+	}
+}
+
+func TestOracleLogin(t *testing.T) {
+	targets := []oracleTarget{{"SERVICE_NAME", "PROD"}, {"SERVICE_NAME", "prod.corp"}, {"SID", "TEST"}}
+	tests := []struct {
+		name string
+		// errs 各目标首次登录返回的错误
+		errs map[string]error
+		// tries 首组口令依次尝试的目标，之后的口令只尝试 cached
+		tries  []string
+		cached string
+	}{
+		{"first", map[string]error{
+			"SERVICE_NAME=PROD": errors.New("ORA-01017: invalid username/password; logon denied"),
+		}, []string{"SERVICE_NAME=PROD"}, "SERVICE_NAME=PROD"},
+		{"second", map[string]error{
+			"SERVICE_NAME=PROD":      errors.New("ORA-12514: TNS:listener does not currently know of service requested"),
+			"SERVICE_NAME=prod.corp": errors.New("ORA-28000: the account is locked"),
+		}, []string{"SERVICE_NAME=PROD", "SERVICE_NAME=prod.corp"}, "SERVICE_NAME=prod.corp"},
+		{"none", map[string]error{
+			"SERVICE_NAME=PROD":      errors.New("ORA-12514: TNS:listener does not currently know of service requested"),
+			"SERVICE_NAME=prod.corp": errors.New("ORA-12514: TNS:listener does not currently know of service requested"),
+			"SID=TEST":               errors.New("ORA-12505: TNS:listener does not currently know of SID given in connect descriptor"),
+		}, []string{"SERVICE_NAME=PROD", "SERVICE_NAME=prod.corp", "SID=TEST"}, "SERVICE_NAME=PROD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tried []string
+			try := func(target oracleTarget) (bool, error) {
+				tried = append(tried, target.String())
+				if err, ok := tt.errs[target.String()]; ok {
+					return false, err
+				}
+				return false, errors.New("ORA-01017: invalid username/password; logon denied")
+			}
+			realhost := "10.0.0.2:" + tt.name
+			_, _ = oracleLogin(realhost, targets, try)
+			if !reflect.DeepEqual(tried, tt.tries) {
+				t.Errorf("first login tried %v, want %v", tried, tt.tries)
+			}
+			for i := 0; i < 2; i++ {
+				tried = nil
+				_, _ = oracleLogin(realhost, targets, try)
+				if !reflect.DeepEqual(tried, []string{tt.cached}) {
+					t.Errorf("login %d tried %v, want [%v]", i+2, tried, tt.cached)
+				}
+			}
+		})
+	}
+}
+
+func TestOracleLoginNetworkError(t *testing.T) {
+	targets := []oracleTarget{{"SERVICE_NAME", "PROD"}, {"SID", "TEST"}}
+	fail := true
+	var tried []string
+	try := func(target oracleTarget) (bool, error) {
+		tried = append(tried, target.String())
+		if fail {
+			return false, errors.New("dial tcp 10.0.0.3:1521: i/o timeout")
+		}
+		return target.Name == "TEST", nil
+	}
+	// 网络错误时不缓存目标
+	if flag, _ := oracleLogin("10.0.0.3:1521", targets, try); flag || len(tried) != 1 {
+		t.Fatalf("oracleLogin() = %v, tried %v", flag, tried)
+	}
+	fail, tried = false, nil
+	if flag, _ := oracleLogin("10.0.0.3:1521", targets, try); !flag || !reflect.DeepEqual(tried, []string{"SERVICE_NAME=PROD", "SID=TEST"}) {
+		t.Errorf("oracleLogin() = %v, tried %v", flag, tried)
+	}
+}
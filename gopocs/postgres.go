@@ -59,6 +59,7 @@ func PostgresConn(info *structs.HostInfo, user string, pass string) (flag bool,
 		defer db.Close()
 		err = db.Ping()
 		if err == nil {
+			AddCredential("PostgreSQL", Host, Port, Username, Password, info.CredReuse)
 			result := fmt.Sprintf("PostgreSQL://%v:%v %v %v", Host, Port, Username, Password)
 			// gologger.Silent().Msg("[GoPoc] " + result)
 
@@ -90,6 +91,11 @@ func PostgresConn(info *structs.HostInfo, user string, pass string) (flag bool,
 				Description: "PostgreSQL弱口令",
 			})
 
+			if structs.GlobalConfig.DBPostAuth {
+				reportDBPostAuth(postgresPostAuth(db, Host+":"+Port))
+			}
+
+			flag = true
 		}
 	}
 	return flag, err
@@ -20,6 +20,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -54,7 +55,7 @@ func RdpScan(info *structs.HostInfo) (tmperr error) {
 
 	for i := 0; i < 1; i++ {
 		wg.Add(1)
-		go worker(info.Host, "", port, &wg, brlist, &signal, &num, all, &mutex, 6)
+		go worker(info.Host, "", port, &wg, brlist, &signal, &num, all, &mutex, 6, info.CredReuse)
 	}
 
 	close(brlist)
@@ -68,7 +69,50 @@ func RdpScan(info *structs.HostInfo) (tmperr error) {
 	return tmperr
 }
 
-func worker(host, domain string, port int, wg *sync.WaitGroup, brlist chan Brutelist, signal *bool, num *int, all int, mutex *sync.Mutex, timeout int64) {
+// RDPSecurityScan 输出 common.RDPSecurityCheckTarget 的安全层分析结果
+func RDPSecurityScan(info *structs.HostInfo) error {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	structs.GlobalRDPInfoMapLock.Lock()
+	rdpInfo, ok := structs.GlobalRDPInfoMap[realhost]
+	structs.GlobalRDPInfoMapLock.Unlock()
+	if !ok {
+		return nil
+	}
+
+	security := "INFO"
+	description := "RDP安全层信息"
+	if rdpInfo.LegacyRDP {
+		security = "MEDIUM"
+		description = "RDP允许标准RDP安全层(无TLS)，未强制NLA，存在中间人与未认证攻击面"
+	} else if !rdpInfo.NLARequired {
+		security = "LOW"
+		description = "RDP未强制NLA(CredSSP)，未认证即可访问登录界面"
+	}
+
+	showData := fmt.Sprintf("Host: %v\nProtocols: %v\nNLA Required: %v\nLegacy RDP Security: %v\n",
+		realhost, strings.Join(rdpInfo.Protocols, ", "), rdpInfo.NLARequired, rdpInfo.LegacyRDP)
+	if rdpInfo.CertCN != "" {
+		showData += fmt.Sprintf("Cert CN: %v\nCert Issuer: %v\nCert Expire: %v\n", rdpInfo.CertCN, rdpInfo.CertIssuer, rdpInfo.CertExpire)
+	}
+	if len(rdpInfo.CertSAN) > 0 {
+		showData += fmt.Sprintf("Cert SAN: %v\n", strings.Join(rdpInfo.CertSAN, ", "))
+	}
+
+	showMsg := fmt.Sprintf("RDP-Security %s [%s] NLA:%v", realhost, strings.Join(rdpInfo.Protocols, ","), rdpInfo.NLARequired)
+	if rdpInfo.CertCN != "" {
+		showMsg += " CN:" + rdpInfo.CertCN
+	}
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     "RDP-Security",
+		Security:    security,
+		Target:      realhost,
+		InfoLeft:    showData,
+		Description: description,
+	}, showMsg)
+	return nil
+}
+
+func worker(host, domain string, port int, wg *sync.WaitGroup, brlist chan Brutelist, signal *bool, num *int, all int, mutex *sync.Mutex, timeout int64, reuse bool) {
 	defer wg.Done()
 	for one := range brlist {
 		if *signal == true {
@@ -80,48 +124,52 @@ func worker(host, domain string, port int, wg *sync.WaitGroup, brlist chan Brute
 
 		flag, err := RdpConn(host, domain, user, pass, port, timeout)
 		if flag == true && err == nil {
-			var result string
-			if domain != "" {
-				result = fmt.Sprintf("RDP://%v:%v:%v\\%v %v", host, port, domain, user, pass)
-			} else {
-				result = fmt.Sprintf("RDP://%v:%v:%v %v", host, port, user, pass)
-			}
-
-			// gologger.Silent().Msg("[GoPoc] " + result)
-			showData := fmt.Sprintf("Host: %v:%v\nUsername: %v\nPassword: %v\n", host, port, user, pass)
-
-			ddout.FormatOutput(ddout.OutputMessage{
-				Type:     "GoPoc",
-				IP:       "",
-				IPs:      nil,
-				Port:     "",
-				Protocol: "",
-				Web:      ddout.WebInfo{},
-				Finger:   nil,
-				Domain:   "",
-				GoPoc: ddout.GoPocsResultType{PocName: "RDP-Login",
-					Security:    "CRITICAL",
-					Target:      fmt.Sprintf("%v:%v", host, port),
-					InfoLeft:    showData,
-					Description: "RDP弱口令",
-					ShowMsg:     result},
-				AdditionalMsg: "",
-			})
-
-			GoPocWriteResult(structs.GoPocsResultType{
-				PocName:     "RDP-Login",
-				Security:    "CRITICAL",
-				Target:      fmt.Sprintf("%v:%v", host, port),
-				InfoLeft:    showData,
-				Description: "RDP弱口令",
-			})
-
+			rdpLoginResult(host, domain, port, user, pass, reuse)
 			*signal = true
 			return
 		}
 	}
 }
 
+func rdpLoginResult(host, domain string, port int, user, pass string, reuse bool) {
+	AddCredential("RDP", host, strconv.Itoa(port), user, pass, reuse)
+	var result string
+	if domain != "" {
+		result = fmt.Sprintf("RDP://%v:%v:%v\\%v %v", host, port, domain, user, pass)
+	} else {
+		result = fmt.Sprintf("RDP://%v:%v:%v %v", host, port, user, pass)
+	}
+
+	// gologger.Silent().Msg("[GoPoc] " + result)
+	showData := fmt.Sprintf("Host: %v:%v\nUsername: %v\nPassword: %v\n", host, port, user, pass)
+
+	ddout.FormatOutput(ddout.OutputMessage{
+		Type:     "GoPoc",
+		IP:       "",
+		IPs:      nil,
+		Port:     "",
+		Protocol: "",
+		Web:      ddout.WebInfo{},
+		Finger:   nil,
+		Domain:   "",
+		GoPoc: ddout.GoPocsResultType{PocName: "RDP-Login",
+			Security:    "CRITICAL",
+			Target:      fmt.Sprintf("%v:%v", host, port),
+			InfoLeft:    showData,
+			Description: "RDP弱口令",
+			ShowMsg:     result},
+		AdditionalMsg: "",
+	})
+
+	GoPocWriteResult(structs.GoPocsResultType{
+		PocName:     "RDP-Login",
+		Security:    "CRITICAL",
+		Target:      fmt.Sprintf("%v:%v", host, port),
+		InfoLeft:    showData,
+		Description: "RDP弱口令",
+	})
+}
+
 func incrNum(num *int, mutex *sync.Mutex) {
 	mutex.Lock()
 	*num = *num + 1
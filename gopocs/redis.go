@@ -4,13 +4,11 @@ import (
 	"dddd/common"
 	"dddd/ddout"
 	"dddd/structs"
-	"dddd/utils"
 	_ "embed"
 	"encoding/hex"
 	"fmt"
 	"github.com/projectdiscovery/gologger"
 	"net"
-	"os"
 	"strings"
 	"time"
 )
@@ -18,71 +16,27 @@ import (
 //go:embed dict/redis.txt
 var redisUserPasswdDict string
 
+//go:embed dict/redis_acl.txt
+var redisACLUserPasswdDict string
+
 func RedisScan(info *structs.HostInfo) (tmperr error) {
 	starttime := time.Now().Unix()
-	flagA, errA := RedisUnauth(info)
-	if flagA == true && errA == nil {
-		return errA
-	}
-
-	if structs.GlobalConfig.NoServiceBruteForce {
-		return errA
-	}
-
-	var upList []string
-
-	if structs.GlobalConfig.Password != "" {
-		upList = append(upList, structs.GlobalConfig.Password)
-	} else if structs.GlobalConfig.PasswordFile != "" {
-		b, err := os.ReadFile(structs.GlobalConfig.PasswordFile)
-		if err == nil {
-			t := strings.ReplaceAll(string(b), "\r\n", "\n")
-			for _, v := range strings.Split(t, "\n") {
-				if !strings.Contains(v, " : ") {
-					continue
-				}
-				upList = append(upList, v)
-			}
-		}
-	} else {
-		for _, v := range info.UserPass {
-			_, p := splitUserPass(v)
-			upList = append(upList, p)
+	if !info.CredReuse {
+		flagA, errA := RedisUnauth(info)
+		if flagA == true && errA == nil {
+			return errA
 		}
-		for _, v := range strings.Split(strings.ReplaceAll(redisUserPasswdDict, "\r\n", "\n"), "\n") {
-			upList = append(upList, v)
-		}
-	}
 
-	upList = utils.RemoveDuplicateElement(upList)
-
-	var passwdList []string
-	// 统计变形后的字典
-	for _, oriPass := range upList {
-		oriPass = strings.TrimSuffix(oriPass, "\r")
-		if strings.Contains(oriPass, "{{key}}") {
-			for _, sKey := range info.InfoStr {
-				newKeys := generateKeys(sKey)
-				for _, nKey := range newKeys {
-					newPass := strings.Replace(oriPass, "{{key}}", nKey, -1)
-					passwdList = append(passwdList, newPass)
-				}
-
-			}
-			newKeys := generateKeys("redis")
-			for _, nKey := range newKeys {
-				newPass := strings.Replace(oriPass, "{{key}}", nKey, -1)
-				passwdList = append(passwdList, newPass)
-			}
-		} else {
-			passwdList = append(passwdList, oriPass)
+		if structs.GlobalConfig.NoServiceBruteForce {
+			return errA
 		}
 	}
-	passwdList = utils.RemoveDuplicateElement(passwdList)
+
+	passwdList := sortPassword(info, redisUserPasswdDict, []string{"redis"})
 
 	for _, pass := range passwdList {
 		gologger.AuditTimeLogger("[Go] [Redis-Brute] try %s:%v Pass:%s", info.Host, info.Ports, pass)
-		flag, err := RedisConn(info, pass)
+		flag, err := RedisConn(info, "", pass)
 		if flag == true && err == nil {
 			return err
 		} else {
@@ -96,71 +50,105 @@ func RedisScan(info *structs.HostInfo) (tmperr error) {
 			}
 		}
 	}
+
+	// Redis 6 ACL 用户
+	if redisACLSupported(info) {
+		starttime = time.Now().Unix()
+		userPasswdList := sortUserPassword(info, redisACLUserPasswdDict, []string{"redis"})
+		for _, up := range userPasswdList {
+			if up.UserName == "" {
+				continue
+			}
+			gologger.AuditTimeLogger("[Go] [Redis-Brute] try %s:%v User:%s Pass:%s", info.Host, info.Ports, up.UserName, up.Password)
+			flag, err := RedisConn(info, up.UserName, up.Password)
+			if flag == true && err == nil {
+				return err
+			} else {
+				tmperr = err
+				if CheckErrs(err) {
+					return err
+				}
+				if time.Now().Unix()-starttime > (int64(len(userPasswdList)) * 6) {
+					gologger.AuditTimeLogger("[Go] [Redis-Brute] Timeout,break! %s:%v", info.Host, info.Ports)
+					return err
+				}
+			}
+		}
+	}
 	gologger.AuditTimeLogger("[Go] [Redis-Brute] RedisScan return! %s:%v", info.Host, info.Ports)
 
 	return tmperr
 }
 
-func RedisConn(info *structs.HostInfo, pass string) (flag bool, err error) {
-	flag = false
-	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
-	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
-	defer func() {
-		if conn != nil {
-			conn.Close()
-		}
-	}()
+// redisACLSupported 老版本对 AUTH <user> <pass> 返回参数个数错误，支持ACL的版本返回 WRONGPASS
+func redisACLSupported(info *structs.HostInfo) bool {
+	r, err := redisDial(fmt.Sprintf("%s:%v", info.Host, info.Ports))
 	if err != nil {
-		return flag, err
+		return false
 	}
-	err = conn.SetReadDeadline(time.Now().Add(time.Duration(6) * time.Second))
-	if err != nil {
-		return flag, err
-	}
-	_, err = conn.Write([]byte(fmt.Sprintf("auth %s\r\n", pass)))
+	defer r.Close()
+	err = r.Auth("dddd", "dddd")
+	return err != nil && strings.Contains(err.Error(), "WRONGPASS")
+}
+
+func RedisConn(info *structs.HostInfo, user, pass string) (flag bool, err error) {
+	flag = false
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	r, err := redisDial(realhost)
 	if err != nil {
 		return flag, err
 	}
-	reply, err := readreply(conn)
+	defer r.Close()
+	err = r.Auth(user, pass)
 	if err != nil {
+		if _, ok := err.(redisReplyError); ok {
+			// 口令错误
+			return flag, nil
+		}
 		return flag, err
 	}
-	if strings.Contains(reply, "+OK") {
-		flag = true
-
-		result := fmt.Sprintf("Redis:%s %s", realhost, pass)
-		// gologger.Silent().Msg("[GoPoc] " + result)
-
-		showData := fmt.Sprintf("Host: %v\nPassword: %v\n", realhost, pass)
-
-		ddout.FormatOutput(ddout.OutputMessage{
-			Type:     "GoPoc",
-			IP:       "",
-			IPs:      nil,
-			Port:     "",
-			Protocol: "",
-			Web:      ddout.WebInfo{},
-			Finger:   nil,
-			Domain:   "",
-			GoPoc: ddout.GoPocsResultType{PocName: "Redis-Login",
-				Security:    "HIGH",
-				Target:      realhost,
-				InfoLeft:    showData,
-				InfoRight:   reply,
-				Description: "Redis未授权/弱口令",
-				ShowMsg:     result},
-			AdditionalMsg: "",
-		})
-
-		GoPocWriteResult(structs.GoPocsResultType{
-			PocName:     "Redis-Login",
+	flag = true
+	AddCredential("Redis", info.Host, info.Ports, user, pass, info.CredReuse)
+
+	result := fmt.Sprintf("Redis:%s %s", realhost, pass)
+	showData := fmt.Sprintf("Host: %v\nPassword: %v\n", realhost, pass)
+	if user != "" {
+		result = fmt.Sprintf("Redis:%s %s:%s", realhost, user, pass)
+		showData = fmt.Sprintf("Host: %v\nUsername: %v\nPassword: %v\n", realhost, user, pass)
+	}
+
+	ddout.FormatOutput(ddout.OutputMessage{
+		Type:     "GoPoc",
+		IP:       "",
+		IPs:      nil,
+		Port:     "",
+		Protocol: "",
+		Web:      ddout.WebInfo{},
+		Finger:   nil,
+		Domain:   "",
+		GoPoc: ddout.GoPocsResultType{PocName: "Redis-Login",
 			Security:    "HIGH",
 			Target:      realhost,
 			InfoLeft:    showData,
-			InfoRight:   reply,
+			InfoRight:   "+OK",
 			Description: "Redis未授权/弱口令",
-		})
-
+			ShowMsg:     result},
+		AdditionalMsg: "",
+	})
+
+	GoPocWriteResult(structs.GoPocsResultType{
+		PocName:     "Redis-Login",
+		Security:    "HIGH",
+		Target:      realhost,
+		InfoLeft:    showData,
+		InfoRight:   "+OK",
+		Description: "Redis未授权/弱口令",
+	})
+
+	if structs.GlobalConfig.DBPostAuth {
+		if d, postErr := redisPostAuth(realhost, user, pass); postErr == nil {
+			reportDBPostAuth(d)
+		}
 	}
 	return flag, err
 }
@@ -227,6 +215,11 @@ func RedisUnauth(info *structs.HostInfo) (flag bool, err error) {
 			Description: "Redis未授权/弱口令",
 		})
 
+		if structs.GlobalConfig.DBPostAuth {
+			if d, postErr := redisPostAuth(realhost, "", ""); postErr == nil {
+				reportDBPostAuth(d)
+			}
+		}
 	}
 	return flag, err
 }
@@ -0,0 +1,364 @@
+package gopocs
+
+import (
+	"bufio"
+	"dddd/common"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Redis 登录成功后的信息收集，只执行 INFO/CONFIG GET/ACL WHOAMI 等只读命令，
+// CONFIG SET 是否可用通过 ACL DRYRUN 与 enable-protected-configs 判断，不实际修改配置
+
+var redisProtocolErr = errors.New("redis protocol error")
+
+// redisReplyError 服务端返回的 -ERR/-WRONGPASS/-NOPERM 等错误
+type redisReplyError string
+
+func (e redisReplyError) Error() string {
+	return string(e)
+}
+
+type redisClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func redisDial(realhost string) (*redisClient, error) {
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	err = conn.SetDeadline(time.Now().Add(time.Duration(30) * time.Second))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &redisClient{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func (r *redisClient) Close() {
+	r.conn.Close()
+}
+
+// Do 以RESP数组发送命令，密码中的空格等字符不需要转义
+func (r *redisClient) Do(args ...string) (interface{}, error) {
+	cmd := fmt.Sprintf("*%d\r\n", len(args))
+	for _, a := range args {
+		cmd += fmt.Sprintf("$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := r.conn.Write([]byte(cmd)); err != nil {
+		return nil, err
+	}
+	return r.readReply()
+}
+
+// String 返回字符串结果，数组按行拼接
+func (r *redisClient) String(args ...string) (string, error) {
+	reply, err := r.Do(args...)
+	if err != nil {
+		return "", err
+	}
+	return redisReplyString(reply), nil
+}
+
+func redisReplyString(reply interface{}) string {
+	switch v := reply.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case []interface{}:
+		var lines []string
+		for _, item := range v {
+			lines = append(lines, redisReplyString(item))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return ""
+}
+
+func (r *redisClient) readReply() (interface{}, error) {
+	line, err := r.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return nil, redisProtocolErr
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisReplyError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, redisProtocolErr
+		}
+		if n < 0 {
+			return "", nil
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(r.reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, redisProtocolErr
+		}
+		var items []interface{}
+		for i := 0; i < n; i++ {
+			item, err := r.readReply()
+			if err != nil {
+				if _, ok := err.(redisReplyError); !ok {
+					return nil, err
+				}
+				item = err.Error()
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return nil, redisProtocolErr
+}
+
+// Auth user 为空时使用 AUTH <password>，否则使用 Redis 6 ACL 的 AUTH <user> <password>
+func (r *redisClient) Auth(user, pass string) error {
+	args := []string{"AUTH"}
+	if user != "" {
+		args = append(args, user)
+	}
+	_, err := r.Do(append(args, pass)...)
+	return err
+}
+
+// parseRedisInfo 解析 INFO 返回的 key:value
+func parseRedisInfo(info string) map[string]string {
+	m := make(map[string]string)
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.Index(line, ":"); i > 0 {
+			m[line[:i]] = line[i+1:]
+		}
+	}
+	return m
+}
+
+// redisConfigGet CONFIG GET 返回 [name, value]
+func redisConfigGet(r *redisClient, name string) (string, error) {
+	reply, err := r.Do("CONFIG", "GET", name)
+	if err != nil {
+		return "", err
+	}
+	items, _ := reply.([]interface{})
+	if len(items) < 2 {
+		return "", redisProtocolErr
+	}
+	return redisReplyString(items[1]), nil
+}
+
+// redisRootPaths 持久化目录位于这些路径时，一般以root运行，可写入计划任务或SSH公钥
+var redisRootPaths = []string{"/root", "/etc", "/var/spool/cron", "/usr/lib/systemd"}
+
+// redisPostAuth 认证成功(或未授权)后收集服务端信息并评估风险
+func redisPostAuth(realhost, user, pass string) (*DBPostAuthInfo, error) {
+	r, err := redisDial(realhost)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if pass != "" {
+		if err = r.Auth(user, pass); err != nil {
+			return nil, err
+		}
+	}
+
+	d := &DBPostAuthInfo{Service: "Redis", Target: realhost, User: "(anonymous)"}
+	if user != "" {
+		d.User = user
+	}
+
+	server, err := r.String("INFO", "server")
+	if err != nil {
+		return nil, err
+	}
+	serverInfo := parseRedisInfo(server)
+	d.Version = serverInfo["redis_version"]
+	for _, name := range []string{"redis_mode", "os", "arch_bits", "process_id", "executable", "config_file"} {
+		if v, ok := serverInfo[name]; ok {
+			d.addSetting(name, v)
+		}
+	}
+
+	if replication, err := r.String("INFO", "replication"); err == nil {
+		replicationInfo := parseRedisInfo(replication)
+		for _, name := range []string{"role", "connected_slaves", "master_host", "master_port", "master_link_status"} {
+			if v, ok := replicationInfo[name]; ok {
+				d.addSetting(name, v)
+			}
+		}
+		// slave0:ip=10.0.0.2,port=6379,state=online,...
+		for i := 0; ; i++ {
+			v, ok := replicationInfo[fmt.Sprintf("slave%d", i)]
+			if !ok {
+				break
+			}
+			d.addSetting(fmt.Sprintf("slave%d", i), v)
+		}
+	}
+
+	if keyspace, err := r.String("INFO", "keyspace"); err == nil {
+		keyspaceInfo := parseRedisInfo(keyspace)
+		var dbs []string
+		for db := range keyspaceInfo {
+			dbs = append(dbs, db)
+		}
+		sort.Strings(dbs)
+		for _, db := range dbs {
+			if len(d.Databases) >= dbPostAuthMaxDatabases {
+				break
+			}
+			e := DBEntry{Name: db}
+			for _, field := range strings.Split(keyspaceInfo[db], ",") {
+				if strings.HasPrefix(field, "keys=") {
+					e.Rows = toInt64(strings.TrimPrefix(field, "keys="))
+				}
+			}
+			d.Databases = append(d.Databases, e)
+		}
+	}
+
+	// Redis 6 以上才支持 ACL，老版本只有 default 用户
+	if whoami, err := r.String("ACL", "WHOAMI"); err == nil {
+		d.User = whoami
+		if list, err := r.Do("ACL", "LIST"); err == nil {
+			items, _ := list.([]interface{})
+			for _, item := range items {
+				rule := redisReplyString(item)
+				if strings.HasPrefix(rule, "user "+whoami+" ") {
+					d.Privileges = append(d.Privileges, redisMaskACLRule(rule))
+				}
+			}
+		}
+	}
+
+	if modules, err := r.Do("MODULE", "LIST"); err == nil {
+		items, _ := modules.([]interface{})
+		for _, item := range items {
+			fields, _ := item.([]interface{})
+			for i := 0; i+1 < len(fields); i += 2 {
+				if redisReplyString(fields[i]) == "name" {
+					d.addSetting("module", redisReplyString(fields[i+1]))
+				}
+			}
+		}
+	}
+
+	dir, dirErr := redisConfigGet(r, "dir")
+	dbfilename, _ := redisConfigGet(r, "dbfilename")
+	if dirErr != nil {
+		// CONFIG 被 rename-command 禁用或 ACL 无权限
+		d.addSetting("config", dirErr.Error())
+		d.addSetting("config_set", "denied")
+		d.Risks = removeDuplicateString(d.Risks)
+		return d, nil
+	}
+	d.addSetting("dir", dir)
+	d.addSetting("dbfilename", dbfilename)
+
+	configSet := redisConfigSetPermitted(r, d.Version, d.User, dir)
+	d.addSetting("config_set", configSet)
+
+	rootLike := false
+	for _, p := range redisRootPaths {
+		if dir == p || strings.HasPrefix(dir, p+"/") {
+			rootLike = true
+		}
+	}
+	if strings.HasPrefix(serverInfo["executable"], "/root/") {
+		rootLike = true
+	}
+
+	if configSet == "permitted" {
+		d.Risks = append(d.Risks, "CONFIG SET")
+		d.Rating = "HIGH"
+		if rootLike {
+			d.Risks = append(d.Risks, "root(dir="+dir+")")
+			d.Rating = "CRITICAL"
+		}
+	}
+	if serverInfo["redis_version"] != "" && redisVersionBelow(serverInfo["redis_version"], 5) {
+		// 4.x 及以下可通过主从复制加载恶意模块，同样需要 CONFIG SET，无法确认时只记录不提升等级
+		if configSet == "permitted" {
+			d.Risks = append(d.Risks, "MODULE LOAD(4.x)")
+		} else if configSet == "unknown" {
+			d.addSetting("module_load", "unverified(4.x)")
+		}
+	}
+	d.Risks = removeDuplicateString(d.Risks)
+	return d, nil
+}
+
+// redisConfigSetPermitted 判断能否修改 dir，返回 permitted/denied/unknown，调用前 CONFIG GET 已成功
+func redisConfigSetPermitted(r *redisClient, version, user, dir string) string {
+	// Redis 7 默认禁止修改 dir 等受保护配置，local 表示仅本地连接可修改
+	if protected, err := redisConfigGet(r, "enable-protected-configs"); err == nil && protected != "no" {
+		return "denied"
+	}
+	if user == "(anonymous)" {
+		user = "default"
+	}
+	// ACL DRYRUN 只做权限检查，不执行命令(Redis 7.0+)，无权限时返回原因字符串
+	reply, err := r.Do("ACL", "DRYRUN", user, "CONFIG", "SET", "dir", dir)
+	if err == nil {
+		if redisReplyString(reply) == "OK" {
+			return "permitted"
+		}
+		return "denied"
+	}
+	if e, ok := err.(redisReplyError); ok {
+		msg := strings.ToLower(string(e))
+		if strings.Contains(msg, "unknown subcommand") || strings.Contains(msg, "unknown command") {
+			// Redis 6 以前没有 ACL，rename-command 只能整体重命名 CONFIG，能 GET 即能 SET
+			if version != "" && redisVersionBelow(version, 6) {
+				return "permitted"
+			}
+			// 6.x 有 ACL 但没有 DRYRUN，无法在不修改配置的情况下确认
+			return "unknown"
+		}
+	}
+	gologger.AuditTimeLogger("[Go] [Redis-PostAuth] ACL DRYRUN: %v", err)
+	return "unknown"
+}
+
+func redisVersionBelow(version string, major int) bool {
+	v, err := strconv.Atoi(strings.Split(version, ".")[0])
+	return err == nil && v < major
+}
+
+// redisMaskACLRule ACL LIST 中的密码哈希(#sha256)不输出
+func redisMaskACLRule(rule string) string {
+	fields := strings.Fields(rule)
+	for i, f := range fields {
+		if strings.HasPrefix(f, "#") && len(f) == 65 {
+			fields[i] = "#" + f[1:9] + "..."
+		}
+	}
+	return strings.Join(fields, " ")
+}
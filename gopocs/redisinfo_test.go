@@ -0,0 +1,187 @@
+package gopocs
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRedisInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		info string
+		want map[string]string
+	}{
+		{"empty", "", map[string]string{}},
+		{"server", "# Server\r\nredis_version:7.2.4\r\nos:Linux 5.15.0 x86_64\r\n\r\n", map[string]string{"redis_version": "7.2.4", "os": "Linux 5.15.0 x86_64"}},
+		{"keyspace", "# Keyspace\r\ndb0:keys=12,expires=0,avg_ttl=0\r\ndb3:keys=1,expires=1,avg_ttl=10\r\n", map[string]string{"db0": "keys=12,expires=0,avg_ttl=0", "db3": "keys=1,expires=1,avg_ttl=10"}},
+		{"value-with-colon", "executable:/usr/bin/redis-server\nconfig_file:C:\\redis\\redis.conf\n", map[string]string{"executable": "/usr/bin/redis-server", "config_file": "C:\\redis\\redis.conf"}},
+		{"no-colon", "garbage\n:novalue\n", map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRedisInfo(tt.info); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRedisInfo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisMaskACLRule(t *testing.T) {
+	hash := strings.Repeat("a1b2c3d4", 8)
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"user default on nopass ~* &* +@all", "user default on nopass ~* &* +@all"},
+		{"user admin on #" + hash + " ~* +@all", "user admin on #a1b2c3d4... ~* +@all"},
+		{"user app on #" + hash + " #" + hash + " ~app:* +get", "user app on #a1b2c3d4... #a1b2c3d4... ~app:* +get"},
+		// 不是sha256长度的不处理
+		{"user x on #short ~*", "user x on #short ~*"},
+	}
+	for _, tt := range tests {
+		if got := redisMaskACLRule(tt.rule); got != tt.want {
+			t.Errorf("redisMaskACLRule(%q) = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+// redisServe 按命令名(大写，空格连接)返回预设的RESP响应，未设置的命令返回 unknown command
+func redisServe(replies map[string]string) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			var n int
+			if _, err := fmt.Sscanf(line, "*%d", &n); err != nil {
+				return
+			}
+			var args []string
+			for i := 0; i < n; i++ {
+				if _, err := reader.ReadString('\n'); err != nil {
+					return
+				}
+				arg, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				args = append(args, strings.TrimRight(arg, "\r\n"))
+			}
+			reply := "-ERR unknown command '" + args[0] + "'\r\n"
+			for k := len(args); k > 0; k-- {
+				if r, ok := replies[strings.ToUpper(strings.Join(args[:k], " "))]; ok {
+					reply = r
+					break
+				}
+			}
+			if _, err := conn.Write([]byte(reply)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func redisStub(t *testing.T, replies map[string]string) *redisClient {
+	conn := stubPipe(t, redisServe(replies))
+	return &redisClient{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// redisBulk 构造RESP bulk string
+func redisBulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func TestRedisConfigSetPermitted(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		replies map[string]string
+		want    string
+	}{
+		{"protected", "7.2.4", map[string]string{
+			"CONFIG GET ENABLE-PROTECTED-CONFIGS": "*2\r\n$24\r\nenable-protected-configs\r\n$5\r\nlocal\r\n",
+		}, "denied"},
+		{"dryrun-ok", "7.2.4", map[string]string{
+			"CONFIG GET ENABLE-PROTECTED-CONFIGS": "*2\r\n$24\r\nenable-protected-configs\r\n$2\r\nno\r\n",
+			"ACL DRYRUN":                          "+OK\r\n",
+		}, "permitted"},
+		{"dryrun-noperm", "7.2.4", map[string]string{
+			"CONFIG GET ENABLE-PROTECTED-CONFIGS": "*2\r\n$24\r\nenable-protected-configs\r\n$2\r\nno\r\n",
+			"ACL DRYRUN":                          "$24\r\nUser default has no perm\r\n",
+		}, "denied"},
+		// 没有 ACL 的老版本，CONFIG GET 可用即 CONFIG SET 可用
+		{"pre-acl", "5.0.7", map[string]string{
+			"CONFIG GET ENABLE-PROTECTED-CONFIGS": "*0\r\n",
+			"ACL":                                 "-ERR unknown command 'ACL'\r\n",
+		}, "permitted"},
+		{"unknown-version", "", map[string]string{
+			"CONFIG GET ENABLE-PROTECTED-CONFIGS": "*0\r\n",
+			"ACL":                                 "-ERR unknown command 'ACL'\r\n",
+		}, "unknown"},
+		// 6.x 有 ACL 但没有 DRYRUN，不能据此认为可以修改配置
+		{"no-dryrun-subcommand", "6.2.14", map[string]string{
+			"CONFIG GET ENABLE-PROTECTED-CONFIGS": "*0\r\n",
+			"ACL DRYRUN":                          "-ERR unknown subcommand 'DRYRUN'. Try ACL HELP.\r\n",
+		}, "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := redisStub(t, tt.replies)
+			if got := redisConfigSetPermitted(r, tt.version, "(anonymous)", "/var/lib/redis"); got != tt.want {
+				t.Errorf("redisConfigSetPermitted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisPostAuth(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		dir       string
+		rating    string
+		risks     []string
+		configSet string
+	}{
+		{"5.x root", "5.0.7", "/root", "CRITICAL", []string{"CONFIG SET", "root(dir=/root)"}, "permitted"},
+		{"5.x data", "5.0.7", "/var/lib/redis", "HIGH", []string{"CONFIG SET"}, "permitted"},
+		{"4.x data", "4.0.14", "/data", "HIGH", []string{"CONFIG SET", "MODULE LOAD(4.x)"}, "permitted"},
+		{"6.x root", "6.2.14", "/root", "", nil, "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := stubServer(t, redisServe(map[string]string{
+				"INFO SERVER":                         redisBulk("# Server\r\nredis_version:" + tt.version + "\r\nos:Linux 4.15.0 x86_64\r\n"),
+				"CONFIG GET DIR":                      "*2\r\n" + redisBulk("dir") + redisBulk(tt.dir),
+				"CONFIG GET DBFILENAME":               "*2\r\n" + redisBulk("dbfilename") + redisBulk("dump.rdb"),
+				"CONFIG GET ENABLE-PROTECTED-CONFIGS": "*0\r\n",
+				"ACL DRYRUN":                          "-ERR unknown subcommand 'DRYRUN'. Try ACL HELP.\r\n",
+			}))
+			d, err := redisPostAuth(addr, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Version != tt.version || d.Rating != tt.rating || !reflect.DeepEqual(d.Risks, tt.risks) {
+				t.Errorf("redisPostAuth() = version %q rating %q risks %v, want %q %q %v", d.Version, d.Rating, d.Risks, tt.version, tt.rating, tt.risks)
+			}
+			if got := redisSetting(d, "config_set"); got != tt.configSet {
+				t.Errorf("config_set = %q, want %q", got, tt.configSet)
+			}
+		})
+	}
+}
+
+func redisSetting(d *DBPostAuthInfo, name string) string {
+	for _, kv := range d.Settings {
+		if kv[0] == name {
+			return kv[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+package gopocs
+
+import (
+	"bufio"
+	"dddd/common"
+	"dddd/structs"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"net"
+	"strings"
+	"time"
+)
+
+type rsyncModule struct {
+	Name    string
+	Comment string
+	Unauth  bool
+}
+
+func rsyncConnect(realhost string) (net.Conn, *bufio.Reader, error) {
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = conn.SetDeadline(time.Now().Add(time.Duration(6) * time.Second))
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(conn)
+	greeting, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	gologger.AuditTimeLogger("[Go] [Rsync] Dumped TCP response for %s\n\n%s\n", realhost, greeting)
+	if !strings.HasPrefix(greeting, "@RSYNCD:") {
+		conn.Close()
+		return nil, nil, errors.New("not rsync protocol")
+	}
+	// 回显服务端的协议版本
+	_, err = conn.Write([]byte(greeting))
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, reader, nil
+}
+
+// rsyncListModules 通过 #list 获取模块列表
+func rsyncListModules(realhost string) ([]rsyncModule, error) {
+	conn, reader, err := rsyncConnect(realhost)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("#list\n"))
+	gologger.AuditTimeLogger("[Go] [Rsync] Dumped TCP request for %s\n\n#list\n", realhost)
+	if err != nil {
+		return nil, err
+	}
+	var modules []rsyncModule
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		gologger.AuditTimeLogger("[Go] [Rsync] Dumped TCP response for %s\n\n%s\n", realhost, line)
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "@RSYNCD: EXIT") {
+			break
+		}
+		if strings.HasPrefix(line, "@") || line == "" {
+			continue
+		}
+		name, comment, _ := strings.Cut(line, "\t")
+		modules = append(modules, rsyncModule{Name: strings.TrimSpace(name), Comment: strings.TrimSpace(comment)})
+	}
+	return modules, nil
+}
+
+// rsyncModuleUnauth 请求模块，服务端返回 @RSYNCD: OK 表示无需认证
+func rsyncModuleUnauth(realhost string, module string) (bool, error) {
+	conn, reader, err := rsyncConnect(realhost)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(module + "\n"))
+	gologger.AuditTimeLogger("[Go] [Rsync] Dumped TCP request for %s\n\n%s\n", realhost, module)
+	if err != nil {
+		return false, err
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return false, err
+		}
+		gologger.AuditTimeLogger("[Go] [Rsync] Dumped TCP response for %s\n\n%s\n", realhost, line)
+		if strings.HasPrefix(line, "@RSYNCD: OK") {
+			return true, nil
+		}
+		if strings.HasPrefix(line, "@RSYNCD: AUTHREQD") || strings.HasPrefix(line, "@ERROR") {
+			return false, nil
+		}
+	}
+}
+
+func RsyncScan(info *structs.HostInfo) error {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	modules, err := rsyncListModules(realhost)
+	if err != nil {
+		return err
+	}
+	if len(modules) == 0 {
+		return nil
+	}
+
+	var unauth []string
+	listing := ""
+	for i, m := range modules {
+		ok, _ := rsyncModuleUnauth(realhost, m.Name)
+		modules[i].Unauth = ok
+		state := "AUTH"
+		if ok {
+			state = "UNAUTH"
+			unauth = append(unauth, m.Name)
+		}
+		listing += fmt.Sprintf("[%s] %-20s %s\n", state, m.Name, m.Comment)
+	}
+
+	var names []string
+	for _, m := range modules {
+		names = append(names, m.Name)
+	}
+
+	security := "INFO"
+	description := "Rsync模块列表泄露"
+	pocName := "Rsync-Modules"
+	if len(unauth) > 0 {
+		security = "HIGH"
+		description = "Rsync未授权访问"
+		pocName = "Rsync-Unauthorized"
+		names = unauth
+	}
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     pocName,
+		Security:    security,
+		Target:      realhost,
+		InfoLeft:    fmt.Sprintf("Host: %v\nModules: %v\nUnauthorized: %v\n", realhost, len(modules), strings.Join(unauth, ",")),
+		InfoRight:   listing,
+		Description: description,
+	}, fmt.Sprintf("%s rsync://%s [%s]", pocName, realhost, strings.Join(names, ",")))
+	return nil
+}
@@ -0,0 +1,129 @@
+package gopocs
+
+import (
+	"dddd/common"
+	"dddd/structs"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"io"
+	"net"
+	"time"
+)
+
+// Siemens S7comm，COTP连接后只读取 SZL 0x0011(模块标识) 与 0x001C(组件标识)
+
+// COTP CR，目标TSAP 0x0102(机架0槽位2，S7-300/400) 与 0x0200(S7-1200/1500)
+var s7COTPRequests = [][]byte{
+	{0x03, 0x00, 0x00, 0x16, 0x11, 0xe0, 0x00, 0x00, 0x00, 0x14, 0x00, 0xc1, 0x02, 0x01, 0x00, 0xc2, 0x02, 0x01, 0x02, 0xc0, 0x01, 0x0a},
+	{0x03, 0x00, 0x00, 0x16, 0x11, 0xe0, 0x00, 0x00, 0x00, 0x05, 0x00, 0xc1, 0x02, 0x01, 0x00, 0xc2, 0x02, 0x02, 0x00, 0xc0, 0x01, 0x0a},
+}
+
+// Setup Communication
+var s7SetupRequest = []byte{0x03, 0x00, 0x00, 0x19, 0x02, 0xf0, 0x80, 0x32, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, 0x00,
+	0x00, 0xf0, 0x00, 0x00, 0x01, 0x00, 0x01, 0x01, 0xe0}
+
+// s7ReadSZLRequest Userdata 读取SZL
+func s7ReadSZLRequest(szlID uint16) []byte {
+	req := []byte{0x03, 0x00, 0x00, 0x21, 0x02, 0xf0, 0x80, 0x32, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, 0x00,
+		0x08, 0x00, 0x01, 0x12, 0x04, 0x11, 0x44, 0x01, 0x00, 0xff, 0x09, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01}
+	binary.BigEndian.PutUint16(req[29:31], szlID)
+	return req
+}
+
+// s7Exchange 发送请求并读取一个TPKT
+func s7Exchange(conn net.Conn, realhost string, req []byte) ([]byte, error) {
+	icsTake()
+	gologger.AuditTimeLogger("[Go] [S7] Dumped TCP request for %s\n\n%s\n", realhost, hex.Dump(req))
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[2:4]))
+	if header[0] != 0x03 || length < 7 || length > 4096 {
+		return nil, errors.New("not s7 protocol")
+	}
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return nil, err
+	}
+	resp := append(header, body...)
+	gologger.AuditTimeLogger("[Go] [S7] Dumped TCP response for %s\n\n%s\n", realhost, hex.Dump(resp))
+	return resp, nil
+}
+
+// s7Connect 依次尝试两个TSAP，返回完成 Setup Communication 的连接
+func s7Connect(realhost string) (net.Conn, error) {
+	var lastErr error
+	for _, cr := range s7COTPRequests {
+		conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		err = conn.SetDeadline(time.Now().Add(time.Duration(15) * time.Second))
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		resp, err := s7Exchange(conn, realhost, cr)
+		// COTP CC
+		if err != nil || resp[5] != 0xd0 {
+			conn.Close()
+			lastErr = errors.New("cotp connect refused")
+			continue
+		}
+		resp, err = s7Exchange(conn, realhost, s7SetupRequest)
+		if err != nil || len(resp) < 8 || resp[7] != 0x32 {
+			conn.Close()
+			lastErr = errors.New("s7 setup communication failed")
+			continue
+		}
+		return conn, nil
+	}
+	return nil, lastErr
+}
+
+func S7Scan(info *structs.HostInfo) error {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	conn, err := s7Connect(realhost)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	d := &ICSDevice{Protocol: "S7comm", Vendor: "Siemens"}
+
+	// SZL 0x0011 模块标识：订货号、硬件、固件版本
+	resp, err := s7Exchange(conn, realhost, s7ReadSZLRequest(0x0011))
+	if err == nil && len(resp) > 7 && resp[7] == 0x32 {
+		d.addExtra("Module", icsCString(resp, 43, 20))
+		d.addExtra("BasicHardware", icsCString(resp, 71, 20))
+		if len(resp) >= 125 {
+			d.Firmware = fmt.Sprintf("V%d.%d.%d", resp[122], resp[123], resp[124])
+		}
+	}
+
+	// SZL 0x001C 组件标识：系统名、模块类型、序列号、工厂标识
+	resp, err = s7Exchange(conn, realhost, s7ReadSZLRequest(0x001c))
+	if err == nil && len(resp) > 30 && resp[7] == 0x32 {
+		offset := 0
+		if resp[30] != 0x1c {
+			offset = 4
+		}
+		d.Model = icsCString(resp, 73+offset, 32)
+		d.addExtra("SystemName", icsCString(resp, 39+offset, 32))
+		d.addExtra("PlantIdentification", icsCString(resp, 107+offset, 32))
+		d.addExtra("Copyright", icsCString(resp, 141+offset, 32))
+		d.addExtra("SerialNumber", icsCString(resp, 175+offset, 32))
+	}
+	if d.Model == "" && len(d.Extra) == 0 && d.Firmware == "" {
+		return errors.New("s7 szl read failed")
+	}
+	icsReport("S7-Info", "s7", realhost, d, "Siemens S7 PLC模块与固件信息(SZL读取)")
+	return nil
+}
@@ -7,25 +7,81 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 )
 
 var Mutex = &sync.Mutex{}
 
+// 主机信息收集任务，结果用于生成爆破字典中的 {{key}}
+var hostInfoPlugins = map[string]bool{"NetBios-GetHostInfo": true, "RPC-GetHostInfo": true, "NTLM-GetHostInfo": true}
+
+// 主机 => 进行中的信息收集任务数，同一主机的其他任务等待其结束
+var hostInfoPending = make(map[string]int)
+var hostInfoLock sync.Mutex
+var hostInfoCond = sync.NewCond(&hostInfoLock)
+
+func hostInfoDone(host string) {
+	hostInfoLock.Lock()
+	hostInfoPending[host]--
+	if hostInfoPending[host] <= 0 {
+		delete(hostInfoPending, host)
+	}
+	hostInfoLock.Unlock()
+	hostInfoCond.Broadcast()
+}
+
+// waitHostInfo 等待主机上进行中的信息收集任务结束。信息收集任务在占用并发后才计数，等待时不会死锁
+func waitHostInfo(host string) {
+	hostInfoLock.Lock()
+	for hostInfoPending[host] > 0 {
+		hostInfoCond.Wait()
+	}
+	hostInfoLock.Unlock()
+}
+
 // 单线程的
 var currentCount = 0
 
 func AddScan(scantype string, info structs.HostInfo, ch *chan struct{}, wg *sync.WaitGroup) {
+	if sprayEnabled(scantype) && !info.CredReuse {
+		addSprayTarget(scantype, info)
+		return
+	}
+
 	currentCount += 1
 	if currentCount%100 == 0 {
 		gologger.Info().Msgf("[GoPoc] 当前进度: %v %v [%v/%v]", scantype, info.Host+":"+info.Ports, currentCount, allCount)
 	}
 
+	var task *credReuseTask
+	if credReusePlugins[scantype] && !info.CredReuse {
+		task = &credReuseTask{Name: scantype, Host: info.Host, Port: info.Ports}
+		credReuseTasks = append(credReuseTasks, task)
+	}
+
 	*ch <- struct{}{}
 	wg.Add(1)
+	hostInfo := hostInfoPlugins[scantype] && info.Host != ""
+	if hostInfo {
+		hostInfoLock.Lock()
+		hostInfoPending[info.Host]++
+		hostInfoLock.Unlock()
+	}
 	go func() {
 		Mutex.Lock()
 		structs.AddScanNum += 1
 		Mutex.Unlock()
+		if hostInfo {
+			defer hostInfoDone(info.Host)
+		} else if info.Host != "" {
+			waitHostInfo(info.Host)
+		}
+		if task != nil {
+			task.Start = time.Now()
+		}
+		if info.Host != "" {
+			info.InfoStr = removeDuplicateKeepOrder(append(info.InfoStr, targetKeywords(info.Host)...))
+		}
 		ScanFunc(&scantype, &info)
 		Mutex.Lock()
 		structs.AddScanEnd += 1
@@ -48,114 +104,266 @@ func ScanFunc(name *string, info *structs.HostInfo) {
 
 var allCount = 0
 
-func GoPocsDispatcher(nucleiResults []output.ResultEvent) {
-	if len(structs.GlobalIPPortMap) == 0 && len(nucleiResults) == 0 {
-		return
-	}
-
-	initDic()
+// ServiceTarget 协议识别出的服务，由流水线边扫描边派发GoPoc任务
+type ServiceTarget struct {
+	HostPort string
+	Protocol string
+}
 
-	allCount = len(structs.GlobalIPPortMap) + len(nucleiResults)
+// GoPoc任务的并发控制，流水线中派发的任务与 GoPocsDispatcher 共用
+var (
+	goPocOnce sync.Once
+	goPocCh   chan struct{}
+	goPocWG   sync.WaitGroup
+)
 
-	var ch = make(chan struct{}, structs.GlobalConfig.GoPocThreads)
-	var wg = sync.WaitGroup{}
-	gologger.Info().Msg("Golang Poc引擎启动")
+// 已派发过的服务，只在派发协程中访问
+var dispatched = make(map[string]bool)
 
-	// 各类协议
+// 流水线中已派发NTLM信息收集的主机 => 派发时的端点数量
+var ntlmDispatched = make(map[string]int)
 
-	for hostPort, protocol := range structs.GlobalIPPortMap {
-		t := strings.Split(hostPort, ":")
-		host := t[0]
-		port := t[1]
+func goPocInit() {
+	goPocOnce.Do(func() {
+		initDic()
+		goPocCh = make(chan struct{}, structs.GlobalConfig.GoPocThreads)
+		gologger.Info().Msg("Golang Poc引擎启动")
+	})
+}
 
-		if protocol == "ssh" || port == "22" {
-			AddScan("SSH-Crack",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
-		}
-		if protocol == "ftp" || port == "21" {
-			AddScan("FTP-Crack",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
-		}
-		if protocol == "mysql" || port == "3306" {
-			AddScan("Mysql-Crack",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
-		}
-		if protocol == "mssql" || port == "1433" {
-			AddScan("Mssql-Crack",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
+// GoPocsStream 流水线中协议识别出服务后立即派发GoPoc任务，不等待任务结束，services 关闭后返回。
+// 依赖Nuclei结果与全部资产的探测(Shiro、BACnet、Web服务的NTLM、密码喷洒、凭据复用)由 GoPocsDispatcher 进行
+func GoPocsStream(services <-chan ServiceTarget) {
+	for s := range services {
+		if dispatched[s.HostPort] {
+			continue
 		}
-		if protocol == "oracle" || port == "1521" {
-			AddScan("Oracle-Crack",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
-		}
-		if protocol == "mongodb" || port == "27017" {
-			AddScan("MongoDB-Crack",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
-		}
-		if protocol == "rdp" || port == "3389" {
-			if structs.GlobalConfig.NoServiceBruteForce {
-				continue
+		goPocInit()
+		dispatched[s.HostPort] = true
+		allCount++
+		t := strings.Split(s.HostPort, ":")
+		host, port := t[0], t[1]
+		dispatchHostInfo(host, port, s.Protocol)
+		if e, ok := ntlmServiceEndpoint(host, port, s.Protocol); ok {
+			addNTLMEndpoint(host, e)
+			if _, ok := ntlmDispatched[host]; !ok {
+				ntlmDispatched[host] = len(ntlmHostEndpoints(host))
+				AddScan("NTLM-GetHostInfo",
+					structs.HostInfo{Host: host},
+					&goPocCh, &goPocWG)
 			}
-			AddScan("RDP-Crack",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
 		}
-		if protocol == "redis" || port == "6379" {
-			// 有未授权检测
-			AddScan("Redis-Crack",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
-		}
-		if protocol == "smb" || port == "445" {
-			AddScan("SMB-MS17-010",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
-			AddScan("SMB-Crack",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
-		}
-		if protocol == "postgresql" || port == "5432" {
-			AddScan("PostgreSQL-Crack",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
-		}
-		if protocol == "telnet" || port == "23" {
-			AddScan("Telnet-Crack",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
-		}
-		if protocol == "memcached" || port == "11211" {
-			AddScan("Memcache-Crack",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
-		}
-		if protocol == "netbios" || port == "445" {
-			AddScan("NetBios-GetHostInfo",
+		dispatchService(host, port, s.Protocol)
+	}
+}
+
+// dispatchHostInfo 主机名、域名信息收集，用于生成爆破字典中的 {{key}}
+func dispatchHostInfo(host, port, protocol string) {
+	if protocol == "netbios" || port == "445" {
+		AddScan("NetBios-GetHostInfo",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "rpc" {
+		AddScan("RPC-GetHostInfo",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+}
+
+// dispatchService 各类协议
+func dispatchService(host, port, protocol string) {
+	if protocol == "ssh" || port == "22" {
+		AddScan("SSH-Audit",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+		if structs.GlobalConfig.SSHKeyDir != "" {
+			AddScan("SSH-Key-Crack",
 				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
+				&goPocCh, &goPocWG)
 		}
-		if protocol == "rpc" {
-			AddScan("RPC-GetHostInfo",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
+		AddScan("SSH-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "ftp" || port == "21" {
+		AddScan("FTP-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "mysql" || port == "3306" {
+		AddScan("Mysql-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "mssql" || port == "1433" {
+		AddScan("Mssql-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "oracle" || port == "1521" {
+		AddScan("Oracle-TNS",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+		AddScan("Oracle-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "mongodb" || port == "27017" {
+		AddScan("MongoDB-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "rdp" || port == "3389" {
+		AddScan("RDP-Security",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+		if structs.GlobalConfig.NoServiceBruteForce {
+			return
 		}
-		if protocol == "jdwp" {
-			AddScan("JDWP-Scan",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
+		AddScan("RDP-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "redis" || port == "6379" {
+		// 有未授权检测
+		AddScan("Redis-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "smb" || port == "445" {
+		AddScan("SMB-MS17-010",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+		AddScan("SMB-Negotiate",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+		AddScan("SMB-SMBGhost",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+		AddScan("SMB-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "postgresql" || port == "5432" {
+		AddScan("PostgreSQL-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "telnet" || port == "23" {
+		AddScan("Telnet-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "memcached" || port == "11211" {
+		AddScan("Memcache-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "vnc" || port == "5900" {
+		// 有未授权检测
+		AddScan("VNC-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "wsman" || port == "5985" || port == "5986" {
+		AddScan("WinRM-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "smtp" || protocol == "smtp-ssl" || protocol == "smtps" ||
+		port == "25" || port == "465" || port == "587" {
+		AddScan("SMTP-Scan",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+		AddScan("SMTP-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "pop3" || protocol == "pop3-ssl" || protocol == "pop3s" ||
+		port == "110" || port == "995" {
+		AddScan("POP3-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "imap" || protocol == "imap-ssl" || protocol == "imaps" ||
+		port == "143" || port == "993" {
+		AddScan("IMAP-Crack",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "rsync" || port == "873" {
+		AddScan("Rsync-Scan",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "rpcbind" || protocol == "nfs" || port == "111" || port == "2049" {
+		AddScan("NFS-Scan",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "jdwp" {
+		AddScan("JDWP-Scan",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	if protocol == "adb" || port == "5555" {
+		AddScan("ADB-Scan",
+			structs.HostInfo{Host: host, Ports: port},
+			&goPocCh, &goPocWG)
+	}
+	// Modbus/S7/DNP3 在协议识别后、指纹识别前进行，见 ICSCheckTarget
+}
+
+func GoPocsDispatcher(nucleiResults []output.ResultEvent) {
+	// 流水线中尚未派发的服务
+	var services []ServiceTarget
+	for hostPort, protocol := range structs.GlobalIPPortMap {
+		if !dispatched[hostPort] {
+			services = append(services, ServiceTarget{hostPort, protocol})
 		}
-		if protocol == "adb" || port == "5555" {
-			AddScan("ADB-Scan",
-				structs.HostInfo{Host: host, Ports: port},
-				&ch, &wg)
+	}
+	if len(services) == 0 && len(nucleiResults) == 0 && len(dispatched) == 0 {
+		return
+	}
+
+	goPocInit()
+
+	allCount += len(services) + len(nucleiResults)
+
+	// 先收集主机名、域名，用于生成爆破字典中的 {{key}}
+	for _, s := range services {
+		t := strings.Split(s.HostPort, ":")
+		dispatchHostInfo(t[0], t[1], s.Protocol)
+	}
+	// Web服务的NTLM端点在流水线结束后才能确定，流水线中没有获取到信息且有新端点的主机再次尝试
+	collectNTLMEndpoints()
+	for _, host := range ntlmEndpointHosts() {
+		structs.GlobalNTLMInfoMapLock.Lock()
+		_, ok := structs.GlobalNTLMInfoMap[host]
+		structs.GlobalNTLMInfoMapLock.Unlock()
+		if n, tried := ntlmDispatched[host]; ok || (tried && len(ntlmHostEndpoints(host)) <= n) {
+			continue
 		}
+		AddScan("NTLM-GetHostInfo",
+			structs.HostInfo{Host: host},
+			&goPocCh, &goPocWG)
+	}
+	goPocWG.Wait()
+
+	for _, s := range services {
+		dispatched[s.HostPort] = true
+		t := strings.Split(s.HostPort, ":")
+		dispatchService(t[0], t[1], s.Protocol)
+	}
 
+	// BACnet/IP 为UDP协议，端口扫描发现不了，对每个存活主机探测一次
+	if structs.GlobalConfig.ICSScan {
+		for _, host := range icsHosts() {
+			AddScan("BACnet-Info",
+				structs.HostInfo{Host: host, Ports: "47808"},
+				&goPocCh, &goPocWG)
+		}
 	}
 
 	// 各类指纹
@@ -163,13 +371,24 @@ func GoPocsDispatcher(nucleiResults []output.ResultEvent) {
 	//
 	//}
 
-	for _, result := range nucleiResults {
-		if result.TemplateID == "shiro-detect" {
-			AddScan("Shiro-Key-Crack",
-				structs.HostInfo{Url: result.Matched},
-				&ch, &wg)
-		}
+	for _, u := range shiroTargets(nucleiResults) {
+		AddScan("Shiro-Key-Crack",
+			structs.HostInfo{Url: u},
+			&goPocCh, &goPocWG)
 	}
 
-	wg.Wait()
+	goPocWG.Wait()
+
+	// 主机公钥复用
+	sshHostKeyReuseReport()
+	shiroGadgetClose()
+
+	// 密码喷洒
+	sprayScheduler()
+
+	// 凭据复用
+	credentialReusePass(&goPocCh, &goPocWG)
+	goPocWG.Wait()
+
+	ExportCredentials()
 }
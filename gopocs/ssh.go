@@ -70,6 +70,7 @@ func SshConn(info *structs.HostInfo, user string, pass string) (flag bool, err e
 		if err == nil {
 			defer session.Close()
 			flag = true
+			AddCredential("SSH", Host, Port, Username, Password, info.CredReuse)
 			var result string
 			result = fmt.Sprintf("SSH://%v:%v:%v %v", Host, Port, Username, Password)
 			// gologger.Silent().Msg("[GoPoc] " + result)
@@ -0,0 +1,501 @@
+package gopocs
+
+import (
+	"bufio"
+	"dddd/common"
+	"dddd/structs"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSH审计：KEXINIT算法列表与弱算法、各类型主机公钥指纹、用户可用的认证方式，
+// 以及 -ssh-key-dir 指定私钥的批量登录测试
+
+var sshKexInitErr = errors.New("ssh kexinit not found")
+
+// 检测认证方式时使用的用户数量，取字典中的前几个用户
+const sshAuditUsers = 3
+
+var sshAlgorithmNames = []string{"KEX", "HostKey", "Cipher", "MAC", "Compression"}
+
+// OpenSSH 客户端的默认算法偏好，用于计算实际协商结果
+var sshClientPreference = [][]string{
+	{"sntrup761x25519-sha512@openssh.com", "curve25519-sha256", "curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group-exchange-sha256", "diffie-hellman-group16-sha512",
+		"diffie-hellman-group18-sha512", "diffie-hellman-group14-sha256"},
+	{"ssh-ed25519", "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521",
+		"rsa-sha2-512", "rsa-sha2-256"},
+	{"chacha20-poly1305@openssh.com", "aes128-ctr", "aes192-ctr", "aes256-ctr",
+		"aes128-gcm@openssh.com", "aes256-gcm@openssh.com"},
+	{"umac-64-etm@openssh.com", "umac-128-etm@openssh.com", "hmac-sha2-256-etm@openssh.com",
+		"hmac-sha2-512-etm@openssh.com", "hmac-sha1-etm@openssh.com", "umac-64@openssh.com",
+		"umac-128@openssh.com", "hmac-sha2-256", "hmac-sha2-512", "hmac-sha1"},
+	{"none", "zlib@openssh.com"},
+}
+
+// 已知不安全的算法，前缀匹配
+var sshWeakAlgorithms = [][]string{
+	{"diffie-hellman-group1-sha1", "diffie-hellman-group14-sha1", "diffie-hellman-group-exchange-sha1",
+		"rsa1024-sha1", "gss-group1-sha1-", "gss-group14-sha1-", "gss-gex-sha1-"},
+	{"ssh-dss", "ssh-rsa", "ssh-rsa-cert-v01@openssh.com", "ssh-dss-cert-v01@openssh.com"},
+	{"3des-cbc", "aes128-cbc", "aes192-cbc", "aes256-cbc", "blowfish-cbc", "cast128-cbc",
+		"arcfour", "rijndael-cbc@lysator.liu.se", "des-cbc", "none"},
+	{"hmac-md5", "hmac-sha1", "hmac-sha1-96", "hmac-ripemd160", "umac-64@openssh.com",
+		"umac-64-etm@openssh.com", "none"},
+	nil,
+}
+
+// 探测时使用的算法，包含老旧设备上的算法以保证能完成握手
+var sshAuditKeyExchanges = []string{
+	"curve25519-sha256", "curve25519-sha256@libssh.org", "ecdh-sha2-nistp256", "ecdh-sha2-nistp384",
+	"ecdh-sha2-nistp521", "diffie-hellman-group-exchange-sha256", "diffie-hellman-group16-sha512",
+	"diffie-hellman-group14-sha256", "diffie-hellman-group14-sha1", "diffie-hellman-group-exchange-sha1",
+	"diffie-hellman-group1-sha1",
+}
+var sshAuditCiphers = []string{
+	"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+	"aes128-ctr", "aes192-ctr", "aes256-ctr", "aes128-cbc", "3des-cbc",
+}
+
+type sshHostKey struct {
+	Type        string
+	Fingerprint string
+}
+
+type SSHAuditInfo struct {
+	Banner      string
+	Algorithms  [][]string
+	Negotiated  []string
+	Weak        []string
+	HostKeys    []sshHostKey
+	AuthMethods map[string][]string
+}
+
+func (s *SSHAuditInfo) String() string {
+	msg := fmt.Sprintf("Banner: %s\n", s.Banner)
+	for i, name := range sshAlgorithmNames {
+		msg += fmt.Sprintf("%s: %s\n", name, strings.Join(s.Algorithms[i], ","))
+	}
+	msg += fmt.Sprintf("Negotiated (OpenSSH client): %s\n", strings.Join(s.Negotiated, " | "))
+	if len(s.Weak) > 0 {
+		msg += fmt.Sprintf("Weak: %s\n", strings.Join(s.Weak, ","))
+	}
+	for _, k := range s.HostKeys {
+		msg += fmt.Sprintf("HostKey: %s %s\n", k.Type, k.Fingerprint)
+	}
+	var users []string
+	for user := range s.AuthMethods {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		msg += fmt.Sprintf("Auth [%s]: %s\n", user, strings.Join(s.AuthMethods[user], ","))
+	}
+	return msg
+}
+
+// 主机公钥指纹 => 使用该公钥的服务
+var sshHostKeyMap = make(map[string][]string)
+var sshHostKeyLock sync.Mutex
+
+func sshNameList(data []byte) ([]string, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, sshKexInitErr
+	}
+	l := int(binary.BigEndian.Uint32(data[0:4]))
+	if len(data) < 4+l {
+		return nil, nil, sshKexInitErr
+	}
+	if l == 0 {
+		return nil, data[4:], nil
+	}
+	return strings.Split(string(data[4:4+l]), ","), data[4+l:], nil
+}
+
+// sshReadKexInit 读取服务端版本与明文的 SSH_MSG_KEXINIT
+func sshReadKexInit(info *structs.HostInfo) (banner string, algorithms [][]string, err error) {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	err = conn.SetDeadline(time.Now().Add(time.Duration(6) * time.Second))
+	if err != nil {
+		return
+	}
+
+	_, err = conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+	if err != nil {
+		return
+	}
+	reader := bufio.NewReader(conn)
+	for i := 0; i < 20; i++ {
+		var line string
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "SSH-") {
+			banner = line
+			break
+		}
+	}
+	if banner == "" {
+		err = sshKexInitErr
+		return
+	}
+	gologger.AuditTimeLogger("[Go] [SSH-Audit] %s banner: %s", realhost, banner)
+
+	header := make([]byte, 5)
+	if _, err = io.ReadFull(reader, header); err != nil {
+		return
+	}
+	length := int(binary.BigEndian.Uint32(header[0:4]))
+	padding := int(header[4])
+	if length < 1+padding+17 || length > 35000 {
+		err = sshKexInitErr
+		return
+	}
+	packet := make([]byte, length-1)
+	if _, err = io.ReadFull(reader, packet); err != nil {
+		return
+	}
+	payload := packet[:length-1-padding]
+	// SSH_MSG_KEXINIT(20) + cookie(16)
+	if payload[0] != 20 {
+		err = sshKexInitErr
+		return
+	}
+	data := payload[17:]
+	var lists [][]string
+	for i := 0; i < 10; i++ {
+		var list []string
+		list, data, err = sshNameList(data)
+		if err != nil {
+			return
+		}
+		lists = append(lists, list)
+	}
+	// kex, hostkey, cipher c2s, cipher s2c, mac c2s, mac s2c, compression c2s, compression s2c
+	algorithms = [][]string{lists[0], lists[1],
+		removeDuplicateKeepOrder(append(lists[2], lists[3]...)),
+		removeDuplicateKeepOrder(append(lists[4], lists[5]...)),
+		removeDuplicateKeepOrder(append(lists[6], lists[7]...))}
+	return
+}
+
+func sshNegotiate(client, server []string) string {
+	for _, c := range client {
+		for _, s := range server {
+			if c == s {
+				return c
+			}
+		}
+	}
+	return "none"
+}
+
+func sshWeak(index int, algorithms []string) []string {
+	var weak []string
+	for _, algo := range algorithms {
+		for _, w := range sshWeakAlgorithms[index] {
+			if algo == w || (strings.HasSuffix(w, "-") && strings.HasPrefix(algo, w)) ||
+				(w == "hmac-md5" && strings.HasPrefix(algo, "hmac-md5")) ||
+				(w == "arcfour" && strings.HasPrefix(algo, "arcfour")) {
+				weak = append(weak, algo)
+				break
+			}
+		}
+	}
+	return weak
+}
+
+func sshAuditConfig(user string, auth []ssh.AuthMethod, hostKeyAlgorithms []string, callback ssh.HostKeyCallback) *ssh.ClientConfig {
+	config := &ssh.ClientConfig{
+		User:              user,
+		Auth:              auth,
+		Timeout:           time.Duration(6) * time.Second,
+		HostKeyCallback:   callback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+	}
+	config.KeyExchanges = sshAuditKeyExchanges
+	config.Ciphers = sshAuditCiphers
+	return config
+}
+
+// sshHostKeys 按公钥类型分别握手，取得每种主机公钥的指纹
+func sshHostKeys(info *structs.HostInfo, offered []string) []sshHostKey {
+	groups := [][]string{
+		{"ssh-ed25519"},
+		{"ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521"},
+		{"rsa-sha2-512", "rsa-sha2-256", "ssh-rsa"},
+		{"ssh-dss"},
+	}
+	var result []sshHostKey
+	for _, group := range groups {
+		var algo string
+		for _, a := range group {
+			if sshNegotiate([]string{a}, offered) != "none" {
+				algo = a
+				break
+			}
+		}
+		if algo == "" {
+			continue
+		}
+		var key ssh.PublicKey
+		config := sshAuditConfig("root", nil, []string{algo}, func(hostname string, remote net.Addr, k ssh.PublicKey) error {
+			key = k
+			return errors.New("host key collected")
+		})
+		_, _ = ssh.Dial("tcp", fmt.Sprintf("%v:%v", info.Host, info.Ports), config)
+		if key != nil {
+			result = append(result, sshHostKey{Type: key.Type(), Fingerprint: ssh.FingerprintSHA256(key)})
+		}
+	}
+	return result
+}
+
+type sshGSSAPIProbe struct {
+	tried *[]string
+}
+
+func (g sshGSSAPIProbe) InitSecContext(target string, token []byte, isGSSDelegCreds bool) ([]byte, bool, error) {
+	*g.tried = append(*g.tried, "gssapi-with-mic")
+	return nil, false, errors.New("probe only")
+}
+
+func (g sshGSSAPIProbe) GetMIC(micFiled []byte) ([]byte, error) {
+	return nil, errors.New("probe only")
+}
+
+func (g sshGSSAPIProbe) DeleteSecContext() error {
+	return nil
+}
+
+// sshAuthMethods 服务端返回的可用认证方式，客户端只会尝试服务端列出的方式，每种方式在回调中记录后立即放弃
+func sshAuthMethods(info *structs.HostInfo, user string) []string {
+	var tried []string
+	auth := []ssh.AuthMethod{
+		ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			tried = append(tried, "publickey")
+			return nil, errors.New("probe only")
+		}),
+		ssh.PasswordCallback(func() (string, error) {
+			tried = append(tried, "password")
+			return "", errors.New("probe only")
+		}),
+		ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+			tried = append(tried, "keyboard-interactive")
+			return nil, errors.New("probe only")
+		}),
+		ssh.GSSAPIWithMICAuthMethod(sshGSSAPIProbe{tried: &tried}, info.Host),
+	}
+	config := sshAuditConfig(user, auth, nil, func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		return nil
+	})
+	client, err := ssh.Dial("tcp", fmt.Sprintf("%v:%v", info.Host, info.Ports), config)
+	if err == nil {
+		// none 认证直接成功
+		client.Close()
+		return []string{"none"}
+	}
+	return removeDuplicateKeepOrder(tried)
+}
+
+// sshDictUsers 字典中的用户名，保持字典顺序
+func sshDictUsers() []string {
+	var users []string
+	for _, up := range sortUserPassword(&structs.HostInfo{}, sshUserPasswdDict, []string{"ssh"}) {
+		if up.UserName != "" && !strings.Contains(up.UserName, "{{") {
+			users = append(users, up.UserName)
+		}
+	}
+	return removeDuplicateKeepOrder(users)
+}
+
+func SSHAuditScan(info *structs.HostInfo) error {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	banner, algorithms, err := sshReadKexInit(info)
+	if err != nil {
+		gologger.AuditTimeLogger("[Go] [SSH-Audit] %s error: %v", realhost, err)
+		return err
+	}
+
+	audit := &SSHAuditInfo{Banner: banner, Algorithms: algorithms, AuthMethods: make(map[string][]string)}
+	for i := range sshAlgorithmNames {
+		audit.Negotiated = append(audit.Negotiated, sshNegotiate(sshClientPreference[i], algorithms[i]))
+		audit.Weak = append(audit.Weak, sshWeak(i, algorithms[i])...)
+	}
+	audit.HostKeys = sshHostKeys(info, algorithms[1])
+	users := sshDictUsers()
+	if len(users) > sshAuditUsers {
+		users = users[:sshAuditUsers]
+	}
+	for _, user := range users {
+		if methods := sshAuthMethods(info, user); len(methods) > 0 {
+			audit.AuthMethods[user] = methods
+		}
+	}
+
+	sshHostKeyLock.Lock()
+	for _, k := range audit.HostKeys {
+		sshHostKeyMap[k.Fingerprint] = append(sshHostKeyMap[k.Fingerprint], realhost)
+	}
+	sshHostKeyLock.Unlock()
+
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     "SSH-Audit",
+		Security:    "INFO",
+		Target:      realhost,
+		InfoLeft:    audit.String(),
+		Description: "SSH服务算法、主机公钥与认证方式",
+	}, fmt.Sprintf("SSH-Audit %s %s", realhost, banner))
+
+	if len(audit.Weak) > 0 {
+		GoPocOutput(structs.GoPocsResultType{
+			PocName:     "SSH-Weak-Algorithms",
+			Security:    "LOW",
+			Target:      realhost,
+			InfoLeft:    fmt.Sprintf("Host: %v\nBanner: %v\nWeak: %v\n", realhost, banner, strings.Join(audit.Weak, ",")),
+			Description: "SSH服务支持不安全的密钥交换、主机密钥、加密或MAC算法",
+		}, fmt.Sprintf("SSH-Weak-Algorithms %s [%s]", realhost, strings.Join(audit.Weak, ",")))
+	}
+	return nil
+}
+
+// sshHostKeyReuseReport 多个主机使用相同的主机公钥，一般为同一镜像/模板部署，私钥泄露影响所有主机
+func sshHostKeyReuseReport() {
+	sshHostKeyLock.Lock()
+	defer sshHostKeyLock.Unlock()
+	var fingerprints []string
+	for fp := range sshHostKeyMap {
+		fingerprints = append(fingerprints, fp)
+	}
+	sort.Strings(fingerprints)
+	for _, fp := range fingerprints {
+		targets := removeDuplicateString(sshHostKeyMap[fp])
+		if len(targets) < 2 {
+			continue
+		}
+		GoPocOutput(structs.GoPocsResultType{
+			PocName:     "SSH-HostKey-Reuse",
+			Security:    "MEDIUM",
+			Target:      targets[0],
+			InfoLeft:    fmt.Sprintf("Fingerprint: %v\nHosts:\n    %v\n", fp, strings.Join(targets, "\n    ")),
+			Description: "多个SSH服务使用相同的主机公钥，可能为同一模板部署，存在中间人攻击风险",
+		}, fmt.Sprintf("SSH-HostKey-Reuse %s [%s]", fp, strings.Join(targets, ",")))
+	}
+}
+
+type sshPrivateKey struct {
+	Path   string
+	Signer ssh.Signer
+}
+
+var sshKeys []sshPrivateKey
+var sshKeysOnce sync.Once
+
+func loadSSHKeys() {
+	dir := structs.GlobalConfig.SSHKeyDir
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		gologger.Error().Msgf("读取SSH私钥目录失败: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		b, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(b)
+		if err != nil {
+			var missing *ssh.PassphraseMissingError
+			if errors.As(err, &missing) {
+				gologger.Warning().Msgf("跳过带口令的SSH私钥: %s", path)
+			}
+			continue
+		}
+		sshKeys = append(sshKeys, sshPrivateKey{Path: path, Signer: signer})
+	}
+	gologger.Info().Msgf("SSH私钥: %d 个", len(sshKeys))
+}
+
+func SshKeyScan(info *structs.HostInfo) (tmperr error) {
+	if structs.GlobalConfig.NoServiceBruteForce || structs.GlobalConfig.SSHKeyDir == "" {
+		return
+	}
+	sshKeysOnce.Do(loadSSHKeys)
+	gologger.AuditTimeLogger("[Go] [SSH-Key] start try %s:%v", info.Host, info.Ports)
+	defer gologger.AuditTimeLogger("[Go] [SSH-Key] SshKeyScan return %s:%v", info.Host, info.Ports)
+
+	users := sshDictUsers()
+	for _, key := range sshKeys {
+		for _, user := range users {
+			gologger.AuditTimeLogger("[Go] [SSH-Key] start try %s:%v %v %v", info.Host, info.Ports, user, key.Path)
+			flag, err := SshKeyConn(info, user, key)
+			if flag && err == nil {
+				tmperr = nil
+				break
+			}
+			tmperr = err
+			if err != nil && !strings.Contains(err.Error(), "unable to authenticate") {
+				return err
+			}
+		}
+	}
+	return tmperr
+}
+
+func SshKeyConn(info *structs.HostInfo, user string, key sshPrivateKey) (bool, error) {
+	config := sshAuditConfig(user, []ssh.AuthMethod{ssh.PublicKeys(key.Signer)}, nil,
+		func(hostname string, remote net.Addr, k ssh.PublicKey) error {
+			return nil
+		})
+	realhost := fmt.Sprintf("%v:%v", info.Host, info.Ports)
+	client, err := ssh.Dial("tcp", realhost, config)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		return false, err
+	}
+	defer session.Close()
+
+	shellInfo := "bash$ whoami&id&ifconfig\n"
+	combo, sshErr := session.CombinedOutput("whoami&id&ifconfig")
+	if sshErr == nil {
+		shellInfo += string(combo)
+	}
+	showData := fmt.Sprintf("Host: %v\nUsername: %v\nPrivate Key: %v\nKey Fingerprint: %v\n",
+		realhost, user, key.Path, ssh.FingerprintSHA256(key.Signer.PublicKey()))
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     "SSH-Key-Login",
+		Security:    "CRITICAL",
+		Target:      realhost,
+		InfoLeft:    showData,
+		InfoRight:   shellInfo,
+		Description: "SSH私钥登录成功",
+	}, fmt.Sprintf("SSH-Key://%v %v %v", realhost, user, key.Path))
+	return true, nil
+}
@@ -0,0 +1,42 @@
+package gopocs
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// stubServer 启动本地测试服务，每个连接调用一次 serve，返回监听地址
+func stubServer(t *testing.T, serve func(conn net.Conn)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+				serve(conn)
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// stubPipe 与 stubServer 相同，不经过网络，返回客户端一侧的连接
+func stubPipe(t *testing.T, serve func(conn net.Conn)) net.Conn {
+	server, client := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	go func() {
+		defer server.Close()
+		_ = server.SetDeadline(time.Now().Add(5 * time.Second))
+		serve(server)
+	}()
+	return client
+}
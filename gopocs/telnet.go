@@ -40,6 +40,9 @@ func TelnetScan(info *structs.HostInfo) (tmperr error) {
 	serverType := GetTelnetServerType(info.Host, portInt)
 	gologger.AuditTimeLogger("[Go] [TelnetScan] start try %s:%v Type: %v", info.Host, info.Ports, serverType)
 	if serverType == telnetlib.UnauthorizedAccess {
+		if info.CredReuse {
+			return tmperr
+		}
 		result := fmt.Sprintf("Telnet://%v:%v Unauthorized", info.Host, info.Ports)
 		// gologger.Silent().Msg("[GoPoc] " + result)
 
@@ -78,7 +81,9 @@ func TelnetScan(info *structs.HostInfo) (tmperr error) {
 		return
 	}
 	var upList []string
-	if structs.GlobalConfig.Password != "" {
+	if info.CredReuse {
+		upList = info.UserPass
+	} else if structs.GlobalConfig.Password != "" {
 		upList = append(upList, structs.GlobalConfig.Password)
 	} else if structs.GlobalConfig.PasswordFile != "" {
 		b, err := os.ReadFile(structs.GlobalConfig.PasswordFile)
@@ -98,103 +103,96 @@ func TelnetScan(info *structs.HostInfo) (tmperr error) {
 		}
 	}
 
+	// 已确认的凭据优先尝试
+	if !info.CredReuse {
+		upList = append(reuseCandidates(info, time.Time{}), upList...)
+	}
 	upList = utils.RemoveDuplicateElement(upList)
 
 	// Telnet爆破
 	starttime := time.Now().Unix()
-	for _, userPasswd := range upList {
-		user, oriPass := splitUserPass(userPasswd)
-		var passList []string
-		if strings.Contains(oriPass, "{{key}}") {
-			for _, sKey := range info.InfoStr {
-				newPass := strings.Replace(oriPass, "{{key}}", sKey, -1)
-				passList = append(passList, newPass)
-			}
-		} else {
-			passList = append(passList, oriPass)
-		}
-
-		for _, pass := range passList {
-			gologger.AuditTimeLogger("[Go] [RDP-Brute] start try %s:%v %v %v", info.Host, info.Ports, user, pass)
-			err := TelnetCheck(info.Host, user, pass, portInt, serverType)
-			if err == nil {
-				if serverType == telnetlib.OnlyPassword {
-					result := fmt.Sprintf("Telnet://%v:%v %s", info.Host, info.Ports, pass)
-					// gologger.Silent().Msg("[GoPoc] " + result)
-
-					showData := fmt.Sprintf("Host: %v:%v\nPass: %v\n", info.Host, info.Ports, pass)
-
-					ddout.FormatOutput(ddout.OutputMessage{
-						Type:     "GoPoc",
-						IP:       "",
-						IPs:      nil,
-						Port:     "",
-						Protocol: "",
-						Web:      ddout.WebInfo{},
-						Finger:   nil,
-						Domain:   "",
-						GoPoc: ddout.GoPocsResultType{PocName: "Telnet-Login",
-							Security:    "CRITICAL",
-							Target:      info.Host + ":" + info.Ports,
-							InfoLeft:    showData,
-							Description: "Telnet未授权/弱口令",
-							ShowMsg:     result},
-						AdditionalMsg: "",
-					})
-
-					GoPocWriteResult(structs.GoPocsResultType{
-						PocName:     "Telnet-Login",
+	for _, userPass := range expandUserPass(info, upList, []string{"telnet"}) {
+		user, pass := userPass.UserName, userPass.Password
+		gologger.AuditTimeLogger("[Go] [RDP-Brute] start try %s:%v %v %v", info.Host, info.Ports, user, pass)
+		err := TelnetCheck(info.Host, user, pass, portInt, serverType)
+		if err == nil {
+			AddCredential("Telnet", info.Host, info.Ports, user, pass, info.CredReuse)
+			if serverType == telnetlib.OnlyPassword {
+				result := fmt.Sprintf("Telnet://%v:%v %s", info.Host, info.Ports, pass)
+				// gologger.Silent().Msg("[GoPoc] " + result)
+
+				showData := fmt.Sprintf("Host: %v:%v\nPass: %v\n", info.Host, info.Ports, pass)
+
+				ddout.FormatOutput(ddout.OutputMessage{
+					Type:     "GoPoc",
+					IP:       "",
+					IPs:      nil,
+					Port:     "",
+					Protocol: "",
+					Web:      ddout.WebInfo{},
+					Finger:   nil,
+					Domain:   "",
+					GoPoc: ddout.GoPocsResultType{PocName: "Telnet-Login",
 						Security:    "CRITICAL",
 						Target:      info.Host + ":" + info.Ports,
 						InfoLeft:    showData,
 						Description: "Telnet未授权/弱口令",
-					})
-
-					return err
-				} else if serverType == telnetlib.UsernameAndPassword {
-					result := fmt.Sprintf("Telnet://%v:%v %s %s", info.Host, info.Ports, user, pass)
-					gologger.Silent().Msg("[GoPoc] " + result)
-
-					showData := fmt.Sprintf("Host: %v:%v\nUser: %v\nPass: %v\n", info.Host, info.Ports, user, pass)
-
-					ddout.FormatOutput(ddout.OutputMessage{
-						Type:     "GoPoc",
-						IP:       "",
-						IPs:      nil,
-						Port:     "",
-						Protocol: "",
-						Web:      ddout.WebInfo{},
-						Finger:   nil,
-						Domain:   "",
-						GoPoc: ddout.GoPocsResultType{PocName: "Telnet-Login",
-							Security:    "CRITICAL",
-							Target:      info.Host + ":" + info.Ports,
-							InfoLeft:    showData,
-							Description: "Telnet未授权/弱口令",
-							ShowMsg:     result},
-						AdditionalMsg: "",
-					})
-
-					GoPocWriteResult(structs.GoPocsResultType{
-						PocName:     "Telnet-Login",
+						ShowMsg:     result},
+					AdditionalMsg: "",
+				})
+
+				GoPocWriteResult(structs.GoPocsResultType{
+					PocName:     "Telnet-Login",
+					Security:    "CRITICAL",
+					Target:      info.Host + ":" + info.Ports,
+					InfoLeft:    showData,
+					Description: "Telnet未授权/弱口令",
+				})
+
+				return err
+			} else if serverType == telnetlib.UsernameAndPassword {
+				result := fmt.Sprintf("Telnet://%v:%v %s %s", info.Host, info.Ports, user, pass)
+				gologger.Silent().Msg("[GoPoc] " + result)
+
+				showData := fmt.Sprintf("Host: %v:%v\nUser: %v\nPass: %v\n", info.Host, info.Ports, user, pass)
+
+				ddout.FormatOutput(ddout.OutputMessage{
+					Type:     "GoPoc",
+					IP:       "",
+					IPs:      nil,
+					Port:     "",
+					Protocol: "",
+					Web:      ddout.WebInfo{},
+					Finger:   nil,
+					Domain:   "",
+					GoPoc: ddout.GoPocsResultType{PocName: "Telnet-Login",
 						Security:    "CRITICAL",
 						Target:      info.Host + ":" + info.Ports,
 						InfoLeft:    showData,
 						Description: "Telnet未授权/弱口令",
-					})
+						ShowMsg:     result},
+					AdditionalMsg: "",
+				})
+
+				GoPocWriteResult(structs.GoPocsResultType{
+					PocName:     "Telnet-Login",
+					Security:    "CRITICAL",
+					Target:      info.Host + ":" + info.Ports,
+					InfoLeft:    showData,
+					Description: "Telnet未授权/弱口令",
+				})
 
-					return err
-				}
-
-			}
-			errStr := fmt.Sprintf("%v", err)
-			if err != nil && !strings.Contains(strings.ToLower(errStr), "login failed") {
 				return err
 			}
 
-			if time.Now().Unix()-starttime > (int64(len(strings.Split(telnetUserPasswdDict, "\n"))) * 6) {
-				return err
-			}
+		}
+		errStr := fmt.Sprintf("%v", err)
+		if err != nil && !strings.Contains(strings.ToLower(errStr), "login failed") {
+			return err
+		}
+
+		if time.Now().Unix()-starttime > (int64(len(strings.Split(telnetUserPasswdDict, "\n"))) * 6) {
+			return err
 		}
 
 	}
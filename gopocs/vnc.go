@@ -0,0 +1,229 @@
+package gopocs
+
+import (
+	"crypto/des"
+	"dddd/common"
+	"dddd/structs"
+	_ "embed"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/projectdiscovery/gologger"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+//go:embed dict/vnc.txt
+var vncPasswdDict string
+
+var vncAuthFailed = errors.New("vnc authentication failed")
+
+const (
+	vncSecurityNone = 1
+	vncSecurityVNC  = 2
+)
+
+func VncScan(info *structs.HostInfo) (tmperr error) {
+	starttime := time.Now().Unix()
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	gologger.AuditTimeLogger("[Go] [VNC-Brute] start try %s", realhost)
+	defer gologger.AuditTimeLogger("[Go] [VNC-Brute] VncScan return %s", realhost)
+
+	version, types, err := vncHandshake(realhost)
+	if err != nil {
+		return err
+	}
+
+	if vncHasType(types, vncSecurityNone) {
+		if info.CredReuse {
+			return nil
+		}
+		showData := fmt.Sprintf("Host: %v\nVersion: %v\nSecurityTypes: %v\n", realhost, version, types)
+		GoPocOutput(structs.GoPocsResultType{
+			PocName:     "VNC-Unauthorized",
+			Security:    "CRITICAL",
+			Target:      realhost,
+			InfoLeft:    showData,
+			Description: "VNC未授权访问",
+		}, fmt.Sprintf("VNC://%s Unauthorized", realhost))
+		return nil
+	}
+
+	if structs.GlobalConfig.NoServiceBruteForce || !vncHasType(types, vncSecurityVNC) {
+		return nil
+	}
+
+	passwdList := sortPassword(info, vncPasswdDict, []string{"vnc"})
+	for _, pass := range passwdList {
+		gologger.AuditTimeLogger("[Go] [VNC-Brute] start try %s %v", realhost, pass)
+		flag, err := VncConn(info, pass)
+		if flag == true && err == nil {
+			return err
+		} else {
+			tmperr = err
+			if CheckErrs(err) {
+				return err
+			}
+			if time.Now().Unix()-starttime > (int64(len(passwdList)) * 6) {
+				return err
+			}
+		}
+	}
+	return tmperr
+}
+
+// vncHandshake 完成RFB版本协商并返回服务端支持的安全类型
+func vncHandshake(realhost string) (version string, types []byte, err error) {
+	conn, err := vncConnect(realhost)
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	if err != nil {
+		return
+	}
+	return vncNegotiate(conn, realhost)
+}
+
+func vncConnect(realhost string) (net.Conn, error) {
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(6)*time.Second)
+	if err != nil {
+		return conn, err
+	}
+	err = conn.SetDeadline(time.Now().Add(time.Duration(6) * time.Second))
+	return conn, err
+}
+
+func vncNegotiate(conn net.Conn, realhost string) (version string, types []byte, err error) {
+	banner := make([]byte, 12)
+	if _, err = io.ReadFull(conn, banner); err != nil {
+		return
+	}
+	gologger.AuditTimeLogger("[Go] [VNC] Dumped TCP response for %s\n\n%s\n", realhost, hex.Dump(banner))
+	if !strings.HasPrefix(string(banner), "RFB ") {
+		err = errors.New("not rfb protocol")
+		return
+	}
+	version = strings.TrimSpace(string(banner))
+
+	// RFB 3.3 由服务端直接指定安全类型
+	if version == "RFB 003.003" {
+		if _, err = conn.Write(banner); err != nil {
+			return
+		}
+		secType := make([]byte, 4)
+		if _, err = io.ReadFull(conn, secType); err != nil {
+			return
+		}
+		types = []byte{byte(binary.BigEndian.Uint32(secType))}
+		return
+	}
+
+	clientVersion := []byte("RFB 003.008\n")
+	if version == "RFB 003.007" {
+		clientVersion = banner
+	}
+	if _, err = conn.Write(clientVersion); err != nil {
+		return
+	}
+	count := make([]byte, 1)
+	if _, err = io.ReadFull(conn, count); err != nil {
+		return
+	}
+	if count[0] == 0 {
+		err = errors.New("vnc server refused connection")
+		return
+	}
+	types = make([]byte, int(count[0]))
+	_, err = io.ReadFull(conn, types)
+	return
+}
+
+func vncHasType(types []byte, t byte) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// vncEncryptChallenge VNC认证使用的DES密钥为按位反转的8字节密码
+func vncEncryptChallenge(pass string, challenge []byte) []byte {
+	key := make([]byte, 8)
+	copy(key, pass)
+	for i := range key {
+		b := key[i]
+		var r byte
+		for j := 0; j < 8; j++ {
+			r = r<<1 | b&1
+			b >>= 1
+		}
+		key[i] = r
+	}
+	block, _ := des.NewCipher(key)
+	response := make([]byte, 16)
+	block.Encrypt(response[:8], challenge[:8])
+	block.Encrypt(response[8:], challenge[8:16])
+	return response
+}
+
+func VncConn(info *structs.HostInfo, pass string) (flag bool, err error) {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	conn, err := vncConnect(realhost)
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	if err != nil {
+		return false, err
+	}
+
+	version, types, err := vncNegotiate(conn, realhost)
+	if err != nil {
+		return false, err
+	}
+	if !vncHasType(types, vncSecurityVNC) {
+		return false, errors.New("vnc authentication not supported")
+	}
+	if version != "RFB 003.003" {
+		if _, err = conn.Write([]byte{vncSecurityVNC}); err != nil {
+			return false, err
+		}
+	}
+
+	challenge := make([]byte, 16)
+	if _, err = io.ReadFull(conn, challenge); err != nil {
+		return false, err
+	}
+	response := vncEncryptChallenge(pass, challenge)
+	if _, err = conn.Write(response); err != nil {
+		return false, err
+	}
+	gologger.AuditTimeLogger("[Go] [VNC-Brute] Dumped TCP request for %s\n\n%s\n", realhost, hex.Dump(response))
+
+	result := make([]byte, 4)
+	if _, err = io.ReadFull(conn, result); err != nil {
+		return false, err
+	}
+	if binary.BigEndian.Uint32(result) != 0 {
+		return false, vncAuthFailed
+	}
+
+	AddCredential("VNC", info.Host, info.Ports, "", pass, info.CredReuse)
+	showData := fmt.Sprintf("Host: %v\nVersion: %v\nPassword: %v\n", realhost, version, pass)
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     "VNC-Login",
+		Security:    "CRITICAL",
+		Target:      realhost,
+		InfoLeft:    showData,
+		Description: "VNC弱口令",
+	}, fmt.Sprintf("VNC://%s %s", realhost, pass))
+
+	return true, nil
+}
@@ -0,0 +1,105 @@
+package gopocs
+
+import (
+	"crypto/tls"
+	"dddd/structs"
+	"errors"
+	"fmt"
+	"github.com/Azure/go-ntlmssp"
+	"github.com/projectdiscovery/gologger"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var winrmAuthFailed = errors.New("winrm authentication failed")
+
+// Identify 为只读请求，仅返回WS-Management协议版本与厂商信息
+var winrmIdentifyBody = `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:wsmid="http://schemas.dmtf.org/wbem/wsman/identity/1/wsmanidentity.xsd"><s:Header/><s:Body><wsmid:Identify/></s:Body></s:Envelope>`
+
+func WinRMScan(info *structs.HostInfo) (tmperr error) {
+	if structs.GlobalConfig.NoServiceBruteForce {
+		return
+	}
+
+	starttime := time.Now().Unix()
+	gologger.AuditTimeLogger("[Go] [WinRM-Brute] start try %s:%v", info.Host, info.Ports)
+	defer gologger.AuditTimeLogger("[Go] [WinRM-Brute] WinRMScan return %s:%v", info.Host, info.Ports)
+
+	// WinRM与SMB同为Windows本地/域账户认证，复用SMB字典
+	userPasswdList := sortUserPassword(info, smbUserPasswdDict, []string{})
+
+	for _, userPass := range userPasswdList {
+		gologger.AuditTimeLogger("[Go] [WinRM-Brute] start try %s:%v %v %v", info.Host, info.Ports, userPass.UserName, userPass.Password)
+		flag, err := WinRMConn(info, userPass.UserName, userPass.Password)
+		if flag == true && err == nil {
+			return err
+		} else {
+			tmperr = err
+			if err != winrmAuthFailed {
+				return err
+			}
+			if time.Now().Unix()-starttime > (int64(len(userPasswdList)) * 6) {
+				return err
+			}
+		}
+	}
+	return tmperr
+}
+
+func winrmURL(info *structs.HostInfo) string {
+	scheme := "http"
+	if info.Ports == "5986" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%s/wsman", scheme, info.Host, info.Ports)
+}
+
+func WinRMConn(info *structs.HostInfo, user string, pass string) (flag bool, err error) {
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Transport: ntlmssp.Negotiator{RoundTripper: transport},
+		Timeout:   time.Duration(6) * time.Second,
+	}
+
+	u := winrmURL(info)
+	req, err := http.NewRequest("POST", u, strings.NewReader(winrmIdentifyBody))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	req.SetBasicAuth(user, pass)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	gologger.AuditTimeLogger("[Go] [WinRM-Brute] %s %s:%s status %v\n\n%s\n", u, user, pass, resp.StatusCode, string(body))
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return false, winrmAuthFailed
+	}
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "IdentifyResponse") {
+		return false, fmt.Errorf("winrm unexpected status %v", resp.StatusCode)
+	}
+
+	AddCredential("WinRM", info.Host, info.Ports, user, pass, info.CredReuse)
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	showData := fmt.Sprintf("Host: %v\nUsername: %v\nPassword: %v\n", realhost, user, pass)
+	GoPocOutput(structs.GoPocsResultType{
+		PocName:     "WinRM-Login",
+		Security:    "CRITICAL",
+		Target:      realhost,
+		InfoLeft:    showData,
+		InfoRight:   string(body),
+		Description: "WinRM弱口令",
+	}, fmt.Sprintf("WinRM://%v:%v:%v %v", info.Host, info.Ports, user, pass))
+
+	return true, nil
+}
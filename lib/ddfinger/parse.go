@@ -245,6 +245,24 @@ func dataCheckString(op int16, dataSource string, dataRule string) bool {
 	return false
 }
 
+// dataCheckStrings 多个值中任意一个满足规则，不包含(!=)时要求全部不包含
+func dataCheckStrings(op int16, dataSources []string, dataRule string) bool {
+	if op == 1 {
+		for _, dataSource := range dataSources {
+			if !dataCheckString(op, dataSource, dataRule) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, dataSource := range dataSources {
+		if dataCheckString(op, dataSource, dataRule) {
+			return true
+		}
+	}
+	return false
+}
+
 func dataCheckInt(op int16, dataSource int, dataRule int) bool {
 	if op == 0 { // 数字相等
 		if dataSource == dataRule {
@@ -272,6 +290,7 @@ func checkPath(Path string,
 	Protocol string, // 协议
 	Banner string, // 响应
 	Cert string, // TLS证书
+	Service structs.ServiceInfo, // 协议识别得到的版本信息
 ) []string {
 	var fingerPrintResults []string
 
@@ -389,6 +408,22 @@ func checkPath(Path string,
 						if singleRule.Value == "service" {
 							singleRuleResult = true
 						}
+					} else if singleRule.Key == "product" {
+						if dataCheckString(singleRule.Op, Service.Product, singleRule.Value) {
+							singleRuleResult = true
+						}
+					} else if singleRule.Key == "version" {
+						if dataCheckString(singleRule.Op, Service.Version, singleRule.Value) {
+							singleRuleResult = true
+						}
+					} else if singleRule.Key == "os" {
+						if dataCheckString(singleRule.Op, Service.OS, singleRule.Value) {
+							singleRuleResult = true
+						}
+					} else if singleRule.Key == "cpe" {
+						if dataCheckStrings(singleRule.Op, Service.CPE, singleRule.Value) {
+							singleRuleResult = true
+						}
 					}
 					if singleRuleResult {
 						expr = expr[:singleRule.Start] + "T" + expr[singleRule.End:]
@@ -419,112 +454,116 @@ func checkPath(Path string,
 	return utils.RemoveDuplicateElement(fingerPrintResults)
 }
 
-func FingerprintIdentification() {
-	gologger.Info().Msg("指纹识别中")
+// FingerprintService 识别非Web服务，结果写入 GlobalResultMap
+func FingerprintService(hostPort string, protocol string) {
+	if protocol == "http" || protocol == "https" || protocol == "" {
+		return
+	}
+	t := strings.Split(hostPort, ":")
+	if len(t) != 2 {
+		return
+	}
+	// host := t[0]
+	port, err := strconv.Atoi(t[1])
+	if err != nil {
+		return
+	}
+	banner := ""
+	bodyBytes, ok := structs.GlobalBannerHMap.Get(hostPort)
+	if !ok {
+		banner = ""
+	} else {
+		banner = string(bodyBytes)
+	}
+	structs.GlobalServiceCertMapLock.Lock()
+	cert := structs.GlobalServiceCertMap[hostPort]
+	structs.GlobalServiceCertMapLock.Unlock()
+	structs.GlobalServiceInfoMapLock.Lock()
+	service := structs.GlobalServiceInfoMap[hostPort]
+	structs.GlobalServiceInfoMapLock.Unlock()
+	results := checkPath("no#web", structs.UrlPathEntity{}, port, protocol, banner, cert, service)
+	if len(results) > 0 {
+		Url := fmt.Sprintf("%s://%s", protocol, hostPort)
+		structs.GlobalResultMap[Url] = results
+
+		//msg := "[Finger] " + Url + " ["
+		//for _, r := range results {
+		//	msg += aurora.Cyan(r).String() + ","
+		//}
+		//msg = msg[:len(msg)-1] + "]"
+		//gologger.Silent().Msg(msg)
+
+		ddout.FormatOutput(ddout.OutputMessage{
+			Type:          "Finger",
+			IP:            "",
+			IPs:           nil,
+			Port:          "",
+			Protocol:      "",
+			Web:           ddout.WebInfo{},
+			Finger:        results,
+			Domain:        "",
+			GoPoc:         ddout.GoPocsResultType{},
+			URI:           Url,
+			AdditionalMsg: "",
+		})
+
+	}
+}
+
+// FingerprintURL 识别Web路径，结果写入 GlobalResultMap
+func FingerprintURL(rootURL string, path string, urlEntity structs.URLEntity, pathEntity structs.UrlPathEntity) {
+	banner := ""
+	var service structs.ServiceInfo
+	if urlEntity.IP != "" {
+		hostPort := fmt.Sprintf("%s:%d", urlEntity.IP, urlEntity.Port)
 
-	// 先识别非Web
-	for hostPort, protocol := range structs.GlobalIPPortMap {
-		if protocol == "http" || protocol == "https" || protocol == "" {
-			continue
-		}
-		t := strings.Split(hostPort, ":")
-		if len(t) != 2 {
-			continue
-		}
-		// host := t[0]
-		port, err := strconv.Atoi(t[1])
-		if err != nil {
-			continue
-		}
-		banner := ""
 		bodyBytes, ok := structs.GlobalBannerHMap.Get(hostPort)
 		if !ok {
 			banner = ""
 		} else {
 			banner = string(bodyBytes)
 		}
-		results := checkPath("no#web", structs.UrlPathEntity{}, port, protocol, banner, "")
-		if len(results) > 0 {
-			Url := fmt.Sprintf("%s://%s", protocol, hostPort)
-			structs.GlobalResultMap[Url] = results
-
-			//msg := "[Finger] " + Url + " ["
-			//for _, r := range results {
-			//	msg += aurora.Cyan(r).String() + ","
-			//}
-			//msg = msg[:len(msg)-1] + "]"
-			//gologger.Silent().Msg(msg)
-
-			ddout.FormatOutput(ddout.OutputMessage{
-				Type:          "Finger",
-				IP:            "",
-				IPs:           nil,
-				Port:          "",
-				Protocol:      "",
-				Web:           ddout.WebInfo{},
-				Finger:        results,
-				Domain:        "",
-				GoPoc:         ddout.GoPocsResultType{},
-				URI:           Url,
-				AdditionalMsg: "",
-			})
-
-		}
-
+		structs.GlobalServiceInfoMapLock.Lock()
+		service = structs.GlobalServiceInfoMap[hostPort]
+		structs.GlobalServiceInfoMapLock.Unlock()
 	}
-	for rootURL, urlEntity := range structs.GlobalURLMap {
-		banner := ""
-		if urlEntity.IP != "" {
-			hostPort := fmt.Sprintf("%s:%d", urlEntity.IP, urlEntity.Port)
-
-			bodyBytes, ok := structs.GlobalBannerHMap.Get(hostPort)
-			if !ok {
-				banner = ""
-			} else {
-				banner = string(bodyBytes)
-			}
-		}
 
-		URL, _ := url.Parse(rootURL)
-
-		for path, pathEntity := range urlEntity.WebPaths {
-			results := checkPath(path, pathEntity, urlEntity.Port, URL.Scheme, banner, urlEntity.Cert)
-			fullURL := rootURL + path
-
-			if len(results) > 0 {
-				structs.GlobalResultMap[fullURL] = results
-				//msg := "[Finger] " + fullURL + " "
-				//msg += fmt.Sprintf("[%d] [", pathEntity.StatusCode)
-				//for _, r := range results {
-				//	msg += aurora.Cyan(r).String() + ","
-				//}
-				//msg = msg[:len(msg)-1] + "]"
-				//if pathEntity.Title != "" {
-				//	msg += fmt.Sprintf(" [%s]", pathEntity.Title)
-				//}
-				//gologger.Silent().Msg(msg)
-				ddout.FormatOutput(ddout.OutputMessage{
-					Type:     "Finger",
-					IP:       "",
-					IPs:      nil,
-					Port:     "",
-					Protocol: "",
-					Web: ddout.WebInfo{
-						Status: strconv.Itoa(pathEntity.StatusCode),
-						Title:  pathEntity.Title,
-					},
-					Finger:        results,
-					Domain:        "",
-					GoPoc:         ddout.GoPocsResultType{},
-					URI:           fullURL,
-					AdditionalMsg: "",
-				})
-			} else {
-				structs.GlobalResultMap[fullURL] = []string{}
-			}
-		}
+	URL, _ := url.Parse(rootURL)
+
+	results := checkPath(path, pathEntity, urlEntity.Port, URL.Scheme, banner, urlEntity.Cert, service)
+	fullURL := rootURL + path
+
+	if len(results) > 0 {
+		structs.GlobalResultMap[fullURL] = results
+		//msg := "[Finger] " + fullURL + " "
+		//msg += fmt.Sprintf("[%d] [", pathEntity.StatusCode)
+		//for _, r := range results {
+		//	msg += aurora.Cyan(r).String() + ","
+		//}
+		//msg = msg[:len(msg)-1] + "]"
+		//if pathEntity.Title != "" {
+		//	msg += fmt.Sprintf(" [%s]", pathEntity.Title)
+		//}
+		//gologger.Silent().Msg(msg)
+		ddout.FormatOutput(ddout.OutputMessage{
+			Type:     "Finger",
+			IP:       "",
+			IPs:      nil,
+			Port:     "",
+			Protocol: "",
+			Web: ddout.WebInfo{
+				Status: strconv.Itoa(pathEntity.StatusCode),
+				Title:  pathEntity.Title,
+			},
+			Finger:        results,
+			Domain:        "",
+			GoPoc:         ddout.GoPocsResultType{},
+			URI:           fullURL,
+			AdditionalMsg: "",
+		})
+	} else {
+		structs.GlobalResultMap[fullURL] = []string{}
 	}
-	gologger.AuditTimeLogger("指纹识别结束")
 }
 
 func SingleCheck(finger structs.FingerPEntity, Protocol string, headerString string, body string,
@@ -1,6 +1,7 @@
 package gonmap
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"regexp"
@@ -48,7 +49,8 @@ func initWithFilter(filter int) {
 	for i := 0; i <= 65535; i++ {
 		nmap.portProbeMap[i] = []string{}
 	}
-	nmap.loads(nmapServiceProbes + nmapCustomizeProbes)
+	nmap.loads("nmap-service-probes", nmapServiceProbes)
+	nmap.loads("nmap-customize-probes", nmapCustomizeProbes)
 	//修复fallback
 	nmap.fixFallback()
 	//新增自定义指纹信息
@@ -67,6 +69,7 @@ func initWithFilter(filter int) {
 }
 
 func statistical() {
+	MatchCount, UsedMatchCount = 0, 0
 	ProbesCount = len(nmap.probeSort)
 	for _, p := range nmap.probeNameMap {
 		MatchCount += len(p.matchGroup)
@@ -174,6 +177,119 @@ func SetLogger(v Logger) {
 	logger = v
 }
 
+// LoadProbes 加载 nmap-service-probes 格式的探针，source 为文件名，用于标记指纹来源。
+// 与已有探针同名时只合并 match/softmatch 与 ports/sslports，发送数据以已有探针为准，新增的指纹优先匹配
+func LoadProbes(source string, s string) error {
+	probes, err := parseProbes(source, s)
+	if err != nil {
+		return err
+	}
+	newProbes := make(map[string]bool)
+	loaded := make(map[string]bool)
+	for _, p := range probes {
+		if loaded[p.name] {
+			return fmt.Errorf("%s: 探针 %s 重复定义", source, p.name)
+		}
+		loaded[p.name] = true
+		if _, ok := nmap.probeNameMap[p.name]; !ok {
+			newProbes[p.name] = true
+		}
+	}
+	probeExist := func(name string) bool {
+		_, ok := nmap.probeNameMap[name]
+		return ok || newProbes[name]
+	}
+	for _, p := range probes {
+		if p.fallback == "" || !newProbes[p.name] {
+			continue
+		}
+		if probeExist("TCP_" + p.fallback) {
+			p.fallback = "TCP_" + p.fallback
+		} else if probeExist("UDP_" + p.fallback) {
+			p.fallback = "UDP_" + p.fallback
+		} else {
+			return fmt.Errorf("%s: 探针 %s 的 fallback 不存在: %s", source, p.name, p.fallback)
+		}
+	}
+
+	for _, p := range probes {
+		ports := append(append(PortList{}, p.ports...), p.sslports...)
+		old, ok := nmap.probeNameMap[p.name]
+		if ok {
+			old.matchGroup = append(p.matchGroup, old.matchGroup...)
+			old.ports = old.ports.append(p.ports...)
+			old.sslports = old.sslports.append(p.sslports...)
+			if old.rarity <= nmap.filter {
+				for _, port := range ports {
+					if !nmap.portProbeMap[port].exist(old.name) {
+						nmap.portProbeMap[port] = append(nmap.portProbeMap[port], old.name)
+					}
+				}
+			}
+		} else {
+			nmap.pushProbe(*p)
+		}
+		for _, port := range append(ports, 0) {
+			nmap.portProbeMap[port] = nmap.sortOfRarity(nmap.portProbeMap[port])
+		}
+	}
+	statistical()
+	return nil
+}
+
+// LoadMatches 加载指纹，每行格式为 "<探针名> match|softmatch <service> m|<pattern>|[opts] [<versioninfo>]"，
+// 如 TCP_NULL match myapp m|^MYAPP ([\d.]+)| p/MyApp/ v/$1/，新增的指纹优先匹配
+func LoadMatches(source string, s string) (err error) {
+	var where string
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: %v", where, r)
+		}
+	}()
+	var matches = make(map[string][]*match)
+	var order []string
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[:1] == "#" {
+			continue
+		}
+		where = fmt.Sprintf("%s:%d", source, i+1)
+		args := strings.SplitN(line, " ", 3)
+		if len(args) != 3 || (args[1] != "match" && args[1] != "softmatch") {
+			return fmt.Errorf("%s: 格式应为 <探针名> match|softmatch <指纹>", where)
+		}
+		if _, ok := nmap.probeNameMap[args[0]]; !ok {
+			return fmt.Errorf("%s: 探针不存在: %s", where, args[0])
+		}
+		m := parseMatch(args[2], args[1] == "softmatch")
+		m.source = where
+		if _, ok := matches[args[0]]; !ok {
+			order = append(order, args[0])
+		}
+		matches[args[0]] = append(matches[args[0]], m)
+	}
+	for _, name := range order {
+		p := nmap.probeNameMap[name]
+		p.matchGroup = append(matches[name], p.matchGroup...)
+	}
+	statistical()
+	return nil
+}
+
+// SetPortProbes 指定端口默认使用的探针及顺序，这些探针先于通用探针发送
+func SetPortProbes(port int, probes []string) error {
+	for _, name := range probes {
+		if _, ok := nmap.probeNameMap[name]; !ok {
+			return fmt.Errorf("探针不存在: %s", name)
+		}
+	}
+	nmap.portProbeMap[port] = append(ProbeList{}, probes...)
+	if !nmap.bypassAllProbePort.exist(port) {
+		nmap.bypassAllProbePort = append(nmap.bypassAllProbePort, port)
+	}
+	return nil
+}
+
 // 功能类
 func New() *Nmap {
 	n := *nmap
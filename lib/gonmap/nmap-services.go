@@ -6477,3 +6477,42 @@ var nmapServices = func() []string {
 	}
 	return r
 }()
+
+// nmapTopPorts nmap-services 中开放频率最高的100个TCP端口，按频率从高到低排列(nmap -F)
+var nmapTopPorts = []int{
+	80, 23, 443, 21, 22, 25, 3389, 110, 445, 139, 143, 53, 135, 3306, 8080, 1723, 111, 995, 993, 5900,
+	1025, 587, 8888, 199, 1720, 465, 548, 113, 81, 6001, 10000, 514, 5060, 179, 1026, 2000, 8443, 8000, 32768, 554,
+	26, 1433, 49152, 2001, 515, 8008, 49154, 1027, 5666, 646, 5000, 5631, 631, 49153, 8081, 2049, 88, 79, 5800, 106,
+	2121, 1110, 49155, 6000, 513, 990, 5357, 427, 49156, 543, 544, 5101, 144, 7, 389, 8009, 3128, 444, 9999, 5009,
+	7070, 5190, 3000, 5432, 1900, 3986, 13, 1029, 9, 5051, 6646, 49157, 1028, 873, 1755, 2717, 4899, 9100, 119, 37,
+}
+
+// TopPorts 按开放频率返回前n个端口，n超过已知频率的端口数量时返回全部
+func TopPorts(n int) []int {
+	if n > len(nmapTopPorts) {
+		n = len(nmapTopPorts)
+	}
+	return append([]int{}, nmapTopPorts[:n]...)
+}
+
+// ServicePorts 返回服务名对应的端口，服务名与识别结果一致(如 rdp、smb、mssql)
+func ServicePorts(service string) []int {
+	var ports []int
+	for port, protocol := range nmapServices {
+		if protocol == service {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// NamedPorts 返回 nmap-services 中有服务名的端口，按端口号排列
+func NamedPorts() []int {
+	var ports []int
+	for port, protocol := range nmapServices {
+		if port > 0 && protocol != "unknown" {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
@@ -3,6 +3,7 @@ package gonmap
 type FingerPrint struct {
 	ProbeName        string
 	MatchRegexString string
+	MatchSource      string
 
 	Service         string
 	ProductName     string
@@ -11,10 +12,12 @@ type FingerPrint struct {
 	Hostname        string
 	OperatingSystem string
 	DeviceType      string
+	CPE             []string
 	//  p/vendorproductname/
 	//	v/version/
 	//	i/info/
 	//	h/hostname/
 	//	o/operatingsystem/
 	//	d/devicetype/
+	//	cpe:/cpename/[a]
 }
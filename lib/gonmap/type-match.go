@@ -15,6 +15,8 @@ type match struct {
 	pattern       string
 	patternRegexp *regexp.Regexp
 	versionInfo   *FingerPrint
+	//指纹所在文件及行号
+	source string
 }
 
 var matchLoadRegexps = []*regexp.Regexp{
@@ -33,6 +35,8 @@ var matchVersionInfoRegexps = map[string]*regexp.Regexp{
 	"DEVICE":      regexp.MustCompile("d/([^/]+)/"),
 }
 
+var matchVersionInfoCPERegexp = regexp.MustCompile(`cpe:/([^/]+)/`)
+
 var matchVersionInfoHelperRegxP = regexp.MustCompile(`\$P\((\d)\)`)
 var matchVersionInfoHelperRegx = regexp.MustCompile(`\$(\d)`)
 
@@ -66,6 +70,7 @@ func parseMatch(s string, soft bool) *match {
 		Hostname:         m.getVersionInfo(s, "HOSTNAME"),
 		OperatingSystem:  m.getVersionInfo(s, "OS"),
 		DeviceType:       m.getVersionInfo(s, "DEVICE"),
+		CPE:              m.getCPE(args[4]),
 	}
 	return m
 }
@@ -99,6 +104,15 @@ func (m *match) getVersionInfo(s string, regID string) string {
 	}
 }
 
+// getCPE 只在版本信息部分中查找，避免匹配到正则中的内容
+func (m *match) getCPE(versionInfo string) []string {
+	var cpe []string
+	for _, r := range matchVersionInfoCPERegexp.FindAllStringSubmatch(versionInfo, -1) {
+		cpe = append(cpe, "cpe:/"+r[1])
+	}
+	return cpe
+}
+
 func (m *match) makeVersionInfo(s string, f *FingerPrint) {
 	f.Info = m.makeVersionInfoSubHelper(s, m.versionInfo.Info)
 	f.DeviceType = m.makeVersionInfoSubHelper(s, m.versionInfo.DeviceType)
@@ -107,6 +121,11 @@ func (m *match) makeVersionInfo(s string, f *FingerPrint) {
 	f.ProductName = m.makeVersionInfoSubHelper(s, m.versionInfo.ProductName)
 	f.Version = m.makeVersionInfoSubHelper(s, m.versionInfo.Version)
 	f.Service = m.makeVersionInfoSubHelper(s, m.versionInfo.Service)
+	f.CPE = nil
+	for _, cpe := range m.versionInfo.CPE {
+		// 版本号未匹配到时去掉末尾多余的冒号
+		f.CPE = append(f.CPE, strings.TrimRight(m.makeVersionInfoSubHelper(s, cpe), ":"))
+	}
 }
 
 func (m *match) makeVersionInfoSubHelper(s string, pattern string) string {
@@ -250,37 +250,62 @@ func (n *Nmap) OpenDeepIdentify() {
 func (n *Nmap) AddMatch(probeName string, expr string) {
 	var probe = n.probeNameMap[probeName]
 	probe.loadMatch(expr, false)
+	probe.matchGroup[len(probe.matchGroup)-1].source = "nmap-customize-match"
 }
 
 //初始化类
 
-func (n *Nmap) loads(s string) {
-	lines := strings.Split(s, "\n")
-	var probeGroups [][]string
-	var probeLines []string
-	for _, line := range lines {
-		if !n.isCommand(line) {
+func (n *Nmap) loads(source string, s string) {
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(line, "Exclude ") {
+			n.loadExclude(strings.TrimPrefix(line, "Exclude "))
+		}
+	}
+	probes, err := parseProbes(source, s)
+	if err != nil {
+		panic(err)
+	}
+	for _, p := range probes {
+		n.pushProbe(*p)
+	}
+}
+
+// parseProbes 解析 nmap-service-probes 格式的文本，指纹记录所在的文件及行号，
+// 语句有误时返回所在行号
+func parseProbes(source string, s string) (probes []*probe, err error) {
+	var where string
+	defer func() {
+		if r := recover(); r != nil {
+			probes = nil
+			err = fmt.Errorf("%s: %v", where, r)
+		}
+	}()
+	var p *probe
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		where = fmt.Sprintf("%s:%d", source, i+1)
+		if !isCommand(line) {
+			if trimmed := strings.TrimSpace(line); trimmed != "" && trimmed[:1] != "#" {
+				return nil, fmt.Errorf("%s: 无法识别的语句", where)
+			}
 			continue
 		}
 		commandName := line[:strings.Index(line, " ")]
 		if commandName == "Exclude" {
-			n.loadExclude(line)
 			continue
 		}
 		if commandName == "Probe" {
-			if len(probeLines) != 0 {
-				probeGroups = append(probeGroups, probeLines)
-				probeLines = []string{}
-			}
+			p = newProbe()
+			probes = append(probes, p)
+		} else if p == nil {
+			return nil, fmt.Errorf("%s: %s 语句之前缺少 Probe 语句", where, commandName)
+		}
+		p.loadLine(line)
+		if commandName == "match" || commandName == "softmatch" {
+			p.matchGroup[len(p.matchGroup)-1].source = where
 		}
-		probeLines = append(probeLines, line)
-	}
-	probeGroups = append(probeGroups, probeLines)
-
-	for _, lines := range probeGroups {
-		p := parseProbe(lines)
-		n.pushProbe(*p)
 	}
+	return probes, nil
 }
 
 func (n *Nmap) loadExclude(expr string) {
@@ -324,7 +349,7 @@ func (n *Nmap) fixFallback() {
 	}
 }
 
-func (n *Nmap) isCommand(line string) bool {
+func isCommand(line string) bool {
 	//删除注释行和空行
 	if len(line) < 2 {
 		return false
@@ -333,7 +358,11 @@ func (n *Nmap) isCommand(line string) bool {
 		return false
 	}
 	//删除异常命令
-	commandName := line[:strings.Index(line, " ")]
+	i := strings.Index(line, " ")
+	if i < 0 {
+		return false
+	}
+	commandName := line[:i]
 	commandArr := []string{
 		"Exclude", "Probe", "match", "softmatch", "ports", "sslports", "totalwaitms", "tcpwrappedms", "rarity", "fallback",
 	}
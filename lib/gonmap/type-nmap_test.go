@@ -0,0 +1,95 @@
+package gonmap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProbes(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		// 探针名称 => 指纹所在行
+		want    map[string][]string
+		ports   map[string][]int
+		wantErr string
+	}{
+		{"empty", "", map[string][]string{}, nil, ""},
+		{"comments", "# nmap-service-probes\n\n   \n# Probe TCP x q||\n", map[string][]string{}, nil, ""},
+		{"probe",
+			"Exclude T:9100-9107\r\nProbe TCP GetRequest q|GET / HTTP/1.0\\r\\n\\r\\n|\r\nrarity 1\r\nports 80,8000-8002\r\nmatch http m|^HTTP/1\\.[01] \\d\\d\\d| p/http/\r\nsoftmatch http m|^HTTP/|\r\n",
+			map[string][]string{"TCP_GetRequest": {"test:5", "test:6"}},
+			map[string][]int{"TCP_GetRequest": {80, 8000, 8001, 8002}}, ""},
+		{"two-probes",
+			"Probe TCP NULL q||\nmatch ssh m|^SSH-2\\.0-| p/OpenSSH/\n# comment\nProbe TCP Redis q|*1\\r\\n$4\\r\\nPING\\r\\n|\nports 6379\nmatch redis m|^\\+PONG|\n",
+			map[string][]string{"TCP_NULL": {"test:2"}, "TCP_Redis": {"test:6"}},
+			map[string][]int{"TCP_NULL": {}, "TCP_Redis": {6379}}, ""},
+		{"match-before-probe", "match http m|^HTTP|\n", nil, nil, "test:1: match 语句之前缺少 Probe 语句"},
+		{"unknown-statement", "Probe TCP NULL q||\nmatchx http m|^HTTP|\n", nil, nil, "test:2: 无法识别的语句"},
+		{"bad-probe", "Probe TCP NULL\n", nil, nil, "test:1: probe 语句格式不正确"},
+		{"bad-match", "Probe TCP NULL q||\nmatch http ^HTTP\n", nil, nil, "test:2: match 语句参数不正确"},
+		{"bad-ports", "Probe TCP NULL q||\nports 80-\n", nil, nil, "test:2: port expression string invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probes, err := parseProbes("test", tt.s)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseProbes() error = %v, want %q", err, tt.wantErr)
+				}
+				if probes != nil {
+					t.Errorf("parseProbes() = %v on error", probes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProbes() error = %v", err)
+			}
+			got := make(map[string][]string)
+			for _, p := range probes {
+				sources := []string{}
+				for _, m := range p.matchGroup {
+					sources = append(sources, m.source)
+				}
+				got[p.name] = sources
+				if want, ok := tt.ports[p.name]; ok && !reflect.DeepEqual([]int(p.ports), want) {
+					t.Errorf("%s ports = %v, want %v", p.name, p.ports, want)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProbes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 出错时不修改已加载的探针
+func TestLoadProbesError(t *testing.T) {
+	tests := []struct {
+		name    string
+		load    func(string, string) error
+		s       string
+		wantErr string
+	}{
+		{"duplicate", LoadProbes, "Probe TCP MyProbe q|x|\nProbe TCP MyProbe q|y|\n", "test: 探针 TCP_MyProbe 重复定义"},
+		{"fallback", LoadProbes, "Probe TCP MyProbe q|x|\nfallback NoSuchProbe\n", "test: 探针 TCP_MyProbe 的 fallback 不存在: NoSuchProbe"},
+		{"syntax", LoadProbes, "Probe TCP MyProbe q|x|\nmatch myapp\n", "test:2: match 语句参数不正确"},
+		{"match-format", LoadMatches, "TCP_NULL myapp m|^x|\n", "test:1: 格式应为"},
+		{"match-probe", LoadMatches, "# comment\nTCP_NoSuchProbe match myapp m|^x|\n", "test:2: 探针不存在: TCP_NoSuchProbe"},
+		{"match-syntax", LoadMatches, "TCP_NULL match myapp ^x\n", "test:1: match 语句参数不正确"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probes := len(nmap.probeNameMap)
+			matches := len(nmap.probeNameMap["TCP_NULL"].matchGroup)
+			err := tt.load("test", tt.s)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+			if len(nmap.probeNameMap) != probes || len(nmap.probeNameMap["TCP_NULL"].matchGroup) != matches {
+				t.Errorf("probes changed after error")
+			}
+		})
+	}
+}
@@ -65,6 +65,7 @@ func (p *probe) match(s string) *FingerPrint {
 		if m.patternRegexp.MatchString(s) {
 			//标记当前正则
 			f.MatchRegexString = m.patternRegexp.String()
+			f.MatchSource = m.source
 			if m.soft {
 				//如果为软捕获，这设置筛选器
 				f.Service = m.service
@@ -85,13 +86,10 @@ var probeExprRegx = regexp.MustCompile("^(UDP|TCP) ([a-zA-Z0-9-_./]+) (?:q\\|([^
 var probeIntRegx = regexp.MustCompile(`^(\d+)$`)
 var probeStrRegx = regexp.MustCompile(`^([a-zA-Z0-9-_./]+)$`)
 
-func parseProbe(lines []string) *probe {
+func newProbe() *probe {
 	var p = &probe{}
 	p.ports = emptyPortList
 	p.sslports = emptyPortList
-	for _, line := range lines {
-		p.loadLine(line)
-	}
 	return p
 }
 
@@ -40,8 +40,6 @@ func RemoveUsedUrl(urls []string) []string {
 var GlobalUsedUrl []string
 
 func CallHTTPx(urls []string, callBack func(resp runner.Result), proxy string, threads int, timeout int) {
-	gologger.Info().Msg("获取Web响应中")
-
 	nextUrls := RemoveDuplicateElement(urls)
 	gologger.AuditLogger("响应探测目标: %s", strings.Join(nextUrls, ","))
 
@@ -7,13 +7,11 @@ import (
 	"dddd/common/report"
 	"dddd/common/uncover"
 	"dddd/gopocs"
-	"dddd/lib/ddfinger"
 	"dddd/structs"
 	"dddd/utils"
 	"dddd/utils/cdn"
 	"github.com/logrusorgru/aurora"
 	"github.com/projectdiscovery/gologger"
-	"github.com/projectdiscovery/httpx"
 	"github.com/projectdiscovery/nuclei/v3/pkg/output"
 	"strings"
 )
@@ -21,6 +19,14 @@ import (
 func main() {
 	common.Flag()
 
+	// 被动发现，只监听不发包
+	if structs.GlobalConfig.PassiveListen > 0 {
+		common.PassiveListen()
+		if !structs.GlobalConfig.PassiveScan {
+			return
+		}
+	}
+
 	workflow()
 }
 func workflow() {
@@ -92,40 +98,14 @@ func workflow() {
 	// 端口扫描
 	if len(ips) > 0 {
 		if !structs.GlobalConfig.SkipHostDiscovery {
-			var ICMPAlive []string
-			// ICMP 探测存活
-			if !structs.GlobalConfig.NoICMPPing {
-				ICMPAlive = common.CheckLive(ips, false)
-			}
-
-			// TCP 探测存活
-			var TCPAlive []string
-			if structs.GlobalConfig.TCPPing {
-				// 获取没有存活的进行探测
-				var uncheck []string
-				for _, ip := range ips {
-					index := utils.GetItemInArray(ICMPAlive, ip)
-					if index == -1 {
-						uncheck = append(uncheck, ip)
-					}
-				}
-				gologger.Info().Msg("TCP存活探测")
-				common.PortScan = false
-				tcpAliveIPPort := common.PortScanTCP(uncheck, "80,443,3389,445,22",
-					structs.GlobalConfig.NoPortString,
-					structs.GlobalConfig.TCPPortScanTimeout)
-				for _, tIPPort := range tcpAliveIPPort {
-					t := strings.Split(tIPPort, ":")
-					TCPAlive = append(TCPAlive, t[0])
-				}
+			if structs.GlobalConfig.SubnetSample {
+				ips = common.SubnetSampleDiscovery(ips)
+			} else {
+				// 存活探测只用于输出与减少后续探测，所有目标仍然进行端口扫描
+				ips = append(ips, common.HostDiscovery(ips)...)
+				ips = utils.RemoveDuplicateElement(ips)
 			}
-
-			ips = append(ips, ICMPAlive...)
-			ips = append(ips, TCPAlive...)
-			ips = utils.RemoveDuplicateElement(ips)
 		}
-		var tmpIPPort []string
-
 		// 检测Masscan安装
 		if structs.GlobalConfig.PortScanType == "syn" {
 			if !common.CheckMasScan() {
@@ -133,55 +113,16 @@ func workflow() {
 				structs.GlobalConfig.PortScanType = "tcp"
 			}
 		}
-
-		if structs.GlobalConfig.PortScanType == "syn" {
-			// 全端口扫描
-			tmpIPPort = common.PortScanSYN(ips)
-		} else {
-			common.PortScan = true
-			tmpIPPort = common.PortScanTCP(ips, structs.GlobalConfig.Ports,
-				structs.GlobalConfig.NoPortString,
-				structs.GlobalConfig.TCPPortScanTimeout)
-		}
-
-		// 单个IP阈值过滤
-		tmpIPPort = common.RemoveFirewall(tmpIPPort)
-
-		for _, each := range tmpIPPort {
-			ipPort = append(ipPort, each)
-		}
-		ipPort = utils.RemoveDuplicateElement(ipPort)
 	}
 
-	getProtocalInput := ipPort
-	for _, each := range domainPort {
-		getProtocalInput = append(getProtocalInput, each)
-	}
-	if len(getProtocalInput) > 0 {
-		common.GetProtocol(getProtocalInput,
-			structs.GlobalConfig.GetBannerThreads,
-			structs.GlobalConfig.GetBannerTimeout)
-	}
-
-	// 获取http响应
-	for hostPort, service := range structs.GlobalIPPortMap {
-		if strings.Contains(service, "http") {
-			urls = append(urls, "http://"+hostPort)
-			urls = append(urls, "https://"+hostPort)
-		}
-	}
-	urls = utils.RemoveDuplicateElement(urls)
-
-	httpx.CallHTTPx(urls, http.UrlCallBack,
-		structs.GlobalConfig.HTTPProxy,
-		structs.GlobalConfig.WebThreads,
-		structs.GlobalConfig.WebTimeout)
+	// GoPoc在流水线中就会写入结果，提前生成报告头部
+	report.GenerateHTMLReportHeader()
 
-	// 非CDN域名 探测域名绑定资产
-	// 把只允许域名访问的资产扒拉出来
-	if !structs.GlobalConfig.NoHostBind {
-		common.HostBindCheck()
-	}
+	// 端口扫描、协议识别、Web探测、指纹识别、GoPoc派发流水线执行
+	goPoc := structs.GlobalConfig.PocNameForSearch == "" &&
+		!structs.GlobalConfig.NoPoc && !structs.GlobalConfig.NoGolangPoc
+	pipeline := newScanPipeline(structs.GlobalConfig.PocNameForSearch == "", goPoc)
+	pipeline.Run(ips, ipPort, domainPort, urls)
 
 	var aliveURLs []string
 	for rootURL, _ := range structs.GlobalURLMap {
@@ -193,9 +134,12 @@ func workflow() {
 		gologger.AuditTimeLogger("模糊搜索Poc: %v", structs.GlobalConfig.PocNameForSearch)
 		TargetAndPocsName := make(map[string][]string)
 		for _, url := range aliveURLs {
+			// Nuclei通过DNS解析主机名，无法解析的域名绑定资产跳过
+			if _, _, ok := uncover.HostBindURL(url); ok {
+				continue
+			}
 			TargetAndPocsName[url] = []string{}
 		}
-		report.GenerateHTMLReportHeader()
 
 		param := callnuclei.NucleiParams{
 			TargetAndPocsName: TargetAndPocsName,
@@ -211,44 +155,18 @@ func workflow() {
 			InteractshToken:   structs.GlobalConfig.InteractshToken,
 		}
 		callnuclei.CallNuclei(param)
+		report.AddServiceInventory()
 		utils.DeleteReportWithNoResult()
 		return
 	}
 
-	// 目录爆破
-	if !structs.GlobalConfig.NoDirSearch {
-		var checkURLs []string
-		for path, _ := range structs.DirDB {
-			for _, u := range aliveURLs {
-				Url := ""
-				if u[len(u)-1:] == "/" && path[0:1] == "/" {
-					Url = u[:len(u)-1] + path
-				} else {
-					Url = u + path
-				}
-				checkURLs = append(checkURLs, Url)
-			}
-		}
-		checkURLs = utils.RemoveDuplicateElement(checkURLs)
-		gologger.Info().Msg("开始主动指纹探测")
-		httpx.DirBrute(checkURLs,
-			http.DirBruteCallBack,
-			structs.GlobalConfig.HTTPProxy,
-			structs.GlobalConfig.WebThreads,
-			structs.GlobalConfig.WebTimeout)
-		gologger.AuditTimeLogger("主动指纹探测结束")
-	}
-
-	ddfinger.FingerprintIdentification()
-
 	if structs.GlobalConfig.NoPoc {
 		gologger.Info().Msg("跳过漏洞探测")
+		report.AddServiceInventory()
+		utils.DeleteReportWithNoResult()
 		return
 	}
 
-	// 生成报告头部
-	report.GenerateHTMLReportHeader()
-
 	// 调用Nuclei
 	var nucleiResults []output.ResultEvent
 	TargetAndPocsName, count := http.GetPocs(structs.WorkFlowDB)
@@ -271,12 +189,16 @@ func workflow() {
 
 	}
 
-	// GoPoc引擎
+	// GoPoc引擎，等待流水线中派发的任务结束并进行依赖全部资产的探测
 	if !structs.GlobalConfig.NoGolangPoc {
 		gopocs.GoPocsDispatcher(nucleiResults)
+		// NTLM信息收集获取到的主机名
+		pipeline.HostBindRecheck()
 	}
 
-	// 没有漏洞结果，删除生成的HTML
+	report.AddServiceInventory()
+
+	// 没有漏洞结果与服务版本清单，删除生成的HTML
 	utils.DeleteReportWithNoResult()
 
 }
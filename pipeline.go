@@ -0,0 +1,407 @@
+package main
+
+import (
+	"dddd/common"
+	"dddd/common/http"
+	"dddd/common/uncover"
+	"dddd/gopocs"
+	"dddd/lib/ddfinger"
+	"dddd/structs"
+	"dddd/utils"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/httpx"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 扫描流水线：端口扫描 -> 协议识别 -> Web探测与主动指纹 -> 指纹识别，协议识别出的服务同时派发GoPoc任务
+// 各阶段同时运行，之间使用有界队列，下游处理不过来时上游阻塞等待。
+// Nuclei引擎在同一进程内只能启动一次，Yaml Poc在流水线结束后统一进行。
+
+// 阶段之间的队列长度
+const pipelineQueueSize = 1000
+
+// Web探测攒批的最长等待时间，批大小为 -wt
+const pipelineFlushInterval = 3 * time.Second
+
+type fingerTask struct {
+	// 非Web服务，为空时识别 GlobalURLMap 中尚未识别的Web路径
+	hostPort string
+	protocol string
+}
+
+type scanPipeline struct {
+	openPorts   chan string
+	webTargets  chan string
+	fingerTasks chan fingerTask
+	// GoPoc任务，不进行GoPoc探测时为nil
+	services chan gopocs.ServiceTarget
+
+	// 是否进行主动指纹探测与指纹识别，-poc 模糊搜索时不需要
+	fingerprint bool
+
+	// 端口扫描结果的单个IP阈值过滤，仅在端口扫描结果接收协程中访问
+	portCount map[string]int
+	firewall  map[string]bool
+
+	// RDP安全层分析、TLS分析与工控协议识别
+	analyzeWG sync.WaitGroup
+	analyzeCh chan struct{}
+
+	// 已进行主动指纹探测的根URL，仅在Web探测阶段访问
+	bruted map[string]bool
+
+	// 已进行指纹识别的Web路径，仅在指纹识别阶段访问
+	fingered map[string]bool
+}
+
+func newScanPipeline(fingerprint bool, goPoc bool) *scanPipeline {
+	p := &scanPipeline{
+		openPorts:   make(chan string, pipelineQueueSize),
+		webTargets:  make(chan string, pipelineQueueSize),
+		fingerTasks: make(chan fingerTask, pipelineQueueSize),
+		fingerprint: fingerprint,
+		portCount:   make(map[string]int),
+		firewall:    make(map[string]bool),
+		analyzeCh:   make(chan struct{}, structs.GlobalConfig.GetBannerThreads),
+		bruted:      make(map[string]bool),
+		fingered:    make(map[string]bool),
+	}
+	if goPoc {
+		p.services = make(chan gopocs.ServiceTarget, pipelineQueueSize)
+	}
+	return p
+}
+
+// Run 运行到所有阶段结束，包括域名绑定资产发现。GoPoc任务派发完成即返回，由 gopocs.GoPocsDispatcher 等待结束
+func (p *scanPipeline) Run(ips []string, ipPort []string, domainPort []string, urls []string) {
+	pocDone := make(chan struct{})
+	go func() {
+		if p.services != nil {
+			gopocs.GoPocsStream(p.services)
+		}
+		close(pocDone)
+	}()
+
+	fingerDone := make(chan struct{})
+	go func() {
+		p.fingerprintStage()
+		close(fingerDone)
+	}()
+
+	webDone := make(chan struct{})
+	go func() {
+		p.webStage()
+		close(webDone)
+	}()
+
+	protocolDone := make(chan struct{})
+	go func() {
+		common.GetProtocolStream(p.openPorts,
+			structs.GlobalConfig.GetBannerThreads,
+			structs.GlobalConfig.GetBannerTimeout,
+			p.onService)
+		p.analyzeWG.Wait()
+		close(p.webTargets)
+		if p.services != nil {
+			close(p.services)
+		}
+		close(protocolDone)
+	}()
+
+	p.produce(ips, ipPort, domainPort, urls)
+	<-protocolDone
+	<-webDone
+
+	// 非CDN域名 探测域名绑定资产
+	// 把只允许域名访问的资产扒拉出来
+	if !structs.GlobalConfig.NoHostBind {
+		common.HostBindCheck()
+		if p.fingerprint {
+			p.dirBrute()
+		}
+	}
+	p.fingerTasks <- fingerTask{}
+	close(p.fingerTasks)
+	<-fingerDone
+	<-pocDone
+}
+
+// produce 输入的端口与端口扫描结果写入协议识别队列
+func (p *scanPipeline) produce(ips []string, ipPort []string, domainPort []string, urls []string) {
+	for _, u := range urls {
+		p.webTargets <- u
+	}
+
+	seen := make(map[string]bool)
+	forward := func(hostPort string) {
+		if !seen[hostPort] {
+			seen[hostPort] = true
+			p.openPorts <- hostPort
+		}
+	}
+	for _, each := range ipPort {
+		forward(each)
+	}
+	for _, each := range domainPort {
+		forward(each)
+	}
+
+	if len(ips) > 0 {
+		if structs.GlobalConfig.PortScanType == "syn" {
+			// 全端口扫描，结果一次性返回，直接按阈值过滤
+			for _, each := range common.RemoveFirewall(common.PortScanSYN(ips)) {
+				forward(each)
+			}
+		} else {
+			out := make(chan common.PortScanResult, pipelineQueueSize)
+			done := make(chan struct{})
+			go func() {
+				p.holdPorts(out, forward)
+				close(done)
+			}()
+			common.PortScan = true
+			common.PortScanTCPStream(ips, structs.GlobalConfig.Ports,
+				structs.GlobalConfig.NoPortString,
+				structs.GlobalConfig.TCPPortScanTimeout, out)
+			close(out)
+			<-done
+		}
+	}
+	close(p.openPorts)
+}
+
+// holdPorts IP的端口全部探测完成、确定没有超出阈值后才传递给协议识别，与 RemoveFirewall 的结果一致
+func (p *scanPipeline) holdPorts(out <-chan common.PortScanResult, forward func(string)) {
+	held := make(map[string][]string)
+	for r := range out {
+		if r.Port == "" {
+			for _, each := range held[r.IP] {
+				forward(each)
+			}
+			delete(held, r.IP)
+			continue
+		}
+		each := r.IP + ":" + r.Port
+		if utils.GetItemInArray(held[r.IP], each) >= 0 {
+			continue
+		}
+		if p.countPort(r.IP) {
+			held[r.IP] = append(held[r.IP], each)
+		} else {
+			delete(held, r.IP)
+		}
+	}
+	// 没有收到完成标记的IP在扫描结束时传递
+	for _, ports := range held {
+		for _, each := range ports {
+			forward(each)
+		}
+	}
+}
+
+// countPort 单个IP阈值过滤，达到阈值后该IP的端口不再向下游传递
+func (p *scanPipeline) countPort(ip string) bool {
+	if p.firewall[ip] {
+		return false
+	}
+	p.portCount[ip]++
+	if p.portCount[ip] >= structs.GlobalConfig.PortsThreshold {
+		p.firewall[ip] = true
+		gologger.Error().Msgf("%s 端口数量超出阈值,已丢弃", ip)
+		return false
+	}
+	return true
+}
+
+// onService 协议识别出服务后分发到Web探测、RDP与TLS分析、工控协议识别、指纹识别
+func (p *scanPipeline) onService(hostPort string, service string) {
+	if strings.Contains(service, "http") {
+		p.webTargets <- "http://" + hostPort
+		p.webTargets <- "https://" + hostPort
+	}
+	rdp := common.IsRDPTarget(hostPort, service)
+	tlsMode, tls := common.TLSMode(hostPort, service)
+	tls = tls && !structs.GlobalConfig.NoTLSCheck
+	icsName, ics := gopocs.ICSTarget(hostPort, service)
+	if !rdp && !tls && !ics {
+		p.dispatch(hostPort, service)
+		return
+	}
+	// 分析结果(Banner、证书)参与指纹识别
+	p.analyzeCh <- struct{}{}
+	p.analyzeWG.Add(1)
+	go func() {
+		defer func() {
+			<-p.analyzeCh
+			p.analyzeWG.Done()
+		}()
+		if rdp {
+			common.RDPSecurityCheckTarget(hostPort)
+		}
+		if tls {
+			common.TLSCheckTarget(hostPort, service, tlsMode)
+		}
+		if ics {
+			gopocs.ICSCheckTarget(hostPort, icsName)
+		}
+		p.dispatch(hostPort, service)
+	}()
+}
+
+// dispatch 服务进入指纹识别与GoPoc派发队列
+func (p *scanPipeline) dispatch(hostPort string, service string) {
+	p.fingerTasks <- fingerTask{hostPort: hostPort, protocol: service}
+	if p.services != nil {
+		p.services <- gopocs.ServiceTarget{HostPort: hostPort, Protocol: service}
+	}
+}
+
+// webStage URL攒批后调用httpx，每批结束后对新的根URL进行主动指纹探测
+func (p *scanPipeline) webStage() {
+	gologger.Info().Msg("获取Web响应中")
+	batchSize := structs.GlobalConfig.WebThreads
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	seen := make(map[string]bool)
+	var batch []string
+	ticker := time.NewTicker(pipelineFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case u, ok := <-p.webTargets:
+			if !ok {
+				p.probe(batch)
+				return
+			}
+			if seen[u] {
+				continue
+			}
+			seen[u] = true
+			batch = append(batch, u)
+			if len(batch) >= batchSize {
+				p.probe(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			p.probe(batch)
+			batch = nil
+		}
+	}
+}
+
+func (p *scanPipeline) probe(urls []string) {
+	// 上一批跟随跳转时已经探测过的URL
+	urls = httpx.RemoveUsedUrl(urls)
+	if len(urls) == 0 {
+		return
+	}
+	httpx.CallHTTPx(urls, http.UrlCallBack,
+		structs.GlobalConfig.HTTPProxy,
+		structs.GlobalConfig.WebThreads,
+		structs.GlobalConfig.WebTimeout)
+	if p.fingerprint {
+		p.dirBrute()
+		p.fingerTasks <- fingerTask{}
+	}
+}
+
+// dirBrute 目录爆破，只探测尚未探测过的根URL
+func (p *scanPipeline) dirBrute() {
+	if structs.GlobalConfig.NoDirSearch {
+		return
+	}
+	var rootURLs []string
+	structs.GlobalURLMapLock.Lock()
+	for rootURL := range structs.GlobalURLMap {
+		if !p.bruted[rootURL] {
+			p.bruted[rootURL] = true
+			rootURLs = append(rootURLs, rootURL)
+		}
+	}
+	structs.GlobalURLMapLock.Unlock()
+	if len(rootURLs) == 0 {
+		return
+	}
+	if len(p.bruted) == len(rootURLs) {
+		gologger.Info().Msg("开始主动指纹探测")
+	}
+
+	var checkURLs []string
+	for path := range structs.DirDB {
+		for _, u := range rootURLs {
+			checkURL := u + path
+			if u[len(u)-1:] == "/" && path[0:1] == "/" {
+				checkURL = u[:len(u)-1] + path
+			}
+			// 主机名无法解析时直接连接IP，使用主机名作为Host头
+			if ipURL, host, ok := uncover.HostBindURL(checkURL); ok {
+				checkURL = host + "," + ipURL
+			}
+			checkURLs = append(checkURLs, checkURL)
+		}
+	}
+	gologger.AuditTimeLogger("主动指纹探测: %s", strings.Join(rootURLs, ","))
+	httpx.DirBrute(checkURLs,
+		http.DirBruteCallBack,
+		structs.GlobalConfig.HTTPProxy,
+		structs.GlobalConfig.WebThreads,
+		structs.GlobalConfig.WebTimeout)
+	gologger.AuditTimeLogger("主动指纹探测结束")
+}
+
+// fingerprintStage 指纹识别，GlobalResultMap 只在此协程中写入
+func (p *scanPipeline) fingerprintStage() {
+	if p.fingerprint {
+		gologger.Info().Msg("指纹识别中")
+	}
+	for task := range p.fingerTasks {
+		if !p.fingerprint {
+			continue
+		}
+		if task.hostPort != "" {
+			ddfinger.FingerprintService(task.hostPort, task.protocol)
+			continue
+		}
+		p.fingerprintWeb()
+	}
+	if p.fingerprint {
+		gologger.AuditTimeLogger("指纹识别结束")
+	}
+}
+
+// fingerprintWeb 识别 GlobalURLMap 中尚未识别的Web路径
+func (p *scanPipeline) fingerprintWeb() {
+	type webPath struct {
+		rootURL    string
+		path       string
+		urlEntity  structs.URLEntity
+		pathEntity structs.UrlPathEntity
+	}
+	var paths []webPath
+	structs.GlobalURLMapLock.Lock()
+	for rootURL, urlEntity := range structs.GlobalURLMap {
+		for path, pathEntity := range urlEntity.WebPaths {
+			if !p.fingered[rootURL+path] {
+				p.fingered[rootURL+path] = true
+				paths = append(paths, webPath{rootURL, path, urlEntity, pathEntity})
+			}
+		}
+	}
+	structs.GlobalURLMapLock.Unlock()
+	for _, each := range paths {
+		ddfinger.FingerprintURL(each.rootURL, each.path, each.urlEntity, each.pathEntity)
+	}
+}
+
+// HostBindRecheck 流水线结束后获取到新的主机名(如NTLM信息收集)时，再次探测域名绑定资产并识别指纹。
+// Nuclei已经结束，新发现的资产不再进行Yaml Poc探测
+func (p *scanPipeline) HostBindRecheck() {
+	if structs.GlobalConfig.NoHostBind || common.HostBindCheck() == 0 || !p.fingerprint {
+		return
+	}
+	p.dirBrute()
+	p.fingerprintWeb()
+}
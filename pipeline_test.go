@@ -0,0 +1,86 @@
+package main
+
+import (
+	"dddd/common"
+	"dddd/structs"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestHoldPorts(t *testing.T) {
+	structs.GlobalConfig.PortsThreshold = 3
+	tests := []struct {
+		name string
+		// 只有IP表示该IP的端口已全部探测
+		results []string
+		// 扫描结束前已传递的端口
+		want []string
+		// 扫描结束后传递的端口
+		wantEnd []string
+	}{
+		{"finished",
+			[]string{"10.0.0.1:22", "10.0.0.1:80", "10.0.0.1"},
+			[]string{"10.0.0.1:22", "10.0.0.1:80"}, nil},
+		{"firewall",
+			[]string{"10.0.0.1:1", "10.0.0.1:2", "10.0.0.1:3", "10.0.0.1:4", "10.0.0.1"},
+			nil, nil},
+		{"duplicate",
+			[]string{"10.0.0.1:22", "10.0.0.1:22", "10.0.0.1:80", "10.0.0.1"},
+			[]string{"10.0.0.1:22", "10.0.0.1:80"}, nil},
+		{"mixed",
+			[]string{"10.0.0.1:22", "10.0.0.2:1", "10.0.0.2:2", "10.0.0.1", "10.0.0.2:3", "10.0.0.2"},
+			[]string{"10.0.0.1:22"}, nil},
+		// 达到阈值前的端口在IP探测完成前不会传递
+		{"pending",
+			[]string{"10.0.0.1:22", "10.0.0.1:80"},
+			nil, []string{"10.0.0.1:22", "10.0.0.1:80"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newScanPipeline(false, false)
+			out := make(chan common.PortScanResult)
+			forwarded := make(chan string, 100)
+			done := make(chan struct{})
+			go func() {
+				p.holdPorts(out, func(hostPort string) { forwarded <- hostPort })
+				close(done)
+			}()
+			for _, r := range tt.results {
+				ip, port, _ := strings.Cut(r, ":")
+				out <- common.PortScanResult{IP: ip, Port: port}
+			}
+			// 无缓冲队列，下一个结果被接收时之前的结果已经处理完
+			out <- common.PortScanResult{IP: "10.0.0.254", Port: "1"}
+			got := drain(forwarded)
+			close(out)
+			<-done
+			var gotEnd []string
+			for _, each := range drain(forwarded) {
+				if each != "10.0.0.254:1" {
+					gotEnd = append(gotEnd, each)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("forwarded %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(gotEnd, tt.wantEnd) {
+				t.Errorf("forwarded at end %v, want %v", gotEnd, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func drain(ch chan string) []string {
+	var r []string
+	for {
+		select {
+		case s := <-ch:
+			r = append(r, s)
+		default:
+			sort.Strings(r)
+			return r
+		}
+	}
+}
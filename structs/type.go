@@ -30,14 +30,19 @@ type Config struct {
 	PortScanType               string
 	GetBannerThreads           int
 	GetBannerTimeout           int
+	NoTLSCheck                 bool
+	TLSCheckDB                 bool
 	TCPPortScanThreads         int
 	SYNPortScanThreads         int
 	PortsThreshold             int
 	TCPPortScanTimeout         int
+	Timing                     string
 	MasscanPath                string
 	AllowLocalAreaDomain       bool
 	AllowCDNAssets             bool
 	NoHostBind                 bool
+	NoPTR                      bool
+	PTRResolvers               string
 	SubdomainWordListFile      string
 	HTTPProxy                  string
 	HTTPProxyTest              bool
@@ -78,6 +83,29 @@ type Config struct {
 	InteractshURL              string
 	InteractshToken            string
 	NoPortString               string
+	SMTPRelayLocalPart         string
+	DBPostAuth                 bool
+	CredReuseScope             string
+	CredOutputFile             string
+	MutateKeys                 string
+	MutateRules                string
+	MutateRuleFile             string
+	MutateMax                  int
+	Spray                      bool
+	SprayAttempts              int
+	SprayWindow                int
+	SprayDelay                 int
+	SprayDomain                bool
+	SSHKeyDir                  string
+	ShiroGadget                bool
+	ICSScan                    bool
+	ICSRate                    int
+	NoARPPing                  bool
+	PassiveListen              int
+	PassiveScan                bool
+	AliveProfile               string
+	TCPPingPorts               string
+	SubnetSample               bool
 }
 
 type CDNResult struct {
@@ -113,6 +141,116 @@ type ProtocolResult struct {
 var GlobalIPDomainMap map[string][]string
 var GlobalIPDomainMapLock sync.Mutex
 
+// GlobalHostBindMap 主机名无法解析的域名绑定资产，主机名RootURL->IP RootURL，后续探测直接连接IP
+var GlobalHostBindMap map[string]string
+var GlobalHostBindMapLock sync.Mutex
+
+// GlobalTargetKeywordMap 存储ip->关键字(备案单位、NetBIOS主机名/域名等)，用于生成爆破字典
+var GlobalTargetKeywordMap map[string][]string
+var GlobalTargetKeywordMapLock sync.Mutex
+
+// NTLMInfo 匿名NTLM协商时服务端Challenge中泄露的主机信息
+type NTLMInfo struct {
+	NetBIOSComputer string
+	NetBIOSDomain   string
+	DNSComputer     string
+	DNSDomain       string
+	DNSTree         string
+	OSVersion       string
+	SystemTime      string
+	Source          string // 获取到信息的服务，如 SMB://1.1.1.1:445
+}
+
+// GlobalNTLMInfoMap 存储ip->NTLM泄露的主机信息
+var GlobalNTLMInfoMap map[string]NTLMInfo
+var GlobalNTLMInfoMapLock sync.Mutex
+
+// RDPSecurityInfo RDP安全层协商结果
+type RDPSecurityInfo struct {
+	Protocols   []string // 服务端接受的安全协议 RDP/SSL/HYBRID/HYBRID_EX
+	NLARequired bool     // 强制CredSSP(NLA)
+	LegacyRDP   bool     // 允许标准RDP安全层(无TLS)
+	CertCN      string
+	CertSAN     []string
+	CertIssuer  string
+	CertExpire  string
+}
+
+// GlobalRDPInfoMap IP:Port : RDP安全层信息
+var GlobalRDPInfoMap map[string]RDPSecurityInfo
+var GlobalRDPInfoMapLock sync.Mutex
+
+// AliveProfile 主机发现探针组合
+type AliveProfile struct {
+	ARP  bool   `yaml:"arp"`
+	ICMP bool   `yaml:"icmp"`
+	TCP  string `yaml:"tcp"` // TCP探活端口，为空不进行TCP探活
+}
+
+var AliveProfileDB map[string]AliveProfile
+
+// GlobalAliveProfile 本次运行使用的主机发现探针
+var GlobalAliveProfile AliveProfile
+
+// HostMACInfo 二层(ARP/NDP)发现的主机信息
+type HostMACInfo struct {
+	MAC    string
+	Method string   // ARP/NDP
+	IPv6   []string // 同一MAC通过NDP发现的IPv6地址
+}
+
+// GlobalHostMACMap ip->MAC地址
+var GlobalHostMACMap map[string]HostMACInfo
+var GlobalHostMACMapLock sync.Mutex
+
+// ServiceInfo 协议识别得到的服务版本信息，来自nmap指纹的 p/ v/ i/ o/ d/ cpe:
+type ServiceInfo struct {
+	Product string   `json:"product,omitempty"`
+	Version string   `json:"version,omitempty"`
+	Info    string   `json:"info,omitempty"`
+	OS      string   `json:"os,omitempty"`
+	Device  string   `json:"device,omitempty"`
+	CPE     []string `json:"cpe,omitempty"`
+}
+
+// GlobalServiceInfoMap IP:Port : 服务版本信息，供 product= version= os= cpe= 指纹规则匹配
+var GlobalServiceInfoMap map[string]ServiceInfo
+var GlobalServiceInfoMapLock sync.Mutex
+
+// TLSCertInfo 证书链中的单个证书
+type TLSCertInfo struct {
+	Subject            string   `json:"subject"`
+	Issuer             string   `json:"issuer"`
+	SAN                []string `json:"san,omitempty"`
+	NotBefore          string   `json:"not_before"`
+	NotAfter           string   `json:"not_after"`
+	KeyType            string   `json:"key_type"`
+	KeyBits            int      `json:"key_bits"`
+	SignatureAlgorithm string   `json:"signature_algorithm"`
+	SHA256             string   `json:"sha256"`
+}
+
+// TLSInfo TLS服务的证书链、协议版本与JARM
+type TLSInfo struct {
+	StartTLS  string        // 通过STARTTLS升级时为对应协议，直接TLS为空
+	Versions  []string      // 支持的协议版本
+	JARM      string        // JARM指纹
+	JARMMatch string        // JARM与已知C2/框架默认值相同，仅作参考，不计入Issues
+	Chain     []TLSCertInfo // 服务端发送的证书链，第一个为服务端证书
+	Issues    []string      // expired,not-yet-valid,self-signed,weak-key,weak-signature,weak-protocol
+}
+
+// GlobalTLSInfoMap IP:Port : TLS信息
+var GlobalTLSInfoMap map[string]TLSInfo
+var GlobalTLSInfoMapLock sync.Mutex
+
+// JARMDB JARM : 已知C2/框架名称
+var JARMDB map[string]string
+
+// GlobalServiceCertMap IP:Port : 非Web服务的TLS证书，格式与 URLEntity.Cert 一致，供 cert= 指纹规则匹配
+var GlobalServiceCertMap map[string]string
+var GlobalServiceCertMapLock sync.Mutex
+
 type UrlPathEntity struct {
 	// Path             string // 根目录为/
 	Hash             string // md5
@@ -198,6 +336,8 @@ type HostInfo struct {
 	Url      string
 	InfoStr  []string
 	UserPass []string
+	// CredReuse 凭据复用轮次，只尝试 UserPass 中的凭据
+	CredReuse bool
 }
 
 var AddScanNum int
@@ -231,7 +231,14 @@ func GetItemInArray(a []string, s string) int {
 	return -1
 }
 
+// DeleteReportWithNoResult 没有漏洞结果时删除生成的HTML，服务版本清单不参与大小判断，有清单时保留报告
 func DeleteReportWithNoResult() {
+	structs.GlobalServiceInfoMapLock.Lock()
+	inventory := len(structs.GlobalServiceInfoMap) > 0
+	structs.GlobalServiceInfoMapLock.Unlock()
+	if inventory {
+		return
+	}
 	fileInfo, err := os.Stat(structs.GlobalConfig.ReportName)
 	if err == nil {
 		fileSize := fileInfo.Size()
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"dddd/structs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeleteReportWithNoResult(t *testing.T) {
+	defer func() { structs.GlobalServiceInfoMap = nil }()
+	tests := []struct {
+		name     string
+		size     int
+		services map[string]structs.ServiceInfo
+		deleted  bool
+	}{
+		{"header-only", 1000, nil, true},
+		{"results", 100000, nil, false},
+		// 服务版本清单不参与大小判断
+		{"inventory", 1000, map[string]structs.ServiceInfo{"10.0.0.1:22": {Product: "OpenSSH"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			structs.GlobalConfig.ReportName = filepath.Join(t.TempDir(), "report.html")
+			if err := os.WriteFile(structs.GlobalConfig.ReportName, []byte(strings.Repeat("a", tt.size)), 0666); err != nil {
+				t.Fatal(err)
+			}
+			structs.GlobalServiceInfoMap = tt.services
+			DeleteReportWithNoResult()
+			_, err := os.Stat(structs.GlobalConfig.ReportName)
+			if deleted := os.IsNotExist(err); deleted != tt.deleted {
+				t.Errorf("deleted = %v, want %v", deleted, tt.deleted)
+			}
+		})
+	}
+}